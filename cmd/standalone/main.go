@@ -28,6 +28,46 @@ var cmdServer = cli.Command{
 			Usage: "Port on which to expose the stacks API (default: 2375)",
 			Value: 2375,
 		},
+		cli.BoolFlag{
+			Name:  "kubernetes",
+			Usage: "Serve Kubernetes stacks along with Swarm stacks, using the in-cluster Kubernetes configuration",
+		},
+		cli.StringFlag{
+			Name:  "quotas",
+			Usage: "Path to a YAML file defining the quotas of collections",
+		},
+		cli.StringFlag{
+			Name:  "admission-policy",
+			Usage: "Path to a YAML policy file configuring the admission controllers run on stacks before they are stored",
+		},
+		cli.StringFlag{
+			Name:  "tlscert",
+			Usage: "Path to the TLS certificate the stacks API is served with",
+		},
+		cli.StringFlag{
+			Name:  "tlskey",
+			Usage: "Path to the TLS key the stacks API is served with",
+		},
+		cli.StringFlag{
+			Name:  "tlscacert",
+			Usage: "Path to the CA certificates client certificates are verified against",
+		},
+		cli.StringFlag{
+			Name:  "tokens",
+			Usage: "Path to a YAML file mapping client identities to their bearer token, requires --tlscert and --tlskey",
+		},
+		cli.StringFlag{
+			Name:  "authorization-policy",
+			Usage: "Path to a YAML policy file defining the operations clients may perform on the stacks of each collection",
+		},
+		cli.StringFlag{
+			Name:  "audit-log",
+			Usage: "Path to the file the mutations of stacks are recorded in, as JSON lines",
+		},
+		cli.StringFlag{
+			Name:  "containers-state",
+			Usage: "Path to the file the stacks of the none orchestrator are kept in, so that they survive restarts",
+		},
 	},
 }
 
@@ -35,9 +75,19 @@ var cmdServer = cli.Command{
 // method from the standalone package.
 func RunStandaloneServer(c *cli.Context) error {
 	return standalone.Server(standalone.ServerOptions{
-		Debug:            c.Bool("debug"),
-		DockerSocketPath: c.String("docker-socket"),
-		ServerPort:       c.Int("port"),
+		Debug:                   c.Bool("debug"),
+		DockerSocketPath:        c.String("docker-socket"),
+		ServerPort:              c.Int("port"),
+		Kubernetes:              c.Bool("kubernetes"),
+		AdmissionPolicyFile:     c.String("admission-policy"),
+		QuotasFile:              c.String("quotas"),
+		TLSCertFile:             c.String("tlscert"),
+		TLSKeyFile:              c.String("tlskey"),
+		TLSCACertFile:           c.String("tlscacert"),
+		TokensFile:              c.String("tokens"),
+		AuthorizationPolicyFile: c.String("authorization-policy"),
+		AuditLogFile:            c.String("audit-log"),
+		ContainersStateFile:     c.String("containers-state"),
 	})
 }
 
@@ -0,0 +1,54 @@
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/stacks/pkg/client"
+	"github.com/docker/stacks/pkg/types"
+
+	"github.com/sirupsen/logrus"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+const waitComposeFile = `version: "3.7"
+services:
+  top:
+    image: busybox:latest
+    command: top
+    deploy:
+      replicas: 2
+`
+
+func TestCreateAndWait(t *testing.T) {
+	ctx := context.Background()
+	settings, err := client.SettingsFromEnv()
+	assert.NilError(t, err)
+	cli, err := client.NewClientWithSettings(*settings)
+	assert.NilError(t, err)
+
+	stack, err := cli.ParseComposeInput(ctx, types.ComposeInput{
+		ComposeFiles: []string{waitComposeFile},
+	})
+	assert.NilError(t, err)
+	stack.Spec.Metadata.Name = "e2e-wait"
+	stack.Orchestrator = types.OrchestratorSwarm
+
+	logrus.Info("Creating stack and waiting for it to converge.")
+	result, err := cli.StackCreateAndWait(ctx, *stack, types.StackCreateOptions{},
+		types.StackWaitOptions{Timeout: 2 * time.Minute},
+		func(progress types.StackProgress) {
+			logrus.Infof("stack progress: %+v", progress.ServicesStatus)
+		})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(result.Status, types.StackDeployConverged))
+
+	created, err := cli.StackInspect(ctx, result.StackID)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(created.Spec.Metadata.Name, "e2e-wait"))
+	assert.Check(t, is.Len(created.Spec.Services, 1))
+
+	assert.NilError(t, cli.StackDelete(ctx, result.StackID))
+}
@@ -0,0 +1,137 @@
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Operation is the operation on a stack submitted to admission.
+type Operation string
+
+const (
+	// OperationCreate is the creation of a new stack.
+	OperationCreate Operation = "create"
+
+	// OperationUpdate is the update of the spec of an existing stack.
+	OperationUpdate Operation = "update"
+)
+
+// Request is a stack about to be stored, submitted to the admission
+// controllers.
+type Request struct {
+	Operation Operation
+
+	// StackID is empty for OperationCreate.
+	StackID      string
+	Orchestrator types.OrchestratorChoice
+
+	// Spec is the spec of the stack, as it will be stored.
+	Spec *types.StackSpec
+
+	// SwarmSpec is the spec the stack was converted to, as it will be
+	// stored. It is nil for the stacks of orchestrators other than swarm.
+	SwarmSpec *interfaces.SwarmStackSpec
+}
+
+// Controller is an admission controller. Controllers implement Mutator,
+// Validator, or both.
+type Controller interface {
+	// Name identifies the controller in violations and errors.
+	Name() string
+}
+
+// Mutator is an admission controller modifying stacks before they are
+// validated and stored. Mutators modifying services are responsible for
+// modifying both the Spec and the SwarmSpec of the request, if any.
+type Mutator interface {
+	Controller
+	Mutate(req *Request) error
+}
+
+// Validator is an admission controller checking stacks against a policy.
+type Validator interface {
+	Controller
+	Validate(req Request) []types.AdmissionViolation
+}
+
+// Chain runs admission controllers on stacks: all of the mutators first, in
+// the order they were registered, then all of the validators.
+type Chain struct {
+	mutators   []Mutator
+	validators []Validator
+}
+
+// NewChain creates a Chain running the provided controllers.
+func NewChain(controllers ...Controller) *Chain {
+	c := &Chain{}
+	for _, controller := range controllers {
+		c.Register(controller)
+	}
+	return c
+}
+
+// Register adds a controller at the end of the chain.
+func (c *Chain) Register(controller Controller) {
+	if mutator, ok := controller.(Mutator); ok {
+		c.mutators = append(c.mutators, mutator)
+	}
+	if validator, ok := controller.(Validator); ok {
+		c.validators = append(c.validators, validator)
+	}
+}
+
+// Admit runs the chain on a request. The violations of all of the
+// validators are reported together in an *Error.
+func (c *Chain) Admit(req *Request) error {
+	for _, mutator := range c.mutators {
+		if err := mutator.Mutate(req); err != nil {
+			return errors.Wrapf(err, "admission controller %s failed", mutator.Name())
+		}
+	}
+
+	violations := []types.AdmissionViolation{}
+	for _, validator := range c.validators {
+		for _, violation := range validator.Validate(*req) {
+			violation.Controller = validator.Name()
+			violations = append(violations, violation)
+		}
+	}
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+// Error is returned for stacks rejected by admission controllers. It is an
+// errdefs.InvalidParameter error.
+type Error struct {
+	Violations []types.AdmissionViolation
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		message := violation.Message
+		if violation.Service != "" {
+			message = fmt.Sprintf("service %s: %s", violation.Service, message)
+		}
+		messages = append(messages, fmt.Sprintf("%s (%s)", message, violation.Controller))
+	}
+	return "stack rejected by admission policy: " + strings.Join(messages, "; ")
+}
+
+// InvalidParameter makes Error an errdefs.ErrInvalidParameter.
+func (e *Error) InvalidParameter() {}
+
+// Response returns the body of the API response to a rejected request.
+func (e *Error) Response() types.AdmissionErrorResponse {
+	return types.AdmissionErrorResponse{
+		Message:    e.Error(),
+		Violations: e.Violations,
+	}
+}
@@ -0,0 +1,218 @@
+package admission
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/substitution"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Policy configures the built-in admission controllers. It is loaded from a
+// YAML policy file such as:
+//
+//	disallow_latest_tag: true
+//	require_resource_limits: true
+//	allowed_registries: [docker.io, registry.example.com]
+//	disallow_host_bind_mounts: true
+//	default_labels:
+//	  com.example.team: platform
+type Policy struct {
+	// DisallowLatestTag rejects images without a tag or digest, or tagged
+	// latest.
+	DisallowLatestTag bool `yaml:"disallow_latest_tag"`
+
+	// RequireResourceLimits rejects services without both CPU and memory
+	// limits.
+	RequireResourceLimits bool `yaml:"require_resource_limits"`
+
+	// AllowedRegistries, if not empty, rejects images from other
+	// registries. Images without a registry are from docker.io.
+	AllowedRegistries []string `yaml:"allowed_registries"`
+
+	// DisallowHostBindMounts rejects services bind mounting host paths.
+	DisallowHostBindMounts bool `yaml:"disallow_host_bind_mounts"`
+
+	// DefaultLabels are added to the services which do not already have
+	// them.
+	DefaultLabels map[string]string `yaml:"default_labels"`
+}
+
+// LoadPolicy reads a policy file. Unknown rules are rejected, so that typos
+// do not silently disable a rule.
+func LoadPolicy(path string) (Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Policy{}, errors.Wrap(err, "unable to read admission policy")
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return Policy{}, errors.Wrapf(err, "invalid admission policy %s", path)
+	}
+	return policy, nil
+}
+
+// Controllers returns the admission controllers enforcing the policy.
+func (p Policy) Controllers() []Controller {
+	controllers := []Controller{}
+	if len(p.DefaultLabels) > 0 {
+		controllers = append(controllers, defaultLabels(p.DefaultLabels))
+	}
+	if p.DisallowLatestTag {
+		controllers = append(controllers, latestTagRule{})
+	}
+	if p.RequireResourceLimits {
+		controllers = append(controllers, resourceLimitsRule{})
+	}
+	if len(p.AllowedRegistries) > 0 {
+		controllers = append(controllers, registriesRule(p.AllowedRegistries))
+	}
+	if p.DisallowHostBindMounts {
+		controllers = append(controllers, bindMountsRule{})
+	}
+	return controllers
+}
+
+// services returns the services of the stack of a request, with their
+// properties substituted, so that rules see the images and options which
+// will actually be deployed.
+func services(req Request) composetypes.Services {
+	substituted, err := substitution.DoSubstitution(*req.Spec)
+	if err != nil {
+		// The stack will fail conversion anyway.
+		return req.Spec.Services
+	}
+	return substituted.Services
+}
+
+// validateServices reports a violation for each service for which check
+// returns a message.
+func validateServices(req Request, check func(composetypes.ServiceConfig) string) []types.AdmissionViolation {
+	violations := []types.AdmissionViolation{}
+	for _, service := range services(req) {
+		if message := check(service); message != "" {
+			violations = append(violations, types.AdmissionViolation{
+				Service: service.Name,
+				Message: message,
+			})
+		}
+	}
+	return violations
+}
+
+type latestTagRule struct{}
+
+func (latestTagRule) Name() string {
+	return "latest-tag"
+}
+
+func (latestTagRule) Validate(req Request) []types.AdmissionViolation {
+	return validateServices(req, func(service composetypes.ServiceConfig) string {
+		named, err := reference.ParseNormalizedNamed(service.Image)
+		if err != nil {
+			return fmt.Sprintf("invalid image reference %q", service.Image)
+		}
+		if _, ok := named.(reference.Digested); ok {
+			return ""
+		}
+		if tagged, ok := named.(reference.Tagged); ok && tagged.Tag() != "latest" {
+			return ""
+		}
+		return fmt.Sprintf("image %s must be pinned to a tag other than latest, or to a digest", service.Image)
+	})
+}
+
+type resourceLimitsRule struct{}
+
+func (resourceLimitsRule) Name() string {
+	return "resource-limits"
+}
+
+func (resourceLimitsRule) Validate(req Request) []types.AdmissionViolation {
+	return validateServices(req, func(service composetypes.ServiceConfig) string {
+		limits := service.Deploy.Resources.Limits
+		if limits == nil || limits.NanoCPUs == "" || limits.MemoryBytes == 0 {
+			return "CPU and memory limits are required"
+		}
+		return ""
+	})
+}
+
+type registriesRule []string
+
+func (registriesRule) Name() string {
+	return "allowed-registries"
+}
+
+func (r registriesRule) Validate(req Request) []types.AdmissionViolation {
+	return validateServices(req, func(service composetypes.ServiceConfig) string {
+		named, err := reference.ParseNormalizedNamed(service.Image)
+		if err != nil {
+			return fmt.Sprintf("invalid image reference %q", service.Image)
+		}
+		domain := reference.Domain(named)
+		for _, allowed := range r {
+			if domain == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("registry %s of image %s is not allowed", domain, service.Image)
+	})
+}
+
+type bindMountsRule struct{}
+
+func (bindMountsRule) Name() string {
+	return "host-bind-mounts"
+}
+
+func (bindMountsRule) Validate(req Request) []types.AdmissionViolation {
+	return validateServices(req, func(service composetypes.ServiceConfig) string {
+		for _, volume := range service.Volumes {
+			if volume.Type == "bind" {
+				return fmt.Sprintf("host path %s cannot be bind mounted", volume.Source)
+			}
+		}
+		return ""
+	})
+}
+
+// defaultLabels adds labels to the services of stacks.
+type defaultLabels map[string]string
+
+func (defaultLabels) Name() string {
+	return "default-labels"
+}
+
+func (l defaultLabels) Mutate(req *Request) error {
+	for i := range req.Spec.Services {
+		service := &req.Spec.Services[i]
+		if service.Deploy.Labels == nil {
+			service.Deploy.Labels = composetypes.Labels{}
+		}
+		l.addTo(service.Deploy.Labels)
+	}
+	if req.SwarmSpec != nil {
+		for i := range req.SwarmSpec.Services {
+			service := &req.SwarmSpec.Services[i]
+			if service.Annotations.Labels == nil {
+				service.Annotations.Labels = map[string]string{}
+			}
+			l.addTo(service.Annotations.Labels)
+		}
+	}
+	return nil
+}
+
+func (l defaultLabels) addTo(labels map[string]string) {
+	for key, value := range l {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+		}
+	}
+}
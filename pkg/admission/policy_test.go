@@ -0,0 +1,100 @@
+package admission
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admission-policy")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	policyFile := filepath.Join(dir, "policy.yml")
+	assert.NilError(t, ioutil.WriteFile(policyFile, []byte(`
+disallow_latest_tag: true
+allowed_registries: [docker.io, registry.example.com]
+default_labels:
+  com.example.team: platform
+`), 0600))
+	policy, err := LoadPolicy(policyFile)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, Policy{
+		DisallowLatestTag: true,
+		AllowedRegistries: []string{"docker.io", "registry.example.com"},
+		DefaultLabels:     map[string]string{"com.example.team": "platform"},
+	}, policy)
+	assert.Check(t, is.Len(policy.Controllers(), 3))
+
+	assert.NilError(t, ioutil.WriteFile(policyFile, []byte("disallow_latest_tags: true\n"), 0600))
+	_, err = LoadPolicy(policyFile)
+	assert.ErrorContains(t, err, "disallow_latest_tags")
+}
+
+func TestPolicyControllers(t *testing.T) {
+	limited := composetypes.DeployConfig{
+		Resources: composetypes.Resources{
+			Limits: &composetypes.Resource{NanoCPUs: "0.5", MemoryBytes: 1024},
+		},
+	}
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Services: []composetypes.ServiceConfig{
+			{Name: "pinned", Image: "registry.example.com/app:1.0", Deploy: limited},
+			{Name: "digest", Image: "nginx@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", Deploy: limited},
+			{Name: "latest", Image: "nginx:latest", Deploy: limited},
+			{Name: "untagged", Image: "quay.io/app"},
+			{
+				Name:    "bind",
+				Image:   "nginx:1.17",
+				Deploy:  limited,
+				Volumes: []composetypes.ServiceVolumeConfig{{Type: "bind", Source: "/etc", Target: "/host/etc"}},
+			},
+		},
+	}
+	swarmSpec := interfaces.SwarmStackSpec{
+		Services: []swarm.ServiceSpec{
+			{Annotations: swarm.Annotations{Name: "pinned", Labels: map[string]string{"com.example.team": "web"}}},
+		},
+	}
+
+	chain := NewChain(Policy{
+		DisallowLatestTag:      true,
+		RequireResourceLimits:  true,
+		AllowedRegistries:      []string{"docker.io", "registry.example.com"},
+		DisallowHostBindMounts: true,
+		DefaultLabels:          map[string]string{"com.example.team": "platform"},
+	}.Controllers()...)
+	err := chain.Admit(&Request{
+		Operation: OperationCreate,
+		Spec:      &spec,
+		SwarmSpec: &swarmSpec,
+	})
+
+	assert.Check(t, errdefs.IsInvalidParameter(err))
+	admissionErr, ok := err.(*Error)
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, []types.AdmissionViolation{
+		{Controller: "latest-tag", Service: "latest", Message: "image nginx:latest must be pinned to a tag other than latest, or to a digest"},
+		{Controller: "latest-tag", Service: "untagged", Message: "image quay.io/app must be pinned to a tag other than latest, or to a digest"},
+		{Controller: "resource-limits", Service: "untagged", Message: "CPU and memory limits are required"},
+		{Controller: "allowed-registries", Service: "untagged", Message: "registry quay.io of image quay.io/app is not allowed"},
+		{Controller: "host-bind-mounts", Service: "bind", Message: "host path /etc cannot be bind mounted"},
+	}, admissionErr.Violations)
+	assert.Check(t, is.Contains(err.Error(), "service bind: host path /etc cannot be bind mounted (host-bind-mounts)"))
+
+	// Default labels are added, without overriding the existing ones
+	assert.Check(t, is.Equal("platform", spec.Services[0].Deploy.Labels["com.example.team"]))
+	assert.Check(t, is.Equal("web", swarmSpec.Services[0].Annotations.Labels["com.example.team"]))
+}
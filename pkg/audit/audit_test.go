@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestFileLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	log, err := OpenFileLog(path)
+	assert.NilError(t, err)
+	for i, id := range []string{"stack1", "stack2", "stack1"} {
+		assert.NilError(t, log.Record(types.AuditEntry{
+			Time:    start.Add(time.Duration(i) * time.Hour),
+			Action:  types.AuditActionUpdate,
+			StackID: id,
+		}))
+	}
+	assert.NilError(t, log.Close())
+
+	// entries are appended to the existing ones
+	log, err = OpenFileLog(path)
+	assert.NilError(t, err)
+	defer log.Close()
+	assert.NilError(t, log.Record(types.AuditEntry{
+		Time:    start.Add(3 * time.Hour),
+		Action:  types.AuditActionDelete,
+		StackID: "stack1",
+	}))
+
+	entries, err := log.Query(types.AuditFilter{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(entries, 4))
+
+	entries, err = log.Query(types.AuditFilter{StackID: "stack1", Since: start.Add(time.Hour)})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(entries, 2))
+	assert.Check(t, entries[0].Time.Equal(start.Add(2*time.Hour)))
+	assert.Check(t, is.Equal(types.AuditActionDelete, entries[1].Action))
+
+	entries, err = log.Query(types.AuditFilter{Until: start.Add(time.Hour)})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(entries, 2))
+}
+
+func TestDiff(t *testing.T) {
+	replicas := func(n uint64) *uint64 { return &n }
+	old := types.StackSpec{
+		Metadata: types.Metadata{Name: "app"},
+		Services: composetypes.Services{
+			{Name: "web", Image: "nginx:1.16", Deploy: composetypes.DeployConfig{Replicas: replicas(2)}},
+			{Name: "db", Image: "postgres:11"},
+			{Name: "cache", Image: "redis:5"},
+		},
+		Networks:       map[string]composetypes.NetworkConfig{"front": {}, "back": {}},
+		PropertyValues: []string{"password=old"},
+	}
+	new := types.StackSpec{
+		Metadata: types.Metadata{Name: "app"},
+		Services: composetypes.Services{
+			{Name: "web", Image: "nginx:1.17", Deploy: composetypes.DeployConfig{Replicas: replicas(3)}},
+			{Name: "db", Image: "postgres:11", Environment: map[string]*string{"DEBUG": nil}},
+			{Name: "worker", Image: "app:1.0"},
+		},
+		Networks:       map[string]composetypes.NetworkConfig{"front": {Driver: "overlay"}},
+		PropertyValues: []string{"password=new"},
+	}
+
+	assert.DeepEqual(t, []string{
+		"property values changed",
+		"service cache removed",
+		"service db: configuration changed",
+		"service web: image nginx:1.16 -> nginx:1.17",
+		"service web: replicas 2 -> 3",
+		"service worker added",
+		"network back removed",
+		"network front changed",
+	}, Diff(old, new))
+	assert.Check(t, is.Len(Diff(old, old), 0))
+}
+
+func TestIsScale(t *testing.T) {
+	spec := func(replicas uint64) types.StackSpec {
+		return types.StackSpec{
+			Services: composetypes.Services{
+				{Name: "web", Image: "nginx", Deploy: composetypes.DeployConfig{Replicas: &replicas}},
+			},
+		}
+	}
+	assert.Check(t, IsScale(spec(1), spec(3)))
+	assert.Check(t, !IsScale(spec(1), spec(1)))
+
+	changed := spec(3)
+	changed.Services[0].Image = "nginx:1.17"
+	assert.Check(t, !IsScale(spec(1), changed))
+}
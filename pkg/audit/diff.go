@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Diff summarizes the differences between two specs of a stack, one line
+// per added, removed or changed object. Property values are not included,
+// as they may hold secrets.
+func Diff(old, new types.StackSpec) []string {
+	changes := []string{}
+	if old.Metadata.Name != new.Metadata.Name {
+		changes = append(changes, fmt.Sprintf("name: %s -> %s", old.Metadata.Name, new.Metadata.Name))
+	}
+	if !reflect.DeepEqual(old.Metadata.Labels, new.Metadata.Labels) {
+		changes = append(changes, "labels changed")
+	}
+	if old.Collection != new.Collection {
+		changes = append(changes, fmt.Sprintf("collection: %q -> %q", old.Collection, new.Collection))
+	}
+	if old.StackImage != new.StackImage {
+		changes = append(changes, fmt.Sprintf("stack image: %s -> %s", old.StackImage, new.StackImage))
+	}
+	if !reflect.DeepEqual(old.PropertyValues, new.PropertyValues) {
+		changes = append(changes, "property values changed")
+	}
+	if old.VolumeRemovalPolicy != new.VolumeRemovalPolicy {
+		changes = append(changes, fmt.Sprintf("volume removal policy: %q -> %q", old.VolumeRemovalPolicy, new.VolumeRemovalPolicy))
+	}
+
+	changes = append(changes, diffServices(old.Services, new.Services)...)
+	changes = append(changes, diffObjects("network", old.Networks, new.Networks)...)
+	changes = append(changes, diffObjects("volume", old.Volumes, new.Volumes)...)
+	changes = append(changes, diffObjects("secret", old.Secrets, new.Secrets)...)
+	changes = append(changes, diffObjects("config", old.Configs, new.Configs)...)
+	return changes
+}
+
+// IsScale tells whether the only differences between two specs of a stack
+// are the numbers of replicas of its services.
+func IsScale(old, new types.StackSpec) bool {
+	if reflect.DeepEqual(old, new) {
+		return false
+	}
+	return reflect.DeepEqual(withoutReplicas(old), withoutReplicas(new))
+}
+
+func withoutReplicas(spec types.StackSpec) types.StackSpec {
+	services := make(composetypes.Services, len(spec.Services))
+	for i, service := range spec.Services {
+		service.Deploy.Replicas = nil
+		services[i] = service
+	}
+	spec.Services = services
+	return spec
+}
+
+func diffServices(old, new composetypes.Services) []string {
+	oldServices := map[string]composetypes.ServiceConfig{}
+	for _, service := range old {
+		oldServices[service.Name] = service
+	}
+	newServices := map[string]composetypes.ServiceConfig{}
+	for _, service := range new {
+		newServices[service.Name] = service
+	}
+
+	changes := []string{}
+	for _, name := range unionKeys(oldServices, newServices) {
+		oldService, inOld := oldServices[name]
+		newService, inNew := newServices[name]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("service %s added", name))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("service %s removed", name))
+		case !reflect.DeepEqual(oldService, newService):
+			changes = append(changes, diffService(name, oldService, newService)...)
+		}
+	}
+	return changes
+}
+
+// diffService details the changes of the image and replicas of a service,
+// which are the most common ones, and reports any other change as a whole.
+func diffService(name string, old, new composetypes.ServiceConfig) []string {
+	changes := []string{}
+	if old.Image != new.Image {
+		changes = append(changes, fmt.Sprintf("service %s: image %s -> %s", name, old.Image, new.Image))
+	}
+	if !reflect.DeepEqual(old.Deploy.Replicas, new.Deploy.Replicas) {
+		changes = append(changes, fmt.Sprintf("service %s: replicas %s -> %s", name, formatReplicas(old.Deploy.Replicas), formatReplicas(new.Deploy.Replicas)))
+	}
+	old.Image, new.Image = "", ""
+	old.Deploy.Replicas, new.Deploy.Replicas = nil, nil
+	if !reflect.DeepEqual(old, new) {
+		changes = append(changes, fmt.Sprintf("service %s: configuration changed", name))
+	}
+	return changes
+}
+
+func formatReplicas(replicas *uint64) string {
+	if replicas == nil {
+		return "default"
+	}
+	return fmt.Sprint(*replicas)
+}
+
+// diffObjects reports the added, removed and changed entries of two maps
+// of top-level objects of a stack.
+func diffObjects(kind string, old, new interface{}) []string {
+	oldMap, newMap := reflect.ValueOf(old), reflect.ValueOf(new)
+	names := map[string]struct{}{}
+	for _, m := range []reflect.Value{oldMap, newMap} {
+		for _, key := range m.MapKeys() {
+			names[key.String()] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	changes := []string{}
+	for _, name := range sorted {
+		key := reflect.ValueOf(name)
+		oldValue, newValue := oldMap.MapIndex(key), newMap.MapIndex(key)
+		switch {
+		case !oldValue.IsValid():
+			changes = append(changes, fmt.Sprintf("%s %s added", kind, name))
+		case !newValue.IsValid():
+			changes = append(changes, fmt.Sprintf("%s %s removed", kind, name))
+		case !reflect.DeepEqual(oldValue.Interface(), newValue.Interface()):
+			changes = append(changes, fmt.Sprintf("%s %s changed", kind, name))
+		}
+	}
+	return changes
+}
+
+func unionKeys(a, b map[string]composetypes.ServiceConfig) []string {
+	keys := []string{}
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,101 @@
+// Package audit records the mutations of stacks, along with the clients
+// which made them, in an append-only log.
+//
+// Entries are only kept in a local file. They are not kept in the stack
+// store, whose objects are replicated by swarmkit to every manager, and
+// which entries added on every mutation would grow without bound.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// FileLog is an audit log appending entries to a local file, one JSON
+// object per line.
+type FileLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenFileLog opens the audit log file at path, creating it if needed.
+// Existing entries are kept.
+func OpenFileLog(path string) (*FileLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open audit log")
+	}
+	return &FileLog{path: path, file: file}, nil
+}
+
+// Record appends an entry to the log.
+func (l *FileLog) Record(entry types.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// entries are written with a single write, so that they are not
+	// interleaved with the ones of other processes appending to the file.
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return errors.Wrap(err, "unable to write audit log entry")
+	}
+	return nil
+}
+
+// Query returns the entries of the log matching the filter, oldest first.
+func (l *FileLog) Query(filter types.AuditFilter) ([]types.AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read audit log")
+	}
+	defer file.Close()
+
+	entries := []types.AuditEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry types.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrap(err, "invalid audit log entry")
+		}
+		if matches(filter, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to read audit log")
+	}
+	return entries, nil
+}
+
+// Close closes the log file.
+func (l *FileLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+func matches(filter types.AuditFilter, entry types.AuditEntry) bool {
+	if filter.StackID != "" && entry.StackID != filter.StackID {
+		return false
+	}
+	if !filter.Since.IsZero() && entry.Time.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && entry.Time.After(filter.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestAuthenticate(t *testing.T) {
+	a := NewAuthenticator(map[string]string{"alice": "alice-token", "ci": "ci-token"})
+
+	request := func(header string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/stacks", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		return r
+	}
+
+	identity, err := a.Authenticate(request("Bearer ci-token"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("ci", identity))
+
+	_, err = a.Authenticate(request("Bearer wrong-token"))
+	assert.Check(t, errdefs.IsUnauthorized(err))
+	_, err = a.Authenticate(request("Basic YWxpY2U6cGFzcw=="))
+	assert.Check(t, errdefs.IsUnauthorized(err))
+	_, err = a.Authenticate(request(""))
+	assert.Check(t, errdefs.IsUnauthorized(err))
+
+	// clients with a verified certificate are identified by its common name
+	r := request("")
+	r.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "bob"}}}},
+	}
+	identity, err = a.Authenticate(r)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("bob", identity))
+}
+
+func TestAuthenticatorHandler(t *testing.T) {
+	a := NewAuthenticator(map[string]string{"alice": "alice-token"})
+	var identity string
+	handler := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ = IdentityFromContext(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stacks", nil))
+	assert.Check(t, is.Equal(http.StatusUnauthorized, w.Code))
+	assert.Check(t, is.Equal("", identity))
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/stacks", nil)
+	r.Header.Set("Authorization", "Bearer alice-token")
+	handler.ServeHTTP(w, r)
+	assert.Check(t, is.Equal(http.StatusOK, w.Code))
+	assert.Check(t, is.Equal("alice", identity))
+}
+
+func TestLoadTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tokens.yml")
+	assert.NilError(t, ioutil.WriteFile(path, []byte("alice: alice-token\nci: ci-token\n"), 0600))
+	tokens, err := LoadTokens(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]string{"alice": "alice-token", "ci": "ci-token"}, tokens)
+
+	assert.NilError(t, ioutil.WriteFile(path, []byte("alice: \"\"\n"), 0600))
+	_, err = LoadTokens(path)
+	assert.ErrorContains(t, err, "empty token for alice")
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authorization")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.yml")
+	assert.NilError(t, ioutil.WriteFile(path, []byte(`
+rules:
+- identities: [alice]
+  collections: [team-a]
+  verbs: [read, create, update, delete]
+- identities: [monitoring]
+  collections: ["*"]
+  verbs: [read]
+- identities: [alice]
+  collections: [team-a]
+  verbs: [adopt]
+- identities: [admin]
+  collections: ["*"]
+  verbs: [adopt]
+`), 0600))
+	policy, err := LoadPolicy(path)
+	assert.NilError(t, err)
+
+	alice := WithIdentity(context.Background(), "alice")
+	monitoring := WithIdentity(context.Background(), "monitoring")
+	admin := WithIdentity(context.Background(), "admin")
+
+	assert.Check(t, policy.Authorize(alice, VerbDelete, "team-a"))
+	assert.Check(t, errdefs.IsForbidden(policy.Authorize(alice, VerbRead, "team-b")))
+	assert.Check(t, errdefs.IsForbidden(policy.Authorize(alice, VerbRead, Any)))
+	assert.Check(t, policy.Authorize(monitoring, VerbRead, "team-b"))
+	assert.Check(t, policy.Authorize(monitoring, VerbRead, Any))
+	err = policy.Authorize(monitoring, VerbCreate, "team-a")
+	assert.Check(t, errdefs.IsForbidden(err))
+	assert.Check(t, is.Error(err, `monitoring is not allowed to create the stacks of collection "team-a"`))
+	assert.Check(t, errdefs.IsUnauthorized(policy.Authorize(context.Background(), VerbRead, "team-a")))
+
+	// Stacks are adopted from any namespace, so adoption is authorized on
+	// all collections, which a client limited to one collection is not
+	// granted.
+	err = policy.Authorize(alice, VerbAdopt, Any)
+	assert.Check(t, errdefs.IsForbidden(err))
+	assert.Check(t, is.Error(err, "alice is not allowed to adopt the stacks of all collections"))
+	assert.Check(t, errdefs.IsForbidden(policy.Authorize(monitoring, VerbAdopt, Any)))
+	assert.Check(t, policy.Authorize(admin, VerbAdopt, Any))
+
+	assert.NilError(t, ioutil.WriteFile(path, []byte("rules:\n- identities: [alice]\n  verbs: [scale]\n"), 0600))
+	_, err = LoadPolicy(path)
+	assert.ErrorContains(t, err, `unknown verb "scale"`)
+}
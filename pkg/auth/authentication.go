@@ -0,0 +1,114 @@
+// Package auth authenticates the clients of the standalone stacks API and
+// authorizes their operations on the stacks of each collection.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/errdefs"
+	pkgerrors "github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity of the client of
+// a request.
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of the client of a request, if
+// it has been authenticated.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(identityKey{}).(string)
+	return identity, ok
+}
+
+// LoadTokens reads the bearer tokens of clients from a YAML file mapping
+// identities to their token, such as:
+//
+//	alice: 3b5d5c3712955042212316173ccf37be
+//	ci: 8d777f385d3dfec8815d20f7496026dc
+func LoadTokens(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "unable to read tokens")
+	}
+	tokens := map[string]string{}
+	if err := yaml.UnmarshalStrict(data, &tokens); err != nil {
+		return nil, pkgerrors.Wrapf(err, "invalid tokens file %s", path)
+	}
+	for identity, token := range tokens {
+		if token == "" {
+			return nil, fmt.Errorf("invalid tokens file %s: empty token for %s", path, identity)
+		}
+	}
+	return tokens, nil
+}
+
+// Authenticator identifies the clients of the API, either by a bearer token
+// or by the common name of a verified TLS client certificate.
+type Authenticator struct {
+	tokens map[string]string
+}
+
+// NewAuthenticator creates an Authenticator accepting the provided tokens,
+// indexed by identity. Client certificates are accepted if the server
+// verified them, which is the case when it is configured with client CAs.
+func NewAuthenticator(tokens map[string]string) *Authenticator {
+	return &Authenticator{tokens: tokens}
+}
+
+// Authenticate returns the identity of the client of a request. Requests
+// with an invalid token or without credentials fail with an
+// errdefs.Unauthorized error.
+func (a *Authenticator) Authenticate(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header {
+			return "", errdefs.Unauthorized(errors.New("unsupported authorization scheme"))
+		}
+		// every token is compared, so that the time taken does not tell
+		// which tokens exist.
+		identity := ""
+		for candidate, expected := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+				identity = candidate
+			}
+		}
+		if identity == "" {
+			return "", errdefs.Unauthorized(errors.New("invalid token"))
+		}
+		return identity, nil
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		if identity := r.TLS.VerifiedChains[0][0].Subject.CommonName; identity != "" {
+			return identity, nil
+		}
+	}
+
+	return "", errdefs.Unauthorized(errors.New("authentication required"))
+}
+
+// Handler wraps an http.Handler so that it is only called for authenticated
+// requests, with the identity of the client in the context of the request.
+func (a *Authenticator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httputils.MakeErrorHandler(err)(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	})
+}
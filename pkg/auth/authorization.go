@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/docker/docker/errdefs"
+	pkgerrors "github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Verb is an operation on the stacks of a collection.
+type Verb string
+
+const (
+	// VerbRead allows to list and inspect stacks, and to watch their
+	// events.
+	VerbRead = Verb("read")
+	// VerbCreate allows to create stacks.
+	VerbCreate = Verb("create")
+	// VerbUpdate allows to update stacks.
+	VerbUpdate = Verb("update")
+	// VerbDelete allows to delete stacks.
+	VerbDelete = Verb("delete")
+	// VerbAdopt allows to adopt the objects deployed with docker stack
+	// deploy as stacks. As these objects may be in any namespace, it is
+	// only granted by rules covering all collections.
+	VerbAdopt = Verb("adopt")
+)
+
+// Any matches every identity or collection in a Rule. When passed as the
+// collection to Policy.Authorize, only rules granting access to every
+// collection allow the operation.
+const Any = "*"
+
+// Rule grants verbs on the stacks of collections to identities.
+type Rule struct {
+	Identities  []string `yaml:"identities"`
+	Collections []string `yaml:"collections"`
+	Verbs       []Verb   `yaml:"verbs"`
+}
+
+// Policy is the set of rules authorizing operations on stacks. Operations
+// not granted by any rule are denied. It is loaded from a YAML file such
+// as:
+//
+//	rules:
+//	- identities: [alice]
+//	  collections: [team-a]
+//	  verbs: [read, create, update, delete]
+//	- identities: [monitoring]
+//	  collections: ["*"]
+//	  verbs: [read]
+//	- identities: [admin]
+//	  collections: ["*"]
+//	  verbs: [read, create, update, delete, adopt]
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadPolicy reads an authorization policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "unable to read authorization policy")
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, pkgerrors.Wrapf(err, "invalid authorization policy %s", path)
+	}
+	for i, rule := range policy.Rules {
+		for _, verb := range rule.Verbs {
+			switch verb {
+			case VerbRead, VerbCreate, VerbUpdate, VerbDelete, VerbAdopt:
+			default:
+				return nil, fmt.Errorf("invalid authorization policy %s: unknown verb %q in rule %d", path, verb, i+1)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// Authorize checks that the client of a request, identified by the context,
+// may perform verb on the stacks of a collection. Unauthenticated requests
+// fail with an errdefs.Unauthorized error, and denied ones with an
+// errdefs.Forbidden error.
+func (p *Policy) Authorize(ctx context.Context, verb Verb, collection string) error {
+	identity, ok := IdentityFromContext(ctx)
+	if !ok {
+		return errdefs.Unauthorized(errors.New("authentication required"))
+	}
+	for _, rule := range p.Rules {
+		if matches(rule.Identities, identity) && matches(rule.Collections, collection) && hasVerb(rule.Verbs, verb) {
+			return nil
+		}
+	}
+	if collection == Any {
+		return errdefs.Forbidden(fmt.Errorf("%s is not allowed to %s the stacks of all collections", identity, verb))
+	}
+	return errdefs.Forbidden(fmt.Errorf("%s is not allowed to %s the stacks of collection %q", identity, verb, collection))
+}
+
+func matches(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == Any || pattern == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVerb(verbs []Verb, verb Verb) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackAdopt creates a new Stack out of the objects deployed with docker
+// stack deploy in a namespace
+func (cli *Client) StackAdopt(ctx context.Context, adopt types.StackAdopt) (types.StackCreateResponse, error) {
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	var response types.StackCreateResponse
+	resp, err := cli.post(ctx, "/stacks/adopt", nil, adopt, headers)
+	if err != nil {
+		return response, err
+	}
+
+	err = json.NewDecoder(resp.body).Decode(&response)
+
+	ensureReaderClosed(resp)
+	return response, err
+}
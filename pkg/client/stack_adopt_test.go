@@ -0,0 +1,47 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestAdoptStackServerError(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackAdopt(ctx, types.StackAdopt{Namespace: "app"})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestAdoptStack(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			assert.Check(t, is.Equal("/stacks/adopt", req.URL.Path))
+			var adopt types.StackAdopt
+			assert.NilError(t, json.NewDecoder(req.Body).Decode(&adopt))
+			assert.Check(t, is.Equal("app", adopt.Namespace))
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"ID":"stack1"}`)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	resp, err := cli.StackAdopt(ctx, types.StackAdopt{Namespace: "app"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("stack1", resp.ID))
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackCompose returns the compose file describing a Stack
+func (cli *Client) StackCompose(ctx context.Context, id string, options types.StackComposeOptions) ([]byte, error) {
+
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	query := url.Values{}
+	if options.Substitute {
+		query.Set("substitute", "1")
+	}
+
+	resp, err := cli.get(ctx, "/stacks/"+id+"/compose", query, headers)
+	if err != nil {
+		return nil, wrapResponseError(err, resp, "stack", id)
+	}
+
+	data, err := ioutil.ReadAll(resp.body)
+
+	ensureReaderClosed(resp)
+	return data, err
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStackComposeServerError(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackCompose(ctx, "dummy", types.StackComposeOptions{})
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackCompose(t *testing.T) {
+	ctx := context.Background()
+	compose := "version: \"3.9\"\nservices:\n  web:\n    image: nginx\n"
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/stacks/dummy/compose" {
+				return nil, fmt.Errorf("unexpected path %s", req.URL.Path)
+			}
+			if req.URL.Query().Get("substitute") != "1" {
+				return nil, fmt.Errorf("expected substitution, got %s", req.URL.RawQuery)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(compose)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	data, err := cli.StackCompose(ctx, "dummy", types.StackComposeOptions{Substitute: true})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(compose, string(data)))
+}
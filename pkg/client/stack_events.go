@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	timetypes "github.com/docker/docker/api/types/time"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackEvents returns a stream of stack lifecycle events, optionally
+// restricted to a single stack. It's up to the caller to close the stream by
+// cancelling the context. Once the stream has been completely read an io.EOF
+// error will be sent over the error channel. If an error is sent all
+// processing will be stopped.
+func (cli *Client) StackEvents(ctx context.Context, options types.StackEventsOptions) (<-chan types.StackEvent, <-chan error) {
+	messages := make(chan types.StackEvent)
+	errs := make(chan error, 1)
+
+	started := make(chan struct{})
+	go func() {
+		defer close(errs)
+
+		headers := map[string][]string{
+			"version": {cli.settings.Version},
+		}
+
+		query := url.Values{}
+		if options.Since != "" {
+			ts, err := timetypes.GetTimestamp(options.Since, time.Now())
+			if err != nil {
+				close(started)
+				errs <- err
+				return
+			}
+			query.Set("since", ts)
+		}
+
+		path := "/stacks/events"
+		if options.StackID != "" {
+			path = "/stacks/" + options.StackID + "/events"
+		}
+
+		resp, err := cli.get(ctx, path, query, headers)
+		if err != nil {
+			close(started)
+			errs <- wrapResponseError(err, resp, "stack", options.StackID)
+			return
+		}
+		defer resp.body.Close()
+
+		decoder := json.NewDecoder(resp.body)
+
+		close(started)
+		for {
+			select {
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			default:
+				var event types.StackEvent
+				if err := decoder.Decode(&event); err != nil {
+					errs <- err
+					return
+				}
+
+				select {
+				case messages <- event:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+		}
+	}()
+	<-started
+
+	return messages, errs
+}
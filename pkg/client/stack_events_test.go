@@ -0,0 +1,57 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStackEventsServerError(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, errs := cli.StackEvents(ctx, types.StackEventsOptions{})
+	assert.ErrorContains(t, <-errs, "Server error")
+}
+
+func TestStackEvents(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			assert.Check(t, is.Equal(req.URL.Path, "/stacks/dummy/events"))
+			assert.Check(t, is.Equal(req.URL.Query().Get("since"), "1500000000"))
+			body := `{"stack_id":"dummy","action":"reconciling"}
+{"stack_id":"dummy","action":"service_created","service":"web"}
+`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+
+	messages, errs := cli.StackEvents(ctx, types.StackEventsOptions{
+		StackID: "dummy",
+		Since:   "1500000000",
+	})
+
+	event := <-messages
+	assert.Equal(t, event.Action, types.StackEventReconciling)
+	event = <-messages
+	assert.Equal(t, event.Action, types.StackEventServiceCreated)
+	assert.Equal(t, event.Service, "web")
+	assert.Equal(t, <-errs, io.EOF)
+}
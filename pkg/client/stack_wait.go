@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// StackCreateAndWait creates a new Stack and waits until it converges, fails
+// or the wait timeout expires. The progress callback, if not nil, is called
+// with every progress update streamed by the server. An error is returned if
+// the stack did not converge, along with the result of the deploy.
+func (cli *Client) StackCreateAndWait(ctx context.Context, stack types.StackCreate, options types.StackCreateOptions, waitOptions types.StackWaitOptions, progress func(types.StackProgress)) (types.StackDeployResult, error) {
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	if options.EncodedRegistryAuth != "" {
+		headers["X-Registry-Auth"] = []string{options.EncodedRegistryAuth}
+	}
+
+	resp, err := cli.post(ctx, "/stacks", waitQuery(waitOptions), stack, headers)
+	defer ensureReaderClosed(resp)
+	if err != nil {
+		return types.StackDeployResult{}, err
+	}
+
+	return readDeployProgress(resp, progress)
+}
+
+// StackUpdateAndWait updates an existing Stack and waits until it converges,
+// fails or the wait timeout expires. It behaves like StackCreateAndWait.
+func (cli *Client) StackUpdateAndWait(ctx context.Context, id string, version types.Version, spec types.StackSpec, options types.StackUpdateOptions, waitOptions types.StackWaitOptions, progress func(types.StackProgress)) (types.StackDeployResult, error) {
+	headers := map[string][]string{
+		"version": {cli.settings.Version},
+	}
+
+	if options.EncodedRegistryAuth != "" {
+		headers["X-Registry-Auth"] = []string{options.EncodedRegistryAuth}
+	}
+
+	query := waitQuery(waitOptions)
+	query.Set("version", strconv.FormatUint(version.Index, 10))
+
+	resp, err := cli.post(ctx, "/stacks/"+id, query, spec, headers)
+	defer ensureReaderClosed(resp)
+	if err != nil {
+		return types.StackDeployResult{}, wrapResponseError(err, resp, "stack", id)
+	}
+
+	return readDeployProgress(resp, progress)
+}
+
+func waitQuery(waitOptions types.StackWaitOptions) url.Values {
+	query := url.Values{}
+	query.Set("wait", "1")
+	if waitOptions.Timeout > 0 {
+		query.Set("timeout", waitOptions.Timeout.String())
+	}
+	return query
+}
+
+// readDeployProgress reads the stream of a synchronous create or update
+// until its final result.
+func readDeployProgress(resp serverResponse, progress func(types.StackProgress)) (types.StackDeployResult, error) {
+	decoder := json.NewDecoder(resp.body)
+	for {
+		var msg types.StackDeployMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return types.StackDeployResult{}, fmt.Errorf("unable to read deploy progress: %s", err)
+		}
+
+		if msg.Progress != nil && progress != nil {
+			progress(*msg.Progress)
+		}
+
+		if result := msg.Result; result != nil {
+			if result.Status != types.StackDeployConverged {
+				return *result, fmt.Errorf("stack %s did not converge (%s): %s", result.StackID, result.Status, result.Message)
+			}
+			return *result, nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStackCreateAndWaitServerError(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+	_, err = cli.StackCreateAndWait(ctx, types.StackCreate{}, types.StackCreateOptions{}, types.StackWaitOptions{}, nil)
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestStackCreateAndWaitConverged(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			assert.Check(t, is.Equal(req.URL.Query().Get("wait"), "1"))
+			assert.Check(t, is.Equal(req.URL.Query().Get("timeout"), "1m0s"))
+			body := `{"progress":{"stack_id":"1","services_status":{"web":{"desired_tasks":2,"running_tasks":1}}}}
+{"progress":{"stack_id":"1","services_status":{"web":{"desired_tasks":2,"running_tasks":2}},"converged":true}}
+{"result":{"stack_id":"1","status":"converged"}}
+`
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+
+	var updates []types.StackProgress
+	result, err := cli.StackCreateAndWait(ctx, types.StackCreate{}, types.StackCreateOptions{},
+		types.StackWaitOptions{Timeout: time.Minute},
+		func(p types.StackProgress) {
+			updates = append(updates, p)
+		})
+	assert.NilError(t, err)
+	assert.Equal(t, result.StackID, "1")
+	assert.Equal(t, result.Status, types.StackDeployConverged)
+	assert.Assert(t, is.Len(updates, 2))
+	assert.Equal(t, updates[0].ServicesStatus["web"].RunningTasks, uint64(1))
+	assert.Assert(t, updates[1].Converged)
+}
+
+func TestStackUpdateAndWaitFailed(t *testing.T) {
+	ctx := context.Background()
+	s := Settings{
+		Client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			assert.Check(t, is.Equal(req.URL.Path, "/stacks/1"))
+			assert.Check(t, is.Equal(req.URL.Query().Get("version"), "3"))
+			body := `{"result":{"stack_id":"1","status":"failed","message":"service web: update paused"}}
+`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			}, nil
+		}),
+	}
+	cli, err := NewClientWithSettings(s)
+	assert.NilError(t, err)
+
+	result, err := cli.StackUpdateAndWait(ctx, "1", types.Version{Index: 3}, types.StackSpec{},
+		types.StackUpdateOptions{}, types.StackWaitOptions{}, nil)
+	assert.ErrorContains(t, err, "update paused")
+	assert.Equal(t, result.Status, types.StackDeployFailed)
+}
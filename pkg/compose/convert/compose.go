@@ -1,7 +1,7 @@
 package convert
 
 import (
-	"io/ioutil"
+	"fmt"
 	"strings"
 
 	"github.com/docker/docker/api/types"
@@ -13,11 +13,16 @@ import (
 const (
 	// LabelNamespace is the label used to track stack resources
 	LabelNamespace = "com.docker.stack.namespace"
+
+	// LabelCollection is the label holding the collection of the stack
+	// resources belong to, if any
+	LabelCollection = "com.docker.stacks.collection"
 )
 
 // Namespace mangles names by prepending the name
 type Namespace struct {
-	name string
+	name       string
+	collection string
 }
 
 // Scope prepends the namespace to a name
@@ -35,22 +40,60 @@ func (n Namespace) Name() string {
 	return n.name
 }
 
+// Collection returns the collection of the namespace, if any
+func (n Namespace) Collection() string {
+	return n.collection
+}
+
 // NewNamespace returns a new Namespace for scoping of names
 func NewNamespace(name string) Namespace {
 	return Namespace{name: name}
 }
 
-// AddStackLabel returns labels with the namespace label added
+// NewCollectionNamespace returns a new Namespace for scoping the names of a
+// stack of a collection. The name of the collection is prepended to the
+// name of the stack, so that the stacks of different collections do not
+// collide, which requires collection names not to contain underscores.
+// Without a collection, it is the same as NewNamespace.
+func NewCollectionNamespace(collection, name string) Namespace {
+	if collection == "" {
+		return NewNamespace(name)
+	}
+	return Namespace{name: collection + "_" + name, collection: collection}
+}
+
+// AddStackLabel returns labels with the namespace label added, along with
+// the collection label if the namespace has a collection
 func AddStackLabel(namespace Namespace, labels map[string]string) map[string]string {
 	if labels == nil {
 		labels = make(map[string]string)
 	}
 	labels[LabelNamespace] = namespace.name
+	if namespace.collection != "" {
+		labels[LabelCollection] = namespace.collection
+	}
 	return labels
 }
 
 type networkMap map[string]composetypes.NetworkConfig
 
+// ServicesNetworks returns the networks the services use, by their key in
+// the compose file, which are the networks to pass to Networks. Services
+// without networks use the default network.
+func ServicesNetworks(services []composetypes.ServiceConfig) map[string]struct{} {
+	serviceNetworks := map[string]struct{}{}
+	for _, service := range services {
+		if len(service.Networks) == 0 {
+			serviceNetworks[defaultNetwork] = struct{}{}
+			continue
+		}
+		for network := range service.Networks {
+			serviceNetworks[network] = struct{}{}
+		}
+	}
+	return serviceNetworks
+}
+
 // Networks from the compose-file type to the engine API type
 func Networks(namespace Namespace, networks networkMap, servicesNetworks map[string]struct{}) (map[string]types.NetworkCreate, []string) {
 	if networks == nil {
@@ -98,36 +141,45 @@ func Networks(namespace Namespace, networks networkMap, servicesNetworks map[str
 	return result, externalNetworks
 }
 
-// Secrets converts secrets from the Compose type to the engine API type
-func Secrets(namespace Namespace, secrets map[string]composetypes.SecretConfig) ([]swarm.SecretSpec, error) {
+// Secrets converts secrets from the Compose type to the engine API type.
+// The data of secrets is looked up in files, by the path of their file.
+func Secrets(namespace Namespace, secrets map[string]composetypes.SecretConfig, files map[string][]byte) ([]swarm.SecretSpec, error) {
 	result := []swarm.SecretSpec{}
 	for name, secret := range secrets {
 		if secret.External.External {
 			continue
 		}
 
-		obj, err := fileObjectConfig(namespace, name, composetypes.FileObjectConfig(secret))
+		obj, err := fileObjectConfig(namespace, name, composetypes.FileObjectConfig(secret), files)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, swarm.SecretSpec{Annotations: obj.Annotations, Data: obj.Data})
+		spec := swarm.SecretSpec{Annotations: obj.Annotations, Data: obj.Data, Templating: obj.Templating}
+		if secret.Driver != "" {
+			spec.Driver = &swarm.Driver{
+				Name:    secret.Driver,
+				Options: secret.DriverOpts,
+			}
+		}
+		result = append(result, spec)
 	}
 	return result, nil
 }
 
-// Configs converts config objects from the Compose type to the engine API type
-func Configs(namespace Namespace, configs map[string]composetypes.ConfigObjConfig) ([]swarm.ConfigSpec, error) {
+// Configs converts config objects from the Compose type to the engine API type.
+// The data of configs is looked up in files, by the path of their file.
+func Configs(namespace Namespace, configs map[string]composetypes.ConfigObjConfig, files map[string][]byte) ([]swarm.ConfigSpec, error) {
 	result := []swarm.ConfigSpec{}
 	for name, config := range configs {
 		if config.External.External {
 			continue
 		}
 
-		obj, err := fileObjectConfig(namespace, name, composetypes.FileObjectConfig(config))
+		obj, err := fileObjectConfig(namespace, name, composetypes.FileObjectConfig(config), files)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, swarm.ConfigSpec{Annotations: obj.Annotations, Data: obj.Data})
+		result = append(result, swarm.ConfigSpec{Annotations: obj.Annotations, Data: obj.Data, Templating: obj.Templating})
 	}
 	return result, nil
 }
@@ -135,12 +187,19 @@ func Configs(namespace Namespace, configs map[string]composetypes.ConfigObjConfi
 type swarmFileObject struct {
 	Annotations swarm.Annotations
 	Data        []byte
+	Templating  *swarm.Driver
 }
 
-func fileObjectConfig(namespace Namespace, name string, obj composetypes.FileObjectConfig) (swarmFileObject, error) {
-	data, err := ioutil.ReadFile(obj.File)
-	if err != nil {
-		return swarmFileObject{}, err
+// fileObjectConfig converts a secret or config. Its data is never read from
+// the filesystem, as the file belongs to the client which sent the stack.
+// Secrets provided by a driver have no data.
+func fileObjectConfig(namespace Namespace, name string, obj composetypes.FileObjectConfig, files map[string][]byte) (swarmFileObject, error) {
+	var data []byte
+	if obj.Driver == "" {
+		var ok bool
+		if data, ok = files[obj.File]; !ok {
+			return swarmFileObject{}, fmt.Errorf("%s: file %s is not attached to the stack", name, obj.File)
+		}
 	}
 
 	if obj.Name != "" {
@@ -149,11 +208,15 @@ func fileObjectConfig(namespace Namespace, name string, obj composetypes.FileObj
 		name = namespace.Scope(name)
 	}
 
-	return swarmFileObject{
+	result := swarmFileObject{
 		Annotations: swarm.Annotations{
 			Name:   name,
 			Labels: AddStackLabel(namespace, obj.Labels),
 		},
 		Data: data,
-	}, nil
+	}
+	if obj.TemplateDriver != "" {
+		result.Templating = &swarm.Driver{Name: obj.TemplateDriver}
+	}
+	return result, nil
 }
@@ -5,10 +5,10 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
-	"gotest.tools/fs"
 )
 
 func TestNamespaceScope(t *testing.T) {
@@ -28,6 +28,17 @@ func TestAddStackLabel(t *testing.T) {
 	assert.Check(t, is.DeepEqual(expected, actual))
 }
 
+func TestCollectionNamespace(t *testing.T) {
+	namespace := NewCollectionNamespace("team", "foo")
+	assert.Check(t, is.Equal("team_foo_bar", namespace.Scope("bar")))
+	assert.Check(t, is.DeepEqual(map[string]string{
+		LabelNamespace:  "team_foo",
+		LabelCollection: "team",
+	}, AddStackLabel(namespace, nil)))
+
+	assert.Check(t, is.Equal(NewNamespace("foo"), NewCollectionNamespace("", "foo")))
+}
+
 func TestNetworks(t *testing.T) {
 	namespace := Namespace{name: "foo"}
 	serviceNetworks := map[string]struct{}{
@@ -112,12 +123,11 @@ func TestSecrets(t *testing.T) {
 	namespace := Namespace{name: "foo"}
 
 	secretText := "this is the first secret"
-	secretFile := fs.NewFile(t, "convert-secrets", fs.WithContent(secretText))
-	defer secretFile.Remove()
+	files := map[string][]byte{"secrets/one.txt": []byte(secretText)}
 
 	source := map[string]composetypes.SecretConfig{
 		"one": {
-			File:   secretFile.Path(),
+			File:   "secrets/one.txt",
 			Labels: map[string]string{"monster": "mash"},
 		},
 		"ext": {
@@ -127,7 +137,7 @@ func TestSecrets(t *testing.T) {
 		},
 	}
 
-	specs, err := Secrets(namespace, source)
+	specs, err := Secrets(namespace, source, files)
 	assert.NilError(t, err)
 	assert.Assert(t, is.Len(specs, 1))
 	secret := specs[0]
@@ -139,16 +149,36 @@ func TestSecrets(t *testing.T) {
 	assert.Check(t, is.DeepEqual([]byte(secretText), secret.Data))
 }
 
+func TestSecretsWithDrivers(t *testing.T) {
+	namespace := Namespace{name: "foo"}
+
+	source := map[string]composetypes.SecretConfig{
+		"vault": {
+			Driver:         "vault",
+			DriverOpts:     map[string]string{"path": "secret/foo"},
+			TemplateDriver: "golang",
+		},
+	}
+
+	specs, err := Secrets(namespace, source, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(specs, 1))
+	secret := specs[0]
+	assert.Check(t, is.Equal("foo_vault", secret.Name))
+	assert.Check(t, is.Len(secret.Data, 0))
+	assert.Check(t, is.DeepEqual(&swarm.Driver{Name: "vault", Options: map[string]string{"path": "secret/foo"}}, secret.Driver))
+	assert.Check(t, is.DeepEqual(&swarm.Driver{Name: "golang"}, secret.Templating))
+}
+
 func TestConfigs(t *testing.T) {
 	namespace := Namespace{name: "foo"}
 
 	configText := "this is the first config"
-	configFile := fs.NewFile(t, "convert-configs", fs.WithContent(configText))
-	defer configFile.Remove()
+	files := map[string][]byte{"configs/one.txt": []byte(configText)}
 
 	source := map[string]composetypes.ConfigObjConfig{
 		"one": {
-			File:   configFile.Path(),
+			File:   "configs/one.txt",
 			Labels: map[string]string{"monster": "mash"},
 		},
 		"ext": {
@@ -158,7 +188,7 @@ func TestConfigs(t *testing.T) {
 		},
 	}
 
-	specs, err := Configs(namespace, source)
+	specs, err := Configs(namespace, source, files)
 	assert.NilError(t, err)
 	assert.Assert(t, is.Len(specs, 1))
 	config := specs[0]
@@ -168,4 +198,9 @@ func TestConfigs(t *testing.T) {
 		LabelNamespace: "foo",
 	}, config.Labels))
 	assert.Check(t, is.DeepEqual([]byte(configText), config.Data))
+
+	// files are never read from the filesystem
+	source["one"] = composetypes.ConfigObjConfig{File: "/etc/passwd"}
+	_, err = Configs(namespace, source, files)
+	assert.Check(t, is.ErrorContains(err, "file /etc/passwd is not attached to the stack"))
 }
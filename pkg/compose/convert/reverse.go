@@ -0,0 +1,504 @@
+package convert
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+)
+
+// ServiceConfig converts the spec of a service deployed in namespace, such
+// as by docker stack deploy, back into a ServiceConfig. It is the reverse of
+// Service: the labels added by the conversion are removed, and the names of
+// the networks, volumes, secrets and configs of the namespace are descoped.
+// The service keeps its full name. Services refer to networks by ID, which
+// networkNames maps to the names of the networks.
+func ServiceConfig(namespace Namespace, spec swarm.ServiceSpec, networkNames map[string]string) composetypes.ServiceConfig {
+	service := composetypes.ServiceConfig{
+		Name: spec.Annotations.Name,
+		Deploy: composetypes.DeployConfig{
+			Labels:         removeStackLabels(spec.Annotations.Labels),
+			UpdateConfig:   updateConfig(spec.UpdateConfig),
+			RollbackConfig: updateConfig(spec.RollbackConfig),
+			Resources:      resources(spec.TaskTemplate.Resources),
+			RestartPolicy:  restartPolicy(spec.TaskTemplate.RestartPolicy),
+		},
+		Networks: serviceNetworks(namespace, spec, networkNames),
+	}
+	delete(service.Deploy.Labels, LabelImage)
+
+	switch {
+	case spec.Mode.Global != nil:
+		service.Deploy.Mode = "global"
+	case spec.Mode.Replicated != nil:
+		service.Deploy.Replicas = spec.Mode.Replicated.Replicas
+	}
+
+	if spec.EndpointSpec != nil {
+		service.Deploy.EndpointMode = string(spec.EndpointSpec.Mode)
+		for _, port := range spec.EndpointSpec.Ports {
+			service.Ports = append(service.Ports, composetypes.ServicePortConfig{
+				Mode:      string(port.PublishMode),
+				Target:    port.TargetPort,
+				Published: port.PublishedPort,
+				Protocol:  string(port.Protocol),
+			})
+		}
+	}
+
+	if placement := spec.TaskTemplate.Placement; placement != nil {
+		service.Deploy.Placement.Constraints = placement.Constraints
+		service.Deploy.Placement.MaxReplicas = placement.MaxReplicas
+		for _, preference := range placement.Preferences {
+			if preference.Spread != nil {
+				service.Deploy.Placement.Preferences = append(service.Deploy.Placement.Preferences, composetypes.PlacementPreferences{
+					Spread: preference.Spread.SpreadDescriptor,
+				})
+			}
+		}
+	}
+
+	if logDriver := spec.TaskTemplate.LogDriver; logDriver != nil {
+		service.Logging = &composetypes.LoggingConfig{
+			Driver:  logDriver.Name,
+			Options: logDriver.Options,
+		}
+	}
+
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if containerSpec == nil {
+		return service
+	}
+
+	// the image label holds the image as written in the compose file,
+	// before it was pinned to a digest.
+	service.Image = containerSpec.Image
+	if image, ok := spec.Annotations.Labels[LabelImage]; ok {
+		service.Image = image
+	}
+	service.Entrypoint = containerSpec.Command
+	service.Command = containerSpec.Args
+	service.Hostname = containerSpec.Hostname
+	service.ExtraHosts = extraHosts(containerSpec.Hosts)
+	if containerSpec.DNSConfig != nil {
+		service.DNS = containerSpec.DNSConfig.Nameservers
+		service.DNSSearch = containerSpec.DNSConfig.Search
+	}
+	service.HealthCheck = healthCheck(containerSpec.Healthcheck)
+	service.Environment = environment(containerSpec.Env)
+	service.Labels = removeStackLabels(containerSpec.Labels)
+	service.WorkingDir = containerSpec.Dir
+	service.User = containerSpec.User
+	service.Volumes = serviceVolumes(namespace, containerSpec.Mounts)
+	if containerSpec.StopGracePeriod != nil {
+		stopGracePeriod := composetypes.Duration(*containerSpec.StopGracePeriod)
+		service.StopGracePeriod = &stopGracePeriod
+	}
+	service.StopSignal = containerSpec.StopSignal
+	service.Tty = containerSpec.TTY
+	service.StdinOpen = containerSpec.OpenStdin
+	service.ReadOnly = containerSpec.ReadOnly
+	service.Isolation = string(containerSpec.Isolation)
+	service.Init = containerSpec.Init
+	service.Sysctls = sysctls(containerSpec.Sysctls)
+
+	for _, secret := range containerSpec.Secrets {
+		if secret.File == nil {
+			continue
+		}
+		service.Secrets = append(service.Secrets, composetypes.ServiceSecretConfig(
+			fileReference(namespace, secret.SecretName, secret.File.Name, secret.File.UID, secret.File.GID, secret.File.Mode),
+		))
+	}
+	for _, config := range containerSpec.Configs {
+		if config.File == nil {
+			continue
+		}
+		service.Configs = append(service.Configs, composetypes.ServiceConfigObjConfig(
+			fileReference(namespace, config.ConfigName, config.File.Name, config.File.UID, config.File.GID, config.File.Mode),
+		))
+	}
+
+	if privileges := containerSpec.Privileges; privileges != nil {
+		if privileges.CredentialSpec != nil {
+			service.CredentialSpec = composetypes.CredentialSpecConfig(*privileges.CredentialSpec)
+			// configs are referred to by ID
+			for _, config := range containerSpec.Configs {
+				if config.ConfigID == service.CredentialSpec.Config {
+					service.CredentialSpec.Config = ObjectKey(namespace, config.ConfigName)
+				}
+			}
+		}
+		service.SecurityOpt = securityOpts(privileges.SELinuxContext)
+	}
+
+	return service
+}
+
+// NetworkConfig converts a network deployed in namespace back into a
+// NetworkConfig, and returns it along with the key it is declared with.
+func NetworkConfig(namespace Namespace, network types.NetworkResource) (string, composetypes.NetworkConfig) {
+	key, name := descope(namespace, network.Name)
+	config := composetypes.NetworkConfig{
+		Name:       name,
+		Driver:     network.Driver,
+		DriverOpts: network.Options,
+		Internal:   network.Internal,
+		Attachable: network.Attachable,
+		Labels:     removeStackLabels(network.Labels),
+	}
+	for _, ipamConfig := range network.IPAM.Config {
+		config.Ipam.Config = append(config.Ipam.Config, &composetypes.IPAMPool{Subnet: ipamConfig.Subnet})
+	}
+	if len(config.Ipam.Config) > 0 && network.IPAM.Driver != "default" {
+		config.Ipam.Driver = network.IPAM.Driver
+	}
+	return key, config
+}
+
+// MountedVolumes returns the named volumes mounted by services deployed in
+// namespace, keyed as in the ServiceConfigs returned by ServiceConfig. The
+// volumes created for the namespace are declared with the options they are
+// mounted with, and others are external.
+func MountedVolumes(namespace Namespace, specs []swarm.ServiceSpec) map[string]composetypes.VolumeConfig {
+	volumes := map[string]composetypes.VolumeConfig{}
+	for _, spec := range specs {
+		if spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+		for _, m := range spec.TaskTemplate.ContainerSpec.Mounts {
+			if m.Type != mount.TypeVolume || m.Source == "" {
+				continue
+			}
+			key, name := descope(namespace, m.Source)
+			options := m.VolumeOptions
+			if options == nil || options.Labels[LabelNamespace] != namespace.Name() {
+				volumes[key] = composetypes.VolumeConfig{
+					Name:     m.Source,
+					External: composetypes.External{External: true},
+				}
+				continue
+			}
+			volume := composetypes.VolumeConfig{
+				Name:   name,
+				Labels: removeStackLabels(options.Labels),
+			}
+			if options.DriverConfig != nil {
+				volume.Driver = options.DriverConfig.Name
+				volume.DriverOpts = options.DriverConfig.Options
+			}
+			volumes[key] = volume
+		}
+	}
+	return volumes
+}
+
+// ObjectKey returns the key an object deployed in namespace is declared
+// with in the StackSpecs reversed from its services.
+func ObjectKey(namespace Namespace, name string) string {
+	key, _ := descope(namespace, name)
+	return key
+}
+
+// ConfigObjConfig converts a config deployed in namespace back into a
+// ConfigObjConfig, and returns it along with the key it is declared with.
+// Its data is expected in the file named after the config.
+func ConfigObjConfig(namespace Namespace, config swarm.Config) (string, composetypes.ConfigObjConfig) {
+	key, name := descope(namespace, config.Spec.Annotations.Name)
+	obj := composetypes.ConfigObjConfig{
+		Name:   name,
+		File:   config.Spec.Annotations.Name,
+		Labels: removeStackLabels(config.Spec.Annotations.Labels),
+	}
+	if config.Spec.Templating != nil {
+		obj.TemplateDriver = config.Spec.Templating.Name
+	}
+	return key, obj
+}
+
+// descope returns the key of an object of namespace. Objects named after
+// the namespace are keyed by their descoped name, and others by their name,
+// which is returned as their explicit name.
+func descope(namespace Namespace, name string) (string, string) {
+	if key := namespace.Descope(name); key != name && key != "" {
+		return key, ""
+	}
+	return name, name
+}
+
+// removeStackLabels returns a copy of labels without the labels added to the
+// objects of stacks, or nil if no labels are left.
+func removeStackLabels(labels map[string]string) map[string]string {
+	var result map[string]string
+	for key, value := range labels {
+		switch key {
+		case LabelNamespace, LabelCollection, interfaces.StackLabel:
+			continue
+		}
+		if result == nil {
+			result = map[string]string{}
+		}
+		result[key] = value
+	}
+	return result
+}
+
+func serviceNetworks(namespace Namespace, spec swarm.ServiceSpec, networkNames map[string]string) map[string]*composetypes.ServiceNetworkConfig {
+	attachments := spec.TaskTemplate.Networks
+	if len(attachments) == 0 {
+		attachments = spec.Networks
+	}
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	networks := map[string]*composetypes.ServiceNetworkConfig{}
+	for _, attachment := range attachments {
+		name := attachment.Target
+		if networkName, ok := networkNames[name]; ok {
+			name = networkName
+		}
+		key, _ := descope(namespace, name)
+
+		// the service name is added to the aliases by the conversion.
+		var aliases []string
+		for _, alias := range attachment.Aliases {
+			if alias != spec.Annotations.Name {
+				aliases = append(aliases, alias)
+			}
+		}
+		var config *composetypes.ServiceNetworkConfig
+		if len(aliases) > 0 {
+			config = &composetypes.ServiceNetworkConfig{Aliases: aliases}
+		}
+		networks[key] = config
+	}
+	return networks
+}
+
+func serviceVolumes(namespace Namespace, mounts []mount.Mount) []composetypes.ServiceVolumeConfig {
+	var volumes []composetypes.ServiceVolumeConfig
+	for _, m := range mounts {
+		volume := composetypes.ServiceVolumeConfig{
+			Type:        string(m.Type),
+			Source:      m.Source,
+			Target:      m.Target,
+			ReadOnly:    m.ReadOnly,
+			Consistency: string(m.Consistency),
+		}
+		switch m.Type {
+		case mount.TypeVolume:
+			if m.Source != "" {
+				volume.Source, _ = descope(namespace, m.Source)
+			}
+			if m.VolumeOptions != nil && m.VolumeOptions.NoCopy {
+				volume.Volume = &composetypes.ServiceVolumeVolume{NoCopy: true}
+			}
+		case mount.TypeBind:
+			if m.BindOptions != nil && m.BindOptions.Propagation != "" {
+				volume.Bind = &composetypes.ServiceVolumeBind{Propagation: string(m.BindOptions.Propagation)}
+			}
+		case mount.TypeTmpfs:
+			if m.TmpfsOptions != nil && m.TmpfsOptions.SizeBytes != 0 {
+				volume.Tmpfs = &composetypes.ServiceVolumeTmpfs{Size: m.TmpfsOptions.SizeBytes}
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// fileReference converts a reference to a secret or config. The target,
+// uid, gid and mode are left out when they have their default value.
+func fileReference(namespace Namespace, name, target, uid, gid string, mode os.FileMode) composetypes.FileReferenceConfig {
+	source, _ := descope(namespace, name)
+	reference := composetypes.FileReferenceConfig{Source: source}
+	if target != source {
+		reference.Target = target
+	}
+	if uid != "0" {
+		reference.UID = uid
+	}
+	if gid != "0" {
+		reference.GID = gid
+	}
+	if mode != 0444 {
+		reference.Mode = uint32Ptr(uint32(mode))
+	}
+	return reference
+}
+
+// extraHosts converts hosts in SwarmKit notation, "IP-address hostname(s)",
+// to <host>:<ip> mappings.
+func extraHosts(hosts []string) composetypes.HostsList {
+	var result composetypes.HostsList
+	for _, host := range hosts {
+		fields := strings.Fields(host)
+		for i := 1; i < len(fields); i++ {
+			result = append(result, fields[i]+":"+fields[0])
+		}
+	}
+	return result
+}
+
+func healthCheck(healthcheck *container.HealthConfig) *composetypes.HealthCheckConfig {
+	if healthcheck == nil {
+		return nil
+	}
+	if len(healthcheck.Test) == 1 && healthcheck.Test[0] == "NONE" {
+		return &composetypes.HealthCheckConfig{Disable: true}
+	}
+	config := &composetypes.HealthCheckConfig{
+		Test:        healthcheck.Test,
+		Timeout:     durationPtr(healthcheck.Timeout),
+		Interval:    durationPtr(healthcheck.Interval),
+		StartPeriod: durationPtr(healthcheck.StartPeriod),
+	}
+	if healthcheck.Retries != 0 {
+		retries := uint64(healthcheck.Retries)
+		config.Retries = &retries
+	}
+	return config
+}
+
+func durationPtr(d time.Duration) *composetypes.Duration {
+	if d == 0 {
+		return nil
+	}
+	duration := composetypes.Duration(d)
+	return &duration
+}
+
+func environment(env []string) composetypes.MappingWithEquals {
+	if len(env) == 0 {
+		return nil
+	}
+	result := composetypes.MappingWithEquals{}
+	for _, keyval := range env {
+		parts := strings.SplitN(keyval, "=", 2)
+		if len(parts) == 1 {
+			result[parts[0]] = nil
+			continue
+		}
+		value := parts[1]
+		result[parts[0]] = &value
+	}
+	return result
+}
+
+func sysctls(sysctls map[string]string) composetypes.Mapping {
+	if len(sysctls) == 0 {
+		return nil
+	}
+	result := make(composetypes.Mapping, len(sysctls))
+	for key, value := range sysctls {
+		result[key] = value
+	}
+	return result
+}
+
+// securityOpts converts the SELinux context of a service to the label
+// options of security_opt.
+func securityOpts(selinux *swarm.SELinuxContext) []string {
+	if selinux == nil {
+		return nil
+	}
+	if selinux.Disable {
+		return []string{"label=disable"}
+	}
+	var opts []string
+	for _, opt := range []struct{ key, value string }{
+		{"user", selinux.User},
+		{"role", selinux.Role},
+		{"type", selinux.Type},
+		{"level", selinux.Level},
+	} {
+		if opt.value != "" {
+			opts = append(opts, "label="+opt.key+":"+opt.value)
+		}
+	}
+	return opts
+}
+
+func restartPolicy(policy *swarm.RestartPolicy) *composetypes.RestartPolicy {
+	if policy == nil {
+		return nil
+	}
+	return &composetypes.RestartPolicy{
+		Condition:   string(policy.Condition),
+		Delay:       composeDurationPtr(policy.Delay),
+		MaxAttempts: policy.MaxAttempts,
+		Window:      composeDurationPtr(policy.Window),
+	}
+}
+
+func composeDurationPtr(d *time.Duration) *composetypes.Duration {
+	if d == nil {
+		return nil
+	}
+	duration := composetypes.Duration(*d)
+	return &duration
+}
+
+func updateConfig(config *swarm.UpdateConfig) *composetypes.UpdateConfig {
+	if config == nil {
+		return nil
+	}
+	parallelism := config.Parallelism
+	return &composetypes.UpdateConfig{
+		Parallelism:     &parallelism,
+		Delay:           composetypes.Duration(config.Delay),
+		FailureAction:   config.FailureAction,
+		Monitor:         composetypes.Duration(config.Monitor),
+		MaxFailureRatio: config.MaxFailureRatio,
+		Order:           config.Order,
+	}
+}
+
+func resources(requirements *swarm.ResourceRequirements) composetypes.Resources {
+	var result composetypes.Resources
+	if requirements == nil {
+		return result
+	}
+	if limits := requirements.Limits; limits != nil {
+		result.Limits = &composetypes.Resource{
+			NanoCPUs:    nanoCPUs(limits.NanoCPUs),
+			MemoryBytes: composetypes.UnitBytes(limits.MemoryBytes),
+		}
+	}
+	if reservations := requirements.Reservations; reservations != nil {
+		result.Reservations = &composetypes.Resource{
+			NanoCPUs:    nanoCPUs(reservations.NanoCPUs),
+			MemoryBytes: composetypes.UnitBytes(reservations.MemoryBytes),
+		}
+		for _, generic := range reservations.GenericResources {
+			if generic.DiscreteResourceSpec == nil {
+				continue
+			}
+			result.Reservations.GenericResources = append(result.Reservations.GenericResources, composetypes.GenericResource{
+				DiscreteResourceSpec: &composetypes.DiscreteGenericResource{
+					Kind:  generic.DiscreteResourceSpec.Kind,
+					Value: generic.DiscreteResourceSpec.Value,
+				},
+			})
+		}
+	}
+	return result
+}
+
+// nanoCPUs formats a number of nano CPUs as the decimal number of CPUs
+// parsed by opts.ParseCPUs.
+func nanoCPUs(cpus int64) string {
+	if cpus == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(cpus)/1e9, 'f', -1, 64)
+}
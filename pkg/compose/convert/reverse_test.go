@@ -0,0 +1,194 @@
+package convert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestServiceConfigReversesService(t *testing.T) {
+	namespace := NewNamespace("app")
+	replicas := uint64(3)
+	parallelism := uint64(2)
+	interval := composetypes.Duration(10 * time.Second)
+	init := true
+	service := composetypes.ServiceConfig{
+		Name:        "web",
+		Image:       "nginx:1.17",
+		Entrypoint:  []string{"/entrypoint.sh"},
+		Command:     []string{"nginx", "-g", "daemon off;"},
+		Environment: composetypes.MappingWithEquals{"MODE": strPtr("prod"), "DEBUG": nil},
+		ExtraHosts:  composetypes.HostsList{"db:10.0.0.2"},
+		HealthCheck: &composetypes.HealthCheckConfig{
+			Test:     composetypes.HealthCheckTest{"CMD", "true"},
+			Interval: &interval,
+		},
+		Init:        &init,
+		Labels:      composetypes.Labels{"tier": "front"},
+		SecurityOpt: []string{"label=type:svirt_apache_t"},
+		Sysctls:     composetypes.Mapping{"net.core.somaxconn": "1024"},
+		Networks: map[string]*composetypes.ServiceNetworkConfig{
+			"front": {Aliases: []string{"www"}},
+		},
+		Ports: []composetypes.ServicePortConfig{
+			{Mode: "ingress", Target: 80, Published: 8080, Protocol: "tcp"},
+		},
+		Volumes: []composetypes.ServiceVolumeConfig{
+			{Type: "volume", Source: "data", Target: "/data"},
+			{Type: "bind", Source: "/etc/nginx", Target: "/etc/nginx", ReadOnly: true},
+		},
+		Deploy: composetypes.DeployConfig{
+			Replicas: &replicas,
+			Labels:   composetypes.Labels{"owner": "team"},
+			UpdateConfig: &composetypes.UpdateConfig{
+				Parallelism: &parallelism,
+				Order:       "start-first",
+			},
+			Resources: composetypes.Resources{
+				Limits: &composetypes.Resource{NanoCPUs: "0.5", MemoryBytes: 1 << 20},
+			},
+			RestartPolicy: &composetypes.RestartPolicy{Condition: "on-failure"},
+			Placement: composetypes.Placement{
+				Constraints: []string{"node.role==worker"},
+				Preferences: []composetypes.PlacementPreferences{{Spread: "node.labels.zone"}},
+			},
+		},
+	}
+	networks := map[string]composetypes.NetworkConfig{"front": {}}
+	volumes := map[string]composetypes.VolumeConfig{"data": {}}
+	secrets := []*swarm.SecretReference{{
+		SecretName: "app_password",
+		File:       &swarm.SecretReferenceFileTarget{Name: "password", UID: "0", GID: "0", Mode: 0400},
+	}}
+
+	spec, err := Service(namespace, service, networks, volumes, secrets, nil)
+	assert.NilError(t, err)
+
+	reversed := ServiceConfig(namespace, spec, nil)
+	assert.Check(t, is.Equal("app_web", reversed.Name))
+	assert.Check(t, is.Equal("nginx:1.17", reversed.Image))
+	assert.Check(t, is.DeepEqual(composetypes.Labels{"owner": "team"}, reversed.Deploy.Labels))
+	assert.Check(t, is.DeepEqual(composetypes.Labels{"tier": "front"}, reversed.Labels))
+	assert.Check(t, is.DeepEqual(composetypes.HostsList{"db:10.0.0.2"}, reversed.ExtraHosts))
+	assert.Check(t, is.DeepEqual(map[string]*composetypes.ServiceNetworkConfig{
+		"front": {Aliases: []string{"www", "web"}},
+	}, reversed.Networks))
+	mode := uint32(0400)
+	assert.Check(t, is.DeepEqual([]composetypes.ServiceSecretConfig{
+		{Source: "password", Mode: &mode},
+	}, reversed.Secrets))
+
+	// converting the reversed service again gives back the same tasks, and
+	// the services are named after their full name by stacks.
+	reversed.Name = "web"
+	respec, err := Service(namespace, reversed, networks, volumes, secrets, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(spec.Annotations, respec.Annotations))
+	assert.Check(t, is.DeepEqual(spec.TaskTemplate.ContainerSpec, respec.TaskTemplate.ContainerSpec))
+	assert.Check(t, is.DeepEqual(spec.TaskTemplate.Resources, respec.TaskTemplate.Resources))
+	assert.Check(t, is.DeepEqual(spec.TaskTemplate.RestartPolicy, respec.TaskTemplate.RestartPolicy))
+	assert.Check(t, is.DeepEqual(spec.TaskTemplate.Placement, respec.TaskTemplate.Placement))
+	assert.Check(t, is.DeepEqual(spec.Mode, respec.Mode))
+	assert.Check(t, is.DeepEqual(spec.EndpointSpec, respec.EndpointSpec))
+	assert.Check(t, is.DeepEqual(spec.UpdateConfig, respec.UpdateConfig))
+}
+
+func TestServiceConfigResolvesNetworkIDs(t *testing.T) {
+	spec := swarm.ServiceSpec{
+		Annotations: swarm.Annotations{Name: "app_web"},
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{Image: "nginx"},
+			Networks: []swarm.NetworkAttachmentConfig{
+				{Target: "id1", Aliases: []string{"web"}},
+				{Target: "id2", Aliases: []string{"app_web"}},
+			},
+		},
+	}
+	service := ServiceConfig(NewNamespace("app"), spec, map[string]string{
+		"id1": "app_default",
+		"id2": "proxy",
+	})
+	assert.Check(t, is.DeepEqual(map[string]*composetypes.ServiceNetworkConfig{
+		"default": {Aliases: []string{"web"}},
+		"proxy":   nil,
+	}, service.Networks))
+}
+
+func TestNetworkConfig(t *testing.T) {
+	namespace := NewNamespace("app")
+	key, network := NetworkConfig(namespace, types.NetworkResource{
+		Name:       "app_back",
+		Driver:     "overlay",
+		Internal:   true,
+		Attachable: true,
+		Labels:     map[string]string{LabelNamespace: "app", "tier": "back"},
+		IPAM: networktypes.IPAM{
+			Driver: "default",
+			Config: []networktypes.IPAMConfig{{Subnet: "10.1.0.0/24"}},
+		},
+	})
+	assert.Check(t, is.Equal("back", key))
+	assert.Check(t, is.DeepEqual(composetypes.NetworkConfig{
+		Driver:     "overlay",
+		Internal:   true,
+		Attachable: true,
+		Labels:     composetypes.Labels{"tier": "back"},
+		Ipam: composetypes.IPAMConfig{
+			Config: []*composetypes.IPAMPool{{Subnet: "10.1.0.0/24"}},
+		},
+	}, network))
+
+	key, network = NetworkConfig(namespace, types.NetworkResource{
+		Name:   "custom",
+		Driver: "overlay",
+		Labels: map[string]string{LabelNamespace: "app"},
+	})
+	assert.Check(t, is.Equal("custom", key))
+	assert.Check(t, is.DeepEqual(composetypes.NetworkConfig{Name: "custom", Driver: "overlay"}, network))
+}
+
+func TestMountedVolumes(t *testing.T) {
+	namespace := NewNamespace("app")
+	spec := swarm.ServiceSpec{
+		TaskTemplate: swarm.TaskSpec{
+			ContainerSpec: &swarm.ContainerSpec{
+				Mounts: []mount.Mount{
+					{
+						Type:   mount.TypeVolume,
+						Source: "app_data",
+						Target: "/data",
+						VolumeOptions: &mount.VolumeOptions{
+							Labels:       map[string]string{LabelNamespace: "app"},
+							DriverConfig: &mount.Driver{Name: "local", Options: map[string]string{"type": "nfs"}},
+						},
+					},
+					{
+						Type:          mount.TypeVolume,
+						Source:        "shared",
+						Target:        "/shared",
+						VolumeOptions: &mount.VolumeOptions{},
+					},
+					{Type: mount.TypeVolume, Target: "/tmp"},
+					{Type: mount.TypeBind, Source: "/etc", Target: "/etc"},
+				},
+			},
+		},
+	}
+	assert.Check(t, is.DeepEqual(map[string]composetypes.VolumeConfig{
+		"data": {
+			Driver:     "local",
+			DriverOpts: map[string]string{"type": "nfs"},
+		},
+		"shared": {
+			Name:     "shared",
+			External: composetypes.External{External: true},
+		},
+	}, MountedVolumes(namespace, []swarm.ServiceSpec{spec})))
+}
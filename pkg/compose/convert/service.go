@@ -1,6 +1,7 @@
 package convert
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"sort"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/versions"
 	"github.com/pkg/errors"
 
 	"github.com/docker/stacks/pkg/compose/parser"
@@ -24,40 +26,94 @@ const (
 	LabelImage = "com.docker.stack.image"
 )
 
+// versionedOptions are the service options converted to fields of the
+// ContainerSpec which are only supported from some version of the engine
+// API. They are left out of the services of engines with an older API.
+var versionedOptions = []struct {
+	property      string
+	minAPIVersion string
+	isSet         func(composetypes.ServiceConfig) bool
+	unset         func(*swarm.ContainerSpec)
+}{
+	{
+		property:      "sysctls",
+		minAPIVersion: "1.40",
+		isSet:         func(service composetypes.ServiceConfig) bool { return len(service.Sysctls) > 0 },
+		unset:         func(spec *swarm.ContainerSpec) { spec.Sysctls = nil },
+	},
+}
+
 // Services converts all services defined in a StackSpec into a list of
-// Swarm services.
+// Swarm services. Service options which the API version of the backend does
+// not support are ignored, and reported in the returned warnings.
 func Services(
 	namespace Namespace,
 	stackSpec types.StackSpec,
 	backend interfaces.SwarmResourceBackend,
-) ([]swarm.ServiceSpec, error) {
+) ([]swarm.ServiceSpec, []types.StackWarning, error) {
 	result := []swarm.ServiceSpec{}
+	var warnings []types.StackWarning
 
 	services := stackSpec.Services
 	volumes := stackSpec.Volumes
 	networks := stackSpec.Networks
 
+	var apiVersion *string
 	for _, service := range services {
 		secrets, err := convertServiceSecrets(backend, namespace, service.Secrets, stackSpec.Secrets)
 		if err != nil {
-			return nil, errors.Wrapf(err, "service %s", service.Name)
+			return nil, nil, errors.Wrapf(err, "service %s", service.Name)
 		}
 
 		configs, err := convertServiceConfigObjs(backend, namespace, service.Configs, stackSpec.Configs)
 		if err != nil {
-			return nil, errors.Wrapf(err, "service %s", service.Name)
+			return nil, nil, errors.Wrapf(err, "service %s", service.Name)
 		}
 
 		serviceSpec, err := Service(namespace, service, networks, volumes, secrets, configs)
 		if err != nil {
-			return nil, errors.Wrapf(err, "service %s", service.Name)
+			return nil, nil, errors.Wrapf(err, "service %s", service.Name)
+		}
+
+		// the API version is only looked up for stacks which need it.
+		for _, option := range versionedOptions {
+			if !option.isSet(service) {
+				continue
+			}
+			if apiVersion == nil {
+				version := backend.APIVersion()
+				apiVersion = &version
+			}
+			if *apiVersion != "" && versions.LessThan(*apiVersion, option.minAPIVersion) {
+				option.unset(serviceSpec.TaskTemplate.ContainerSpec)
+				warnings = append(warnings, types.StackWarning{
+					Code:     types.StackWarningUnsupportedByEngine,
+					Service:  service.Name,
+					Property: option.property,
+					Message: fmt.Sprintf("%s requires API version %s, the engine uses %s",
+						option.property, option.minAPIVersion, *apiVersion),
+				})
+			}
+		}
+		ignored, err := ignoredPropertyWarnings(service)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "service %s", service.Name)
+		}
+		warnings = append(warnings, ignored...)
+		for _, opt := range ignoredSecurityOpts(service.SecurityOpt) {
+			warnings = append(warnings, types.StackWarning{
+				Code:     types.StackWarningUnsupportedProperty,
+				Service:  service.Name,
+				Property: "security_opt",
+				Message:  fmt.Sprintf("%s is ignored, only label options are supported", opt),
+			})
 		}
 
 		serviceSpec.Annotations.Name = service.Name
 		result = append(result, serviceSpec)
 	}
 
-	return result, nil
+	return result, warnings, nil
 }
 
 // Service converts a ServiceConfig into a swarm ServiceSpec
@@ -113,7 +169,11 @@ func Service(
 	}
 
 	var privileges swarm.Privileges
-	privileges.CredentialSpec, err = convertCredentialSpec(service.CredentialSpec)
+	privileges.CredentialSpec, err = convertCredentialSpec(namespace, service.CredentialSpec, configs)
+	if err != nil {
+		return swarm.ServiceSpec{}, err
+	}
+	privileges.SELinuxContext, err = convertSELinuxContext(service.SecurityOpt)
 	if err != nil {
 		return swarm.ServiceSpec{}, err
 	}
@@ -155,6 +215,7 @@ func Service(
 				Privileges:      &privileges,
 				Isolation:       container.Isolation(service.Isolation),
 				Init:            service.Init,
+				Sysctls:         convertSysctls(service.Sysctls),
 			},
 			LogDriver:     logDriver,
 			Resources:     resources,
@@ -162,6 +223,7 @@ func Service(
 			Placement: &swarm.Placement{
 				Constraints: service.Deploy.Placement.Constraints,
 				Preferences: getPlacementPreference(service.Deploy.Placement.Preferences),
+				MaxReplicas: service.Deploy.Placement.MaxReplicas,
 			},
 		},
 		EndpointSpec:   endpoint,
@@ -588,13 +650,156 @@ func convertDNSConfig(DNS []string, DNSSearch []string) (*swarm.DNSConfig, error
 	return nil, nil
 }
 
-func convertCredentialSpec(spec composetypes.CredentialSpecConfig) (*swarm.CredentialSpec, error) {
-	if spec.File == "" && spec.Registry == "" {
-		return nil, nil
+// convertSELinuxContext converts the label options of security_opt, such as
+// "label=type:svirt_apache_t" or "label:disable", to the SELinux context of
+// a service.
+func convertSELinuxContext(securityOpts []string) (*swarm.SELinuxContext, error) {
+	var selinux *swarm.SELinuxContext
+	for _, opt := range securityOpts {
+		value, ok := labelSecurityOpt(opt)
+		if !ok {
+			continue
+		}
+		if selinux == nil {
+			selinux = &swarm.SELinuxContext{}
+		}
+		if value == "disable" {
+			selinux.Disable = true
+			continue
+		}
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid security_opt %s", opt)
+		}
+		switch parts[0] {
+		case "user":
+			selinux.User = parts[1]
+		case "role":
+			selinux.Role = parts[1]
+		case "type":
+			selinux.Type = parts[1]
+		case "level":
+			selinux.Level = parts[1]
+		default:
+			return nil, errors.Errorf("invalid security_opt %s", opt)
+		}
+	}
+	return selinux, nil
+}
+
+// labelSecurityOpt returns the value of a label option of security_opt. Both
+// the "label=" and the legacy "label:" forms are accepted.
+func labelSecurityOpt(opt string) (string, bool) {
+	for _, prefix := range []string{"label=", "label:"} {
+		if strings.HasPrefix(opt, prefix) {
+			return strings.TrimPrefix(opt, prefix), true
+		}
 	}
-	if spec.File != "" && spec.Registry != "" {
-		return nil, errors.New("Invalid credential spec - must provide one of `File` or `Registry`")
+	return "", false
+}
+
+// ignoredPropertyWarnings reports the unsupported and deprecated options
+// of a service, which are kept in its spec but not converted. Stacks
+// created from the API rather than from compose files are reported too.
+func ignoredPropertyWarnings(service composetypes.ServiceConfig) ([]types.StackWarning, error) {
+	// the options are found by their keys in the compose file format,
+	// which are those of the JSON encoding of the service
+	data, err := json.Marshal(service)
+	if err != nil {
+		return nil, err
 	}
+	options := map[string]interface{}{}
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, err
+	}
+	isSet := func(property string) bool {
+		// structs such as build are encoded even when they are empty
+		value, ok := options[property]
+		mapping, isMapping := value.(map[string]interface{})
+		return ok && (!isMapping || len(mapping) > 0)
+	}
+
+	var warnings []types.StackWarning
+	for _, property := range composetypes.UnsupportedProperties {
+		if isSet(property) {
+			warnings = append(warnings, types.StackWarning{
+				Code:     types.StackWarningUnsupportedProperty,
+				Service:  service.Name,
+				Property: property,
+				Message:  fmt.Sprintf("%s is not supported and is ignored", property),
+			})
+		}
+	}
+	deprecated := make([]string, 0, len(composetypes.DeprecatedProperties))
+	for property := range composetypes.DeprecatedProperties {
+		deprecated = append(deprecated, property)
+	}
+	sort.Strings(deprecated)
+	for _, property := range deprecated {
+		if isSet(property) {
+			warnings = append(warnings, types.StackWarning{
+				Code:     types.StackWarningDeprecatedProperty,
+				Service:  service.Name,
+				Property: property,
+				Message:  composetypes.DeprecatedProperties[property],
+			})
+		}
+	}
+	return warnings, nil
+}
+
+// ignoredSecurityOpts returns the options of security_opt which services
+// cannot have, such as seccomp and apparmor profiles.
+func ignoredSecurityOpts(securityOpts []string) []string {
+	var ignored []string
+	for _, opt := range securityOpts {
+		if _, ok := labelSecurityOpt(opt); !ok {
+			ignored = append(ignored, opt)
+		}
+	}
+	return ignored
+}
+
+// convertSysctls converts the sysctls of a service, trimming the spaces
+// around the = of the key=value list form.
+func convertSysctls(sysctls composetypes.Mapping) map[string]string {
+	if len(sysctls) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(sysctls))
+	for key, value := range sysctls {
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// convertCredentialSpec converts the credential spec of a service. A
+// credential spec read from a config refers to the config by ID, so the
+// config has to be attached to the service.
+func convertCredentialSpec(namespace Namespace, spec composetypes.CredentialSpecConfig, refs []*swarm.ConfigReference) (*swarm.CredentialSpec, error) {
+	var sources []string
+	for _, source := range []string{spec.Config, spec.File, spec.Registry} {
+		if source != "" {
+			sources = append(sources, source)
+		}
+	}
+	switch len(sources) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, errors.New("Invalid credential spec - must provide one of `Config`, `File` or `Registry`")
+	}
+
 	swarmCredSpec := swarm.CredentialSpec(spec)
-	return &swarmCredSpec, nil
+	if spec.Config == "" {
+		return &swarmCredSpec, nil
+	}
+	for _, ref := range refs {
+		if ref.ConfigName == spec.Config || ref.ConfigName == namespace.Scope(spec.Config) {
+			swarmCredSpec.Config = ref.ConfigID
+			return &swarmCredSpec, nil
+		}
+	}
+	return nil, errors.Errorf("Invalid credential spec - config %s is not attached to the service", spec.Config)
 }
@@ -16,6 +16,7 @@ import (
 	is "gotest.tools/assert/cmp"
 
 	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
 )
 
 func TestConvertRestartPolicyFromNone(t *testing.T) {
@@ -314,30 +315,56 @@ func TestConvertDNSConfigSearch(t *testing.T) {
 }
 
 func TestConvertCredentialSpec(t *testing.T) {
-	swarmSpec, err := convertCredentialSpec(composetypes.CredentialSpecConfig{})
+	namespace := NewNamespace("foo")
+	swarmSpec, err := convertCredentialSpec(namespace, composetypes.CredentialSpecConfig{}, nil)
 	assert.NilError(t, err)
 	assert.Check(t, is.Nil(swarmSpec))
 
-	swarmSpec, err = convertCredentialSpec(composetypes.CredentialSpecConfig{
+	swarmSpec, err = convertCredentialSpec(namespace, composetypes.CredentialSpecConfig{
 		File: "/foo",
-	})
+	}, nil)
 	assert.NilError(t, err)
 	assert.Check(t, is.Equal(swarmSpec.File, "/foo"))
 	assert.Check(t, is.Equal(swarmSpec.Registry, ""))
 
-	swarmSpec, err = convertCredentialSpec(composetypes.CredentialSpecConfig{
+	swarmSpec, err = convertCredentialSpec(namespace, composetypes.CredentialSpecConfig{
 		Registry: "foo",
-	})
+	}, nil)
 	assert.NilError(t, err)
 	assert.Check(t, is.Equal(swarmSpec.File, ""))
 	assert.Check(t, is.Equal(swarmSpec.Registry, "foo"))
 
-	swarmSpec, err = convertCredentialSpec(composetypes.CredentialSpecConfig{
+	swarmSpec, err = convertCredentialSpec(namespace, composetypes.CredentialSpecConfig{
 		File:     "/asdf",
 		Registry: "foo",
-	})
+	}, nil)
 	assert.Check(t, is.ErrorContains(err, ""))
 	assert.Check(t, is.Nil(swarmSpec))
+
+	swarmSpec, err = convertCredentialSpec(namespace, composetypes.CredentialSpecConfig{
+		Config:   "credspec",
+		Registry: "foo",
+	}, nil)
+	assert.Check(t, is.ErrorContains(err, ""))
+	assert.Check(t, is.Nil(swarmSpec))
+
+	// configs are referred to by the ID of the config attached to the
+	// service
+	refs := []*swarm.ConfigReference{
+		{ConfigID: "id1", ConfigName: "foo_other"},
+		{ConfigID: "id2", ConfigName: "foo_credspec"},
+	}
+	swarmSpec, err = convertCredentialSpec(namespace, composetypes.CredentialSpecConfig{
+		Config: "credspec",
+	}, refs)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(&swarm.CredentialSpec{Config: "id2"}, swarmSpec))
+
+	swarmSpec, err = convertCredentialSpec(namespace, composetypes.CredentialSpecConfig{
+		Config: "missing",
+	}, refs)
+	assert.Check(t, is.Error(err, "Invalid credential spec - config missing is not attached to the service"))
+	assert.Check(t, is.Nil(swarmSpec))
 }
 
 func TestConvertUpdateConfigOrder(t *testing.T) {
@@ -416,6 +443,95 @@ func TestServiceConvertsIsolation(t *testing.T) {
 	assert.Check(t, is.Equal(container.IsolationHyperV, result.TaskTemplate.ContainerSpec.Isolation))
 }
 
+func TestServiceConvertsMaxReplicasPerNode(t *testing.T) {
+	src := composetypes.ServiceConfig{
+		Deploy: composetypes.DeployConfig{
+			Placement: composetypes.Placement{
+				Constraints: []string{"node.role == worker"},
+				MaxReplicas: 2,
+			},
+		},
+	}
+	result, err := Service(Namespace{name: "foo"}, src, nil, nil, nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(uint64(2), result.TaskTemplate.Placement.MaxReplicas))
+	assert.Check(t, is.DeepEqual([]string{"node.role == worker"}, result.TaskTemplate.Placement.Constraints))
+}
+
+func TestServiceConvertsSysctlsAndSELinuxLabels(t *testing.T) {
+	src := composetypes.ServiceConfig{
+		Sysctls:     composetypes.Mapping{"net.core.somaxconn": "1024", "net.ipv4.tcp_syncookies ": " 0"},
+		SecurityOpt: []string{"label=user:USER", "label:type:svirt_apache_t", "seccomp=unconfined"},
+	}
+	result, err := Service(Namespace{name: "foo"}, src, nil, nil, nil, nil)
+	assert.NilError(t, err)
+	spec := result.TaskTemplate.ContainerSpec
+	assert.Check(t, is.DeepEqual(map[string]string{
+		"net.core.somaxconn":      "1024",
+		"net.ipv4.tcp_syncookies": "0",
+	}, spec.Sysctls))
+	assert.Check(t, is.DeepEqual(&swarm.SELinuxContext{User: "USER", Type: "svirt_apache_t"}, spec.Privileges.SELinuxContext))
+
+	src.SecurityOpt = []string{"label=host:foo"}
+	_, err = Service(Namespace{name: "foo"}, src, nil, nil, nil, nil)
+	assert.Check(t, is.Error(err, "invalid security_opt label=host:foo"))
+}
+
+func TestServicesIgnoresOptionsUnsupportedByTheEngine(t *testing.T) {
+	stackSpec := types.StackSpec{
+		Services: composetypes.Services{
+			{Name: "web", Image: "nginx", Sysctls: composetypes.Mapping{"net.core.somaxconn": "1024"}},
+			{Name: "db", Image: "postgres", Sysctls: composetypes.Mapping{"kernel.shmmax": "65536"}},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mocks.NewMockBackendClient(ctrl)
+	// the API version is looked up once per stack
+	backend.EXPECT().APIVersion().Return("1.39")
+	specs, warnings, err := Services(Namespace{name: "foo"}, stackSpec, backend)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(specs, 2))
+	assert.Check(t, is.Nil(specs[0].TaskTemplate.ContainerSpec.Sysctls))
+	assert.Check(t, is.Nil(specs[1].TaskTemplate.ContainerSpec.Sysctls))
+	assert.Check(t, is.DeepEqual([]types.StackWarning{
+		{
+			Code:     types.StackWarningUnsupportedByEngine,
+			Service:  "web",
+			Property: "sysctls",
+			Message:  "sysctls requires API version 1.40, the engine uses 1.39",
+		},
+		{
+			Code:     types.StackWarningUnsupportedByEngine,
+			Service:  "db",
+			Property: "sysctls",
+			Message:  "sysctls requires API version 1.40, the engine uses 1.39",
+		},
+	}, warnings))
+
+	backend.EXPECT().APIVersion().Return("1.40")
+	specs, warnings, err = Services(Namespace{name: "foo"}, stackSpec, backend)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(map[string]string{"net.core.somaxconn": "1024"}, specs[0].TaskTemplate.ContainerSpec.Sysctls))
+	assert.Check(t, is.Len(warnings, 0))
+
+	// stacks without versioned options do not need the API version
+	stackSpec.Services = composetypes.Services{
+		{Name: "web", Image: "nginx", SecurityOpt: []string{"label=disable", "no-new-privileges"}},
+	}
+	_, warnings, err = Services(Namespace{name: "foo"}, stackSpec, backend)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]types.StackWarning{
+		{
+			Code:     types.StackWarningUnsupportedProperty,
+			Service:  "web",
+			Property: "security_opt",
+			Message:  "no-new-privileges is ignored, only label options are supported",
+		},
+	}, warnings))
+}
+
 func TestConvertServiceSecrets(t *testing.T) {
 
 	namespace := Namespace{name: "foo"}
@@ -28,6 +28,7 @@ func TestLoadStacks(t *testing.T) {
 	secretlist, err := Secrets(
 		namespace,
 		stack.Spec.Secrets,
+		stack.Spec.Files,
 	)
 	assert.NilError(t, err)
 	assert.Check(t, is.Len(secretlist, 0))
@@ -35,6 +36,7 @@ func TestLoadStacks(t *testing.T) {
 	cfglist, err := Configs(
 		namespace,
 		stack.Spec.Configs,
+		stack.Spec.Files,
 	)
 	assert.NilError(t, err)
 	assert.Check(t, is.Len(cfglist, 0))
@@ -2,6 +2,7 @@ package convert
 
 import (
 	"github.com/docker/docker/api/types/mount"
+	volumetypes "github.com/docker/docker/api/types/volume"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
 	"github.com/pkg/errors"
 )
@@ -22,6 +23,32 @@ func Volumes(serviceVolumes []composetypes.ServiceVolumeConfig, stackVolumes vol
 	return mounts, nil
 }
 
+// NamedVolumes converts the volumes declared by a stack to the engine API
+// type, keyed by volume name. External volumes are not converted, their
+// names are returned instead.
+func NamedVolumes(namespace Namespace, stackVolumes volumes) (map[string]volumetypes.VolumeCreateBody, []string) {
+	externalVolumes := []string{}
+	result := make(map[string]volumetypes.VolumeCreateBody)
+	for internalName, volume := range stackVolumes {
+		volumeName := namespace.Scope(internalName)
+		if volume.Name != "" {
+			volumeName = volume.Name
+		}
+		if volume.External.External {
+			externalVolumes = append(externalVolumes, volumeName)
+			continue
+		}
+
+		result[volumeName] = volumetypes.VolumeCreateBody{
+			Name:       volumeName,
+			Driver:     volume.Driver,
+			DriverOpts: volume.DriverOpts,
+			Labels:     AddStackLabel(namespace, volume.Labels),
+		}
+	}
+	return result, externalVolumes
+}
+
 func createMountFromVolume(volume composetypes.ServiceVolumeConfig) mount.Mount {
 	return mount.Mount{
 		Type:        mount.Type(volume.Type),
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/docker/docker/api/types/mount"
+	volumetypes "github.com/docker/docker/api/types/volume"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
@@ -23,6 +24,41 @@ func TestConvertVolumeToMountAnonymousVolume(t *testing.T) {
 	assert.Check(t, is.DeepEqual(expected, mount))
 }
 
+func TestNamedVolumes(t *testing.T) {
+	stackVolumes := volumes{
+		"normal": composetypes.VolumeConfig{
+			Driver:     "glusterfs",
+			DriverOpts: map[string]string{"opt": "value"},
+			Labels:     map[string]string{"something": "labeled"},
+		},
+		"named": composetypes.VolumeConfig{
+			Name: "explicit",
+		},
+		"outside": composetypes.VolumeConfig{
+			Name:     "special",
+			External: composetypes.External{External: true},
+		},
+	}
+	expected := map[string]volumetypes.VolumeCreateBody{
+		"foo_normal": {
+			Name:       "foo_normal",
+			Driver:     "glusterfs",
+			DriverOpts: map[string]string{"opt": "value"},
+			Labels: map[string]string{
+				LabelNamespace: "foo",
+				"something":    "labeled",
+			},
+		},
+		"explicit": {
+			Name:   "explicit",
+			Labels: map[string]string{LabelNamespace: "foo"},
+		},
+	}
+	named, external := NamedVolumes(NewNamespace("foo"), stackVolumes)
+	assert.Check(t, is.DeepEqual(expected, named))
+	assert.Check(t, is.DeepEqual([]string{"special"}, external))
+}
+
 func TestConvertVolumeToMountAnonymousBind(t *testing.T) {
 	config := composetypes.ServiceVolumeConfig{
 		Type:   "bind",
@@ -135,7 +135,9 @@ func (p Path) parts() []string {
 	return strings.Split(string(p), pathSeparator)
 }
 
-func (p Path) matches(pattern Path) bool {
+// Matches returns true if the path matches a pattern, where PathMatchAll
+// matches any key.
+func (p Path) Matches(pattern Path) bool {
 	patternParts := pattern.parts()
 	parts := p.parts()
 
@@ -155,7 +157,7 @@ func (p Path) matches(pattern Path) bool {
 
 func (o Options) getCasterForPath(path Path) (Cast, bool) {
 	for pattern, caster := range o.TypeCastMapping {
-		if path.matches(pattern) {
+		if path.Matches(pattern) {
 			return caster, true
 		}
 	}
@@ -0,0 +1,393 @@
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/compose/types"
+)
+
+// mergedMappings are the service options of an extended service which are
+// merged key by key with the ones of the extending service, recursively for
+// deploy. Options which are neither merged mappings nor merged lists are
+// replaced.
+var mergedMappings = map[string]bool{
+	"environment": true,
+	"labels":      true,
+	"ulimits":     true,
+	"sysctls":     true,
+	"healthcheck": true,
+	"logging":     true,
+	"deploy":      true,
+	"networks":    true,
+}
+
+// mergedLists are the service options of an extended service whose items
+// are kept along with the ones of the extending service.
+var mergedLists = map[string]bool{
+	"ports":          true,
+	"expose":         true,
+	"external_links": true,
+	"extra_hosts":    true,
+	"dns":            true,
+	"dns_search":     true,
+	"env_file":       true,
+	"tmpfs":          true,
+	"cap_add":        true,
+	"cap_drop":       true,
+	"security_opt":   true,
+	"devices":        true,
+	"volumes":        true,
+	"secrets":        true,
+	"configs":        true,
+}
+
+// servicesOf returns the services of a compose file. Version 1 files, which
+// have no version, hold their services at the top level.
+func servicesOf(dict map[string]interface{}) map[string]interface{} {
+	if _, ok := dict["version"]; !ok {
+		return dict
+	}
+	services, _ := dict["services"].(map[string]interface{})
+	return services
+}
+
+// extendsReference is a parsed extends option.
+type extendsReference struct {
+	service string
+	file    string
+}
+
+func parseExtends(value interface{}) (extendsReference, error) {
+	switch value := value.(type) {
+	case string:
+		return extendsReference{service: value}, nil
+	case map[string]interface{}:
+		service, _ := value["service"].(string)
+		if service == "" {
+			return extendsReference{}, errors.New("extends requires a service")
+		}
+		file, _ := value["file"].(string)
+		return extendsReference{service: service, file: file}, nil
+	default:
+		return extendsReference{}, errors.Errorf("invalid type %T for extends", value)
+	}
+}
+
+// joinPath returns the path, relative to the working directory, of a file
+// referenced by a compose file located in dir.
+func joinPath(dir, file string) string {
+	file = filepath.ToSlash(file)
+	if path.IsAbs(file) {
+		return path.Clean(file)
+	}
+	return path.Join(dir, file)
+}
+
+// extendsResolver resolves the extends options of the services of a
+// compose file. Extended files are read from the files attached to the
+// config, or from the working directory if there are none.
+type extendsResolver struct {
+	details types.ConfigDetails
+	files   map[string]map[string]interface{}
+}
+
+// resolveExtends returns a copy of a compose file where the services which
+// extend another service are replaced by the result of merging them into
+// the service they extend.
+func resolveExtends(configDict map[string]interface{}, details types.ConfigDetails) (map[string]interface{}, error) {
+	services := servicesOf(configDict)
+	hasExtends := false
+	for _, service := range services {
+		if serviceDict, ok := service.(map[string]interface{}); ok {
+			_, extends := serviceDict["extends"]
+			hasExtends = hasExtends || extends
+		}
+	}
+	if !hasExtends {
+		return configDict, nil
+	}
+
+	r := &extendsResolver{details: details, files: map[string]map[string]interface{}{}}
+	resolved := map[string]interface{}{}
+	for name := range services {
+		service, err := r.resolve("", services, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = service
+	}
+
+	if _, ok := configDict["version"]; !ok {
+		return resolved, nil
+	}
+	result := map[string]interface{}{}
+	for key, value := range configDict {
+		result[key] = value
+	}
+	result["services"] = resolved
+	return result, nil
+}
+
+// resolve returns the service called name among the services of the file
+// at filePath, which is empty for the compose file being loaded, with its
+// extends option resolved. chain holds the services being resolved, to
+// detect cycles.
+func (r *extendsResolver) resolve(filePath string, services map[string]interface{}, name string, chain []string) (map[string]interface{}, error) {
+	location := filePath
+	if location == "" {
+		location = "the compose file"
+	}
+	id := fmt.Sprintf("%s in %s", name, location)
+	for _, previous := range chain {
+		if previous == id {
+			return nil, errors.Errorf("circular reference: %s", strings.Join(append(chain, id), " extends "))
+		}
+	}
+
+	serviceDict, ok := services[name].(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("cannot extend service %q: service not found in %s", name, location)
+	}
+	service := copyMapping(serviceDict)
+	if filePath != "" {
+		rebasePaths(service, path.Dir(filePath))
+	}
+
+	extends, ok := service["extends"]
+	if !ok {
+		return service, nil
+	}
+	delete(service, "extends")
+	ref, err := parseExtends(extends)
+	if err != nil {
+		return nil, errors.Wrapf(err, "service %s", name)
+	}
+
+	baseFilePath, baseServices := filePath, services
+	if ref.file != "" {
+		baseFilePath = joinPath(path.Dir(filePath), ref.file)
+		baseFile, err := r.loadFile(baseFilePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "service %s", name)
+		}
+		baseServices = servicesOf(baseFile)
+	}
+
+	base, err := r.resolve(baseFilePath, baseServices, ref.service, append(chain, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExtended(ref.service, baseFilePath, base); err != nil {
+		return nil, err
+	}
+
+	merged := mergeExtendedService(base, service)
+	_, hasImage := merged["image"]
+	_, hasBuild := merged["build"]
+	if !hasImage && !hasBuild {
+		return nil, errors.Errorf("service %s has neither an image nor a build context specified", name)
+	}
+	return merged, nil
+}
+
+// loadFile reads and parses an extended file.
+func (r *extendsResolver) loadFile(filePath string) (map[string]interface{}, error) {
+	if dict, ok := r.files[filePath]; ok {
+		return dict, nil
+	}
+
+	var data []byte
+	if r.details.Files != nil {
+		var ok bool
+		if data, ok = r.details.Files[filePath]; !ok {
+			return nil, errors.Errorf("extended file %s is not attached", filePath)
+		}
+	} else {
+		var err error
+		if data, err = ioutil.ReadFile(absPath(r.details.WorkingDir, filePath)); err != nil {
+			return nil, err
+		}
+	}
+
+	dict, err := ParseYAML(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid extended file %s", filePath)
+	}
+	r.files[filePath] = dict
+	return dict, nil
+}
+
+// validateExtended rejects the extension of services depending on other
+// services of their file, which would not exist in the extending file.
+func validateExtended(name, filePath string, service map[string]interface{}) error {
+	location := filePath
+	if location == "" {
+		location = "the compose file"
+	}
+	fail := func(option string) error {
+		return errors.Errorf("cannot extend service %q in %s: services with %q cannot be extended", name, location, option)
+	}
+	for _, option := range []string{"links", "volumes_from", "depends_on"} {
+		if _, ok := service[option]; ok {
+			return fail(option)
+		}
+	}
+	if net, ok := service["net"].(string); ok && strings.HasPrefix(net, "container:") {
+		return fail("net: container")
+	}
+	if mode, ok := service["network_mode"].(string); ok && (strings.HasPrefix(mode, "service:") || strings.HasPrefix(mode, "container:")) {
+		return fail("network_mode: " + strings.SplitN(mode, ":", 2)[0])
+	}
+	return nil
+}
+
+// mergeExtendedService merges the options of a service into the ones of the
+// service it extends.
+func mergeExtendedService(base, override map[string]interface{}) map[string]interface{} {
+	merged := copyMapping(base)
+	// image and build exclude each other.
+	if _, ok := override["image"]; ok {
+		delete(merged, "build")
+	}
+	if _, ok := override["build"]; ok {
+		delete(merged, "image")
+	}
+
+	for key, value := range override {
+		baseValue, ok := merged[key]
+		switch {
+		case !ok:
+			merged[key] = value
+		case mergedMappings[key]:
+			merged[key] = mergeMappings(toMapping(baseValue), toMapping(value), key == "deploy")
+		case mergedLists[key]:
+			merged[key] = mergeLists(toList(baseValue), toList(value))
+		default:
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// mergeMappings merges two mappings, recursively for nested mappings if
+// deep is set.
+func mergeMappings(base, override map[string]interface{}, deep bool) map[string]interface{} {
+	merged := copyMapping(base)
+	for key, value := range override {
+		baseMapping, baseIsMapping := merged[key].(map[string]interface{})
+		mapping, isMapping := value.(map[string]interface{})
+		if deep && baseIsMapping && isMapping {
+			merged[key] = mergeMappings(baseMapping, mapping, deep)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeLists appends the items of override which are not in base.
+func mergeLists(base, override []interface{}) []interface{} {
+	merged := append([]interface{}{}, base...)
+	for _, item := range override {
+		found := false
+		for _, existing := range merged {
+			found = found || reflect.DeepEqual(existing, item)
+		}
+		if !found {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
+
+// toMapping converts options which may be written either as a mapping or as
+// a list of key=value strings, such as environment and labels, to a
+// mapping.
+func toMapping(value interface{}) map[string]interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return value
+	case []interface{}:
+		mapping := map[string]interface{}{}
+		for _, item := range value {
+			parts := strings.SplitN(fmt.Sprint(item), "=", 2)
+			if len(parts) == 2 {
+				mapping[parts[0]] = parts[1]
+			} else {
+				mapping[parts[0]] = nil
+			}
+		}
+		return mapping
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func toList(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		return value
+	case nil:
+		return nil
+	default:
+		return []interface{}{value}
+	}
+}
+
+// rebasePaths makes the relative env files and bind mounts of a service
+// from a file located in dir relative to the working directory.
+func rebasePaths(service map[string]interface{}, dir string) {
+	if dir == "." {
+		return
+	}
+	if envFiles, ok := service["env_file"]; ok {
+		rebased := []interface{}{}
+		for _, file := range toList(envFiles) {
+			rebased = append(rebased, joinPath(dir, fmt.Sprint(file)))
+		}
+		service["env_file"] = rebased
+	}
+	volumes, ok := service["volumes"].([]interface{})
+	if !ok {
+		return
+	}
+	rebased := []interface{}{}
+	for _, volume := range volumes {
+		switch volume := volume.(type) {
+		case string:
+			parts := strings.SplitN(volume, ":", 2)
+			if isRelativePath(parts[0]) {
+				parts[0] = "./" + joinPath(dir, parts[0])
+			}
+			rebased = append(rebased, strings.Join(parts, ":"))
+		case map[string]interface{}:
+			volume = copyMapping(volume)
+			if source, ok := volume["source"].(string); ok && volume["type"] == "bind" && isRelativePath(source) {
+				volume["source"] = "./" + joinPath(dir, source)
+			}
+			rebased = append(rebased, volume)
+		default:
+			rebased = append(rebased, volume)
+		}
+	}
+	service["volumes"] = rebased
+}
+
+func isRelativePath(source string) bool {
+	return source == "." || source == ".." || strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+func copyMapping(mapping map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(mapping))
+	for key, value := range mapping {
+		result[key] = value
+	}
+	return result
+}
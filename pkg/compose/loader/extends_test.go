@@ -0,0 +1,237 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	"github.com/docker/stacks/pkg/compose/types"
+)
+
+const extendsFixtures = "../tests/fixtures/extends"
+
+func resolveExtendsFixture(t *testing.T, filename string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(filepath.Join(extendsFixtures, filename))
+	assert.NilError(t, err)
+	dict, err := ParseYAML(data)
+	assert.NilError(t, err)
+	resolved, err := resolveExtends(dict, types.ConfigDetails{WorkingDir: extendsFixtures})
+	if err != nil {
+		return nil, err
+	}
+	return servicesOf(resolved), nil
+}
+
+func TestResolveExtends(t *testing.T) {
+	testcases := []struct {
+		filename string
+		service  string
+		expected map[string]interface{}
+	}{
+		{
+			filename: "docker-compose.yml",
+			service:  "myweb",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"command":     "top",
+				"links":       []interface{}{"mydb:db"},
+				"net":         "bridge",
+				"environment": map[string]interface{}{"FOO": "1", "BAR": "2", "BAZ": "2"},
+			},
+		},
+		{
+			filename: "docker-compose.yml",
+			service:  "mydb",
+			expected: map[string]interface{}{
+				"image":   "busybox",
+				"command": "top",
+			},
+		},
+		{
+			filename: "common-env-labels-ulimits.yml",
+			service:  "web",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"command":     "/bin/true",
+				"net":         "host",
+				"environment": map[string]interface{}{"FOO": "2", "BAR": "1", "BAZ": "3"},
+				"labels":      []interface{}{"label=one"},
+				"ulimits": map[string]interface{}{
+					"nproc":   65535,
+					"memlock": map[string]interface{}{"soft": 1024, "hard": 2048},
+				},
+			},
+		},
+		{
+			filename: "nested.yml",
+			service:  "myweb",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"command":     "/bin/true",
+				"net":         "host",
+				"environment": map[string]interface{}{"FOO": "2", "BAR": "2"},
+			},
+		},
+		{
+			filename: "verbose-and-shorthand.yml",
+			service:  "verbose",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"environment": map[string]interface{}{"BAR": "1", "FOO": "1"},
+			},
+		},
+		{
+			filename: "verbose-and-shorthand.yml",
+			service:  "shorthand",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"environment": map[string]interface{}{"BAR": "1", "FOO": "2"},
+			},
+		},
+		{
+			filename: "no-file-specified.yml",
+			service:  "myweb",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"environment": map[string]interface{}{"BAZ": "3", "BAR": "1"},
+			},
+		},
+		{
+			filename: "specify-file-as-self.yml",
+			service:  "myweb",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"environment": map[string]interface{}{"YEP": "1", "BAZ": "3", "BAR": "1"},
+			},
+		},
+		{
+			filename: "valid-common-config.yml",
+			service:  "myweb",
+			expected: map[string]interface{}{
+				"build":       ".",
+				"command":     "top",
+				"environment": []interface{}{"FOO=1"},
+			},
+		},
+		{
+			filename: "service-with-valid-composite-extends.yml",
+			service:  "myweb",
+			expected: map[string]interface{}{
+				"build":   ".",
+				"command": "top",
+			},
+		},
+		{
+			// the image of the extending service replaces the build of
+			// the extended one.
+			filename: "nonexistent-path-child.yml",
+			service:  "dnechild",
+			expected: map[string]interface{}{
+				"image":       "busybox",
+				"command":     "/bin/true",
+				"environment": map[string]interface{}{"FOO": "1", "BAR": "2"},
+			},
+		},
+		{
+			filename: "healthcheck-2.yml",
+			service:  "demo",
+			expected: map[string]interface{}{
+				"image": "foobar:latest",
+				"healthcheck": map[string]interface{}{
+					"test":     []interface{}{"CMD", "/health.sh"},
+					"interval": "10s",
+					"timeout":  "5s",
+					"retries":  36,
+				},
+			},
+		},
+		{
+			// variables are interpolated once extends are resolved.
+			filename: "valid-interpolation.yml",
+			service:  "myweb",
+			expected: map[string]interface{}{
+				"build":    ".",
+				"hostname": "host-${HOSTNAME_VALUE}",
+				"command":  "top",
+			},
+		},
+	}
+
+	for _, testcase := range testcases {
+		services, err := resolveExtendsFixture(t, testcase.filename)
+		assert.NilError(t, err, testcase.filename)
+		assert.Check(t, is.DeepEqual(testcase.expected, services[testcase.service]), "%s: %s", testcase.filename, testcase.service)
+	}
+}
+
+func TestResolveExtendsErrors(t *testing.T) {
+	testcases := []struct {
+		filename string
+		expected string
+	}{
+		{filename: "circle-1.yml", expected: "circular reference: web in the compose file extends other in circle-2.yml extends web in circle-1.yml extends other in circle-2.yml"},
+		{filename: "invalid-links.yml", expected: `cannot extend service "web" in the compose file: services with "links" cannot be extended`},
+		{filename: "invalid-net.yml", expected: `services with "net: container" cannot be extended`},
+		{filename: "invalid-net-v2.yml", expected: `services with "network_mode: service" cannot be extended`},
+		{filename: "invalid-volumes.yml", expected: `services with "volumes_from" cannot be extended`},
+		{filename: "nonexistent-service.yml", expected: `cannot extend service "foo": service not found in the compose file`},
+		{filename: "service-with-invalid-schema.yml", expected: "service myweb has neither an image nor a build context specified"},
+	}
+
+	for _, testcase := range testcases {
+		_, err := resolveExtendsFixture(t, testcase.filename)
+		assert.Check(t, is.ErrorContains(err, testcase.expected), testcase.filename)
+	}
+}
+
+func TestComposeWithAttachedExtendedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose-extends")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	composefile := filepath.Join(dir, "docker-compose.yml")
+	assert.NilError(t, ioutil.WriteFile(composefile, []byte(`version: "3.4"
+services:
+  web:
+    extends:
+      file: common/services.yml
+      service: web
+    environment:
+      MODE: production
+`), 0600))
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "common"), 0700))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "common", "services.yml"), []byte(`version: "3.4"
+services:
+  web:
+    extends:
+      service: base
+    image: nginx:1.17
+  base:
+    image: nginx
+    env_file: web.env
+    deploy:
+      replicas: 2
+`), 0600))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "common", "web.env"), []byte("MODE=debug\nLEVEL=info\n"), 0600))
+
+	input, err := LoadComposefile([]string{composefile})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(input.Files, 2))
+	assert.Check(t, is.Contains(input.Files, "common/services.yml"))
+	assert.Check(t, is.Contains(input.Files, "common/web.env"))
+
+	// the input is parsed without the files being around anymore
+	assert.NilError(t, os.RemoveAll(dir))
+	stack, err := ParseComposeInput(*input)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(stack.Spec.Services, 1))
+	web := stack.Spec.Services[0]
+	assert.Check(t, is.Equal("nginx:1.17", web.Image))
+	assert.Check(t, is.Equal(uint64(2), *web.Deploy.Replicas))
+	assert.Check(t, is.Equal("production", *web.Environment["MODE"]))
+	assert.Check(t, is.Equal("info", *web.Environment["LEVEL"]))
+}
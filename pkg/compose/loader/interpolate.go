@@ -5,37 +5,70 @@ import (
 	"strings"
 
 	interp "github.com/docker/stacks/pkg/compose/interpolation"
+	"github.com/docker/stacks/pkg/types"
 	"github.com/pkg/errors"
 )
 
-var interpolateTypeCastMapping = map[interp.Path]interp.Cast{
-	servicePath("configs", interp.PathMatchList, "mode"):             toInt,
-	servicePath("secrets", interp.PathMatchList, "mode"):             toInt,
-	servicePath("healthcheck", "retries"):                            toInt,
-	servicePath("healthcheck", "disable"):                            toBoolean,
-	servicePath("deploy", "replicas"):                                toInt,
-	servicePath("deploy", "update_config", "parallelism"):            toInt,
-	servicePath("deploy", "update_config", "max_failure_ratio"):      toFloat,
-	servicePath("deploy", "restart_policy", "max_attempts"):          toInt,
-	servicePath("ports", interp.PathMatchList, "target"):             toInt,
-	servicePath("ports", interp.PathMatchList, "published"):          toInt,
-	servicePath("ulimits", interp.PathMatchAll):                      toInt,
-	servicePath("ulimits", interp.PathMatchAll, "hard"):              toInt,
-	servicePath("ulimits", interp.PathMatchAll, "soft"):              toInt,
-	servicePath("privileged"):                                        toBoolean,
-	servicePath("read_only"):                                         toBoolean,
-	servicePath("stdin_open"):                                        toBoolean,
-	servicePath("tty"):                                               toBoolean,
-	servicePath("volumes", interp.PathMatchList, "read_only"):        toBoolean,
-	servicePath("volumes", interp.PathMatchList, "volume", "nocopy"): toBoolean,
-	iPath("networks", interp.PathMatchAll, "external"):               toBoolean,
-	iPath("networks", interp.PathMatchAll, "internal"):               toBoolean,
-	iPath("networks", interp.PathMatchAll, "attachable"):             toBoolean,
-	iPath("volumes", interp.PathMatchAll, "external"):                toBoolean,
-	iPath("secrets", interp.PathMatchAll, "external"):                toBoolean,
-	iPath("configs", interp.PathMatchAll, "external"):                toBoolean,
+// typedPaths are the paths of the options of compose files whose values are
+// not strings, with the type of their values. Variables used as the value of
+// these options are properties of this type.
+var typedPaths = map[interp.Path]types.PropertyType{
+	servicePath("configs", interp.PathMatchList, "mode"):             types.PropertyTypeInt,
+	servicePath("secrets", interp.PathMatchList, "mode"):             types.PropertyTypeInt,
+	servicePath("healthcheck", "retries"):                            types.PropertyTypeInt,
+	servicePath("healthcheck", "disable"):                            types.PropertyTypeBool,
+	servicePath("healthcheck", "interval"):                           types.PropertyTypeDuration,
+	servicePath("healthcheck", "timeout"):                            types.PropertyTypeDuration,
+	servicePath("healthcheck", "start_period"):                       types.PropertyTypeDuration,
+	servicePath("stop_grace_period"):                                 types.PropertyTypeDuration,
+	servicePath("deploy", "replicas"):                                types.PropertyTypeInt,
+	servicePath("deploy", "update_config", "parallelism"):            types.PropertyTypeInt,
+	servicePath("deploy", "update_config", "max_failure_ratio"):      types.PropertyTypeFloat,
+	servicePath("deploy", "update_config", "delay"):                  types.PropertyTypeDuration,
+	servicePath("deploy", "update_config", "monitor"):                types.PropertyTypeDuration,
+	servicePath("deploy", "rollback_config", "delay"):                types.PropertyTypeDuration,
+	servicePath("deploy", "rollback_config", "monitor"):              types.PropertyTypeDuration,
+	servicePath("deploy", "restart_policy", "max_attempts"):          types.PropertyTypeInt,
+	servicePath("deploy", "restart_policy", "delay"):                 types.PropertyTypeDuration,
+	servicePath("deploy", "restart_policy", "window"):                types.PropertyTypeDuration,
+	servicePath("ports", interp.PathMatchList, "target"):             types.PropertyTypePort,
+	servicePath("ports", interp.PathMatchList, "published"):          types.PropertyTypePort,
+	servicePath("ulimits", interp.PathMatchAll):                      types.PropertyTypeInt,
+	servicePath("ulimits", interp.PathMatchAll, "hard"):              types.PropertyTypeInt,
+	servicePath("ulimits", interp.PathMatchAll, "soft"):              types.PropertyTypeInt,
+	servicePath("privileged"):                                        types.PropertyTypeBool,
+	servicePath("read_only"):                                         types.PropertyTypeBool,
+	servicePath("stdin_open"):                                        types.PropertyTypeBool,
+	servicePath("tty"):                                               types.PropertyTypeBool,
+	servicePath("volumes", interp.PathMatchList, "read_only"):        types.PropertyTypeBool,
+	servicePath("volumes", interp.PathMatchList, "volume", "nocopy"): types.PropertyTypeBool,
+	iPath("networks", interp.PathMatchAll, "external"):               types.PropertyTypeBool,
+	iPath("networks", interp.PathMatchAll, "internal"):               types.PropertyTypeBool,
+	iPath("networks", interp.PathMatchAll, "attachable"):             types.PropertyTypeBool,
+	iPath("volumes", interp.PathMatchAll, "external"):                types.PropertyTypeBool,
+	iPath("secrets", interp.PathMatchAll, "external"):                types.PropertyTypeBool,
+	iPath("configs", interp.PathMatchAll, "external"):                types.PropertyTypeBool,
 }
 
+// typeCasts converts the values of typed options. Durations are parsed
+// along with the rest of the config, and are not cast.
+var typeCasts = map[types.PropertyType]interp.Cast{
+	types.PropertyTypeInt:   toInt,
+	types.PropertyTypeFloat: toFloat,
+	types.PropertyTypeBool:  toBoolean,
+	types.PropertyTypePort:  toInt,
+}
+
+var interpolateTypeCastMapping = func() map[interp.Path]interp.Cast {
+	mapping := map[interp.Path]interp.Cast{}
+	for path, propertyType := range typedPaths {
+		if cast, ok := typeCasts[propertyType]; ok {
+			mapping[path] = cast
+		}
+	}
+	return mapping
+}()
+
 func iPath(parts ...string) interp.Path {
 	return interp.NewPath(parts...)
 }
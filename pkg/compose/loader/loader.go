@@ -32,6 +32,9 @@ type Options struct {
 	SkipInterpolation bool
 	// Interpolation options
 	Interpolate *interp.Options
+	// If not nil, typed options whose values use variables are left out
+	// and their raw values recorded here by path, to be substituted later
+	TemplatedValues map[string]string
 }
 
 // ParseYAML reads the bytes from a file, parses the bytes into a mapping
@@ -83,10 +86,19 @@ func Load(configDetails types.ConfigDetails, options ...func(*Options)) (*types.
 			return nil, errors.Errorf("version mismatched between two composefiles : %v and %v", configDetails.Version, version)
 		}
 
+		configDict, err = resolveExtends(configDict, configDetails)
+		if err != nil {
+			return nil, err
+		}
+
 		if err := validateForbidden(configDict); err != nil {
 			return nil, err
 		}
 
+		if opts.TemplatedValues != nil {
+			configDict = extractTemplatedValues(configDict, opts.TemplatedValues)
+		}
+
 		if !opts.SkipInterpolation {
 			configDict, err = interpolateConfig(configDict, *opts.Interpolate)
 			if err != nil {
@@ -137,7 +149,7 @@ func loadSections(config map[string]interface{}, configDetails types.ConfigDetai
 		{
 			key: "services",
 			fnc: func(config map[string]interface{}) error {
-				cfg.Services, err = LoadServices(config, configDetails.WorkingDir, configDetails.LookupEnv)
+				cfg.Services, err = loadServices(config, configDetails.WorkingDir, configDetails.LookupEnv, configDetails.Files)
 				return err
 			},
 		},
@@ -206,6 +218,47 @@ func GetUnsupportedProperties(configDicts ...map[string]interface{}) []string {
 	return sortedKeys(unsupported)
 }
 
+// GetUnsupportedServiceProperties returns the unsupported properties used by
+// each service of the Compose files, indexed by service name.
+func GetUnsupportedServiceProperties(configDicts ...map[string]interface{}) map[string][]string {
+	return getServiceProperties(types.UnsupportedProperties, configDicts...)
+}
+
+// GetDeprecatedServiceProperties returns the deprecated properties used by
+// each service of the Compose files, indexed by service name.
+func GetDeprecatedServiceProperties(configDicts ...map[string]interface{}) map[string][]string {
+	properties := []string{}
+	for property := range types.DeprecatedProperties {
+		properties = append(properties, property)
+	}
+	return getServiceProperties(properties, configDicts...)
+}
+
+func getServiceProperties(properties []string, configDicts ...map[string]interface{}) map[string][]string {
+	used := map[string]map[string]bool{}
+
+	for _, configDict := range configDicts {
+		for name, service := range getServices(configDict) {
+			serviceDict := service.(map[string]interface{})
+			for _, property := range properties {
+				if _, isSet := serviceDict[property]; !isSet {
+					continue
+				}
+				if used[name] == nil {
+					used[name] = map[string]bool{}
+				}
+				used[name][property] = true
+			}
+		}
+	}
+
+	result := map[string][]string{}
+	for name, properties := range used {
+		result[name] = sortedKeys(properties)
+	}
+	return result
+}
+
 func sortedKeys(set map[string]bool) []string {
 	var keys []string
 	for key := range set {
@@ -306,6 +359,7 @@ func createTransformHook(additionalTransformers ...Transformer) mapstructure.Dec
 		reflect.TypeOf(map[string]*types.ServiceNetworkConfig{}): transformServiceNetworkMap,
 		reflect.TypeOf(types.MappingWithEquals{}):                transformMappingOrListFunc("=", true),
 		reflect.TypeOf(types.Labels{}):                           transformMappingOrListFunc("=", false),
+		reflect.TypeOf(types.Mapping{}):                          transformMappingOrListFunc("=", false),
 		reflect.TypeOf(types.MappingWithColon{}):                 transformMappingOrListFunc(":", false),
 		reflect.TypeOf(types.HostsList{}):                        transformListOrMappingFunc(":", false),
 		reflect.TypeOf(types.ServiceVolumeConfig{}):              transformServiceVolumeConfig,
@@ -377,10 +431,16 @@ func formatInvalidKeyError(keyPrefix string, key interface{}) error {
 // LoadServices produces a ServiceConfig map from a compose file Dict
 // the servicesDict is not validated if directly used. Use Load() to enable validation
 func LoadServices(servicesDict map[string]interface{}, workingDir string, lookupEnv template.Mapping) ([]types.ServiceConfig, error) {
+	return loadServices(servicesDict, workingDir, lookupEnv, nil)
+}
+
+// loadServices loads services, resolving their env files against files
+// unless it is nil.
+func loadServices(servicesDict map[string]interface{}, workingDir string, lookupEnv template.Mapping, files map[string][]byte) ([]types.ServiceConfig, error) {
 	var services []types.ServiceConfig
 
 	for name, serviceDef := range servicesDict {
-		serviceConfig, err := LoadService(name, serviceDef.(map[string]interface{}), workingDir, lookupEnv)
+		serviceConfig, err := loadService(name, serviceDef.(map[string]interface{}), workingDir, lookupEnv, files)
 		if err != nil {
 			return nil, err
 		}
@@ -393,13 +453,17 @@ func LoadServices(servicesDict map[string]interface{}, workingDir string, lookup
 // LoadService produces a single ServiceConfig from a compose file Dict
 // the serviceDict is not validated if directly used. Use Load() to enable validation
 func LoadService(name string, serviceDict map[string]interface{}, workingDir string, lookupEnv template.Mapping) (*types.ServiceConfig, error) {
+	return loadService(name, serviceDict, workingDir, lookupEnv, nil)
+}
+
+func loadService(name string, serviceDict map[string]interface{}, workingDir string, lookupEnv template.Mapping, files map[string][]byte) (*types.ServiceConfig, error) {
 	serviceConfig := &types.ServiceConfig{}
 	if err := Transform(serviceDict, serviceConfig); err != nil {
 		return nil, err
 	}
 	serviceConfig.Name = name
 
-	if err := resolveEnvironment(serviceConfig, workingDir, lookupEnv); err != nil {
+	if err := resolveEnvironment(serviceConfig, workingDir, lookupEnv, files); err != nil {
 		return nil, err
 	}
 
@@ -444,15 +508,24 @@ func updateEnvironment(environment map[string]*string, vars map[string]*string,
 	}
 }
 
-func resolveEnvironment(serviceConfig *types.ServiceConfig, workingDir string, lookupEnv template.Mapping) error {
+func resolveEnvironment(serviceConfig *types.ServiceConfig, workingDir string, lookupEnv template.Mapping, files map[string][]byte) error {
 	environment := make(map[string]*string)
 
 	if len(serviceConfig.EnvFile) > 0 {
 		var envVars []string
 
 		for _, file := range serviceConfig.EnvFile {
-			filePath := absPath(workingDir, file)
-			fileVars, err := opts.ParseEnvFile(filePath)
+			var fileVars []string
+			var err error
+			if files != nil {
+				data, ok := files[attachmentPath(file)]
+				if !ok {
+					return errors.Errorf("service %s: env file %s is not attached", serviceConfig.Name, file)
+				}
+				fileVars, err = opts.ParseEnvFileContent(file, data)
+			} else {
+				fileVars, err = opts.ParseEnvFile(absPath(workingDir, file))
+			}
 			if err != nil {
 				return err
 			}
@@ -650,6 +723,11 @@ func loadFileObjectConfig(name string, objType string, obj types.FileObjectConfi
 			}
 		}
 		// if not "external: true"
+	} else if details.Files != nil && obj.File != "" {
+		obj.File = attachmentPath(obj.File)
+		if _, ok := details.Files[obj.File]; !ok {
+			return obj, errors.Errorf("%s %s: file %s is not attached", objType, name, obj.File)
+		}
 	} else {
 		obj.File = absPath(details.WorkingDir, obj.File)
 	}
@@ -657,6 +735,12 @@ func loadFileObjectConfig(name string, objType string, obj types.FileObjectConfi
 	return obj, nil
 }
 
+// attachmentPath returns the key of a file referenced by a compose file in
+// the files attached to it.
+func attachmentPath(filePath string) string {
+	return path.Clean(filepath.ToSlash(filePath))
+}
+
 func absPath(workingDir string, filePath string) string {
 	if filepath.IsAbs(filePath) {
 		return filePath
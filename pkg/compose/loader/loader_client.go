@@ -3,6 +3,8 @@ package loader
 import (
 	"fmt"
 	"io/ioutil"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -18,19 +20,117 @@ import (
 // TODO - this file needs some refactoring
 
 // LoadComposefile will load the compose files into ComposeInput which can be sent to the server
-// for parsing into a Stack representation
+// for parsing into a Stack representation. The env files and the files of
+// secrets and configs referenced by the compose files are attached to the
+// input, along with the files extended by their services. As with docker
+// stack deploy, relative paths are relative to the directory of the first
+// compose file, and to the directory of extended files for the paths they
+// hold.
 func LoadComposefile(composefiles []string) (*types.ComposeInput, error) {
 	input := types.ComposeInput{}
+	workingDir := ""
+	if len(composefiles) > 0 {
+		workingDir = filepath.Dir(composefiles[0])
+	}
+	attach := func(filePath, referrer string) ([]byte, error) {
+		if input.Files == nil {
+			input.Files = map[string][]byte{}
+		}
+		data, err := ioutil.ReadFile(absPath(workingDir, filePath))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read file referenced by %s", referrer)
+		}
+		input.Files[filePath] = data
+		return data, nil
+	}
+
 	for _, filename := range composefiles {
 		bytes, err := ioutil.ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
 		input.ComposeFiles = append(input.ComposeFiles, string(bytes))
+
+		dict, err := ParseYAML(bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid compose file %s", filename)
+		}
+		files, extended := referencedFiles(dict, ".", true)
+		for _, file := range files {
+			if _, ok := input.Files[file]; ok {
+				continue
+			}
+			if _, err := attach(file, filename); err != nil {
+				return nil, err
+			}
+		}
+
+		// extended files may reference other files in turn.
+		for len(extended) > 0 {
+			file := extended[0]
+			extended = extended[1:]
+			if _, ok := input.Files[file]; ok {
+				continue
+			}
+			data, err := attach(file, filename)
+			if err != nil {
+				return nil, err
+			}
+			extendedDict, err := ParseYAML(data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid extended file %s", file)
+			}
+			moreFiles, moreExtended := referencedFiles(extendedDict, path.Dir(file), false)
+			for _, moreFile := range moreFiles {
+				if _, ok := input.Files[moreFile]; ok {
+					continue
+				}
+				if _, err := attach(moreFile, file); err != nil {
+					return nil, err
+				}
+			}
+			extended = append(extended, moreExtended...)
+		}
 	}
 	return &input, nil
 }
 
+// referencedFiles returns the paths, relative to the working directory, of
+// the files referenced by a compose file located in dir: the env files of
+// its services and, if objects is set, the files of its secrets and
+// configs. The files extended by its services are returned apart.
+func referencedFiles(dict map[string]interface{}, dir string, objects bool) ([]string, []string) {
+	files := []string{}
+	extended := []string{}
+	for _, service := range servicesOf(dict) {
+		serviceDict, _ := service.(map[string]interface{})
+		for _, file := range toList(serviceDict["env_file"]) {
+			if file, ok := file.(string); ok {
+				files = append(files, joinPath(dir, file))
+			}
+		}
+		if extends, ok := serviceDict["extends"].(map[string]interface{}); ok {
+			if file, ok := extends["file"].(string); ok {
+				extended = append(extended, joinPath(dir, file))
+			}
+		}
+	}
+	if objects {
+		for _, section := range []string{"secrets", "configs"} {
+			sectionDict, _ := dict[section].(map[string]interface{})
+			for _, object := range sectionDict {
+				objectDict, _ := object.(map[string]interface{})
+				if file, ok := objectDict["file"].(string); ok {
+					files = append(files, joinPath(dir, file))
+				}
+			}
+		}
+	}
+	sort.Strings(files)
+	sort.Strings(extended)
+	return files, extended
+}
+
 // TODO Remainder of this is server side logic that should move someplace else...
 
 // ParseComposeInput will convert the ComposeInput into the StackCreate type
@@ -38,6 +138,8 @@ func LoadComposefile(composefiles []string) (*types.ComposeInput, error) {
 // listed in the StackSpec.PropertyValues field, so they can be filled
 // in prior to sending the StackCreate to the Create API.  If defaults
 // are defined in the compose file(s) those defaults will be included.
+// The StackSpec.Properties field describes those variables, with their
+// types, defaults and whether they are required.
 func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 	if len(input.ComposeFiles) == 0 {
 		return nil, nil
@@ -52,21 +154,24 @@ func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 
 	// Wire up interpolation as a no-op so we can track the variables in play and default values
 	propertiesMap := map[string]string{}
+	recordVariable := func(key string) (string, bool) {
+		vals := strings.SplitN(key, "=", 2)
+		if len(vals) > 1 {
+			propertiesMap[vals[0]] = vals[1]
+		} else if _, exists := propertiesMap[vals[0]]; !exists {
+			propertiesMap[vals[0]] = ""
+		}
+		return "", false
+	}
 	interpolateOpts := interpolation.Options{
-		LookupValue: func(key string) (string, bool) {
-			vals := strings.SplitN(key, "=", 2)
-			if len(vals) > 1 {
-				propertiesMap[vals[0]] = vals[1]
-			} else if _, exists := propertiesMap[vals[0]]; !exists {
-				propertiesMap[vals[0]] = ""
-			}
-			return "", false
-		},
-		Substitute: defaults.RecordVariablesWithDefaults,
+		LookupValue: recordVariable,
+		Substitute:  defaults.RecordVariablesWithDefaults,
 	}
+	templatedValues := map[string]string{}
 	config, err := Load(configDetails, func(opts *Options) {
 		opts.Interpolate = &interpolateOpts
 		opts.SkipValidation = true
+		opts.TemplatedValues = templatedValues
 	})
 	if err != nil {
 		if fpe, ok := err.(*ForbiddenPropertiesError); ok {
@@ -77,17 +182,22 @@ func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 		return nil, err
 	}
 
-	unsupportedProperties := GetUnsupportedProperties(dicts...)
-	if len(unsupportedProperties) > 0 {
-		fmt.Printf("Ignoring unsupported options: %s\n\n",
-			strings.Join(unsupportedProperties, ", "))
+	schema, err := propertySchema(dicts, configDetails)
+	if err != nil {
+		return nil, err
 	}
 
-	deprecatedProperties := GetDeprecatedProperties(dicts...)
-	if len(deprecatedProperties) > 0 {
-		fmt.Printf("Ignoring deprecated options:\n\n%s\n\n",
-			propertyWarnings(deprecatedProperties))
+	// typed options using variables are not interpolated, so their
+	// variables are recorded separately.
+	for _, value := range templatedValues {
+		if _, err := defaults.RecordVariablesWithDefaults(value, recordVariable); err != nil {
+			return nil, err
+		}
 	}
+	if len(templatedValues) == 0 {
+		templatedValues = nil
+	}
+
 	properties := []string{}
 	for key, value := range propertiesMap {
 		if len(value) > 0 {
@@ -98,17 +208,57 @@ func ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
 	}
 	return &types.StackCreate{
 		Spec: types.StackSpec{
-			Services:       config.Services,
-			Secrets:        config.Secrets,
-			Configs:        config.Configs,
-			Networks:       config.Networks,
-			Volumes:        config.Volumes,
-			PropertyValues: properties,
+			Services:        config.Services,
+			Secrets:         config.Secrets,
+			Configs:         config.Configs,
+			Networks:        config.Networks,
+			Volumes:         config.Volumes,
+			PropertyValues:  properties,
+			Properties:      schema,
+			TemplatedValues: templatedValues,
+			Files:           objectFiles(config, configDetails.Files),
 		},
+		Warnings: propertyStackWarnings(dicts...),
 	}, nil
 
 }
 
+// objectFiles returns the attached files of the secrets and configs of a
+// config, which are needed to create them.
+func objectFiles(config *composetypes.Config, attached map[string][]byte) map[string][]byte {
+	var files map[string][]byte
+	add := func(obj composetypes.FileObjectConfig) {
+		if obj.External.External || obj.File == "" {
+			return
+		}
+		if files == nil {
+			files = map[string][]byte{}
+		}
+		files[obj.File] = attached[obj.File]
+	}
+	for _, secret := range config.Secrets {
+		add(composetypes.FileObjectConfig(secret))
+	}
+	for _, config := range config.Configs {
+		add(composetypes.FileObjectConfig(config))
+	}
+	return files
+}
+
+// propertySchema returns the properties of compose files, including the
+// ones of the services they extend.
+func propertySchema(dicts []map[string]interface{}, configDetails composetypes.ConfigDetails) ([]types.StackProperty, error) {
+	resolved := []map[string]interface{}{}
+	for _, dict := range dicts {
+		dict, err := resolveExtends(dict, configDetails)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, dict)
+	}
+	return GetPropertySchema(resolved...)
+}
+
 func getDictsFrom(configFiles []composetypes.ConfigFile) []map[string]interface{} {
 	dicts := []map[string]interface{}{}
 
@@ -119,6 +269,47 @@ func getDictsFrom(configFiles []composetypes.ConfigFile) []map[string]interface{
 	return dicts
 }
 
+// propertyStackWarnings reports the unsupported and deprecated options of
+// the services of compose files, which are ignored.
+func propertyStackWarnings(configDicts ...map[string]interface{}) []types.StackWarning {
+	var warnings []types.StackWarning
+
+	unsupported := GetUnsupportedServiceProperties(configDicts...)
+	for _, service := range sortedServiceNames(unsupported) {
+		for _, property := range unsupported[service] {
+			warnings = append(warnings, types.StackWarning{
+				Code:     types.StackWarningUnsupportedProperty,
+				Service:  service,
+				Property: property,
+				Message:  fmt.Sprintf("%s is not supported and is ignored", property),
+			})
+		}
+	}
+
+	deprecated := GetDeprecatedServiceProperties(configDicts...)
+	for _, service := range sortedServiceNames(deprecated) {
+		for _, property := range deprecated[service] {
+			warnings = append(warnings, types.StackWarning{
+				Code:     types.StackWarningDeprecatedProperty,
+				Service:  service,
+				Property: property,
+				Message:  composetypes.DeprecatedProperties[property],
+			})
+		}
+	}
+
+	return warnings
+}
+
+func sortedServiceNames(properties map[string][]string) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func propertyWarnings(properties map[string]string) string {
 	var msgs []string
 	for name, description := range properties {
@@ -136,6 +327,12 @@ func getConfigDetails(input types.ComposeInput) (composetypes.ConfigDetails, err
 	if err != nil {
 		return details, err
 	}
+	// Referenced files are only resolved against the attached files, and
+	// never read from the filesystem of the server.
+	details.Files = input.Files
+	if details.Files == nil {
+		details.Files = map[string][]byte{}
+	}
 	// Take the first file version (2 files can't have different version)
 	details.Version = schema.Version(details.ConfigFiles[0].Config)
 	return details, err
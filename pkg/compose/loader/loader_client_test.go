@@ -2,10 +2,16 @@ package loader
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/docker/stacks/pkg/compose/template"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/types"
 
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
@@ -73,3 +79,155 @@ func TestComposeWithEnv(t *testing.T) {
 		// TODO - deeper inspection of the results, default values, etc.
 	*/
 }
+
+func TestComposeWithAttachedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose-files")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	composefile := filepath.Join(dir, "docker-compose.yml")
+	assert.NilError(t, ioutil.WriteFile(composefile, []byte(`version: "3.4"
+services:
+  web:
+    image: nginx
+    env_file: ./web.env
+    secrets: [password]
+secrets:
+  password:
+    file: ./secrets/password.txt
+configs:
+  site:
+    file: site.conf
+`), 0600))
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "secrets"), 0700))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "web.env"), []byte("MODE=production\n"), 0600))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "secrets", "password.txt"), []byte("s3cr3t"), 0600))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "site.conf"), []byte("server {}"), 0600))
+
+	input, err := LoadComposefile([]string{composefile})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string][]byte{
+		"web.env":              []byte("MODE=production\n"),
+		"secrets/password.txt": []byte("s3cr3t"),
+		"site.conf":            []byte("server {}"),
+	}, input.Files)
+
+	// the input is parsed without the files being around anymore
+	assert.NilError(t, os.RemoveAll(dir))
+	stack, err := ParseComposeInput(*input)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("production", *stack.Spec.Services[0].Environment["MODE"]))
+	assert.Check(t, is.Equal("secrets/password.txt", stack.Spec.Secrets["password"].File))
+	assert.DeepEqual(t, map[string][]byte{
+		"secrets/password.txt": []byte("s3cr3t"),
+		"site.conf":            []byte("server {}"),
+	}, stack.Spec.Files)
+
+	// references are never resolved against the filesystem of the server
+	delete(input.Files, "web.env")
+	_, err = ParseComposeInput(*input)
+	assert.Check(t, is.ErrorContains(err, "env file ./web.env is not attached"))
+
+	_, err = ParseComposeInput(types.ComposeInput{
+		ComposeFiles: []string{"version: \"3.4\"\nconfigs:\n  passwd:\n    file: /etc/passwd\n"},
+	})
+	assert.Check(t, is.ErrorContains(err, "config passwd: file /etc/passwd is not attached"))
+}
+
+func TestParseComposeInputWarnings(t *testing.T) {
+	stack, err := ParseComposeInput(types.ComposeInput{
+		ComposeFiles: []string{`version: "3.8"
+services:
+  web:
+    image: nginx
+    container_name: web
+    privileged: true
+    cap_add: [NET_ADMIN]
+  db:
+    image: postgres
+    shm_size: 64M
+  cache:
+    image: redis
+`},
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]types.StackWarning{
+		{
+			Code:     types.StackWarningUnsupportedProperty,
+			Service:  "db",
+			Property: "shm_size",
+			Message:  "shm_size is not supported and is ignored",
+		},
+		{
+			Code:     types.StackWarningUnsupportedProperty,
+			Service:  "web",
+			Property: "cap_add",
+			Message:  "cap_add is not supported and is ignored",
+		},
+		{
+			Code:     types.StackWarningUnsupportedProperty,
+			Service:  "web",
+			Property: "privileged",
+			Message:  "privileged is not supported and is ignored",
+		},
+		{
+			Code:     types.StackWarningDeprecatedProperty,
+			Service:  "web",
+			Property: "container_name",
+			Message:  "Setting the container name is not supported.",
+		},
+	}, stack.Warnings))
+}
+
+func TestParseComposeInputSysctls(t *testing.T) {
+	for _, sysctls := range []string{
+		"{net.core.somaxconn: 1024, net.ipv4.tcp_syncookies: 0}",
+		"[net.core.somaxconn=1024, net.ipv4.tcp_syncookies=0]",
+	} {
+		stack, err := ParseComposeInput(types.ComposeInput{
+			ComposeFiles: []string{`version: "3.8"
+services:
+  web:
+    image: nginx
+    sysctls: ` + sysctls + `
+`},
+		})
+		assert.NilError(t, err, sysctls)
+		assert.Check(t, is.DeepEqual(composetypes.Mapping{
+			"net.core.somaxconn":      "1024",
+			"net.ipv4.tcp_syncookies": "0",
+		}, stack.Spec.Services[0].Sysctls), sysctls)
+	}
+}
+
+func TestParseComposeInputProperties(t *testing.T) {
+	stack, err := ParseComposeInput(types.ComposeInput{
+		ComposeFiles: []string{`version: "3.8"
+x-properties:
+  TAG:
+    description: Tag of the web image
+services:
+  web:
+    image: nginx:${TAG:-latest}
+    ports:
+      - "${PORT:?the web port is required}:80"
+    deploy:
+      replicas: ${REPLICAS:-2}
+`},
+	})
+	assert.NilError(t, err)
+	latest, replicas := "latest", "2"
+	assert.Check(t, is.DeepEqual([]types.StackProperty{
+		{Name: "PORT", Type: types.PropertyTypePort, Required: true, RequiredOperator: ":?", ErrorMessage: "the web port is required"},
+		{Name: "REPLICAS", Type: types.PropertyTypeInt, Default: &replicas, DefaultOperator: ":-"},
+		{Name: "TAG", Description: "Tag of the web image", Type: types.PropertyTypeString, Default: &latest, DefaultOperator: ":-"},
+	}, stack.Spec.Properties))
+
+	// typed options using variables are kept as is
+	assert.Check(t, is.DeepEqual(map[string]string{
+		"services.web.deploy.replicas": "${REPLICAS:-2}",
+	}, stack.Spec.TemplatedValues))
+	assert.Check(t, is.Nil(stack.Spec.Services[0].Deploy.Replicas))
+	sort.Strings(stack.Spec.PropertyValues)
+	assert.Check(t, is.DeepEqual([]string{"PORT", "REPLICAS=2", "TAG=latest"}, stack.Spec.PropertyValues))
+}
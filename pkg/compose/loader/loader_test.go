@@ -738,6 +738,31 @@ services:
 	assert.Check(t, is.DeepEqual([]string{"build", "links", "pid"}, unsupported))
 }
 
+func TestUnsupportedServiceProperties(t *testing.T) {
+	dict, err := ParseYAML([]byte(`
+version: "3.8"
+services:
+  web:
+    image: web
+    cap_add: [NET_ADMIN]
+    privileged: true
+    sysctls:
+      - net.core.somaxconn=1024
+  db:
+    image: db
+    shm_size: 64M
+  cache:
+    image: redis
+`))
+	assert.NilError(t, err)
+
+	unsupported := GetUnsupportedServiceProperties(dict)
+	assert.Check(t, is.DeepEqual(map[string][]string{
+		"web": {"cap_add", "privileged"},
+		"db":  {"shm_size"},
+	}, unsupported))
+}
+
 func TestBuildProperties(t *testing.T) {
 	dict, err := ParseYAML([]byte(`
 version: "3"
@@ -800,9 +825,8 @@ services:
 	assert.ErrorType(t, err, reflect.TypeOf(&ForbiddenPropertiesError{}))
 
 	props := err.(*ForbiddenPropertiesError).Properties
-	assert.Check(t, is.Len(props, 2))
+	assert.Check(t, is.Len(props, 1))
 	assert.Check(t, is.Contains(props, "volume_driver"))
-	assert.Check(t, is.Contains(props, "extends"))
 }
 
 func TestInvalidResource(t *testing.T) {
@@ -0,0 +1,322 @@
+package loader
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	interp "github.com/docker/stacks/pkg/compose/interpolation"
+	"github.com/docker/stacks/pkg/compose/template"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// propertiesExtension is the top-level extension of compose files which
+// describes their properties, such as:
+//
+//	x-properties:
+//	  REPLICAS:
+//	    description: Number of replicas of the web service
+//	    type: int
+//	  DB_PASSWORD:
+//	    sensitive: true
+const propertiesExtension = "x-properties"
+
+// variablePattern splits the substitution of a variable, such as
+// "NAME:-default" or "NAME?error", into its name, operator and argument.
+var variablePattern = regexp.MustCompile(`^(?i)([_a-z][_a-z0-9]*)(?:(:?[-?])(.*))?$`)
+
+// propertyBuilder accumulates the uses of a property in compose files.
+type propertyBuilder struct {
+	property types.StackProperty
+	types    map[types.PropertyType]bool
+}
+
+// GetPropertySchema returns the properties of compose files, which are the
+// variables they use, sorted by name. Properties are typed after the options
+// they are the value of, and string if they have conflicting types or are
+// part of larger strings. Their description and type may also be declared
+// in the x-properties extension of the compose files, along with whether
+// they are sensitive.
+func GetPropertySchema(configDicts ...map[string]interface{}) ([]types.StackProperty, error) {
+	builders := map[string]*propertyBuilder{}
+	get := func(name string) *propertyBuilder {
+		if builders[name] == nil {
+			builders[name] = &propertyBuilder{
+				property: types.StackProperty{Name: name},
+				types:    map[types.PropertyType]bool{},
+			}
+		}
+		return builders[name]
+	}
+
+	for _, configDict := range configDicts {
+		if err := declareProperties(configDict[propertiesExtension], get); err != nil {
+			return nil, err
+		}
+		for _, key := range sortedMapKeys(configDict) {
+			if key == propertiesExtension {
+				continue
+			}
+			if err := recordProperties(configDict[key], interp.NewPath(key), get); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	properties := []types.StackProperty{}
+	for _, name := range sortedBuilderNames(builders) {
+		builder := builders[name]
+		if builder.property.Type == "" {
+			builder.property.Type = types.PropertyTypeString
+			if len(builder.types) == 1 {
+				for propertyType := range builder.types {
+					builder.property.Type = propertyType
+				}
+			}
+		}
+		properties = append(properties, builder.property)
+	}
+	return properties, nil
+}
+
+// declareProperties records the descriptions, types and sensitivity of the
+// properties declared in the x-properties extension of a compose file.
+func declareProperties(extension interface{}, get func(string) *propertyBuilder) error {
+	if extension == nil {
+		return nil
+	}
+	declarations, ok := extension.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("%s must be a mapping", propertiesExtension)
+	}
+	for _, name := range sortedMapKeys(declarations) {
+		declaration, ok := declarations[name].(map[string]interface{})
+		if !ok {
+			return errors.Errorf("%s.%s must be a mapping", propertiesExtension, name)
+		}
+		builder := get(name)
+		if description, ok := declaration["description"]; ok {
+			builder.property.Description = fmt.Sprint(description)
+		}
+		if propertyType, ok := declaration["type"]; ok {
+			builder.property.Type = types.PropertyType(fmt.Sprint(propertyType))
+			if _, ok := propertyValidators[builder.property.Type]; !ok {
+				return errors.Errorf("%s.%s: unknown type %q", propertiesExtension, name, propertyType)
+			}
+		}
+		if sensitive, ok := declaration["sensitive"]; ok {
+			builder.property.Sensitive, ok = sensitive.(bool)
+			if !ok {
+				return errors.Errorf("%s.%s.sensitive must be a boolean", propertiesExtension, name)
+			}
+		}
+	}
+	return nil
+}
+
+// recordProperties records the variables used in a value of a compose file,
+// found at path.
+func recordProperties(value interface{}, path interp.Path, get func(string) *propertyBuilder) error {
+	switch value := value.(type) {
+	case string:
+		return recordStringProperties(value, path, get)
+	case map[string]interface{}:
+		for _, key := range sortedMapKeys(value) {
+			if err := recordProperties(value[key], path.Next(key), get); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := recordProperties(item, path.Next(interp.PathMatchList), get); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func recordStringProperties(value string, path interp.Path, get func(string) *propertyBuilder) error {
+	matches := template.DefaultPattern.FindAllStringSubmatch(value, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	// a variable which is the whole value of a typed option has its type.
+	// The published and target ports of the short syntax of ports are
+	// typed too.
+	valueType := pathType(path)
+	shortPort := path.Matches(servicePath("ports", interp.PathMatchList))
+
+	for _, match := range matches {
+		groups := matchGroups(match, template.DefaultPattern)
+		if groups["escaped"] != "" {
+			continue
+		}
+		substitution := groups["named"]
+		if substitution == "" {
+			substitution = groups["braced"]
+		}
+		parts := variablePattern.FindStringSubmatch(substitution)
+		if parts == nil {
+			return errors.Errorf("invalid interpolation format for %s: %#v", path, value)
+		}
+
+		builder := get(parts[1])
+		switch parts[2] {
+		case types.DefaultOperator, types.DefaultIfUnsetOperator:
+			if builder.property.Default == nil {
+				defaultValue := parts[3]
+				builder.property.Default = &defaultValue
+				builder.property.DefaultOperator = parts[2]
+			}
+		case types.RequiredOperator, types.RequiredIfUnsetOperator:
+			// a property required to be non-empty by any of its uses
+			// must be non-empty
+			if !builder.property.Required || parts[2] == types.RequiredOperator && builder.property.RequiredOperator != types.RequiredOperator {
+				builder.property.Required = true
+				builder.property.RequiredOperator = parts[2]
+				builder.property.ErrorMessage = parts[3]
+			}
+		}
+
+		if shortPort {
+			if isPortVariable(value, match[0]) {
+				builder.types[types.PropertyTypePort] = true
+			}
+		} else if valueType != "" && match[0] == value {
+			builder.types[valueType] = true
+		}
+	}
+	return nil
+}
+
+// pathType returns the type of the values of the option at path, which is
+// empty for string options.
+func pathType(path interp.Path) types.PropertyType {
+	for pattern, propertyType := range typedPaths {
+		if path.Matches(pattern) {
+			return propertyType
+		}
+	}
+	return ""
+}
+
+// isPortVariable returns true if a variable is the published or target port
+// of a port in the short syntax, such as "127.0.0.1:${PORT}:80/tcp". A
+// variable which is the whole port may be any port mapping.
+func isPortVariable(port, variable string) bool {
+	// variables may contain colons and slashes, so they are replaced before
+	// splitting the port
+	port = template.DefaultPattern.ReplaceAllStringFunc(port, func(match string) string {
+		if match == variable {
+			return "\x00"
+		}
+		return "_"
+	})
+	if i := strings.LastIndex(port, "/"); i >= 0 {
+		port = port[:i]
+	}
+	parts := strings.Split(port, ":")
+	if len(parts) == 1 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "\x00" {
+			return true
+		}
+	}
+	return false
+}
+
+// propertyValidators check the values of properties of each type.
+var propertyValidators = map[types.PropertyType]func(string) error{
+	types.PropertyTypeString: func(string) error { return nil },
+	types.PropertyTypeInt:    castValidator(toInt),
+	types.PropertyTypeFloat:  castValidator(toFloat),
+	types.PropertyTypeBool:   castValidator(toBoolean),
+	types.PropertyTypePort: func(value string) error {
+		// the value may cover both the published and target ports of a
+		// port in the short syntax, as in "8000-8010:80"
+		parts := strings.Split(value, ":")
+		if len(parts) > 2 {
+			return errors.Errorf("invalid port: %s", value)
+		}
+		for _, part := range parts {
+			if !isPortRange(part) {
+				return errors.Errorf("invalid port: %s", value)
+			}
+		}
+		return nil
+	},
+	types.PropertyTypeDuration: func(value string) error {
+		_, err := time.ParseDuration(value)
+		return err
+	},
+}
+
+// isPortRange returns true if value is a port, or a range of ports such as
+// "8000-8010".
+func isPortRange(value string) bool {
+	bounds := strings.SplitN(value, "-", 2)
+	start, err := strconv.ParseUint(bounds[0], 10, 16)
+	if err != nil || start == 0 {
+		return false
+	}
+	if len(bounds) == 1 {
+		return true
+	}
+	end, err := strconv.ParseUint(bounds[1], 10, 16)
+	return err == nil && end >= start
+}
+
+func castValidator(cast interp.Cast) func(string) error {
+	return func(value string) error {
+		_, err := cast(value)
+		return err
+	}
+}
+
+// ValidatePropertyValue returns an error if a value is not valid for a
+// property of the given type. Untyped properties are strings.
+func ValidatePropertyValue(propertyType types.PropertyType, value string) error {
+	if propertyType == "" {
+		propertyType = types.PropertyTypeString
+	}
+	validate, ok := propertyValidators[propertyType]
+	if !ok {
+		return errors.Errorf("unknown property type %q", propertyType)
+	}
+	return validate(value)
+}
+
+func matchGroups(matches []string, pattern *regexp.Regexp) map[string]string {
+	groups := make(map[string]string)
+	for i, name := range pattern.SubexpNames()[1:] {
+		groups[name] = matches[i+1]
+	}
+	return groups
+}
+
+func sortedMapKeys(mapping map[string]interface{}) []string {
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedBuilderNames(builders map[string]*propertyBuilder) []string {
+	names := make([]string, 0, len(builders))
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,113 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/docker/stacks/pkg/types"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestGetPropertySchema(t *testing.T) {
+	dict, err := ParseYAML([]byte(`version: "3.8"
+x-properties:
+  REPLICAS:
+    description: Number of web tasks
+  LEVEL:
+    type: int
+  PASSWORD:
+    sensitive: true
+services:
+  web:
+    image: nginx
+    ports:
+      - "127.0.0.1:${PORT-8080}:80/tcp"
+      - ${PORTS}
+      - target: ${TARGET}
+    stop_grace_period: ${TIMEOUT}
+    healthcheck:
+      timeout: ${TIMEOUT}
+      retries: ${RETRIES}
+    deploy:
+      replicas: ${REPLICAS?}
+      labels:
+        replicas: ${REPLICAS:?replicas must not be empty}
+    environment:
+      ESCAPED: $$ESCAPED
+      LABEL: web-${NAME}
+      LEVEL: ${LEVEL}
+      PASSWORD: ${PASSWORD}
+  db:
+    image: postgres
+    stop_grace_period: ${RETRIES}
+`))
+	assert.NilError(t, err)
+	properties, err := GetPropertySchema(dict)
+	assert.NilError(t, err)
+
+	defaultPort := "8080"
+	assert.Check(t, is.DeepEqual([]types.StackProperty{
+		{Name: "LEVEL", Type: types.PropertyTypeInt},
+		{Name: "NAME", Type: types.PropertyTypeString},
+		{Name: "PASSWORD", Type: types.PropertyTypeString, Sensitive: true},
+		{Name: "PORT", Type: types.PropertyTypePort, Default: &defaultPort, DefaultOperator: "-"},
+		{Name: "PORTS", Type: types.PropertyTypeString},
+		{Name: "REPLICAS", Description: "Number of web tasks", Type: types.PropertyTypeInt, Required: true, RequiredOperator: ":?", ErrorMessage: "replicas must not be empty"},
+		{Name: "RETRIES", Type: types.PropertyTypeString},
+		{Name: "TARGET", Type: types.PropertyTypePort},
+		{Name: "TIMEOUT", Type: types.PropertyTypeDuration},
+	}, properties))
+}
+
+func TestGetPropertySchemaInvalidType(t *testing.T) {
+	dict, err := ParseYAML([]byte(`version: "3.8"
+x-properties:
+  REPLICAS:
+    type: number
+`))
+	assert.NilError(t, err)
+	_, err = GetPropertySchema(dict)
+	assert.Check(t, is.Error(err, `x-properties.REPLICAS: unknown type "number"`))
+}
+
+func TestGetPropertySchemaInvalidSensitive(t *testing.T) {
+	dict, err := ParseYAML([]byte(`version: "3.8"
+x-properties:
+  PASSWORD:
+    sensitive: maybe
+`))
+	assert.NilError(t, err)
+	_, err = GetPropertySchema(dict)
+	assert.Check(t, is.Error(err, "x-properties.PASSWORD.sensitive must be a boolean"))
+}
+
+func TestValidatePropertyValue(t *testing.T) {
+	for _, tc := range []struct {
+		propertyType types.PropertyType
+		value        string
+		valid        bool
+	}{
+		{propertyType: types.PropertyTypeString, value: "anything", valid: true},
+		{propertyType: types.PropertyTypeInt, value: "3", valid: true},
+		{propertyType: types.PropertyTypeInt, value: "three", valid: false},
+		{propertyType: types.PropertyTypeFloat, value: "0.5", valid: true},
+		{propertyType: types.PropertyTypeBool, value: "yes", valid: true},
+		{propertyType: types.PropertyTypeBool, value: "maybe", valid: false},
+		{propertyType: types.PropertyTypePort, value: "8080", valid: true},
+		{propertyType: types.PropertyTypePort, value: "0", valid: false},
+		{propertyType: types.PropertyTypePort, value: "65536", valid: false},
+		{propertyType: types.PropertyTypePort, value: "8000-8010", valid: true},
+		{propertyType: types.PropertyTypePort, value: "8000-8010:80", valid: true},
+		{propertyType: types.PropertyTypePort, value: "8000-8010:80-90", valid: true},
+		{propertyType: types.PropertyTypePort, value: "8010-8000", valid: false},
+		{propertyType: types.PropertyTypePort, value: "8000-", valid: false},
+		{propertyType: types.PropertyTypePort, value: "127.0.0.1:8000:80", valid: false},
+		{propertyType: types.PropertyTypeDuration, value: "1m30s", valid: true},
+		{propertyType: types.PropertyTypeDuration, value: "90", valid: false},
+		{propertyType: "number", value: "1", valid: false},
+	} {
+		err := ValidatePropertyValue(tc.propertyType, tc.value)
+		assert.Check(t, (err == nil) == tc.valid, "%s %q: %v", tc.propertyType, tc.value, err)
+	}
+}
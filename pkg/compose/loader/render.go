@@ -0,0 +1,137 @@
+package loader
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// renderVersion is the version of the compose files rendered from stacks.
+const renderVersion = "3.9"
+
+// RenderComposeFile returns a compose file describing a StackSpec, which
+// ParseComposeInput loads back into an equivalent spec. Variables are kept
+// as is, including the ones of the typed options held in TemplatedValues,
+// and the properties of the spec are declared in the x-properties
+// extension.
+//
+// Secrets and configs read from files keep their file references. The
+// contents of files are not part of the compose file, and the API never
+// returns them, so a rendered stack using such secrets or configs only
+// loads back when the files are provided along with the compose file.
+func RenderComposeFile(spec types.StackSpec) ([]byte, error) {
+	data, err := yaml.Marshal(composetypes.Config{
+		Version:  renderVersion,
+		Services: spec.Services,
+		Networks: spec.Networks,
+		Volumes:  spec.Volumes,
+		Secrets:  spec.Secrets,
+		Configs:  spec.Configs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	dict, err := ParseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// ports using variables are rendered with the short syntax they were
+	// written with.
+	services, _ := dict["services"].(map[string]interface{})
+	for _, service := range spec.Services {
+		serviceDict, _ := services[service.Name].(map[string]interface{})
+		ports, _ := serviceDict["ports"].([]interface{})
+		for i, port := range service.Ports {
+			if port.Variable != "" && i < len(ports) {
+				ports[i] = port.Variable
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(spec.TemplatedValues))
+	for path := range spec.TemplatedValues {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := SetTemplatedValue(dict, path, spec.TemplatedValues[path]); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(spec.Properties) > 0 {
+		dict[propertiesExtension] = renderProperties(spec.Properties)
+	}
+
+	// the version comes first, as in most compose files.
+	document := yaml.MapSlice{{Key: "version", Value: renderVersion}}
+	for _, key := range sortedMapKeys(dict) {
+		if key != "version" {
+			document = append(document, yaml.MapItem{Key: key, Value: dict[key]})
+		}
+	}
+	return yaml.Marshal(document)
+}
+
+// renderProperties returns the declarations of properties in the
+// x-properties extension.
+func renderProperties(properties []types.StackProperty) map[string]interface{} {
+	declarations := map[string]interface{}{}
+	for _, property := range properties {
+		declaration := map[string]interface{}{}
+		if property.Description != "" {
+			declaration["description"] = property.Description
+		}
+		if property.Type != "" {
+			declaration["type"] = string(property.Type)
+		}
+		if property.Sensitive {
+			declaration["sensitive"] = true
+		}
+		declarations[property.Name] = declaration
+	}
+	return declarations
+}
+
+// SetTemplatedValue sets a value at a path of TemplatedValues, such as
+// services.web.deploy.replicas, in a compose file or in a StackSpec decoded
+// from JSON. Missing mappings along the path are created.
+func SetTemplatedValue(document map[string]interface{}, path string, value interface{}) error {
+	if err := setPath(document, strings.Split(path, "."), value); err != nil {
+		return errors.Wrapf(err, "unable to set %s", path)
+	}
+	return nil
+}
+
+func setPath(document interface{}, path []string, value interface{}) error {
+	key, last := path[0], len(path) == 1
+	switch document := document.(type) {
+	case map[string]interface{}:
+		if last {
+			document[key] = value
+			return nil
+		}
+		if document[key] == nil {
+			document[key] = map[string]interface{}{}
+		}
+		return setPath(document[key], path[1:], value)
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(document) {
+			return errors.Errorf("no item %s in list", key)
+		}
+		if last {
+			document[index] = value
+			return nil
+		}
+		return setPath(document[index], path[1:], value)
+	}
+	return errors.Errorf("%s is not a mapping or a list", key)
+}
@@ -0,0 +1,97 @@
+package loader
+
+import (
+	"sort"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestRenderComposeFileRoundTrip(t *testing.T) {
+	input := types.ComposeInput{
+		ComposeFiles: []string{`version: "3.8"
+x-properties:
+  PASSWORD:
+    description: Password of the database
+    sensitive: true
+services:
+  web:
+    image: nginx:${TAG:-latest}
+    ports:
+      - "${PORT:?the web port is required}:80"
+      - target: 443
+        published: 8443
+    read_only: ${READ_ONLY}
+    stop_grace_period: 20s
+    volumes:
+      - data:/data
+      - ${LOGS}
+    networks:
+      - front
+    healthcheck:
+      test: ["CMD", "curl", "localhost"]
+      interval: ${INTERVAL:-30s}
+      retries: 3
+    deploy:
+      replicas: ${REPLICAS:-2}
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 64M
+      placement:
+        constraints: [node.role == worker]
+    secrets:
+      - source: password
+        target: db_password
+        mode: 0400
+    configs:
+      - site
+    environment:
+      PASSWORD: ${PASSWORD}
+    x-custom: value
+networks:
+  front:
+    driver: overlay
+    attachable: true
+volumes:
+  data:
+    driver: local
+secrets:
+  password:
+    file: ./password.txt
+configs:
+  site:
+    external: true
+`},
+		Files: map[string][]byte{"password.txt": []byte("secret")},
+	}
+	create, err := ParseComposeInput(input)
+	assert.NilError(t, err)
+
+	// the API returns stacks without the contents of their files, so
+	// secrets read from files keep their file references only
+	files := create.Spec.Files
+	spec := create.Spec
+	spec.Files = nil
+	rendered, err := RenderComposeFile(spec)
+	assert.NilError(t, err)
+	assert.Check(t, is.Contains(string(rendered), "file: password.txt"))
+
+	// and the rendered file only loads back along with the files
+	_, err = ParseComposeInput(types.ComposeInput{
+		ComposeFiles: []string{string(rendered)},
+	})
+	assert.Check(t, is.ErrorContains(err, "file password.txt is not attached"))
+	reloaded, err := ParseComposeInput(types.ComposeInput{
+		ComposeFiles: []string{string(rendered)},
+		Files:        files,
+	})
+	assert.NilError(t, err, string(rendered))
+
+	sort.Strings(create.Spec.PropertyValues)
+	sort.Strings(reloaded.Spec.PropertyValues)
+	assert.Check(t, is.DeepEqual(create.Spec, reloaded.Spec), string(rendered))
+}
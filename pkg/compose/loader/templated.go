@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	interp "github.com/docker/stacks/pkg/compose/interpolation"
+	"github.com/docker/stacks/pkg/compose/template"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// extractTemplatedValues returns a copy of a compose file without the typed
+// options whose values use variables, as they can't be loaded before being
+// substituted. Their raw values are recorded in templated by path, such as
+// services.web.deploy.replicas, where items of lists are referred to by
+// index. Typed options with literal values override the templated values
+// recorded for previous compose files.
+func extractTemplatedValues(configDict map[string]interface{}, templated map[string]string) map[string]interface{} {
+	return extractTemplatedValue(configDict, "", "", templated).(map[string]interface{})
+}
+
+// extractTemplatedValue extracts the templated values of value, found at
+// path, which is pattern once the indices of lists are replaced.
+func extractTemplatedValue(value interface{}, pattern, path interp.Path, templated map[string]string) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			itemPattern, itemPath := nextPath(pattern, key), nextPath(path, key)
+			if pathType(itemPattern) != "" {
+				if s, ok := item.(string); ok && hasVariables(s) {
+					templated[string(itemPath)] = s
+					continue
+				}
+				delete(templated, string(itemPath))
+			}
+			result[key] = extractTemplatedValue(item, itemPattern, itemPath, templated)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = extractTemplatedValue(item, pattern.Next(interp.PathMatchList), path.Next(strconv.Itoa(i)), templated)
+		}
+		return result
+	}
+	return value
+}
+
+func nextPath(path interp.Path, key string) interp.Path {
+	if path == "" {
+		return interp.NewPath(key)
+	}
+	return path.Next(key)
+}
+
+// hasVariables returns true if a value uses variables, which are not
+// escaped.
+func hasVariables(value string) bool {
+	for _, match := range template.DefaultPattern.FindAllStringSubmatch(value, -1) {
+		if matchGroups(match, template.DefaultPattern)["escaped"] == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CastTemplatedValue returns the value of the typed option at path, as
+// recorded in the TemplatedValues of a StackSpec, once its variables are
+// substituted. Durations are returned as strings, once validated.
+func CastTemplatedValue(path, value string) (interface{}, error) {
+	propertyType, ok := templatedPathType(path)
+	if !ok {
+		return nil, errors.Errorf("%s is not a typed option", path)
+	}
+	if err := ValidatePropertyValue(propertyType, value); err != nil {
+		return nil, errors.Errorf("invalid value for %s: %s", path, err)
+	}
+	cast, ok := typeCasts[propertyType]
+	if !ok {
+		return value, nil
+	}
+	result, err := cast(value)
+	if err != nil {
+		return nil, errors.Errorf("invalid value for %s: %s", path, err)
+	}
+	return result, nil
+}
+
+// templatedPathType returns the type of the option at path, where items of
+// lists are referred to by index.
+func templatedPathType(path string) (types.PropertyType, bool) {
+	parts := strings.Split(path, ".")
+	for pattern, propertyType := range typedPaths {
+		patternParts := strings.Split(string(pattern), ".")
+		if len(patternParts) != len(parts) {
+			continue
+		}
+		matched := true
+		for i, part := range parts {
+			switch patternParts[i] {
+			case interp.PathMatchAll, part:
+			case interp.PathMatchList:
+				if _, err := strconv.Atoi(part); err != nil {
+					matched = false
+				}
+			default:
+				matched = false
+			}
+		}
+		if matched {
+			return propertyType, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,72 @@
+package loader
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestExtractTemplatedValues(t *testing.T) {
+	dict, err := ParseYAML([]byte(`version: "3.8"
+services:
+  web:
+    image: nginx:${TAG}
+    read_only: ${READ_ONLY}
+    tty: true
+    ports:
+      - target: 80
+        published: ${PORT}
+    deploy:
+      replicas: $$REPLICAS
+      update_config:
+        parallelism: ${PARALLELISM:-2}
+`))
+	assert.NilError(t, err)
+
+	templated := map[string]string{"services.web.tty": "${TTY}"}
+	extracted := extractTemplatedValues(dict, templated)
+	assert.Check(t, is.DeepEqual(map[string]string{
+		"services.web.read_only":                        "${READ_ONLY}",
+		"services.web.ports.0.published":                "${PORT}",
+		"services.web.deploy.update_config.parallelism": "${PARALLELISM:-2}",
+	}, templated))
+
+	web := extracted["services"].(map[string]interface{})["web"].(map[string]interface{})
+	assert.Check(t, is.DeepEqual(map[string]interface{}{
+		"image": "nginx:${TAG}",
+		"tty":   true,
+		"ports": []interface{}{map[string]interface{}{"target": 80}},
+		"deploy": map[string]interface{}{
+			"replicas":      "$$REPLICAS",
+			"update_config": map[string]interface{}{},
+		},
+	}, web))
+
+	// the compose file is not modified
+	original := dict["services"].(map[string]interface{})["web"].(map[string]interface{})
+	assert.Check(t, is.Equal("${READ_ONLY}", original["read_only"]))
+}
+
+func TestCastTemplatedValue(t *testing.T) {
+	value, err := CastTemplatedValue("services.web.deploy.replicas", "3")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(3, value))
+
+	value, err = CastTemplatedValue("services.web.volumes.1.read_only", "yes")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(true, value))
+
+	value, err = CastTemplatedValue("services.web.healthcheck.interval", "30s")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("30s", value))
+
+	_, err = CastTemplatedValue("services.web.ports.0.published", "99999")
+	assert.Check(t, is.Error(err, "invalid value for services.web.ports.0.published: invalid port: 99999"))
+
+	_, err = CastTemplatedValue("services.web.healthcheck.interval", "often")
+	assert.Check(t, is.ErrorContains(err, "invalid value for services.web.healthcheck.interval"))
+
+	_, err = CastTemplatedValue("services.web.image", "nginx")
+	assert.Check(t, is.Error(err, "services.web.image is not a typed option"))
+}
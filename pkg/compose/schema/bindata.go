@@ -735,6 +735,94 @@ nEbFye/DBpf6q/LNQD4WSf11TQ8obKICc9f36nZ7TfvduKfjbxi9rsq/59X/AwAA//+9o87pcUUAAA==
 `,
 	},
 
+	"/data/config_schema_v3.8.json": {
+		local:   "data/config_schema_v3.8.json",
+		size:    18246,
+		modtime: 1518458244,
+		compressed: `
+H4sIAAAAAAAC/+0cTZObNvTuX7FDcst6NzPNdNrceuypPXfHYWSQbWVBIpJw4mT83/uEAIOQkLDx7qbd
+XpqFpye9T70v/GNxcxO9FckO5yj6eBPtpCw+3t9/Fowu9dM7xrf3KUcbuXz/4V4/exPdqnUkVUsSRjdk
+G+s38f6Xu9/u1HINIg8FVkBs/RknUj/j+EtJOFaLH6I95oIA9Op2od4VnBWYS4IFvP0BT+BZA9I86KAV
+khO6jarHxwoDvBSY70nSwdAe9c39Cf99C3ZrYu0ctnpeICkxp38Pz1a9/vSAlt//WP7zfvn7XbxcvXvb
+e634y/FGb5/iDaFEAjXt/lELeaz/dWw3RmlaAaOst/cGZQL3aaZYfmX80UdzC/ZMNNf7W2juk7NnWZl7
+JdhAPRMxevt55CdwwrH0q6yGejaNVdvPQ7D2Gj6CG6hnIlhvfxnBi4Zo+xmjT9+W6v/HCucoPo2lc76K
+iJ7Ps7HT5nPc/GwZ6uBkiouMHaqT23mmAXJMZdSyCdatS5KlJtcZxX8pFA+dhzeA2XDvHTzV+95fbqVo
+3ztoad+DmCX+JiuixrfWLGDJI+YbkuHQFYhrTXewLCNCxozHKUmkdX2G1ji7CEOC4HqON5zlXiybWFMi
+rIgaDx5IuQTScTBnxS6PBfne4+tDREA6W8yj23bt6misHSDzG6Zp0+q/1cKCEHhXxICuRwTiHB3UiYjE
+ubDTdxOVlHwp8Z81iOQlNvGmcLj5EW85K4u4QFxZ4TjvQfnzHNG5THMKHQGcH1wSPXuv9+i+anfrHctB
+zU2AVlrchcfd+B2O0nRW8iTUf0y1I4AvSRoOvJ0CnLO0f25a5muwzuMAeGCkvb9XC9sbQ/oSEYp5TFGO
+vXoMGQWoO0FZLAqcuHTGIrQxcdUqGMCeKPBCgPRnC36WH6ywC4dPC/NnXX7APYxpKmKdOE33+ICgzaJm
+9U4pHbvJNBp1l6mzRcbCWGDEk92Z61kO6hSiS6BH/FAwor3ni3OLmO7jVtsmswFWE85o3twNYRFFZ/23
+ggl8uU9u7/ea8NvWlaxMy2I8R+qwzd5OKxlqXpeBXRpUJA6uIiP0cX4VB/QcxTsm5DlBW7TDKJM7CNiS
+x5HlXajeatg2RMlJjrZ+INitB7NmLMOI9oGKxItHsAzJuoozBnh2qBvNKsoOWrbdKlCX/g5Sp8CkI+Vk
+D7oeGBmz4pTx2cIDX0jiTZF7oJ/udIY8YqPVv7JsGIrbbn7ziXklhl5uJ6nkKFExOcdC+DSqzljiQeBy
+gh0Ai1C/f1YiNT2BDRKdt8rhDYddIW+4loWFv43YM4IEFpdlpB0vtP8QqBO2tb+OrnUsdeIMzz89qLpx
+Npib7SArf+R9zfS46GcPfV9ReYiugRWMyydJ6E5+6hQ+6M2HOZ4p7qBF10kMR7xUWFrYVEvsC4pyDTa1
+w+mUNZxJlrAszDCs9a9wYxhJEs+K9Aq4ZiFU3hoU28IYjlEK6VJ2CIAUwHlvaUXgpOREHmK4vWePMe21
+spPWt6Wy/oGMLsNrPeX/U08RB5HI82JrIVNCQY0x9dqGkKyItxwlOAaZEWZlRc/BpiXXqcEAjSBb8Bk+
+M5N5sTmzpCCl39jLjOTEbTTWgpI3XtOxmj1EGwnPglz2SIYwniAEZAY7xCdcHZVhbhz30yIwBurPC1T4
+buuDrKzwk0Iv8xgrZ/RjN6pSeJO4CoaKOOBqtzS+fw4P3ZNRBb46y4/XOwX6zmt7/eCIoF8wFuBmME0O
+4RutyaADMzXvCsu6Kii0dZdi7LlJsK3WMxFPQgqFoLRwiOZCMtor5fpUNDGcOzk1PedIHpvDpZeXqsf7
+3pWxhnPmyqG9UQMaCehdvldVatTNnhI+psvH8SmR/gTGxDEWo1Q7NnvRBfXOs4zPgfhmNIhAa6MZZa3b
+gkLxvT3A8kdokElwYvSHmti1G2JBrvQiuyiS5JiV8tzwFPK/6QGuOe3WGalp+jFjKtSBNDXooVWhpuzi
+VZOQeATTtOqDBQUvHI5HEiR8AeIFRX7OsmyNksf41Jedo8tbII6yDMO2eUh0CyLL0OEszdENLUSykuMY
+JQEtkVpWwBrGz98yR9/iZtsKxGO32k55il17YlrdM2Z8qS1juSFcSF2GYEX9V9/9z9jqLosUSfyqEq8q
+0a3QVbmBmEsdrEWAeaYPizK0XxHlOGf+yZFLS/6DgRU1NopcDciXwgAL9BZTuKGTuKcNjitnCHulLsrl
+mq1jDwZ37WHGMSd9jhDPc6GrU35HBeJ5IUWQa/0K2TH7Oj3MmoHbRYYSbIRmlzIazo6A1MmzCiZbCtBj
+zDFN8KhZDmtGI3Wj+QryhSqePEPLyKZtTWCqAvaYmpGsrSJ5jtpc8DWE1VGNZQLDBYOUsi93i7zdcnbL
+V+WWqoeE251t05Y+HRrXn+ixroZ5XXwEyWsZ0D05a97EVXUIWHy0fpzlk2kDNkNqFzL/FTSAVEOpDubs
+HRD/kNHKX38nBcrn8s3BI1mRNdV4CV63XFNHgfvKXne+K7eZzXRI9aEtZd22vFoFi9hpGPOdv6qqmW1L
+W/kNTAQlu6BK3cSCyRMUPgeFfqtLq6FePdoEj/az6//L09X6u1Xvt5EVlP9T0ws0NOAbkRcg/znE+p8z
+S5WvZqrMOELOE+jyIPKw6nIN9arLc+vyC9ECY6Spow3D1tqYgILnrhfdTlp7DBPM8gsdrizUeShXI9jY
+tJbNOOUzOpG7dyPR/tj3EVcKk2cYJrXL1ChQLdrRUfMHBtyup1k/+LkBRSc9DFq/P/rjQ/qnAlY9/hgg
++tuljtdeBRUvbD9CYA4vNT8G4Jin7Gf46scdFsfFvxXf2xdGRwAA
+`,
+	},
+
+	"/data/config_schema_v3.9.json": {
+		local:   "data/config_schema_v3.9.json",
+		size:    18780,
+		modtime: 1518458244,
+		compressed: `
+H4sIAAAAAAAC/+1cS3PbNhC+61d4mNwi2Z5ppjPJrcee2nM9CgciIQkxCTAAqFjJ6L8XIEiKAAEClChZ
+aZ1LLHLxWOwD3z6kn7O7u+g9S7YwB9Hnu2jLefH54eErI3ihnt4TunlIKVjzxePHB/XsXTSX41AqhyQE
+r9EmVm/i3W/3n+7lcEXC9wWURGT1FSZcPaPwW4kolIOfoh2kDAnq5Xwm3xWUFJByBJl4+1M8Ec8akuZB
+Z1rGKcKbqHp8qGYQLxmkO5R0Zmi3+u7hOP9DSzY3Z+1stnpeAM4hxX/391a9/vIEFj/+WPzzuPh0Hy+W
+H95rr+X5UrhWy6dwjTDigpt2/ailPNR/HdqFQZpWxCDT1l6DjEGdZwz5d0KffTy3ZK/Ec72+hWednR3J
+ytwrwYbqlZhRy08jPwYTCrlfZRXVq2msXH4ahpXX8DHcUL0Sw2r58xieNUzb9xh9eVnI/w/VnIPzqVk6
++6uY0Hye7ThtPsd9nu2BOk4yhUVG9tXO7WemCHKIedQekxi3KlGWmqdOMPxLTvHUeXgnZjbce2ee6r32
+ya0U7XsHL+17IWYOX3jF1PDS6ghI8gzpGmUwdASgStMdR5YhxmNC4xQl3Do+AyuYnTVDAsT1HK8pyb2z
+rGPFCbNO1HjwQM65YB0Gnyzb5jFDP7RzfYqQkM4G0mjejl0ejLGHvsz9xqaLyGfIpg+Q/5YzywbEWRex
+mE5jGlAK9pIDxGHO7OdxF5UYfSvhnzUJpyU0503F5qafeENJWcQFoNJqh2UljCXPAZ7KlMfwEXDyvUtF
+8w/1Gt1X7Wrathzc3AVoscW9eNyT30FJyyAlTUL9zVi7E/QlSsOJN2OIc5Lq+8ZlvhLWfOgR94xa+7yc
+2d4Y0ucAYUhjDHLo1WMRgQh1RyCLWQETl85YhDYkrloFA44nCrxARLi0EX6Z7q20M4cPHOP/wnxf9+zE
+HQ9xymIVlI2/TcQEbYQ2qSdL8dAtqaaR96TcW2QMjBkENNmeOJ7kQvVC9E7oHN0XBClPe3MuFOJd3Grm
+6GMQoxElOG/ukTC00hn/UhAGz/ffLXaoGZ+3bmdpWiGhOZCbbdZ2WlRf87oH2OVBGp5wKxnCz9OruJie
+gnhLGD8FEEZbCDK+FWAweR4Y3qXSRotlQ5Qc5WDjJxKraTQrQjIIsE5UJN55GMkArzNEQ4Qnw+hoUlF2
+piWbjSR16W8vLAsMaFKKdkLXA1E3KY7RpA1K+OCLN/zWSL/cq+h7wEarv7KsD/NtKMF8Yl6fowKB4Kvw
+KMMcJBLtU8iYT//q2CnuQaIjbY+Yhd4SJ4V040PpIEF78y1eoO0C0+E6GQasG7FnCDDIzouNOz5r9zFQ
+J2xjfx8c6xjqnDM8svVM1UXwwjhtG1n6Mf0lA+9Cj0t0z1L5k66BFYTyq4SKR692BBtq8X70aIo7aNBl
+Qs4BLxUWcDZ5G/uAolwJm9rCdMwYSjhJSBZmGNZMXLgxDISfJ+HCQlzKAlhvDI5toIdCkIrgKtsHUDJx
+8t6kDYNJSRHfx+KunxyR2rN2R61vk3b6hox6x1um5v+TqWF7lvDTkDjjKcJCjSH22gbjpIg3FCQwFjJD
+xHoUmoNNS6oCid40DG2Ez/CZGc+L9YkJCM79xl5mKEduo7Gmqrx4TWE1O0QbgGdBLnsgnhgOJwLiiC2g
+I66OyjDXjvtpFoiB9M6Far55vZGllX4U9DK3sXSiH7tRlcwb8lU0mMUBV7ulBP9reGhNRhX58iQ/Xq8U
+6Dsv7fWDEYGeimbCzUCc7MMXWqFebWds3BUWdVVUYONO3Nhjk2BbrbszrsIKFqC0cIjmTDbaK+XyXDQY
+zh2cmp5zII7NxaWXl7La/OiKWMNP5sLQ3sgBDQB6l++VmRp5s6eIDunyYbhfpZ+VGtFQYyR2h7pAuqSn
+ddaM3Onc21WCGFgZ5TBrNlgoHt3ZgZgfyYmIgyKj6tRg3C4UEzHVTdZmOMohKfmpMFbEieOBsNmf12kC
+aqo8Q6rWoTQ17alVtSY941WTENwCcVpV14JADhXbQwlgPiB5RumAkixbgeQ5PlaGp6gzF4CCLINi2TwE
+BQuRZWB/kuaoMhlAWUlhDJKAQkstK3E0hJ6+ZA5e4mbZisRjt8pOaQpda0Jc3UcmDlWWsVgjyrhKV5Ci
+/qRfE69UbC+LFHD4pj5v6nOS+lCoYhM2lepYkxDT9GEWZWi9JMphTvw9MdfuDey17siGW+Aqr97KgVmo
+NxALpJDEmvY4rr4+7Y20Z17XyhS+IgJP7CdsJlP7CPGYZ7po6S/lCeUFZ0FXwneEU/J9PJS8smSKDCTQ
+gKrnCkXwCcSxjO4I6fUmCHuCFOIEDrqHfq5tIN82XSGjkEmnVyi12TSzAeoygImxiextmdxLq9gVQnqr
+Ex6KtvoDeuG9rksWHXLrjltnZPwu63mwXdnWU+vTy2GdjJ7rzKT3+op2ICsDKlkn9f64MkABgw/Wr+z5
+ZNqQTRA+h3TuBbWO1VSymjx5NcrfHrb010JQAfKp/H1wM11kDdFuwZOXK+woNlzYkzv98ehrvOmqdUj1
+qU0XztuzWgaL2GkY0+2/ylyaJWRbilOYCEi2QdnQkUmpK9xYvaKL1aXVVG8ebYRH+9X1//Z0tf42s/cb
+sxWVv0xyhoYGfBPoBuQ/hVj/c2Yp4+VMpmcH2LmCLveQh1WXa6o3XZ5al29EC4z2so429MuXQwIK7oGf
+dauV7TZMMsvvtriiUOemXEV5Y9FaNsOcT+hE7j8MoP2hb7ZcCCZP0Nhrl6mR9Jq1bbzmz064XU8zvvcj
+FJJPvO+V13/qrVzqBySW2vkYJOpbZx2vvQxKXth+msJsJGt+IsLR26pH+PInP2aH2b+fSYatXEkAAA==
+`,
+	},
+
 	"/": {
 		isDir: true,
 		local: "",
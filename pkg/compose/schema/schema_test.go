@@ -236,3 +236,82 @@ func TestValidateRollbackConfigWithUpdateConfigFull(t *testing.T) {
 
 	assert.NilError(t, Validate(config, "3.7"))
 }
+
+func TestValidateMaxReplicasPerNode(t *testing.T) {
+	config := dict{
+		"version": "3.8",
+		"services": dict{
+			"foo": dict{
+				"image": "busybox",
+				"deploy": dict{
+					"placement": dict{
+						"max_replicas_per_node": 1,
+					},
+				},
+			},
+		},
+	}
+
+	assert.NilError(t, Validate(config, "3.8"))
+	assert.ErrorContains(t, Validate(config, "3.7"), "Additional property max_replicas_per_node is not allowed")
+}
+
+func TestValidateSecretDrivers(t *testing.T) {
+	config := dict{
+		"version": "3.8",
+		"secrets": dict{
+			"foo": dict{
+				"driver":          "vault",
+				"driver_opts":     dict{"path": "secret/foo", "version": 2},
+				"template_driver": "golang",
+			},
+		},
+		"configs": dict{
+			"bar": dict{
+				"file":            "./bar.conf",
+				"template_driver": "golang",
+			},
+		},
+	}
+
+	assert.NilError(t, Validate(config, "3.8"))
+}
+
+func TestValidateAllowsNestedXFields(t *testing.T) {
+	config := dict{
+		"version": "3.9",
+		"services": dict{
+			"foo": dict{
+				"image": "busybox",
+				"build": dict{
+					"context":       ".",
+					"x-extra-stuff": dict{},
+				},
+				"deploy": dict{
+					"x-extra-stuff": dict{},
+					"placement": dict{
+						"x-extra-stuff": dict{},
+					},
+					"resources": dict{
+						"limits": dict{
+							"x-extra-stuff": dict{},
+						},
+					},
+					"rollback_config": dict{
+						"order":         "start-first",
+						"x-extra-stuff": dict{},
+					},
+				},
+				"healthcheck": dict{
+					"x-extra-stuff": dict{},
+				},
+				"logging": dict{
+					"x-extra-stuff": dict{},
+				},
+			},
+		},
+	}
+
+	assert.NilError(t, Validate(config, "3.9"))
+	assert.ErrorContains(t, Validate(config, "3.8"), "Additional property x-extra-stuff is not allowed")
+}
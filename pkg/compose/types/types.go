@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -23,9 +24,7 @@ var UnsupportedProperties = []string{
 	"pid",
 	"privileged",
 	"restart",
-	"security_opt",
 	"shm_size",
-	"sysctls",
 	"ulimits",
 	"userns_mode",
 }
@@ -40,7 +39,6 @@ var DeprecatedProperties = map[string]string{
 // ForbiddenProperties that are not supported in this implementation of the
 // compose file.
 var ForbiddenProperties = map[string]string{
-	"extends":       "Support for `extends` is not implemented yet.",
 	"volume_driver": "Instead of setting the volume driver on the service, define a volume using the top-level `volumes` option and specify the driver there.",
 	"volumes_from":  "To share a volume between services, define it using the top-level `volumes` option and reference it from each service that shares it using the service-level `volumes` option.",
 	"cpu_quota":     "Set resource limits using deploy.resources",
@@ -62,6 +60,11 @@ type ConfigDetails struct {
 	WorkingDir  string
 	ConfigFiles []ConfigFile
 	Environment map[string]string
+
+	// Files, if not nil, holds the contents of the files referenced by the
+	// config files, indexed by path. Referenced files are then never read
+	// from the filesystem.
+	Files map[string][]byte
 }
 
 // Duration is a thin wrapper around time.Duration with improved JSON marshalling
@@ -85,6 +88,28 @@ func (d Duration) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.String())
 }
 
+// UnmarshalJSON makes Duration implement json.Unmarshaler. Durations are
+// strings such as "1m30s", or numbers of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(duration)
+	case float64:
+		*d = Duration(value)
+	default:
+		return fmt.Errorf("invalid duration: %s", string(data))
+	}
+	return nil
+}
+
 // MarshalYAML makes Duration implement yaml.Marshaler
 func (d Duration) MarshalYAML() (interface{}, error) {
 	return d.String(), nil
@@ -162,7 +187,14 @@ func (s *Services) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
-	for name, service := range services {
+	// services are sorted by name, as the order of mappings is not kept
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		service := services[name]
 		service.Name = name
 		*s = append(*s, service)
 	}
@@ -216,7 +248,7 @@ type ServiceConfig struct {
 	StdinOpen       bool                             `mapstructure:"stdin_open" yaml:"stdin_open,omitempty" json:"stdin_open,omitempty"`
 	StopGracePeriod *Duration                        `mapstructure:"stop_grace_period" yaml:"stop_grace_period,omitempty" json:"stop_grace_period,omitempty"`
 	StopSignal      string                           `mapstructure:"stop_signal" yaml:"stop_signal,omitempty" json:"stop_signal,omitempty"`
-	Sysctls         StringList                       `yaml:",omitempty" json:"sysctls,omitempty"`
+	Sysctls         Mapping                          `yaml:",omitempty" json:"sysctls,omitempty"`
 	Tmpfs           StringList                       `yaml:",omitempty" json:"tmpfs,omitempty"`
 	Tty             bool                             `mapstructure:"tty" yaml:"tty,omitempty" json:"tty,omitempty"`
 	Ulimits         map[string]*UlimitsConfig        `yaml:",omitempty" json:"ulimits,omitempty"`
@@ -256,6 +288,10 @@ type StringOrNumberList []string
 // For the key without value (`key`), the mapped value is set to nil.
 type MappingWithEquals map[string]*string
 
+// Mapping is a mapping type that can be converted from a list of
+// 'key=value' strings
+type Mapping map[string]string
+
 // Labels is a mapping type for labels
 type Labels map[string]string
 
@@ -362,6 +398,7 @@ type RestartPolicy struct {
 type Placement struct {
 	Constraints []string               `yaml:",omitempty" json:"constraints,omitempty"`
 	Preferences []PlacementPreferences `yaml:",omitempty" json:"preferences,omitempty"`
+	MaxReplicas uint64                 `mapstructure:"max_replicas_per_node" yaml:"max_replicas_per_node,omitempty" json:"max_replicas_per_node,omitempty"`
 }
 
 // PlacementPreferences is the preferences for a service placement
@@ -438,6 +475,18 @@ type UlimitsConfig struct {
 	Hard   int `yaml:",omitempty" json:"hard,omitempty"`
 }
 
+// UnmarshalJSON makes UlimitsConfig implement json.Unmarshaller. Single
+// ulimits may be numbers.
+func (u *UlimitsConfig) UnmarshalJSON(data []byte) error {
+	var single int
+	if err := json.Unmarshal(data, &single); err == nil {
+		*u = UlimitsConfig{Single: single}
+		return nil
+	}
+	type ulimits UlimitsConfig
+	return json.Unmarshal(data, (*ulimits)(u))
+}
+
 // MarshalYAML makes UlimitsConfig implement yaml.Marshaller
 func (u *UlimitsConfig) MarshalYAML() (interface{}, error) {
 	if u.Single != 0 {
@@ -544,11 +593,14 @@ type CredentialSpecConfig struct {
 
 // FileObjectConfig is a config type for a file used by a service
 type FileObjectConfig struct {
-	Name     string                 `yaml:",omitempty" json:"name,omitempty"`
-	File     string                 `yaml:",omitempty" json:"file,omitempty"`
-	External External               `yaml:",omitempty" json:"external,omitempty"`
-	Labels   Labels                 `yaml:",omitempty" json:"labels,omitempty"`
-	Extras   map[string]interface{} `yaml:",inline" json:"-"`
+	Name           string                 `yaml:",omitempty" json:"name,omitempty"`
+	File           string                 `yaml:",omitempty" json:"file,omitempty"`
+	External       External               `yaml:",omitempty" json:"external,omitempty"`
+	Labels         Labels                 `yaml:",omitempty" json:"labels,omitempty"`
+	Driver         string                 `yaml:",omitempty" json:"driver,omitempty"`
+	DriverOpts     map[string]string      `mapstructure:"driver_opts" yaml:"driver_opts,omitempty" json:"driver_opts,omitempty"`
+	TemplateDriver string                 `mapstructure:"template_driver" yaml:"template_driver,omitempty" json:"template_driver,omitempty"`
+	Extras         map[string]interface{} `yaml:",inline" json:"-"`
 }
 
 // SecretConfig for a secret
@@ -0,0 +1,310 @@
+package containers
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/substitution"
+	stacktypes "github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// labelOrchestrator marks the containers, networks and volumes created
+	// by the Driver, so that they are never confused with the objects of
+	// swarm stacks, which carry the same namespace label.
+	labelOrchestrator = "com.docker.stacks.orchestrator"
+
+	// labelService is the label holding the scoped name of the service a
+	// container belongs to.
+	labelService = "com.docker.stacks.service"
+
+	// labelSpecHash is the label holding a hash of the configuration a
+	// container was created with, used to detect containers which need to
+	// be recreated after a stack update.
+	labelSpecHash = "com.docker.stacks.spec-hash"
+)
+
+// desiredState is the set of docker objects which make up a stack.
+type desiredState struct {
+	// networks are the bridge networks to create, by name.
+	networks map[string]types.NetworkCreate
+	// volumes are the local volumes to create, by name.
+	volumes map[string]volumetypes.VolumeCreateBody
+	// containers are the containers to run, by name.
+	containers map[string]containerSpec
+	// replicas is the number of containers of each service, by scoped
+	// service name.
+	replicas map[string]uint64
+}
+
+// networkNames returns the names of the networks, sorted.
+func (s desiredState) networkNames() []string {
+	names := make([]string, 0, len(s.networks))
+	for name := range s.networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// volumeNames returns the names of the volumes, sorted.
+func (s desiredState) volumeNames() []string {
+	names := make([]string, 0, len(s.volumes))
+	for name := range s.volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containerNames returns the names of the containers, sorted.
+func (s desiredState) containerNames() []string {
+	names := make([]string, 0, len(s.containers))
+	for name := range s.containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containerSpec is the configuration of a single container of a stack.
+type containerSpec struct {
+	service    string
+	config     *container.Config
+	hostConfig *container.HostConfig
+	// networks are the networks the container is attached to, sorted by
+	// name. The container is created on the first one, and connected to
+	// the others before it is started.
+	networks []swarm.NetworkAttachmentConfig
+	// specHash is the digest of the configuration, also stored in the
+	// labelSpecHash label of the container.
+	specHash string
+}
+
+// convertStack converts a stack spec to the docker objects it is made of.
+// Services are first converted to swarm services, whose container specs
+// are then translated to plain containers, so that both orchestrators
+// interpret compose files the same way.
+func convertStack(spec stacktypes.StackSpec) (desiredState, error) {
+	// Substitute variables with desired property values
+	substitutedSpec, err := substitution.DoSubstitution(spec)
+	if err != nil {
+		return desiredState{}, err
+	}
+
+	namespace := convert.NewNamespace(spec.Metadata.Name)
+	state := desiredState{
+		networks:   map[string]types.NetworkCreate{},
+		volumes:    map[string]volumetypes.VolumeCreateBody{},
+		containers: map[string]containerSpec{},
+		replicas:   map[string]uint64{},
+	}
+
+	networks, _ := convert.Networks(namespace, substitutedSpec.Networks, convert.ServicesNetworks(substitutedSpec.Services))
+	for name, network := range networks {
+		// Overlay networks require swarm mode, so all networks of the
+		// stack are local bridges.
+		if network.Driver == "" || network.Driver == "overlay" {
+			network.Driver = "bridge"
+		}
+		network.Attachable = false
+		network.CheckDuplicate = true
+		network.Labels = ownerLabels(network.Labels)
+		state.networks[name] = network
+	}
+
+	volumes, _ := convert.NamedVolumes(namespace, substitutedSpec.Volumes)
+	for name, volume := range volumes {
+		if volume.Driver == "" {
+			volume.Driver = "local"
+		}
+		volume.Labels = ownerLabels(volume.Labels)
+		state.volumes[name] = volume
+	}
+
+	for _, service := range substitutedSpec.Services {
+		if len(service.Secrets) > 0 || len(service.Configs) > 0 {
+			return desiredState{}, fmt.Errorf("service %s: secrets and configs require an orchestrator", service.Name)
+		}
+
+		serviceSpec, err := convert.Service(namespace, service, substitutedSpec.Networks, substitutedSpec.Volumes, nil, nil)
+		if err != nil {
+			return desiredState{}, fmt.Errorf("service %s: %s", service.Name, err)
+		}
+
+		// There is a single node, so global services run a single
+		// container.
+		replicas := uint64(1)
+		if serviceSpec.Mode.Replicated != nil && serviceSpec.Mode.Replicated.Replicas != nil {
+			replicas = *serviceSpec.Mode.Replicated.Replicas
+		}
+		if replicas > 1 && hasPublishedPorts(serviceSpec) {
+			return desiredState{}, fmt.Errorf("service %s: services publishing ports cannot run more than one replica without an orchestrator", service.Name)
+		}
+		state.replicas[serviceSpec.Annotations.Name] = replicas
+
+		for i := uint64(1); i <= replicas; i++ {
+			name := fmt.Sprintf("%s.%d", serviceSpec.Annotations.Name, i)
+			c := convertContainer(service, serviceSpec)
+			if c.specHash, err = c.hash(); err != nil {
+				return desiredState{}, err
+			}
+			c.config.Labels[labelSpecHash] = c.specHash
+			state.containers[name] = c
+		}
+	}
+
+	return state, nil
+}
+
+// convertContainer translates the container spec of a swarm service to the
+// configuration of a single container.
+func convertContainer(service composetypes.ServiceConfig, serviceSpec swarm.ServiceSpec) containerSpec {
+	spec := serviceSpec.TaskTemplate.ContainerSpec
+
+	labels := ownerLabels(spec.Labels)
+	labels[labelService] = serviceSpec.Annotations.Name
+
+	config := &container.Config{
+		Hostname:    spec.Hostname,
+		Image:       spec.Image,
+		Entrypoint:  spec.Command,
+		Cmd:         spec.Args,
+		Env:         spec.Env,
+		Labels:      labels,
+		WorkingDir:  spec.Dir,
+		User:        spec.User,
+		Tty:         spec.TTY,
+		OpenStdin:   spec.OpenStdin,
+		StopSignal:  spec.StopSignal,
+		Healthcheck: spec.Healthcheck,
+	}
+	if spec.StopGracePeriod != nil {
+		timeout := int(spec.StopGracePeriod.Seconds())
+		config.StopTimeout = &timeout
+	}
+
+	hostConfig := &container.HostConfig{
+		Mounts:         spec.Mounts,
+		RestartPolicy:  convertRestartPolicy(service.Restart, serviceSpec.TaskTemplate.RestartPolicy),
+		ExtraHosts:     []string(service.ExtraHosts),
+		ReadonlyRootfs: spec.ReadOnly,
+		Init:           spec.Init,
+		Isolation:      spec.Isolation,
+	}
+	if spec.DNSConfig != nil {
+		hostConfig.DNS = spec.DNSConfig.Nameservers
+		hostConfig.DNSSearch = spec.DNSConfig.Search
+		hostConfig.DNSOptions = spec.DNSConfig.Options
+	}
+	if logDriver := serviceSpec.TaskTemplate.LogDriver; logDriver != nil {
+		hostConfig.LogConfig = container.LogConfig{
+			Type:   logDriver.Name,
+			Config: logDriver.Options,
+		}
+	}
+	if resources := serviceSpec.TaskTemplate.Resources; resources != nil && resources.Limits != nil {
+		hostConfig.NanoCPUs = resources.Limits.NanoCPUs
+		hostConfig.Memory = resources.Limits.MemoryBytes
+	}
+
+	if serviceSpec.EndpointSpec != nil {
+		config.ExposedPorts = nat.PortSet{}
+		hostConfig.PortBindings = nat.PortMap{}
+		for _, port := range serviceSpec.EndpointSpec.Ports {
+			p := nat.Port(fmt.Sprintf("%d/%s", port.TargetPort, port.Protocol))
+			config.ExposedPorts[p] = struct{}{}
+			if port.PublishedPort != 0 {
+				hostConfig.PortBindings[p] = append(hostConfig.PortBindings[p], nat.PortBinding{
+					HostPort: fmt.Sprintf("%d", port.PublishedPort),
+				})
+			}
+		}
+	}
+
+	// The networks of the service are sorted by target.
+	networks := serviceSpec.TaskTemplate.Networks
+	if len(networks) > 0 {
+		hostConfig.NetworkMode = container.NetworkMode(networks[0].Target)
+	}
+
+	return containerSpec{
+		service:    serviceSpec.Annotations.Name,
+		config:     config,
+		hostConfig: hostConfig,
+		networks:   networks,
+	}
+}
+
+// convertRestartPolicy translates the restart policy of a swarm service to
+// the restart policy of its containers. Like swarm tasks, containers are
+// restarted unless the compose file says otherwise.
+func convertRestartPolicy(restart string, policy *swarm.RestartPolicy) container.RestartPolicy {
+	if policy == nil {
+		if restart == "no" {
+			return container.RestartPolicy{Name: "no"}
+		}
+		return container.RestartPolicy{Name: "always"}
+	}
+
+	switch policy.Condition {
+	case swarm.RestartPolicyConditionNone:
+		return container.RestartPolicy{Name: "no"}
+	case swarm.RestartPolicyConditionOnFailure:
+		result := container.RestartPolicy{Name: "on-failure"}
+		if policy.MaxAttempts != nil {
+			result.MaximumRetryCount = int(*policy.MaxAttempts)
+		}
+		return result
+	default:
+		return container.RestartPolicy{Name: "always"}
+	}
+}
+
+// ownerLabels returns a copy of labels with the labelOrchestrator label
+// added.
+func ownerLabels(labels map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[labelOrchestrator] = stacktypes.OrchestratorNone
+	return result
+}
+
+func hasPublishedPorts(serviceSpec swarm.ServiceSpec) bool {
+	if serviceSpec.EndpointSpec == nil {
+		return false
+	}
+	for _, port := range serviceSpec.EndpointSpec.Ports {
+		if port.PublishedPort != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hash returns a digest of the configuration of the container, which
+// changes whenever the container needs to be recreated. It must be computed
+// before the labelSpecHash label is added.
+func (s containerSpec) hash() (string, error) {
+	data, err := json.Marshal(struct {
+		Config     *container.Config
+		HostConfig *container.HostConfig
+		Networks   []swarm.NetworkAttachmentConfig
+	}{s.config, s.hostConfig, s.networks})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
@@ -0,0 +1,365 @@
+// Package containers implements the "none" orchestrator, which runs stacks
+// as plain containers on a single docker engine, without swarm mode.
+package containers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	"github.com/docker/stacks/pkg/metrics"
+	stacktypes "github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// stackIDPrefix prefixes the name of a stack to form its ID. Stack
+	// names are unique on an engine, as they scope the names of the
+	// containers, networks and volumes of the stack.
+	stackIDPrefix = "none_"
+
+	// resyncDelay is the time after which a stack is reconciled again,
+	// after a failed attempt.
+	resyncDelay = 5 * time.Second
+)
+
+// validStackName matches the stack names which produce valid container
+// names.
+var validStackName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var errNotFound = errdefs.NotFound(errors.New("stack not found"))
+
+// Driver is the interfaces.OrchestratorDriver of the "none" orchestrator.
+// Stacks are kept in memory, and in the state file of drivers created by
+// LoadDriver, and reconciled into containers, bridge networks and local
+// volumes through the docker API by the Run loop.
+type Driver struct {
+	client    DockerClient
+	stateFile string
+
+	mu     sync.Mutex
+	stacks map[string]stacktypes.Stack
+
+	// pending is the set of stacks waiting to be reconciled, and notify
+	// wakes up the Run loop when stacks are added to it.
+	pending map[string]struct{}
+	notify  chan struct{}
+
+	// removeVolumes is the set of deleted stacks whose volumes must be
+	// removed, as their volume removal policy asked for it.
+	removeVolumes map[string]struct{}
+
+	// publish reports the progress of the reconciliation of stacks as
+	// stack lifecycle events.
+	publish func(stacktypes.StackEvent)
+}
+
+// NewDriver creates a new Driver using the provided docker client, which
+// keeps its stacks in memory only.
+func NewDriver(client DockerClient) *Driver {
+	return &Driver{
+		client:        client,
+		stacks:        map[string]stacktypes.Stack{},
+		pending:       map[string]struct{}{},
+		notify:        make(chan struct{}, 1),
+		removeVolumes: map[string]struct{}{},
+		publish:       func(stacktypes.StackEvent) {},
+	}
+}
+
+// SetEventPublisher sets the function with which the driver publishes the
+// progress of the reconciliation of its stacks, as stack lifecycle events.
+// It must be called before Run.
+func (d *Driver) SetEventPublisher(publish func(stacktypes.StackEvent)) {
+	d.publish = publish
+}
+
+func stackID(name string) string {
+	return stackIDPrefix + name
+}
+
+func stackName(id string) string {
+	return strings.TrimPrefix(id, stackIDPrefix)
+}
+
+// Orchestrator returns types.OrchestratorNone.
+func (d *Driver) Orchestrator() stacktypes.OrchestratorChoice {
+	return stacktypes.OrchestratorNone
+}
+
+// CreateStack validates and stores a new stack, and schedules its
+// reconciliation.
+func (d *Driver) CreateStack(create stacktypes.StackCreate) (string, error) {
+	name := create.Spec.Metadata.Name
+	if !validStackName.MatchString(name) {
+		return "", errdefs.InvalidParameter(fmt.Errorf("invalid stack name %q", name))
+	}
+	if _, err := convertStack(create.Spec); err != nil {
+		return "", errdefs.InvalidParameter(fmt.Errorf("unable to translate stack to containers: %s", err))
+	}
+
+	id := stackID(name)
+	d.mu.Lock()
+	if _, ok := d.stacks[id]; ok {
+		d.mu.Unlock()
+		return "", errdefs.Conflict(fmt.Errorf("stack %s already exists", name))
+	}
+	_, removingVolumes := d.removeVolumes[id]
+	d.stacks[id] = stacktypes.Stack{
+		ID:           id,
+		Spec:         create.Spec,
+		Orchestrator: stacktypes.OrchestratorNone,
+	}
+	delete(d.removeVolumes, id)
+	if err := d.saveLocked(); err != nil {
+		delete(d.stacks, id)
+		if removingVolumes {
+			d.removeVolumes[id] = struct{}{}
+		}
+		d.mu.Unlock()
+		return "", err
+	}
+	d.mu.Unlock()
+
+	d.enqueue(id)
+	return id, nil
+}
+
+// GetStack retrieves a stack by its ID.
+func (d *Driver) GetStack(id string) (stacktypes.Stack, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stack, ok := d.stacks[id]
+	if !ok {
+		return stacktypes.Stack{}, errNotFound
+	}
+	return stack, nil
+}
+
+// ListStacks lists all stacks, ordered by ID.
+func (d *Driver) ListStacks() ([]stacktypes.Stack, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stacks := []stacktypes.Stack{}
+	for _, stack := range d.stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		return stacks[i].ID < stacks[j].ID
+	})
+	return stacks, nil
+}
+
+// UpdateStack updates the spec of a stack, and schedules its
+// reconciliation.
+func (d *Driver) UpdateStack(id string, spec stacktypes.StackSpec, version uint64) error {
+	if _, err := convertStack(spec); err != nil {
+		return errdefs.InvalidParameter(fmt.Errorf("unable to translate stack to containers: %s", err))
+	}
+
+	d.mu.Lock()
+	stack, ok := d.stacks[id]
+	switch {
+	case !ok:
+		d.mu.Unlock()
+		return errNotFound
+	case stack.Version.Index != version:
+		d.mu.Unlock()
+		return fmt.Errorf("update out of sequence")
+	case spec.Metadata.Name != stack.Spec.Metadata.Name:
+		d.mu.Unlock()
+		return errdefs.InvalidParameter(fmt.Errorf("the name of stack %s cannot be changed", id))
+	}
+	previous := stack
+	stack.Version.Index++
+	stack.Spec = spec
+	d.stacks[id] = stack
+	if err := d.saveLocked(); err != nil {
+		d.stacks[id] = previous
+		d.mu.Unlock()
+		return err
+	}
+	d.mu.Unlock()
+
+	d.enqueue(id)
+	return nil
+}
+
+// DeleteStack removes a stack, and schedules the removal of its containers
+// and networks. Volumes are kept, so as not to lose data, unless the volume
+// removal policy of the stack says otherwise.
+func (d *Driver) DeleteStack(id string) error {
+	d.mu.Lock()
+	stack, ok := d.stacks[id]
+	if ok && stack.Spec.VolumeRemovalPolicy == stacktypes.VolumeRemovalPolicyDelete {
+		d.removeVolumes[id] = struct{}{}
+	}
+	delete(d.stacks, id)
+	if err := d.saveLocked(); err != nil {
+		if ok {
+			d.stacks[id] = stack
+		}
+		delete(d.removeVolumes, id)
+		d.mu.Unlock()
+		return err
+	}
+	d.mu.Unlock()
+
+	d.enqueue(id)
+	return nil
+}
+
+// enqueue schedules the reconciliation of a stack.
+func (d *Driver) enqueue(id string) {
+	d.mu.Lock()
+	d.pending[id] = struct{}{}
+	d.mu.Unlock()
+
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+// takePending returns the stacks waiting to be reconciled, and empties the
+// pending set.
+func (d *Driver) takePending() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ids := make([]string, 0, len(d.pending))
+	for id := range d.pending {
+		ids = append(ids, id)
+	}
+	d.pending = map[string]struct{}{}
+	sort.Strings(ids)
+	return ids
+}
+
+// enqueueExisting schedules the reconciliation of the known stacks, of the
+// deleted stacks whose volumes are still to be removed, and of the stacks
+// owning containers or networks on the engine. The objects left behind by
+// stacks deleted before a restart are thereby removed.
+func (d *Driver) enqueueExisting(ctx context.Context) error {
+	owned := filters.NewArgs(filters.Arg("label", labelOrchestrator+"="+stacktypes.OrchestratorNone))
+	names := map[string]struct{}{}
+	containers, err := d.client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: owned})
+	if err != nil {
+		return errors.Wrap(err, "unable to list containers")
+	}
+	for _, c := range containers {
+		names[c.Labels[convert.LabelNamespace]] = struct{}{}
+	}
+	networks, err := d.client.NetworkList(ctx, types.NetworkListOptions{Filters: owned})
+	if err != nil {
+		return errors.Wrap(err, "unable to list networks")
+	}
+	for _, n := range networks {
+		names[n.Labels[convert.LabelNamespace]] = struct{}{}
+	}
+
+	d.mu.Lock()
+	ids := map[string]struct{}{}
+	for id := range d.stacks {
+		ids[id] = struct{}{}
+	}
+	for id := range d.removeVolumes {
+		ids[id] = struct{}{}
+	}
+	for name := range names {
+		if name != "" {
+			ids[stackID(name)] = struct{}{}
+		}
+	}
+	for id := range ids {
+		d.pending[id] = struct{}{}
+	}
+	d.mu.Unlock()
+	metrics.Resyncs.WithLabelValues("startup").Add(float64(len(ids)))
+
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run reconciles stacks until the context is cancelled, or the docker
+// event stream fails. Stacks are reconciled when Run starts, whenever they
+// are created, updated or deleted, and whenever one of their containers
+// dies or is removed.
+func (d *Driver) Run(ctx context.Context) error {
+	eventC, errC := d.client.Events(ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", events.ContainerEventType),
+			filters.Arg("label", labelOrchestrator+"="+stacktypes.OrchestratorNone),
+		),
+	})
+	if err := d.enqueueExisting(ctx); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errC:
+			return fmt.Errorf("docker event stream failed: %s", err)
+		case event := <-eventC:
+			if event.Action != "die" && event.Action != "destroy" {
+				continue
+			}
+			if name, ok := event.Actor.Attributes[convert.LabelNamespace]; ok {
+				d.enqueue(stackID(name))
+			}
+		case <-d.notify:
+			for _, id := range d.takePending() {
+				id := id
+				if err := d.reconcileAndPublish(ctx, id); err != nil {
+					logrus.Errorf("unable to reconcile stack %s: %s", id, err)
+					metrics.Resyncs.WithLabelValues("retry").Inc()
+					time.AfterFunc(resyncDelay, func() {
+						d.enqueue(id)
+					})
+				}
+			}
+		}
+	}
+}
+
+// reconcileAndPublish reconciles a stack, and publishes the progress of the
+// reconciliation of the stacks which still exist. The objects of deleted
+// stacks are removed silently, as their removal was already published.
+func (d *Driver) reconcileAndPublish(ctx context.Context, id string) error {
+	if _, err := d.GetStack(id); err != nil {
+		return d.reconcile(ctx, id)
+	}
+
+	d.publish(stacktypes.StackEvent{
+		StackID: id,
+		Action:  stacktypes.StackEventReconciling,
+	})
+	if err := d.reconcile(ctx, id); err != nil {
+		d.publish(stacktypes.StackEvent{
+			StackID: id,
+			Action:  stacktypes.StackEventFailed,
+			Message: err.Error(),
+		})
+		return err
+	}
+	d.publish(stacktypes.StackEvent{
+		StackID: id,
+		Action:  stacktypes.StackEventConverged,
+	})
+	return nil
+}
@@ -0,0 +1,335 @@
+package containers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/metrics"
+	"github.com/docker/stacks/pkg/mocks"
+	stacktypes "github.com/docker/stacks/pkg/types"
+)
+
+// imageNotFound mimics the error returned by the docker client when the
+// image of a container is missing.
+type imageNotFound struct{}
+
+func (imageNotFound) Error() string  { return "no such image" }
+func (imageNotFound) NotFound() bool { return true }
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func testStackCreate() stacktypes.StackCreate {
+	return stacktypes.StackCreate{
+		Orchestrator: stacktypes.OrchestratorNone,
+		Spec: stacktypes.StackSpec{
+			Metadata: stacktypes.Metadata{Name: "test"},
+			Services: composetypes.Services{
+				{
+					Name:    "web",
+					Image:   "nginx:alpine",
+					Restart: "no",
+					Deploy: composetypes.DeployConfig{
+						Replicas: uint64Ptr(2),
+					},
+					Volumes: []composetypes.ServiceVolumeConfig{
+						{Type: "volume", Source: "data", Target: "/data"},
+					},
+				},
+			},
+			Volumes: map[string]composetypes.VolumeConfig{
+				"data": {},
+			},
+		},
+	}
+}
+
+func TestDriverCreateInvalid(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	d := NewDriver(mocks.NewMockDockerClient(ctrl))
+
+	create := testStackCreate()
+	create.Spec.Metadata.Name = "-invalid"
+	_, err := d.CreateStack(create)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	create = testStackCreate()
+	create.Spec.Services[0].Secrets = []composetypes.ServiceSecretConfig{{Source: "password"}}
+	_, err = d.CreateStack(create)
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "secrets and configs require an orchestrator")
+
+	create = testStackCreate()
+	create.Spec.Services[0].Ports = []composetypes.ServicePortConfig{{Target: 80, Published: 8080, Protocol: "tcp"}}
+	_, err = d.CreateStack(create)
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "cannot run more than one replica")
+
+	_, err = d.CreateStack(testStackCreate())
+	require.NoError(err)
+	_, err = d.CreateStack(testStackCreate())
+	require.True(errdefs.IsConflict(err))
+}
+
+func TestDriverReconcile(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	cli := mocks.NewMockDockerClient(ctrl)
+	d := NewDriver(cli)
+	ctx := context.Background()
+	var actions []stacktypes.StackEventAction
+	d.SetEventPublisher(func(event stacktypes.StackEvent) {
+		actions = append(actions, event.Action)
+	})
+
+	id, err := d.CreateStack(testStackCreate())
+	require.NoError(err)
+	require.Equal("none_test", id)
+
+	cli.EXPECT().NetworkList(gomock.Any(), gomock.Any()).Return(nil, nil)
+	cli.EXPECT().NetworkCreate(gomock.Any(), "test_default", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, create types.NetworkCreate) (types.NetworkCreateResponse, error) {
+			require.Equal("bridge", create.Driver)
+			require.Equal("test", create.Labels["com.docker.stack.namespace"])
+			require.Equal("none", create.Labels[labelOrchestrator])
+			return types.NetworkCreateResponse{ID: "net1"}, nil
+		})
+	cli.EXPECT().VolumeList(gomock.Any(), gomock.Any()).Return(volumetypes.VolumeListOKBody{}, nil)
+	cli.EXPECT().VolumeCreate(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, body volumetypes.VolumeCreateBody) (types.Volume, error) {
+			require.Equal("test_data", body.Name)
+			require.Equal("local", body.Driver)
+			return types.Volume{Name: body.Name}, nil
+		})
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+	// The image is pulled when it is missing.
+	cli.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), "test_web.1").Return(container.ContainerCreateCreatedBody{}, imageNotFound{})
+	cli.EXPECT().ImagePull(gomock.Any(), "nginx:alpine", gomock.Any()).Return(ioutil.NopCloser(strings.NewReader("{}")), nil)
+	for i, name := range []string{"test_web.1", "test_web.2"} {
+		containerID := []string{"c1", "c2"}[i]
+		cli.EXPECT().ContainerCreate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), name).DoAndReturn(
+			func(_ context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, _ string) (container.ContainerCreateCreatedBody, error) {
+				require.Equal("nginx:alpine", config.Image)
+				require.Equal("test_web", config.Labels[labelService])
+				require.NotEmpty(config.Labels[labelSpecHash])
+				require.Equal("no", hostConfig.RestartPolicy.Name)
+				require.Equal(container.NetworkMode("test_default"), hostConfig.NetworkMode)
+				require.Len(hostConfig.Mounts, 1)
+				require.Equal("test_data", hostConfig.Mounts[0].Source)
+				require.Equal([]string{"web"}, networkingConfig.EndpointsConfig["test_default"].Aliases)
+				return container.ContainerCreateCreatedBody{ID: containerID}, nil
+			})
+		cli.EXPECT().ContainerStart(gomock.Any(), containerID, gomock.Any()).Return(nil)
+	}
+
+	require.NoError(d.reconcileAndPublish(ctx, id))
+	require.Equal([]stacktypes.StackEventAction{stacktypes.StackEventReconciling, stacktypes.StackEventConverged}, actions)
+
+	stack, err := d.GetStack(id)
+	require.NoError(err)
+	require.Equal(stacktypes.StackResource{Orchestrator: stacktypes.OrchestratorNone, Kind: "container", ID: "c1"}, stack.StackResources.Services["test_web.1"])
+	require.Equal("c2", stack.StackResources.Services["test_web.2"].ID)
+	require.Equal("net1", stack.StackResources.Networks["test_default"].ID)
+	require.Equal("test_data", stack.StackResources.Volumes["test_data"].ID)
+}
+
+func TestDriverReconcileUpdateAndDelete(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	cli := mocks.NewMockDockerClient(ctrl)
+	d := NewDriver(cli)
+	ctx := context.Background()
+
+	create := testStackCreate()
+	create.Spec.Volumes = nil
+	create.Spec.Services[0].Volumes = nil
+	id, err := d.CreateStack(create)
+	require.NoError(err)
+
+	desired, err := convertStack(create.Spec)
+	require.NoError(err)
+	upToDate := desired.containers["test_web.1"].specHash
+
+	// Scale down to a single replica: the second container is removed, and
+	// the first one is kept as its configuration did not change.
+	spec := create.Spec
+	spec.Services[0].Deploy.Replicas = uint64Ptr(1)
+	require.NoError(d.UpdateStack(id, spec, 0))
+
+	network := types.NetworkResource{Name: "test_default", ID: "net1"}
+	cli.EXPECT().NetworkList(gomock.Any(), gomock.Any()).Return([]types.NetworkResource{network}, nil)
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return([]types.Container{
+		{ID: "c1", Names: []string{"/test_web.1"}, State: "running", Labels: map[string]string{labelSpecHash: upToDate}},
+		{ID: "c2", Names: []string{"/test_web.2"}, State: "running", Labels: map[string]string{labelSpecHash: upToDate}},
+	}, nil)
+	cli.EXPECT().ContainerRemove(gomock.Any(), "c2", types.ContainerRemoveOptions{Force: true}).Return(nil)
+	require.NoError(d.reconcile(ctx, id))
+
+	stack, err := d.GetStack(id)
+	require.NoError(err)
+	require.Len(stack.StackResources.Services, 1)
+	require.Equal("c1", stack.StackResources.Services["test_web.1"].ID)
+
+	// Deleting the stack removes its containers and networks.
+	require.NoError(d.DeleteStack(id))
+	cli.EXPECT().NetworkList(gomock.Any(), gomock.Any()).Return([]types.NetworkResource{network}, nil)
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return([]types.Container{
+		{ID: "c1", Names: []string{"/test_web.1"}, State: "running", Labels: map[string]string{labelSpecHash: upToDate}},
+	}, nil)
+	cli.EXPECT().ContainerRemove(gomock.Any(), "c1", types.ContainerRemoveOptions{Force: true}).Return(nil)
+	cli.EXPECT().NetworkRemove(gomock.Any(), "net1").Return(nil)
+	require.NoError(d.reconcile(ctx, id))
+
+	_, err = d.GetStack(id)
+	require.True(errdefs.IsNotFound(err))
+}
+
+func TestDriverDeleteRemovesVolumes(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	cli := mocks.NewMockDockerClient(ctrl)
+	d := NewDriver(cli)
+	ctx := context.Background()
+
+	create := testStackCreate()
+	create.Spec.VolumeRemovalPolicy = stacktypes.VolumeRemovalPolicyDelete
+	id, err := d.CreateStack(create)
+	require.NoError(err)
+	require.NoError(d.DeleteStack(id))
+
+	cli.EXPECT().NetworkList(gomock.Any(), gomock.Any()).Return(nil, nil)
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return(nil, nil)
+	cli.EXPECT().VolumeList(gomock.Any(), gomock.Any()).Return(volumetypes.VolumeListOKBody{
+		Volumes: []*types.Volume{{Name: "test_data"}},
+	}, nil)
+	cli.EXPECT().VolumeRemove(gomock.Any(), "test_data", false).Return(nil)
+	require.NoError(d.reconcile(ctx, id))
+
+	// The volumes are only removed once.
+	cli.EXPECT().NetworkList(gomock.Any(), gomock.Any()).Return(nil, nil)
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return(nil, nil)
+	require.NoError(d.reconcile(ctx, id))
+}
+
+func TestDriverGetStackProgress(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	cli := mocks.NewMockDockerClient(ctrl)
+	d := NewDriver(cli)
+
+	create := testStackCreate()
+	create.Spec.Services[0].HealthCheck = &composetypes.HealthCheckConfig{
+		Test: []string{"CMD", "true"},
+	}
+	id, err := d.CreateStack(create)
+	require.NoError(err)
+
+	desired, err := convertStack(create.Spec)
+	require.NoError(err)
+	hash := desired.containers["test_web.1"].specHash
+	require.Equal([]string{"CMD", "true"}, desired.containers["test_web.1"].config.Healthcheck.Test)
+
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return([]types.Container{
+		{ID: "c1", Names: []string{"/test_web.1"}, State: "running", Labels: map[string]string{labelSpecHash: hash}},
+		{ID: "c2", Names: []string{"/test_web.2"}, State: "running", Labels: map[string]string{labelSpecHash: hash}},
+	}, nil)
+	cli.EXPECT().ContainerInspect(gomock.Any(), "c1").Return(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			State: &types.ContainerState{Health: &types.Health{Status: types.Healthy}},
+		},
+	}, nil)
+	cli.EXPECT().ContainerInspect(gomock.Any(), "c2").Return(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			State: &types.ContainerState{Health: &types.Health{Status: types.Starting}},
+		},
+	}, nil)
+
+	progress, err := d.GetStackProgress(id)
+	require.NoError(err)
+	require.False(progress.Converged)
+	require.False(progress.Failed)
+	status := progress.ServicesStatus["test_web"]
+	require.Equal(uint64(2), status.DesiredTasks)
+	require.Equal(uint64(1), status.RunningTasks)
+	require.Equal("1/2 containers running", status.Message)
+
+	// A container which exited without a restart policy fails the stack.
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return([]types.Container{
+		{ID: "c1", Names: []string{"/test_web.1"}, State: "exited", Labels: map[string]string{labelSpecHash: hash}},
+	}, nil)
+
+	progress, err = d.GetStackProgress(id)
+	require.NoError(err)
+	require.True(progress.Failed)
+	require.Equal("service test_web: container test_web.1 exited", progress.Message)
+}
+
+func TestDriverLoadState(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	cli := mocks.NewMockDockerClient(ctrl)
+	dir, err := ioutil.TempDir("", "containers-driver")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+
+	d, err := LoadDriver(cli, stateFile)
+	require.NoError(err)
+	id, err := d.CreateStack(testStackCreate())
+	require.NoError(err)
+	stack, err := d.GetStack(id)
+	require.NoError(err)
+	spec := stack.Spec
+	spec.Services[0].Image = "nginx:latest"
+	require.NoError(d.UpdateStack(id, spec, stack.Version.Index))
+
+	gone := testStackCreate()
+	gone.Spec.Metadata.Name = "gone"
+	gone.Spec.VolumeRemovalPolicy = stacktypes.VolumeRemovalPolicyDelete
+	goneID, err := d.CreateStack(gone)
+	require.NoError(err)
+	require.NoError(d.DeleteStack(goneID))
+
+	// A restarted driver knows the stacks, and the deleted stacks whose
+	// volumes are still to be removed.
+	d, err = LoadDriver(cli, stateFile)
+	require.NoError(err)
+	stack, err = d.GetStack(id)
+	require.NoError(err)
+	require.Equal(uint64(1), stack.Version.Index)
+	require.Equal("nginx:latest", stack.Spec.Services[0].Image)
+	_, err = d.GetStack(goneID)
+	require.True(errdefs.IsNotFound(err))
+	require.Contains(d.removeVolumes, goneID)
+
+	// All of them are reconciled when the Run loop starts, along with the
+	// stacks the driver no longer knows which left objects behind.
+	cli.EXPECT().ContainerList(gomock.Any(), gomock.Any()).Return([]types.Container{
+		{Labels: map[string]string{convert.LabelNamespace: "orphan"}},
+	}, nil)
+	cli.EXPECT().NetworkList(gomock.Any(), gomock.Any()).Return([]types.NetworkResource{
+		{Labels: map[string]string{convert.LabelNamespace: "test"}},
+	}, nil)
+	resyncs := testutil.ToFloat64(metrics.Resyncs.WithLabelValues("startup"))
+	require.NoError(d.enqueueExisting(context.Background()))
+	require.Equal([]string{goneID, "none_orphan", id}, d.takePending())
+	require.Equal(resyncs+3, testutil.ToFloat64(metrics.Resyncs.WithLabelValues("startup")))
+}
@@ -0,0 +1,339 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	stacktypes "github.com/docker/stacks/pkg/types"
+)
+
+// stackFilters returns the filters matching the objects created by the
+// Driver for the named stack.
+func stackFilters(name string) filters.Args {
+	return filters.NewArgs(
+		filters.Arg("label", convert.LabelNamespace+"="+name),
+		filters.Arg("label", labelOrchestrator+"="+stacktypes.OrchestratorNone),
+	)
+}
+
+// containerName returns the name of a container, without the leading
+// slash reported by the docker API.
+func containerName(c types.Container) string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+func resource(kind, id string) stacktypes.StackResource {
+	return stacktypes.StackResource{
+		Orchestrator: stacktypes.OrchestratorNone,
+		Kind:         kind,
+		ID:           id,
+	}
+}
+
+// reconcile creates, recreates and removes the containers, networks and
+// volumes of a stack so that they match its spec. The objects of a deleted
+// stack are removed, except for its volumes unless the stack was deleted
+// with the delete volume removal policy.
+func (d *Driver) reconcile(ctx context.Context, id string) error {
+	name := stackName(id)
+	stack, err := d.GetStack(id)
+	deleted := errdefs.IsNotFound(err)
+
+	desired := desiredState{}
+	if !deleted {
+		if desired, err = convertStack(stack.Spec); err != nil {
+			return err
+		}
+	}
+
+	resources := stacktypes.StackResources{
+		Services: map[string]stacktypes.StackResource{},
+		Networks: map[string]stacktypes.StackResource{},
+		Volumes:  map[string]stacktypes.StackResource{},
+	}
+
+	existingNetworks, err := d.client.NetworkList(ctx, types.NetworkListOptions{Filters: stackFilters(name)})
+	if err != nil {
+		return errors.Wrap(err, "unable to list networks")
+	}
+	for _, networkName := range desired.networkNames() {
+		networkID := ""
+		for _, existing := range existingNetworks {
+			if existing.Name == networkName {
+				networkID = existing.ID
+			}
+		}
+		if networkID == "" {
+			resp, err := d.client.NetworkCreate(ctx, networkName, desired.networks[networkName])
+			if err != nil {
+				return errors.Wrapf(err, "unable to create network %s", networkName)
+			}
+			networkID = resp.ID
+		}
+		resources.Networks[networkName] = resource(stacktypes.StackResourceKindNetwork, networkID)
+	}
+
+	if len(desired.volumes) > 0 {
+		existingVolumes, err := d.client.VolumeList(ctx, stackFilters(name))
+		if err != nil {
+			return errors.Wrap(err, "unable to list volumes")
+		}
+		for _, volumeName := range desired.volumeNames() {
+			found := false
+			for _, existing := range existingVolumes.Volumes {
+				found = found || existing.Name == volumeName
+			}
+			if !found {
+				if _, err := d.client.VolumeCreate(ctx, desired.volumes[volumeName]); err != nil {
+					return errors.Wrapf(err, "unable to create volume %s", volumeName)
+				}
+			}
+			resources.Volumes[volumeName] = resource(stacktypes.StackResourceKindVolume, volumeName)
+		}
+	}
+
+	existingContainers, err := d.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: stackFilters(name),
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to list containers")
+	}
+	for _, existing := range existingContainers {
+		spec, ok := desired.containers[containerName(existing)]
+		if ok && existing.Labels[labelSpecHash] == spec.specHash {
+			continue
+		}
+		logrus.Debugf("removing container %s of stack %s", containerName(existing), id)
+		if err := d.client.ContainerRemove(ctx, existing.ID, types.ContainerRemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+			return errors.Wrapf(err, "unable to remove container %s", containerName(existing))
+		}
+	}
+
+	for _, cName := range desired.containerNames() {
+		spec := desired.containers[cName]
+		containerID := ""
+		for _, existing := range existingContainers {
+			if containerName(existing) == cName && existing.Labels[labelSpecHash] == spec.specHash {
+				containerID = existing.ID
+				// Containers which were never started are started
+				// now. Stopped containers are left to their restart
+				// policy.
+				if existing.State == "created" {
+					if err := d.client.ContainerStart(ctx, containerID, types.ContainerStartOptions{}); err != nil {
+						return errors.Wrapf(err, "unable to start container %s", cName)
+					}
+				}
+			}
+		}
+		if containerID == "" {
+			if containerID, err = d.runContainer(ctx, cName, spec); err != nil {
+				return err
+			}
+		}
+		resources.Services[cName] = resource(stacktypes.StackResourceKindContainer, containerID)
+	}
+
+	// Networks are removed last, once no container of the stack uses
+	// them anymore.
+	for _, existing := range existingNetworks {
+		if _, ok := desired.networks[existing.Name]; ok {
+			continue
+		}
+		if err := d.client.NetworkRemove(ctx, existing.ID); err != nil && !client.IsErrNotFound(err) {
+			return errors.Wrapf(err, "unable to remove network %s", existing.Name)
+		}
+	}
+
+	if deleted {
+		return d.removeStackVolumes(ctx, id)
+	}
+	d.setStackResources(id, resources)
+	return nil
+}
+
+// removeStackVolumes removes the volumes of a deleted stack, if its volume
+// removal policy asked for it.
+func (d *Driver) removeStackVolumes(ctx context.Context, id string) error {
+	d.mu.Lock()
+	_, remove := d.removeVolumes[id]
+	d.mu.Unlock()
+	if !remove {
+		return nil
+	}
+
+	existingVolumes, err := d.client.VolumeList(ctx, stackFilters(stackName(id)))
+	if err != nil {
+		return errors.Wrap(err, "unable to list volumes")
+	}
+	for _, volume := range existingVolumes.Volumes {
+		if err := d.client.VolumeRemove(ctx, volume.Name, false); err != nil && !client.IsErrNotFound(err) {
+			return errors.Wrapf(err, "unable to remove volume %s", volume.Name)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.removeVolumes, id)
+	return d.saveLocked()
+}
+
+// runContainer creates, connects and starts a container, pulling its image
+// if it is missing.
+func (d *Driver) runContainer(ctx context.Context, name string, spec containerSpec) (string, error) {
+	var networkingConfig *network.NetworkingConfig
+	var otherNetworks []swarm.NetworkAttachmentConfig
+	if len(spec.networks) > 0 {
+		otherNetworks = spec.networks[1:]
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				spec.networks[0].Target: {Aliases: spec.networks[0].Aliases},
+			},
+		}
+	}
+
+	resp, err := d.client.ContainerCreate(ctx, spec.config, spec.hostConfig, networkingConfig, name)
+	if client.IsErrNotFound(err) {
+		if err := d.pullImage(ctx, spec.config.Image); err != nil {
+			return "", err
+		}
+		resp, err = d.client.ContainerCreate(ctx, spec.config, spec.hostConfig, networkingConfig, name)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create container %s", name)
+	}
+
+	for _, attachment := range otherNetworks {
+		if err := d.client.NetworkConnect(ctx, attachment.Target, resp.ID, &network.EndpointSettings{Aliases: attachment.Aliases}); err != nil {
+			return "", errors.Wrapf(err, "unable to connect container %s to network %s", name, attachment.Target)
+		}
+	}
+
+	if err := d.client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return "", errors.Wrapf(err, "unable to start container %s", name)
+	}
+	return resp.ID, nil
+}
+
+func (d *Driver) pullImage(ctx context.Context, image string) error {
+	logrus.Infof("pulling image %s", image)
+	body, err := d.client.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "unable to pull image %s", image)
+	}
+	defer body.Close()
+	_, err = io.Copy(ioutil.Discard, body)
+	return err
+}
+
+// setStackResources records the objects a stack was reconciled into.
+func (d *Driver) setStackResources(id string, resources stacktypes.StackResources) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stack, ok := d.stacks[id]; ok {
+		stack.StackResources = resources
+		d.stacks[id] = stack
+	}
+}
+
+// GetStackProgress reports how many containers of each service are running
+// and, for services with a healthcheck, healthy.
+func (d *Driver) GetStackProgress(id string) (stacktypes.StackProgress, error) {
+	ctx := context.TODO()
+	stack, err := d.GetStack(id)
+	if err != nil {
+		return stacktypes.StackProgress{}, err
+	}
+	desired, err := convertStack(stack.Spec)
+	if err != nil {
+		return stacktypes.StackProgress{}, err
+	}
+
+	existingContainers, err := d.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: stackFilters(stackName(id)),
+	})
+	if err != nil {
+		return stacktypes.StackProgress{}, errors.Wrap(err, "unable to list containers")
+	}
+	existing := map[string]types.Container{}
+	for _, c := range existingContainers {
+		existing[containerName(c)] = c
+	}
+
+	progress := stacktypes.StackProgress{
+		StackID:        id,
+		ServicesStatus: map[string]stacktypes.ServiceStatus{},
+		Converged:      true,
+	}
+	for service, replicas := range desired.replicas {
+		progress.ServicesStatus[service] = stacktypes.ServiceStatus{DesiredTasks: replicas}
+	}
+
+	for _, cName := range desired.containerNames() {
+		spec := desired.containers[cName]
+		status := progress.ServicesStatus[spec.service]
+
+		c, ok := existing[cName]
+		switch {
+		case !ok || c.Labels[labelSpecHash] != spec.specHash:
+			status.Message = "waiting for the containers to be created"
+		case c.State == "exited" && spec.hostConfig.RestartPolicy.Name == "no":
+			status.Message = fmt.Sprintf("container %s exited", cName)
+			progress.Failed = true
+			progress.Message = fmt.Sprintf("service %s: %s", spec.service, status.Message)
+		case c.State == "running":
+			healthy, err := d.isHealthy(ctx, c.ID, spec)
+			if err != nil {
+				return stacktypes.StackProgress{}, err
+			}
+			if healthy {
+				status.RunningTasks++
+			}
+		}
+		progress.ServicesStatus[spec.service] = status
+	}
+
+	for service, status := range progress.ServicesStatus {
+		if status.RunningTasks < status.DesiredTasks {
+			progress.Converged = false
+			if status.Message == "" {
+				status.Message = fmt.Sprintf("%d/%d containers running", status.RunningTasks, status.DesiredTasks)
+				progress.ServicesStatus[service] = status
+			}
+		}
+	}
+
+	return progress, nil
+}
+
+// isHealthy returns false for running containers whose healthcheck has not
+// passed yet.
+func (d *Driver) isHealthy(ctx context.Context, containerID string, spec containerSpec) (bool, error) {
+	healthcheck := spec.config.Healthcheck
+	if healthcheck == nil || (len(healthcheck.Test) > 0 && healthcheck.Test[0] == "NONE") {
+		return true, nil
+	}
+
+	c, err := d.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to inspect container %s", containerID)
+	}
+	return c.State != nil && c.State.Health != nil && c.State.Health.Status == types.Healthy, nil
+}
@@ -0,0 +1,76 @@
+package containers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/pkg/errors"
+
+	stacktypes "github.com/docker/stacks/pkg/types"
+)
+
+// driverState is the content of the state file of a Driver.
+type driverState struct {
+	Stacks        []stacktypes.Stack `json:"stacks"`
+	RemoveVolumes []string           `json:"remove_volumes,omitempty"`
+}
+
+// LoadDriver creates a new Driver using the provided docker client, which
+// keeps its stacks in stateFile so that they survive restarts. The stacks
+// previously kept in stateFile are loaded, and reconciled again once the
+// Run loop starts.
+func LoadDriver(client DockerClient, stateFile string) (*Driver, error) {
+	d := NewDriver(client)
+	d.stateFile = stateFile
+
+	content, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the state of the containers driver")
+	}
+	var state driverState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse the state file %s", stateFile)
+	}
+	for _, stack := range state.Stacks {
+		d.stacks[stack.ID] = stack
+	}
+	for _, id := range state.RemoveVolumes {
+		d.removeVolumes[id] = struct{}{}
+	}
+	return d, nil
+}
+
+// saveLocked writes the stacks of the driver, and the deleted stacks whose
+// volumes are still to be removed, to its state file. It is a no-op for
+// drivers without a state file. d.mu must be held.
+func (d *Driver) saveLocked() error {
+	if d.stateFile == "" {
+		return nil
+	}
+	state := driverState{Stacks: []stacktypes.Stack{}}
+	for _, stack := range d.stacks {
+		state.Stacks = append(state.Stacks, stack)
+	}
+	sort.Slice(state.Stacks, func(i, j int) bool {
+		return state.Stacks[i].ID < state.Stacks[j].ID
+	})
+	for id := range d.removeVolumes {
+		state.RemoveVolumes = append(state.RemoveVolumes, id)
+	}
+	sort.Strings(state.RemoveVolumes)
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := ioutils.AtomicWriteFile(d.stateFile, content, 0600); err != nil {
+		return errors.Wrap(err, "unable to save the state of the containers driver")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/admission"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// recordingController labels the services of stacks, rejects the stacks
+// named "rejected", and records the requests it sees.
+type recordingController struct {
+	requests []admission.Request
+}
+
+func (c *recordingController) Name() string {
+	return "recording"
+}
+
+func (c *recordingController) Mutate(req *admission.Request) error {
+	req.Spec.Metadata.Labels = map[string]string{"admitted": "true"}
+	if req.SwarmSpec != nil {
+		for i := range req.SwarmSpec.Services {
+			req.SwarmSpec.Services[i].Annotations.Labels["admitted"] = "true"
+		}
+	}
+	return nil
+}
+
+func (c *recordingController) Validate(req admission.Request) []types.AdmissionViolation {
+	c.requests = append(c.requests, req)
+	if req.Spec.Metadata.Name == "rejected" {
+		return []types.AdmissionViolation{{Message: "stack is rejected"}}
+	}
+	return nil
+}
+
+func TestStacksBackendAdmission(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+	kubeDriver := &fakeDriver{stacks: map[string]types.Stack{}}
+	b.RegisterDriver(kubeDriver)
+	controller := &recordingController{}
+	b.RegisterAdmissionController(controller)
+
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Services: []composeTypes.ServiceConfig{{Name: "web", Image: "nginx:1.17"}},
+	}
+
+	// Swarm stacks are admitted once converted, and stored as mutated
+	resp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+	require.Len(controller.requests, 1)
+	require.Equal(admission.OperationCreate, controller.requests[0].Operation)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), controller.requests[0].Orchestrator)
+	require.NotNil(controller.requests[0].SwarmSpec)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal("true", stack.Spec.Metadata.Labels["admitted"])
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.Equal("true", swarmStack.Spec.Services[0].Annotations.Labels["admitted"])
+
+	_, err = b.UpdateStack(resp.ID, spec, stack.Version.Index)
+	require.NoError(err)
+	require.Len(controller.requests, 2)
+	require.Equal(admission.OperationUpdate, controller.requests[1].Operation)
+	require.Equal(resp.ID, controller.requests[1].StackID)
+
+	// Stacks of other orchestrators are admitted without a SwarmSpec
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorKubernetes,
+		Spec:         spec,
+	})
+	require.NoError(err)
+	require.Len(controller.requests, 3)
+	require.Nil(controller.requests[2].SwarmSpec)
+	require.Equal("true", kubeDriver.stacks["kube_teststack"].Spec.Metadata.Labels["admitted"])
+
+	// Rejected stacks are not stored
+	for _, orchestrator := range []types.OrchestratorChoice{types.OrchestratorSwarm, types.OrchestratorKubernetes} {
+		spec.Metadata.Name = "rejected"
+		_, err = b.CreateStack(types.StackCreate{
+			Orchestrator: orchestrator,
+			Spec:         spec,
+		})
+		require.True(errdefs.IsInvalidParameter(err))
+		admissionErr, ok := errors.Cause(err).(*admission.Error)
+		require.True(ok)
+		require.Equal([]types.AdmissionViolation{{Controller: "recording", Message: "stack is rejected"}}, admissionErr.Violations)
+	}
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 2)
+}
@@ -0,0 +1,273 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/admission"
+	"github.com/docker/stacks/pkg/compose/convert"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/substitution"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// adoptStack creates a stack out of the services, networks, secrets and
+// configs deployed with docker stack deploy in a namespace, which are
+// labeled with it. The stack is named after the namespace, and its spec is
+// reversed from the deployed objects. Its services are stored as they are
+// deployed, so that the reconciler finds nothing to update, and nothing is
+// redeployed.
+//
+// Once stored, the services, secrets and configs are labeled with the ID of
+// the stack, and the stack is removed again if they can't be. The labels of
+// networks can't be changed. Secrets are declared external, as their data
+// can't be read back, and are thus left in place when the stack is deleted.
+func (d *swarmDriver) adoptStack(name string) (string, error) {
+	namespace := convert.NewNamespace(name)
+	namespaceFilter := filters.NewArgs(filters.Arg("label", convert.LabelNamespace+"="+name))
+
+	services, err := d.swarmBackend.GetServices(dockerTypes.ServiceListOptions{Filters: namespaceFilter})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list the services of stack %s", name)
+	}
+	if len(services) == 0 {
+		return "", errdefs.NotFound(fmt.Errorf("no services of stack %s found", name))
+	}
+	for _, service := range services {
+		labels := service.Spec.Annotations.Labels
+		if _, ok := labels[interfaces.StackLabel]; ok || labels[convert.LabelCollection] != "" {
+			return "", errdefs.Conflict(fmt.Errorf("service %s already belongs to a stack", service.Spec.Annotations.Name))
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Spec.Annotations.Name < services[j].Spec.Annotations.Name
+	})
+
+	networks, err := d.swarmBackend.GetNetworks(namespaceFilter)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list the networks of stack %s", name)
+	}
+	secrets, err := d.swarmBackend.GetSecrets(dockerTypes.SecretListOptions{Filters: namespaceFilter})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list the secrets of stack %s", name)
+	}
+	configs, err := d.swarmBackend.GetConfigs(dockerTypes.ConfigListOptions{Filters: namespaceFilter})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list the configs of stack %s", name)
+	}
+
+	stack := types.Stack{
+		Orchestrator: types.OrchestratorSwarm,
+	}
+	stack.Spec, err = d.adoptedStackSpec(namespace, services, networks, configs)
+	if err != nil {
+		return "", err
+	}
+	if err := d.checkNameAvailable(stack.Spec, ""); err != nil {
+		return "", err
+	}
+
+	substitutedSpec, err := substitution.DoSubstitution(stack.Spec)
+	if err != nil {
+		return "", fmt.Errorf("unable to translate swarm spec: %s", err)
+	}
+	swarmSpec, _, err := d.convertToSwarmStackSpec(stack.Spec, substitutedSpec)
+	if err != nil {
+		return "", fmt.Errorf("unable to translate swarm spec: %s", err)
+	}
+	swarmSpec.Services = make([]swarm.ServiceSpec, len(services))
+	for i, service := range services {
+		swarmSpec.Services[i] = service.Spec
+	}
+	if err := d.admit(&admission.Request{
+		Operation:    admission.OperationCreate,
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         &stack.Spec,
+		SwarmSpec:    &swarmSpec,
+	}); err != nil {
+		return "", err
+	}
+
+	id, err := d.stackStore.AddStack(stack, interfaces.SwarmStack{Spec: swarmSpec})
+	if err != nil {
+		return "", fmt.Errorf("unable to store stack: %s", err)
+	}
+
+	if err := d.labelAdoptedObjects(id, services, swarmSpec, secrets, configs); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// labelAdoptedObjects labels the services, secrets and configs of a stack
+// being adopted with its ID. If labeling fails part-way, the labels of the
+// objects labeled so far are reverted and the stored stack is removed, so
+// that adoption can be retried. If reverting fails too, the stack is kept,
+// as the reconciler would otherwise remove the services still labeled with
+// it.
+func (d *swarmDriver) labelAdoptedObjects(id string, services []swarm.Service, swarmSpec interfaces.SwarmStackSpec, secrets []swarm.Secret, configs []swarm.Config) error {
+	// reverts unlabels the objects labeled so far, in reverse order.
+	var reverts []func() error
+	rollback := func(err error) error {
+		for i := len(reverts) - 1; i >= 0; i-- {
+			if revertErr := reverts[i](); revertErr != nil {
+				return errors.Wrapf(err, "adopted stack %s was kept, as some of its objects could not be unlabeled (%s)", id, revertErr)
+			}
+		}
+		if deleteErr := d.stackStore.DeleteStack(id); deleteErr != nil {
+			return errors.Wrapf(err, "unable to remove adopted stack %s (%s)", id, deleteErr)
+		}
+		return err
+	}
+
+	// the services are labeled once their stored specs are, as the
+	// reconciler would otherwise update them back.
+	stored, err := d.stackStore.GetStack(id)
+	if err != nil {
+		return rollback(errors.Wrapf(err, "unable to retrieve adopted stack %s", id))
+	}
+	labeledSpec := swarmSpec
+	labeledSpec.Services = make([]swarm.ServiceSpec, len(swarmSpec.Services))
+	for i, service := range swarmSpec.Services {
+		labeledSpec.Services[i] = service
+		labeledSpec.Services[i].Annotations.Labels = withStackLabel(service.Annotations.Labels, id)
+	}
+	if err := d.stackStore.UpdateStack(id, stored.Spec, labeledSpec, stored.Version.Index); err != nil {
+		return rollback(errors.Wrapf(err, "unable to label the services of adopted stack %s", id))
+	}
+
+	for i, service := range services {
+		if _, err := d.swarmBackend.UpdateService(service.ID, service.Version.Index, labeledSpec.Services[i], dockerTypes.ServiceUpdateOptions{}, false); err != nil {
+			return rollback(errors.Wrapf(err, "unable to label service %s of adopted stack %s", service.Spec.Annotations.Name, id))
+		}
+		service := service
+		reverts = append(reverts, func() error {
+			current, err := d.swarmBackend.GetService(service.ID, false)
+			if err != nil {
+				return err
+			}
+			_, err = d.swarmBackend.UpdateService(service.ID, current.Version.Index, service.Spec, dockerTypes.ServiceUpdateOptions{}, false)
+			return err
+		})
+	}
+	for _, secret := range secrets {
+		spec := secret.Spec
+		spec.Annotations.Labels = withStackLabel(spec.Annotations.Labels, id)
+		if err := d.swarmBackend.UpdateSecret(secret.ID, secret.Version.Index, spec); err != nil {
+			return rollback(errors.Wrapf(err, "unable to label secret %s of adopted stack %s", spec.Annotations.Name, id))
+		}
+		secret := secret
+		reverts = append(reverts, func() error {
+			current, err := d.swarmBackend.GetSecret(secret.ID)
+			if err != nil {
+				return err
+			}
+			return d.swarmBackend.UpdateSecret(secret.ID, current.Version.Index, secret.Spec)
+		})
+	}
+	for _, config := range configs {
+		spec := config.Spec
+		spec.Annotations.Labels = withStackLabel(spec.Annotations.Labels, id)
+		if err := d.swarmBackend.UpdateConfig(config.ID, config.Version.Index, spec); err != nil {
+			return rollback(errors.Wrapf(err, "unable to label config %s of adopted stack %s", spec.Annotations.Name, id))
+		}
+		config := config
+		reverts = append(reverts, func() error {
+			current, err := d.swarmBackend.GetConfig(config.ID)
+			if err != nil {
+				return err
+			}
+			return d.swarmBackend.UpdateConfig(config.ID, current.Version.Index, config.Spec)
+		})
+	}
+	return nil
+}
+
+// adoptedStackSpec reverses the spec of a stack deployed in namespace from
+// its objects. The networks, secrets and configs the services use which
+// don't belong to the namespace are declared external.
+func (d *swarmDriver) adoptedStackSpec(namespace convert.Namespace, services []swarm.Service, networks []dockerTypes.NetworkResource, configs []swarm.Config) (types.StackSpec, error) {
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: namespace.Name()},
+		Networks: map[string]composetypes.NetworkConfig{},
+		Secrets:  map[string]composetypes.SecretConfig{},
+		Configs:  map[string]composetypes.ConfigObjConfig{},
+		Files:    map[string][]byte{},
+	}
+
+	networkNames := map[string]string{}
+	for _, network := range networks {
+		networkNames[network.ID] = network.Name
+		key, config := convert.NetworkConfig(namespace, network)
+		spec.Networks[key] = config
+	}
+	configNames := map[string]bool{}
+	for _, config := range configs {
+		configNames[config.Spec.Annotations.Name] = true
+		key, obj := convert.ConfigObjConfig(namespace, config)
+		spec.Configs[key] = obj
+		spec.Files[obj.File] = config.Spec.Data
+	}
+
+	serviceSpecs := make([]swarm.ServiceSpec, len(services))
+	for i, service := range services {
+		serviceSpecs[i] = service.Spec
+		for _, attachment := range service.Spec.TaskTemplate.Networks {
+			if _, ok := networkNames[attachment.Target]; ok {
+				continue
+			}
+			network, err := d.swarmBackend.GetNetwork(attachment.Target)
+			if err != nil {
+				return types.StackSpec{}, errors.Wrapf(err, "unable to look up network %s of service %s", attachment.Target, service.Spec.Annotations.Name)
+			}
+			networkNames[attachment.Target] = network.Name
+			spec.Networks[convert.ObjectKey(namespace, network.Name)] = composetypes.NetworkConfig{
+				Name:     network.Name,
+				External: composetypes.External{External: true},
+			}
+		}
+
+		containerSpec := service.Spec.TaskTemplate.ContainerSpec
+		if containerSpec == nil {
+			continue
+		}
+		for _, secret := range containerSpec.Secrets {
+			spec.Secrets[convert.ObjectKey(namespace, secret.SecretName)] = composetypes.SecretConfig{
+				Name:     secret.SecretName,
+				External: composetypes.External{External: true},
+			}
+		}
+		for _, config := range containerSpec.Configs {
+			if configNames[config.ConfigName] {
+				continue
+			}
+			spec.Configs[convert.ObjectKey(namespace, config.ConfigName)] = composetypes.ConfigObjConfig{
+				Name:     config.ConfigName,
+				External: composetypes.External{External: true},
+			}
+		}
+	}
+
+	for _, service := range serviceSpecs {
+		spec.Services = append(spec.Services, convert.ServiceConfig(namespace, service, networkNames))
+	}
+	spec.Volumes = convert.MountedVolumes(namespace, serviceSpecs)
+	return spec, nil
+}
+
+// withStackLabel returns a copy of labels with the StackLabel of a stack.
+func withStackLabel(labels map[string]string, id string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		result[key] = value
+	}
+	result[interfaces.StackLabel] = id
+	return result
+}
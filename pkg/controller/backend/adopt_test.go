@@ -0,0 +1,253 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/stacks/pkg/compose/convert"
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// deployedService returns a service as deployed by docker stack deploy in
+// the app namespace.
+func deployedService() swarm.Service {
+	namespaceLabels := map[string]string{convert.LabelNamespace: "app"}
+	return swarm.Service{
+		ID:   "service1",
+		Meta: swarm.Meta{Version: swarm.Version{Index: 7}},
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{
+				Name:   "app_web",
+				Labels: map[string]string{convert.LabelNamespace: "app", convert.LabelImage: "nginx"},
+			},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{
+					Image:  "nginx:latest@sha256:abcd",
+					Labels: namespaceLabels,
+					Mounts: []mount.Mount{{
+						Type:          mount.TypeVolume,
+						Source:        "app_data",
+						Target:        "/data",
+						VolumeOptions: &mount.VolumeOptions{Labels: namespaceLabels},
+					}},
+					Secrets: []*swarm.SecretReference{{
+						SecretID:   "secret1",
+						SecretName: "app_password",
+						File:       &swarm.SecretReferenceFileTarget{Name: "password", UID: "0", GID: "0", Mode: 0444},
+					}},
+					Configs: []*swarm.ConfigReference{{
+						ConfigID:   "config1",
+						ConfigName: "app_nginx",
+						File:       &swarm.ConfigReferenceFileTarget{Name: "/etc/nginx.conf", UID: "0", GID: "0", Mode: 0444},
+					}},
+				},
+				Networks: []swarm.NetworkAttachmentConfig{
+					{Target: "network1", Aliases: []string{"web"}},
+					{Target: "network2", Aliases: []string{"web"}},
+				},
+			},
+		},
+	}
+}
+
+func TestStacksBackendAdoptStack(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	service := deployedService()
+	secret := swarm.Secret{
+		ID:   "secret1",
+		Meta: swarm.Meta{Version: swarm.Version{Index: 3}},
+		Spec: swarm.SecretSpec{Annotations: swarm.Annotations{
+			Name:   "app_password",
+			Labels: map[string]string{convert.LabelNamespace: "app"},
+		}},
+	}
+	config := swarm.Config{
+		ID:   "config1",
+		Meta: swarm.Meta{Version: swarm.Version{Index: 4}},
+		Spec: swarm.ConfigSpec{
+			Annotations: swarm.Annotations{
+				Name:   "app_nginx",
+				Labels: map[string]string{convert.LabelNamespace: "app"},
+			},
+			Data: []byte("server {}"),
+		},
+	}
+	namespaceFilter := filters.NewArgs(filters.Arg("label", convert.LabelNamespace+"=app"))
+
+	backendClient.EXPECT().GetServices(dockerTypes.ServiceListOptions{Filters: namespaceFilter}).Return([]swarm.Service{service}, nil).Times(2)
+	backendClient.EXPECT().GetNetworks(namespaceFilter).Return([]dockerTypes.NetworkResource{{
+		ID:     "network1",
+		Name:   "app_default",
+		Driver: "overlay",
+		Labels: map[string]string{convert.LabelNamespace: "app"},
+	}}, nil).Times(2)
+	backendClient.EXPECT().GetNetwork("network2").Return(dockerTypes.NetworkResource{ID: "network2", Name: "proxy"}, nil).Times(2)
+	backendClient.EXPECT().GetSecrets(gomock.Any()).Return([]swarm.Secret{secret}, nil).AnyTimes()
+	backendClient.EXPECT().GetConfigs(gomock.Any()).Return([]swarm.Config{config}, nil).AnyTimes()
+
+	// the objects are only labeled with the ID of the stack.
+	labeled := service.Spec
+	labeled.Annotations.Labels = map[string]string{
+		convert.LabelNamespace: "app",
+		convert.LabelImage:     "nginx",
+		interfaces.StackLabel:  "1",
+	}
+	backendClient.EXPECT().UpdateService("service1", uint64(7), labeled, dockerTypes.ServiceUpdateOptions{}, false).Return(&dockerTypes.ServiceUpdateResponse{}, nil)
+	labeledSecret := secret.Spec
+	labeledSecret.Annotations.Labels = map[string]string{convert.LabelNamespace: "app", interfaces.StackLabel: "1"}
+	backendClient.EXPECT().UpdateSecret("secret1", uint64(3), labeledSecret).Return(nil)
+	labeledConfig := config.Spec
+	labeledConfig.Annotations.Labels = map[string]string{convert.LabelNamespace: "app", interfaces.StackLabel: "1"}
+	backendClient.EXPECT().UpdateConfig("config1", uint64(4), labeledConfig).Return(nil)
+
+	resp, err := b.AdoptStack(types.StackAdopt{Namespace: "app"})
+	require.NoError(err)
+	require.Equal("1", resp.ID)
+
+	// the services are stored as they are deployed, so that the reconciler
+	// doesn't update them.
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.Equal([]swarm.ServiceSpec{labeled}, swarmStack.Spec.Services)
+	require.Contains(swarmStack.Spec.Networks, "app_default")
+	require.Len(swarmStack.Spec.Configs, 1)
+	require.Empty(swarmStack.Spec.Secrets)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal("app", stack.Spec.Name)
+	require.Len(stack.Spec.Services, 1)
+	require.Equal("app_web", stack.Spec.Services[0].Name)
+	require.Equal("nginx", stack.Spec.Services[0].Image)
+	require.Equal(map[string]*composeTypes.ServiceNetworkConfig{
+		"default": {Aliases: []string{"web"}},
+		"proxy":   {Aliases: []string{"web"}},
+	}, stack.Spec.Services[0].Networks)
+	require.Equal(composeTypes.NetworkConfig{Driver: "overlay"}, stack.Spec.Networks["default"])
+	require.True(stack.Spec.Networks["proxy"].External.External)
+	require.Equal(composeTypes.SecretConfig{
+		Name:     "app_password",
+		External: composeTypes.External{External: true},
+	}, stack.Spec.Secrets["password"])
+	require.Equal(composeTypes.ConfigObjConfig{File: "app_nginx"}, stack.Spec.Configs["nginx"])
+	require.Nil(stack.Spec.Files)
+	stored, err := b.stackStore.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal([]byte("server {}"), stored.Spec.Files["app_nginx"])
+	require.Equal(map[string]composeTypes.VolumeConfig{"data": {}}, stack.Spec.Volumes)
+
+	// the namespace is now taken by the adopted stack, so it is listed
+	// again before being rejected
+	_, err = b.AdoptStack(types.StackAdopt{Namespace: "app"})
+	require.True(errdefs.IsConflict(err), "expected a conflict, got %v", err)
+}
+
+func TestStacksBackendAdoptStackInvalid(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	_, err := b.AdoptStack(types.StackAdopt{})
+	require.True(errdefs.IsInvalidParameter(err), "expected an invalid parameter, got %v", err)
+
+	backendClient.EXPECT().GetServices(gomock.Any()).Return(nil, nil)
+	_, err = b.AdoptStack(types.StackAdopt{Namespace: "app"})
+	require.True(errdefs.IsNotFound(err), "expected not found, got %v", err)
+
+	// services which already belong to a stack can't be adopted
+	service := deployedService()
+	service.Spec.Annotations.Labels[interfaces.StackLabel] = "2"
+	backendClient.EXPECT().GetServices(gomock.Any()).Return([]swarm.Service{service}, nil)
+	_, err = b.AdoptStack(types.StackAdopt{Namespace: "app"})
+	require.True(errdefs.IsConflict(err), "expected a conflict, got %v", err)
+
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Empty(stacks)
+}
+
+func TestStacksBackendAdoptStackRollback(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	service := deployedService()
+	secret := swarm.Secret{
+		ID:   "secret1",
+		Meta: swarm.Meta{Version: swarm.Version{Index: 3}},
+		Spec: swarm.SecretSpec{Annotations: swarm.Annotations{
+			Name:   "app_password",
+			Labels: map[string]string{convert.LabelNamespace: "app"},
+		}},
+	}
+	config := swarm.Config{
+		ID:   "config1",
+		Meta: swarm.Meta{Version: swarm.Version{Index: 4}},
+		Spec: swarm.ConfigSpec{
+			Annotations: swarm.Annotations{
+				Name:   "app_nginx",
+				Labels: map[string]string{convert.LabelNamespace: "app"},
+			},
+			Data: []byte("server {}"),
+		},
+	}
+	backendClient.EXPECT().GetServices(gomock.Any()).Return([]swarm.Service{service}, nil).AnyTimes()
+	backendClient.EXPECT().GetNetworks(gomock.Any()).Return(nil, nil).AnyTimes()
+	backendClient.EXPECT().GetNetwork(gomock.Any()).Return(dockerTypes.NetworkResource{Name: "proxy"}, nil).AnyTimes()
+	backendClient.EXPECT().GetSecrets(gomock.Any()).Return([]swarm.Secret{secret}, nil).AnyTimes()
+	backendClient.EXPECT().GetConfigs(gomock.Any()).Return([]swarm.Config{config}, nil).AnyTimes()
+
+	// labeling the config fails, so the service and the secret are
+	// unlabeled, from their current versions, and the stack is removed
+	backendClient.EXPECT().UpdateService("service1", uint64(7), gomock.Any(), dockerTypes.ServiceUpdateOptions{}, false).Return(&dockerTypes.ServiceUpdateResponse{}, nil)
+	backendClient.EXPECT().UpdateSecret("secret1", uint64(3), gomock.Any()).Return(nil)
+	backendClient.EXPECT().UpdateConfig("config1", uint64(4), gomock.Any()).Return(errors.New("unavailable"))
+	labeledService := service
+	labeledService.Version.Index = 8
+	backendClient.EXPECT().GetService("service1", false).Return(labeledService, nil)
+	backendClient.EXPECT().UpdateService("service1", uint64(8), service.Spec, dockerTypes.ServiceUpdateOptions{}, false).Return(&dockerTypes.ServiceUpdateResponse{}, nil)
+	labeledSecret := secret
+	labeledSecret.Version.Index = 5
+	backendClient.EXPECT().GetSecret("secret1").Return(labeledSecret, nil)
+	backendClient.EXPECT().UpdateSecret("secret1", uint64(5), secret.Spec).Return(nil)
+
+	_, err := b.AdoptStack(types.StackAdopt{Namespace: "app"})
+	require.Error(err)
+	require.Contains(err.Error(), "unable to label config app_nginx of adopted stack 1: unavailable")
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Empty(stacks)
+
+	// the stack is kept if the service can't be unlabeled, as the
+	// reconciler would otherwise remove it
+	backendClient.EXPECT().UpdateService("service1", uint64(7), gomock.Any(), dockerTypes.ServiceUpdateOptions{}, false).Return(&dockerTypes.ServiceUpdateResponse{}, nil)
+	backendClient.EXPECT().UpdateSecret("secret1", uint64(3), gomock.Any()).Return(errors.New("unavailable"))
+	backendClient.EXPECT().GetService("service1", false).Return(swarm.Service{}, errors.New("unavailable"))
+
+	_, err = b.AdoptStack(types.StackAdopt{Namespace: "app"})
+	require.Error(err)
+	require.Contains(err.Error(), "adopted stack 2 was kept")
+	stacks, err = b.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 1)
+}
@@ -2,75 +2,258 @@ package backend
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/docker/docker/api/types/swarm"
-	"github.com/docker/stacks/pkg/compose/convert"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/admission"
 	"github.com/docker/stacks/pkg/compose/loader"
-	composetypes "github.com/docker/stacks/pkg/compose/types"
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/substitution"
 	"github.com/docker/stacks/pkg/types"
 )
 
 // DefaultStacksBackend implements the interfaces.StacksBackend interface, which serves as the
-// API handler for the Stacks APIs.
+// API handler for the Stacks APIs. Stack operations are routed to the
+// orchestrator driver of each stack.
 type DefaultStacksBackend struct {
-	// stackStore is the underlying CRUD store of stacks.
+	// stackStore is the underlying CRUD store of swarm stacks.
 	stackStore interfaces.StackStore
 
-	// swarmBackend provides access to swarmkit operations on secrets
-	// and configs, required for stack validation and conversion.
-	swarmBackend interfaces.SwarmResourceBackend
+	// drivers are the registered orchestrator drivers, by orchestrator.
+	drivers map[types.OrchestratorChoice]interfaces.OrchestratorDriver
+
+	// events records the stack lifecycle events and streams them to
+	// subscribers.
+	events *eventBroker
+
+	// phases are the phases of the stacks, as reported by their lifecycle
+	// events.
+	phases *stackPhases
+
+	// admission is the chain of admission controllers run on stacks
+	// before they are stored.
+	admission *admission.Chain
+
+	// quotas are the quotas of collections, by collection.
+	quotas   map[string]types.QuotaResources
+	quotasMu sync.Mutex
+
+	// collectionLocks serialize the creations and updates of the stacks
+	// of each collection, by collectionKey.
+	collectionLocks   map[string]*sync.Mutex
+	collectionLocksMu sync.Mutex
+}
+
+// admittingDriver is implemented by the drivers which run the admission
+// chain themselves, once their stacks are converted, so that admission
+// controllers see the converted stacks.
+type admittingDriver interface {
+	setAdmission(admit func(*admission.Request) error)
+}
+
+// warningDriver is implemented by the drivers which report the options of
+// the stacks they create and update which are ignored.
+type warningDriver interface {
+	createStackWithWarnings(types.StackCreate) (string, []types.StackWarning, error)
+	updateStackWithWarnings(id string, spec types.StackSpec, version uint64) ([]types.StackWarning, error)
+}
+
+// adoptingDriver is implemented by the drivers which can create stacks out
+// of the objects deployed with docker stack deploy.
+type adoptingDriver interface {
+	adoptStack(namespace string) (string, error)
+}
+
+// statusDriver is implemented by the drivers which report the status of
+// their stacks in the stacks returned by GetStack and ListStacks. The
+// stacks the backend looks up for itself are left without, as computing
+// the status may be costly. Stacks of other orchestrators are ignored.
+type statusDriver interface {
+	setStacksStatus(stacks []types.Stack)
+}
+
+// publishingDriver is implemented by the drivers which reconcile their
+// stacks themselves, and publish the progress of the reconciliation as stack
+// lifecycle events.
+type publishingDriver interface {
+	SetEventPublisher(publish func(types.StackEvent))
 }
 
-// NewDefaultStacksBackend creates a new DefaultStacksBackend.
+// NewDefaultStacksBackend creates a new DefaultStacksBackend, with a swarm
+// driver storing stacks in stackStore. Drivers for other orchestrators can
+// be added with RegisterDriver.
 func NewDefaultStacksBackend(stackStore interfaces.StackStore, swarmBackend interfaces.SwarmResourceBackend) *DefaultStacksBackend {
-	return &DefaultStacksBackend{
-		stackStore:   stackStore,
-		swarmBackend: swarmBackend,
+	b := &DefaultStacksBackend{
+		stackStore: stackStore,
+		drivers:    make(map[types.OrchestratorChoice]interfaces.OrchestratorDriver),
+		events:     newEventBroker(),
+		phases:     newStackPhases(),
+		admission:  admission.NewChain(),
+		quotas:     map[string]types.QuotaResources{},
+
+		collectionLocks: map[string]*sync.Mutex{},
+	}
+	b.RegisterAdmissionController(quotaController{backend: b})
+	b.RegisterDriver(newSwarmDriver(stackStore, swarmBackend))
+	return b
+}
+
+// RegisterDriver registers an orchestrator driver. If a driver already
+// exists for the orchestrator of the new driver, it is overridden.
+func (b *DefaultStacksBackend) RegisterDriver(driver interfaces.OrchestratorDriver) {
+	if d, ok := driver.(admittingDriver); ok {
+		d.setAdmission(b.admission.Admit)
+	}
+	if d, ok := driver.(publishingDriver); ok {
+		d.SetEventPublisher(b.PublishStackEvent)
+	}
+	b.drivers[driver.Orchestrator()] = driver
+}
+
+// RegisterAdmissionController adds an admission controller at the end of
+// the admission chain, run on stacks before they are created or updated.
+func (b *DefaultStacksBackend) RegisterAdmissionController(controller admission.Controller) {
+	b.admission.Register(controller)
+}
+
+// admit runs the admission chain on a stack, unless its driver runs it
+// itself.
+func (b *DefaultStacksBackend) admit(driver interfaces.OrchestratorDriver, req *admission.Request) error {
+	if _, ok := driver.(admittingDriver); ok {
+		return nil
+	}
+	req.Orchestrator = driver.Orchestrator()
+	return b.admission.Admit(req)
+}
+
+// sortedDrivers returns the registered drivers ordered by orchestrator, so
+// that stacks are listed and looked up in a stable order.
+func (b *DefaultStacksBackend) sortedDrivers() []interfaces.OrchestratorDriver {
+	drivers := make([]interfaces.OrchestratorDriver, 0, len(b.drivers))
+	for _, driver := range b.drivers {
+		drivers = append(drivers, driver)
+	}
+	sort.Slice(drivers, func(i, j int) bool {
+		return drivers[i].Orchestrator() < drivers[j].Orchestrator()
+	})
+	return drivers
+}
+
+// findStack looks for a stack across all drivers, and returns it along with
+// the driver it belongs to.
+func (b *DefaultStacksBackend) findStack(id string) (types.Stack, interfaces.OrchestratorDriver, error) {
+	for _, driver := range b.sortedDrivers() {
+		stack, err := driver.GetStack(id)
+		switch {
+		case errdefs.IsNotFound(err):
+			continue
+		case err != nil:
+			return types.Stack{}, nil, errors.Wrapf(err, "unable to look for stack in %s driver", driver.Orchestrator())
+		}
+		return stack, driver, nil
 	}
+	return types.Stack{}, nil, errdefs.NotFound(errors.New("stack not found"))
 }
 
-// CreateStack creates a new stack if the stack is valid.
+// CreateStack creates a new stack with the driver of its orchestrator, if
+// the stack is valid.
 func (b *DefaultStacksBackend) CreateStack(create types.StackCreate) (types.StackCreateResponse, error) {
-	if create.Orchestrator != types.OrchestratorSwarm {
-		return types.StackCreateResponse{}, fmt.Errorf("invalid orchestrator type %s. This backend only supports orchestrator type swarm", create.Orchestrator)
+	if err := validateVolumeRemovalPolicy(create.Spec.VolumeRemovalPolicy); err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	if err := validateCollection(create.Spec.Collection); err != nil {
+		return types.StackCreateResponse{}, err
+	}
+	if err := substitution.ValidateProperties(create.Spec); err != nil {
+		return types.StackCreateResponse{}, errdefs.InvalidParameter(err)
 	}
 
-	// Create the Swarm Stack object
-	stack := types.Stack{
-		Spec:         create.Spec,
-		Orchestrator: types.OrchestratorSwarm,
+	driver, ok := b.drivers[create.Orchestrator]
+	if !ok {
+		return types.StackCreateResponse{}, errdefs.InvalidParameter(fmt.Errorf("invalid orchestrator type %s. No driver is registered for this orchestrator", create.Orchestrator))
 	}
 
-	// Convert to the Stack to a SwarmStack
-	swarmSpec, err := b.convertToSwarmStackSpec(create.Spec)
+	unlock := b.lockCollection(collectionKey(create.Spec.Collection, create.Spec.Metadata.Name))
+	defer unlock()
+
+	if err := b.admit(driver, &admission.Request{
+		Operation: admission.OperationCreate,
+		Spec:      &create.Spec,
+	}); err != nil {
+		return types.StackCreateResponse{}, err
+	}
+
+	var (
+		id       string
+		warnings []types.StackWarning
+		err      error
+	)
+	if d, ok := driver.(warningDriver); ok {
+		id, warnings, err = d.createStackWithWarnings(create)
+	} else {
+		id, err = driver.CreateStack(create)
+	}
 	if err != nil {
-		return types.StackCreateResponse{}, fmt.Errorf("unable to translate swarm spec: %s", err)
+		return types.StackCreateResponse{}, err
+	}
+
+	b.PublishStackEvent(types.StackEvent{
+		StackID: id,
+		Action:  types.StackEventCreated,
+	})
+
+	return types.StackCreateResponse{
+		ID:       id,
+		Warnings: warnings,
+		Stack:    storedStack(driver, id),
+	}, nil
+}
+
+// AdoptStack creates a swarm stack out of the objects deployed with docker
+// stack deploy in a namespace, without redeploying them.
+func (b *DefaultStacksBackend) AdoptStack(adopt types.StackAdopt) (types.StackCreateResponse, error) {
+	if adopt.Namespace == "" {
+		return types.StackCreateResponse{}, errdefs.InvalidParameter(errors.New("a namespace is required to adopt a stack"))
 	}
 
-	swarmStack := interfaces.SwarmStack{
-		Spec: swarmSpec,
+	driver, ok := b.drivers[types.OrchestratorSwarm].(adoptingDriver)
+	if !ok {
+		return types.StackCreateResponse{}, errdefs.NotImplemented(fmt.Errorf("the %s driver can't adopt stacks", types.OrchestratorSwarm))
 	}
+	unlock := b.lockCollection(collectionKey("", adopt.Namespace))
+	defer unlock()
 
-	id, err := b.stackStore.AddStack(stack, swarmStack)
+	id, err := driver.adoptStack(adopt.Namespace)
 	if err != nil {
-		return types.StackCreateResponse{}, fmt.Errorf("unable to store stack: %s", err)
+		return types.StackCreateResponse{}, err
 	}
 
+	b.PublishStackEvent(types.StackEvent{
+		StackID: id,
+		Action:  types.StackEventCreated,
+	})
 	return types.StackCreateResponse{
-		ID: id,
-	}, err
+		ID:    id,
+		Stack: storedStack(b.drivers[types.OrchestratorSwarm], id),
+	}, nil
 }
 
-// GetStack retrieves a stack by its ID.
+// GetStack retrieves a stack by its ID, from any driver. The contents of
+// the files of its secrets and configs are left out.
 func (b *DefaultStacksBackend) GetStack(id string) (types.Stack, error) {
-	stack, err := b.stackStore.GetStack(id)
+	stack, _, err := b.findStack(id)
 	if err != nil {
-		return types.Stack{}, fmt.Errorf("unable to retrieve stack %s: %s", id, err)
+		return types.Stack{}, err
 	}
-
-	return stack, err
+	stacks := []types.Stack{stack}
+	b.setStacksStatus(stacks)
+	return withoutFiles(stacks[0]), nil
 }
 
 // GetSwarmStack retrieves a swarm stack by its ID.
@@ -78,15 +261,47 @@ func (b *DefaultStacksBackend) GetStack(id string) (types.Stack, error) {
 func (b *DefaultStacksBackend) GetSwarmStack(id string) (interfaces.SwarmStack, error) {
 	stack, err := b.stackStore.GetSwarmStack(id)
 	if err != nil {
-		return interfaces.SwarmStack{}, fmt.Errorf("unable to retrieve swarm stack %s: %s", id, err)
+		return interfaces.SwarmStack{}, errors.Wrapf(err, "unable to retrieve swarm stack %s", id)
 	}
 
 	return stack, err
 }
 
-// ListStacks lists all stacks.
+// ListStacks lists all stacks across all drivers, without the contents of
+// the files of their secrets and configs.
 func (b *DefaultStacksBackend) ListStacks() ([]types.Stack, error) {
-	return b.stackStore.ListStacks()
+	stacks, err := b.listStacks()
+	if err != nil {
+		return []types.Stack{}, err
+	}
+	b.setStacksStatus(stacks)
+	for i := range stacks {
+		stacks[i] = withoutFiles(stacks[i])
+	}
+	return stacks, nil
+}
+
+// listStacks lists all stacks across all drivers, as they are stored.
+func (b *DefaultStacksBackend) listStacks() ([]types.Stack, error) {
+	allStacks := []types.Stack{}
+	for _, driver := range b.sortedDrivers() {
+		stacks, err := driver.ListStacks()
+		if err != nil {
+			return []types.Stack{}, fmt.Errorf("unable to list stacks from %s driver: %s", driver.Orchestrator(), err)
+		}
+		allStacks = append(allStacks, stacks...)
+	}
+	return allStacks, nil
+}
+
+// setStacksStatus sets the status of stacks, with the drivers which report
+// one.
+func (b *DefaultStacksBackend) setStacksStatus(stacks []types.Stack) {
+	for _, driver := range b.sortedDrivers() {
+		if statuses, ok := driver.(statusDriver); ok {
+			statuses.setStacksStatus(stacks)
+		}
+	}
 }
 
 // ListSwarmStacks lists all swarm stacks.
@@ -95,107 +310,231 @@ func (b *DefaultStacksBackend) ListSwarmStacks() ([]interfaces.SwarmStack, error
 	return b.stackStore.ListSwarmStacks()
 }
 
-// UpdateStack updates a stack.
-func (b *DefaultStacksBackend) UpdateStack(id string, spec types.StackSpec, version uint64) error {
-	// Convert the new StackSpec to a SwarmStackSpec, while retaining the
-	// namespace label.
-	swarmSpec, err := b.convertToSwarmStackSpec(spec)
-	if err != nil {
-		return fmt.Errorf("unable to translate swarm spec: %s", err)
-	}
-
-	return b.stackStore.UpdateStack(id, spec, swarmSpec, version)
+// AddStackResource records an object realized for a swarm stack in its
+// StackResources, under the given name.
+// NOTE: this is an internal-only method used by the Swarm Stacks Reconciler.
+func (b *DefaultStacksBackend) AddStackResource(stackID, name string, resource types.StackResource) error {
+	return b.updateStackResources(stackID, func(resources *types.StackResources) {
+		resources.Set(name, resource)
+	})
 }
 
-// DeleteStack deletes a stack.
-func (b *DefaultStacksBackend) DeleteStack(id string) error {
-	return b.stackStore.DeleteStack(id)
+// RemoveStackResource removes an object from the StackResources of a swarm
+// stack.
+// NOTE: this is an internal-only method used by the Swarm Stacks Reconciler.
+func (b *DefaultStacksBackend) RemoveStackResource(stackID, kind, name string) error {
+	return b.updateStackResources(stackID, func(resources *types.StackResources) {
+		resources.Remove(kind, name)
+	})
 }
 
-// ParseComposeInput parses a compose file and returns the StackCreate object with the spec and any properties
-func (b *DefaultStacksBackend) ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
-	return loader.ParseComposeInput(input)
+func (b *DefaultStacksBackend) updateStackResources(stackID string, update func(*types.StackResources)) error {
+	stack, err := b.stackStore.GetStack(stackID)
+	if err != nil {
+		return err
+	}
+	update(&stack.StackResources)
+	if err := b.stackStore.UpdateStackResources(stackID, stack.StackResources); err != nil {
+		return fmt.Errorf("unable to update resources of stack %s: %s", stackID, err)
+	}
+	return nil
 }
 
-func (b *DefaultStacksBackend) convertToSwarmStackSpec(spec types.StackSpec) (interfaces.SwarmStackSpec, error) {
-	// Substitute variables with desired property values
-	substitutedSpec, err := substitution.DoSubstitution(spec)
+// UpdateStack updates a stack with the driver it belongs to.
+func (b *DefaultStacksBackend) UpdateStack(id string, spec types.StackSpec, version uint64) (types.StackUpdateResponse, error) {
+	if err := validateVolumeRemovalPolicy(spec.VolumeRemovalPolicy); err != nil {
+		return types.StackUpdateResponse{}, err
+	}
+	if err := validateCollection(spec.Collection); err != nil {
+		return types.StackUpdateResponse{}, err
+	}
+
+	current, driver, err := b.findStack(id)
 	if err != nil {
-		return interfaces.SwarmStackSpec{}, err
+		return types.StackUpdateResponse{}, err
+	}
+
+	// the values of sensitive properties are not returned to clients, so
+	// updates keep them unless they provide new ones.
+	spec = substitution.PreserveSensitiveValues(current.Spec, spec)
+	// neither are the contents of files.
+	spec = preserveFiles(current.Spec, spec)
+	if err := substitution.ValidateProperties(spec); err != nil {
+		return types.StackUpdateResponse{}, errdefs.InvalidParameter(err)
 	}
 
-	namespace := convert.NewNamespace(spec.Metadata.Name)
+	// moving a stack to another collection locks both collections
+	unlock := b.lockCollections(
+		collectionKey(current.Spec.Collection, current.Spec.Metadata.Name),
+		collectionKey(spec.Collection, spec.Metadata.Name),
+	)
+	defer unlock()
 
-	services, err := convert.Services(namespace, substitutedSpec, b.swarmBackend)
+	if err := b.admit(driver, &admission.Request{
+		Operation: admission.OperationUpdate,
+		StackID:   id,
+		Spec:      &spec,
+	}); err != nil {
+		return types.StackUpdateResponse{}, err
+	}
+
+	restorePhase := b.phases.setPending(id)
+	var warnings []types.StackWarning
+	if d, ok := driver.(warningDriver); ok {
+		warnings, err = d.updateStackWithWarnings(id, spec, version)
+	} else {
+		err = driver.UpdateStack(id, spec, version)
+	}
 	if err != nil {
-		return interfaces.SwarmStackSpec{}, fmt.Errorf("failed to convert services : %s", err)
+		restorePhase()
+		return types.StackUpdateResponse{}, err
 	}
 
-	configs, err := convert.Configs(namespace, substitutedSpec.Configs)
+	b.PublishStackEvent(types.StackEvent{
+		StackID: id,
+		Action:  types.StackEventUpdated,
+	})
+	return types.StackUpdateResponse{
+		Warnings: warnings,
+		Stack:    storedStack(driver, id),
+	}, nil
+}
+
+// storedStack returns a stack as a creation or update stored it, without
+// the contents of its files. It is looked up while the collection of the
+// stack is still locked, so that no other creation or update is mistaken
+// for this one. Failures are only logged, as the stack is already stored.
+func storedStack(driver interfaces.OrchestratorDriver, id string) *types.Stack {
+	stack, err := driver.GetStack(id)
 	if err != nil {
-		return interfaces.SwarmStackSpec{}, fmt.Errorf("failed to convert configs: %s", err)
+		logrus.Warnf("unable to retrieve stored stack %s: %s", id, err)
+		return nil
+	}
+	stack = withoutFiles(stack)
+	return &stack
+}
+
+// DeleteStack deletes a stack from the driver it belongs to. Deleting a
+// stack which does not exist is not an error.
+func (b *DefaultStacksBackend) DeleteStack(id string) error {
+	_, driver, err := b.findStack(id)
+	switch {
+	case errdefs.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if err := driver.DeleteStack(id); err != nil {
+		return err
 	}
 
-	secrets, err := convert.Secrets(namespace, substitutedSpec.Secrets)
+	b.PublishStackEvent(types.StackEvent{
+		StackID: id,
+		Action:  types.StackEventRemoved,
+	})
+	return nil
+}
+
+// GetStackProgress reports how far the objects of a stack are from their
+// desired state, as seen by the driver the stack belongs to.
+func (b *DefaultStacksBackend) GetStackProgress(id string) (types.StackProgress, error) {
+	_, driver, err := b.findStack(id)
 	if err != nil {
-		return interfaces.SwarmStackSpec{}, fmt.Errorf("failed to convert secrets: %s", err)
+		return types.StackProgress{}, err
 	}
+	return driver.GetStackProgress(id)
+}
 
-	serviceNetworks := getServicesDeclaredNetworks(substitutedSpec.Services)
-	networkCreates, _ := convert.Networks(namespace, substitutedSpec.Networks, serviceNetworks)
-	// TODO: validate external networks?
+// SubscribeToStackEvents subscribes to the stack lifecycle events. Past
+// events which occurred after since are returned immediately, and all
+// further events are sent on the returned channel.
+func (b *DefaultStacksBackend) SubscribeToStackEvents(since time.Time) ([]types.StackEvent, chan types.StackEvent) {
+	return b.events.subscribe(since)
+}
+
+// UnsubscribeFromStackEvents cancels a subscription made with
+// SubscribeToStackEvents.
+func (b *DefaultStacksBackend) UnsubscribeFromStackEvents(eventC chan types.StackEvent) {
+	b.events.unsubscribe(eventC)
+}
 
-	stackSpec := interfaces.SwarmStackSpec{
-		Annotations: swarm.Annotations{
-			Name:   spec.Metadata.Name,
-			Labels: spec.Metadata.Labels,
-		},
-		Services: services,
-		Configs:  configs,
-		Secrets:  secrets,
-		Networks: networkCreates,
+// PublishStackEvent records a stack lifecycle event and sends it to all
+// subscribers. It is used by the reconciler to report its progress, which
+// sets the phase of the stack.
+func (b *DefaultStacksBackend) PublishStackEvent(event types.StackEvent) {
+	b.phases.record(event)
+	b.events.publish(event)
+}
+
+// validateCollection checks that the name of a collection can't be
+// confused with the name of a stack once prepended to it, as they are
+// separated by an underscore in the namespaces of stacks.
+func validateCollection(collection string) error {
+	if strings.Contains(collection, "_") {
+		return errdefs.InvalidParameter(fmt.Errorf("invalid collection %q, collection names may not contain underscores", collection))
 	}
+	return nil
+}
 
-	return stackSpec, nil
+// collectionKey returns the key locking the stacks of a collection. Stacks
+// outside of collections are locked by the part of their name before its
+// first underscore, which is the key of the collection their namespace
+// could be confused with.
+func collectionKey(collection, name string) string {
+	if collection != "" {
+		return collection
+	}
+	return strings.SplitN(name, "_", 2)[0]
 }
 
-func getServicesDeclaredNetworks(serviceConfigs []composetypes.ServiceConfig) map[string]struct{} {
-	serviceNetworks := map[string]struct{}{}
-	for _, serviceConfig := range serviceConfigs {
-		if len(serviceConfig.Networks) == 0 {
-			serviceNetworks["default"] = struct{}{}
+// lockCollection locks the stacks of a collection until the returned
+// function is called, so that the checks of its quota and of the names of
+// its stacks hold until a stack is stored.
+func (b *DefaultStacksBackend) lockCollection(key string) func() {
+	return b.lockCollections(key)
+}
+
+// lockCollections locks the stacks of several collections, in a stable
+// order so that concurrent calls don't deadlock.
+func (b *DefaultStacksBackend) lockCollections(keys ...string) func() {
+	keys = append([]string{}, keys...)
+	sort.Strings(keys)
+
+	locks := []*sync.Mutex{}
+	b.collectionLocksMu.Lock()
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
 			continue
 		}
-		for network := range serviceConfig.Networks {
-			serviceNetworks[network] = struct{}{}
+		lock, ok := b.collectionLocks[key]
+		if !ok {
+			lock = &sync.Mutex{}
+			b.collectionLocks[key] = lock
 		}
+		locks = append(locks, lock)
 	}
-	return serviceNetworks
-}
+	b.collectionLocksMu.Unlock()
 
-// TODO: rewrite if needed
-/*
-func validateExternalNetworks(
-	ctx context.Context,
-	client dockerclient.NetworkAPIClient,
-	externalNetworks []string,
-) error {
-	for _, networkName := range externalNetworks {
-		if !container.NetworkMode(networkName).IsUserDefined() {
-			// Networks that are not user defined always exist on all nodes as
-			// local-scoped networks, so there's no need to inspect them.
-			continue
-		}
-		network, err := client.NetworkInspect(ctx, networkName, types.NetworkInspectOptions{})
-		switch {
-		case dockerclient.IsErrNotFound(err):
-			return errors.Errorf("network %q is declared as external, but could not be found. You need to create a swarm-scoped network before the stack is deployed", networkName)
-		case err != nil:
-			return err
-		case network.Scope != "swarm":
-			return errors.Errorf("network %q is declared as external, but it is not in the right scope: %q instead of \"swarm\"", networkName, network.Scope)
+	for _, lock := range locks {
+		lock.Lock()
+	}
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
 		}
 	}
-	return nil
 }
-*/
+
+func validateVolumeRemovalPolicy(policy types.VolumeRemovalPolicy) error {
+	switch policy {
+	case "", types.VolumeRemovalPolicyRetain, types.VolumeRemovalPolicyDelete:
+		return nil
+	}
+	return errdefs.InvalidParameter(fmt.Errorf("invalid volume removal policy %q, must be %q or %q", policy, types.VolumeRemovalPolicyRetain, types.VolumeRemovalPolicyDelete))
+}
+
+// ParseComposeInput parses a compose file and returns the StackCreate object with the spec and any properties
+func (b *DefaultStacksBackend) ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error) {
+	return loader.ParseComposeInput(input)
+}
@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -12,6 +13,8 @@ import (
 
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/stacks/pkg/compose/convert"
 	composeTypes "github.com/docker/stacks/pkg/compose/types"
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/mocks"
@@ -41,14 +44,18 @@ func TestStacksBackendUpdateOutOfSequence(t *testing.T) {
 	// Inspect the stack
 	stack, err := b.GetStack(resp.ID)
 	require.NoError(err)
+	require.Equal(&stack, resp.Stack)
 
 	stack.Spec.Collection = "test1"
 
-	err = b.UpdateStack(stack.ID, stack.Spec, stack.Version.Index)
+	updateResp, err := b.UpdateStack(stack.ID, stack.Spec, stack.Version.Index)
 	require.NoError(err)
+	// the response carries the stored version, for the audit log
+	require.Equal(stack.Version.Index+1, updateResp.Stack.Version.Index)
+	require.Equal(stack.Spec, updateResp.Stack.Spec)
 
 	stack.Spec.Collection = "test2"
-	err = b.UpdateStack(stack.ID, stack.Spec, stack.Version.Index)
+	_, err = b.UpdateStack(stack.ID, stack.Spec, stack.Version.Index)
 	require.Error(err)
 	require.Contains(err.Error(), "out of sequence")
 
@@ -57,6 +64,139 @@ func TestStacksBackendUpdateOutOfSequence(t *testing.T) {
 	require.Equal(stack.Spec.Collection, "test1")
 }
 
+func TestStacksBackendWarnings(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Services: composeTypes.Services{
+			{Name: "web", Image: "nginx", Sysctls: composeTypes.Mapping{"net.core.somaxconn": "1024"}},
+			// stacks created from the API report the options which
+			// are ignored as well
+			{Name: "db", Image: "postgres", CapAdd: []string{"NET_ADMIN"}, Privileged: true, Expose: composeTypes.StringOrNumberList{"5432"}},
+		},
+	}
+	expected := []types.StackWarning{
+		{
+			Code:     types.StackWarningUnsupportedProperty,
+			Service:  "db",
+			Property: "cap_add",
+			Message:  "cap_add is not supported and is ignored",
+		},
+		{
+			Code:     types.StackWarningUnsupportedProperty,
+			Service:  "db",
+			Property: "privileged",
+			Message:  "privileged is not supported and is ignored",
+		},
+		{
+			Code:     types.StackWarningDeprecatedProperty,
+			Service:  "db",
+			Property: "expose",
+			Message:  composeTypes.DeprecatedProperties["expose"],
+		},
+		{
+			Code:     types.StackWarningUnsupportedByEngine,
+			Service:  "web",
+			Property: "sysctls",
+			Message:  "sysctls requires API version 1.40, the engine uses 1.39",
+		},
+	}
+
+	backendClient.EXPECT().APIVersion().Return("1.39").Times(2)
+	resp, err := b.CreateStack(types.StackCreate{Spec: spec, Orchestrator: types.OrchestratorSwarm})
+	require.NoError(err)
+	require.Equal(expected, resp.Warnings)
+
+	updateResp, err := b.UpdateStack(resp.ID, spec, 1)
+	require.NoError(err)
+	require.Equal(expected, updateResp.Warnings)
+}
+
+func TestStacksBackendSensitiveProperties(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{Name: "teststack"},
+			Properties: []types.StackProperty{
+				{Name: "PASSWORD", Type: types.PropertyTypeString, Required: true, Sensitive: true},
+				{Name: "USER", Type: types.PropertyTypeString},
+			},
+			PropertyValues: []string{"PASSWORD=secret", "USER=admin"},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	// Updates which don't resend sensitive values keep them
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	stack.Spec.Properties = []types.StackProperty{
+		{Name: "PASSWORD", Type: types.PropertyTypeString, Required: true},
+		{Name: "USER", Type: types.PropertyTypeString},
+	}
+	stack.Spec.PropertyValues = []string{"PASSWORD", "USER=root"}
+	_, err = b.UpdateStack(stack.ID, stack.Spec, stack.Version.Index)
+	require.NoError(err)
+
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal([]string{"PASSWORD=secret", "USER=root"}, stack.Spec.PropertyValues)
+	require.True(stack.Spec.Properties[0].Sensitive)
+
+	// Updates are validated with the preserved values
+	stack.Spec.PropertyValues = []string{"PASSWORD="}
+	_, err = b.UpdateStack(stack.ID, stack.Spec, stack.Version.Index)
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "property PASSWORD is required")
+}
+
+func TestStacksBackendFiles(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{Name: "teststack"},
+			Secrets: map[string]composeTypes.SecretConfig{
+				"password": {File: "./password.txt"},
+			},
+			Files: map[string][]byte{"./password.txt": []byte("secret")},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	// The contents of files are never returned
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Nil(stack.Spec.Files)
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 1)
+	require.Nil(stacks[0].Spec.Files)
+
+	// Updates which don't resend files keep them
+	stack.Spec.Labels = map[string]string{"updated": "true"}
+	_, err = b.UpdateStack(stack.ID, stack.Spec, stack.Version.Index)
+	require.NoError(err)
+
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.Len(swarmStack.Spec.Secrets, 1)
+	require.Equal([]byte("secret"), swarmStack.Spec.Secrets[0].Data)
+}
+
 func TestStacksBackendInvalidCreate(t *testing.T) {
 	require := require.New(t)
 	ctrl := gomock.NewController(t)
@@ -82,6 +222,28 @@ func TestStacksBackendInvalidCreate(t *testing.T) {
 	require.Error(err)
 	require.Contains(err.Error(), "invalid orchestrator type")
 
+	// Property values must match the properties of the stack.
+	spec := types.StackSpec{
+		Properties: []types.StackProperty{
+			{Name: "REPLICAS", Type: types.PropertyTypeInt, Required: true},
+		},
+		PropertyValues: []string{"REPLICAS"},
+	}
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "property REPLICAS is required")
+
+	spec.PropertyValues = []string{"REPLICAS=many"}
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "invalid value for property REPLICAS")
+
 	// Ensure no stacks were created
 	stacks, err := b.ListStacks()
 	require.NoError(err)
@@ -166,7 +328,7 @@ func TestStacksBackendCRUD(t *testing.T) {
 	}
 	stack2, err := b.GetStack("2")
 	require.NoError(err)
-	err = b.UpdateStack("2", stack3Spec, stack2.Version.Index)
+	_, err = b.UpdateStack("2", stack3Spec, stack2.Version.Index)
 	require.NoError(err)
 
 	// Get the updated stack by ID
@@ -182,6 +344,190 @@ func TestStacksBackendCRUD(t *testing.T) {
 	require.Contains(err.Error(), "stack not found")
 }
 
+func TestStacksBackendStackResources(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec: types.StackSpec{
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:  "service1",
+					Image: "image1",
+				},
+			},
+		},
+	})
+	require.NoError(err)
+	created, err := b.GetStack(resp.ID)
+	require.NoError(err)
+
+	service := types.StackResource{
+		Orchestrator: types.OrchestratorSwarm,
+		Kind:         types.StackResourceKindService,
+		ID:           "serviceID",
+	}
+	network := types.StackResource{
+		Orchestrator: types.OrchestratorSwarm,
+		Kind:         types.StackResourceKindNetwork,
+		ID:           "networkID",
+	}
+	require.NoError(b.AddStackResource(resp.ID, "service1", service))
+	require.NoError(b.AddStackResource(resp.ID, "default", network))
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal(map[string]types.StackResource{"service1": service}, stack.StackResources.Services)
+	require.Equal(map[string]types.StackResource{"default": network}, stack.StackResources.Networks)
+
+	require.NoError(b.RemoveStackResource(resp.ID, types.StackResourceKindNetwork, "default"))
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Empty(stack.StackResources.Networks)
+	require.Len(stack.StackResources.Services, 1)
+
+	// Recording resources does not change the version of the stack
+	require.Equal(created.Version, stack.Version)
+
+	require.Error(b.AddStackResource("missing", "service1", service))
+}
+
+func TestStacksBackendVolumes(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Services: []composeTypes.ServiceConfig{
+			{
+				Name:  "service1",
+				Image: "image1",
+				Volumes: []composeTypes.ServiceVolumeConfig{
+					{Type: "volume", Source: "data", Target: "/data"},
+					{Type: "volume", Source: "shared", Target: "/shared"},
+				},
+			},
+		},
+		Volumes: map[string]composeTypes.VolumeConfig{
+			"data": {
+				Driver: "nfs",
+				Labels: map[string]string{"key": "value"},
+			},
+			"shared": {
+				Name:     "shared",
+				External: composeTypes.External{External: true},
+			},
+		},
+		VolumeRemovalPolicy: "sometimes",
+	}
+
+	// The volume removal policy must be valid
+	_, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+
+	// External volumes must exist
+	spec.VolumeRemovalPolicy = types.VolumeRemovalPolicyDelete
+	backendClient.EXPECT().GetVolume("shared").Return(dockerTypes.Volume{}, errdefs.NotFound(errors.New("no such volume")))
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), `volume "shared"`)
+
+	backendClient.EXPECT().GetVolume("shared").Return(dockerTypes.Volume{Name: "shared"}, nil)
+	resp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+
+	// Only the volumes of the stack are provisioned
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	require.True(swarmStack.Spec.RemoveVolumes)
+	require.Len(swarmStack.Spec.Volumes, 1)
+	data := swarmStack.Spec.Volumes["teststack_data"]
+	require.Equal("teststack_data", data.Name)
+	require.Equal("nfs", data.Driver)
+	require.Equal(map[string]string{"key": "value", convert.LabelNamespace: "teststack"}, data.Labels)
+}
+
+func TestStacksBackendExternalReferences(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	external := composeTypes.External{External: true}
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Services: []composeTypes.ServiceConfig{
+			{
+				Name:  "service1",
+				Image: "image1",
+				Networks: map[string]*composeTypes.ServiceNetworkConfig{
+					"missing": nil,
+					"local":   nil,
+					"host":    nil,
+				},
+			},
+		},
+		Networks: map[string]composeTypes.NetworkConfig{
+			"missing": {Name: "missing", External: external},
+			"local":   {Name: "local", External: external},
+			"host":    {Name: "host", External: external},
+		},
+		Secrets: map[string]composeTypes.SecretConfig{
+			"secret1": {Name: "secret1", External: external},
+			"secret2": {External: external},
+		},
+		Configs: map[string]composeTypes.ConfigObjConfig{
+			"config1": {Name: "config1", External: external},
+		},
+	}
+
+	// Networks which are not user defined are not looked up
+	backendClient.EXPECT().GetNetwork("missing").Return(dockerTypes.NetworkResource{}, errdefs.NotFound(errors.New("no such network")))
+	backendClient.EXPECT().GetNetwork("local").Return(dockerTypes.NetworkResource{Name: "local", Scope: "local"}, nil)
+	backendClient.EXPECT().GetSecrets(gomock.Any()).Return([]swarm.Secret{
+		{Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "secret1"}}},
+		// names are filtered by prefix
+		{Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "secret2_suffixed"}}},
+	}, nil)
+	backendClient.EXPECT().GetConfigs(gomock.Any()).Return([]swarm.Config{}, nil)
+
+	_, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Equal(`invalid external references: network "local" is not swarm-scoped (scope "local"); network "missing" not found; secret "secret2" not found; config "config1" not found`, err.Error())
+
+	// Stacks are validated on update too
+	resp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{Name: "teststack"},
+		},
+	})
+	require.NoError(err)
+	backendClient.EXPECT().GetConfigs(gomock.Any()).Return([]swarm.Config{}, nil)
+	_, err = b.UpdateStack(resp.ID, types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Configs:  spec.Configs,
+	}, 1)
+	require.True(errdefs.IsInvalidParameter(err))
+}
+
 // TODO: we need a large variety of tests at this level
 func TestStackBackendSwarmSimpleConversion(t *testing.T) {
 	require := require.New(t)
@@ -257,7 +603,9 @@ func TestStackBackendSwarmSimpleConversion(t *testing.T) {
 		},
 	}
 
-	backendClient.EXPECT().GetSecrets(gomock.Any()).Return([]swarm.Secret{
+	// External secrets and configs are looked up once to validate the
+	// stack, and once to convert its services.
+	backendClient.EXPECT().GetSecrets(gomock.Any()).Times(2).Return([]swarm.Secret{
 		{
 			Spec: swarm.SecretSpec{
 				Annotations: swarm.Annotations{
@@ -273,7 +621,7 @@ func TestStackBackendSwarmSimpleConversion(t *testing.T) {
 			},
 		},
 	}, nil)
-	backendClient.EXPECT().GetConfigs(gomock.Any()).Return([]swarm.Config{
+	backendClient.EXPECT().GetConfigs(gomock.Any()).Times(2).Return([]swarm.Config{
 		{
 			Spec: swarm.ConfigSpec{
 				Annotations: swarm.Annotations{
@@ -411,3 +759,189 @@ func assertNetworkEquality(t *testing.T, swarmNetworkSpec dockerTypes.NetworkCre
 	assert.Equal(swarmNetworkSpec.Options, stackNetworkSpec.DriverOpts)
 	assert.Equal(swarmNetworkSpec.IPAM.Driver, stackNetworkSpec.Ipam.Driver)
 }
+
+// fakeDriver is an in-memory OrchestratorDriver for the kubernetes
+// orchestrator, used to test the routing of stacks across drivers.
+type fakeDriver struct {
+	stacks map[string]types.Stack
+}
+
+func (d *fakeDriver) Orchestrator() types.OrchestratorChoice {
+	return types.OrchestratorKubernetes
+}
+
+func (d *fakeDriver) CreateStack(create types.StackCreate) (string, error) {
+	id := "kube_" + create.Spec.Metadata.Name
+	d.stacks[id] = types.Stack{
+		ID:           id,
+		Spec:         create.Spec,
+		Orchestrator: types.OrchestratorKubernetes,
+	}
+	return id, nil
+}
+
+func (d *fakeDriver) GetStack(id string) (types.Stack, error) {
+	stack, ok := d.stacks[id]
+	if !ok {
+		return types.Stack{}, errdefs.NotFound(errors.New("stack not found"))
+	}
+	return stack, nil
+}
+
+func (d *fakeDriver) ListStacks() ([]types.Stack, error) {
+	stacks := []types.Stack{}
+	for _, stack := range d.stacks {
+		stacks = append(stacks, stack)
+	}
+	return stacks, nil
+}
+
+func (d *fakeDriver) UpdateStack(id string, spec types.StackSpec, _ uint64) error {
+	stack := d.stacks[id]
+	stack.Spec = spec
+	d.stacks[id] = stack
+	return nil
+}
+
+func (d *fakeDriver) DeleteStack(id string) error {
+	delete(d.stacks, id)
+	return nil
+}
+
+func (d *fakeDriver) GetStackProgress(id string) (types.StackProgress, error) {
+	return types.StackProgress{StackID: id, Converged: true}, nil
+}
+
+func TestStacksBackendDriverRouting(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+	kubeDriver := &fakeDriver{stacks: map[string]types.Stack{}}
+	b.RegisterDriver(kubeDriver)
+
+	swarmResp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{Name: "swarmstack"},
+		},
+	})
+	require.NoError(err)
+
+	kubeResp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorKubernetes,
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{Name: "kubestack"},
+		},
+	})
+	require.NoError(err)
+	require.Equal("kube_kubestack", kubeResp.ID)
+	require.Contains(kubeDriver.stacks, kubeResp.ID)
+
+	// Stacks of all drivers are listed, ordered by orchestrator.
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 2)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorKubernetes), stacks[0].Orchestrator)
+	require.Equal(types.OrchestratorChoice(types.OrchestratorSwarm), stacks[1].Orchestrator)
+
+	stack, err := b.GetStack(kubeResp.ID)
+	require.NoError(err)
+	require.Equal("kubestack", stack.Spec.Metadata.Name)
+
+	stack, err = b.GetStack(swarmResp.ID)
+	require.NoError(err)
+	require.Equal("swarmstack", stack.Spec.Metadata.Name)
+
+	// Updates are routed to the driver owning the stack.
+	stack.Spec.Metadata.Labels = map[string]string{"key": "value"}
+	_, err = b.UpdateStack(kubeResp.ID, stack.Spec, 1)
+	require.NoError(err)
+	require.Equal("value", kubeDriver.stacks[kubeResp.ID].Spec.Metadata.Labels["key"])
+
+	progress, err := b.GetStackProgress(kubeResp.ID)
+	require.NoError(err)
+	require.True(progress.Converged)
+
+	require.NoError(b.DeleteStack(kubeResp.ID))
+	require.Empty(kubeDriver.stacks)
+	_, err = b.GetStack(kubeResp.ID)
+	require.True(errdefs.IsNotFound(err))
+
+	// Deleting a missing stack is not an error.
+	require.NoError(b.DeleteStack(kubeResp.ID))
+}
+
+func TestStacksBackendCollections(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	spec := types.StackSpec{
+		Metadata:   types.Metadata{Name: "app"},
+		Collection: "team-a",
+		Services: []composeTypes.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx",
+			},
+		},
+	}
+	respA, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+
+	// The objects of the stack are scoped and labeled by collection
+	swarmStack, err := b.GetSwarmStack(respA.ID)
+	require.NoError(err)
+	require.Equal("team-a", swarmStack.Spec.Collection)
+	require.Contains(swarmStack.Spec.Networks, "team-a_app_default")
+	require.Equal("team-a_app", swarmStack.Spec.Networks["team-a_app_default"].Labels[convert.LabelNamespace])
+	require.Equal("team-a", swarmStack.Spec.Networks["team-a_app_default"].Labels[convert.LabelCollection])
+	require.Equal("team-a", swarmStack.Spec.Services[0].Annotations.Labels[convert.LabelCollection])
+
+	// Names are unique per collection
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsConflict(err))
+
+	spec.Collection = "team-b"
+	respB, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+
+	// Stacks cannot be renamed to the name of another stack of their
+	// collection
+	spec.Collection = "team-a"
+	stack, err := b.GetStack(respB.ID)
+	require.NoError(err)
+	_, err = b.UpdateStack(respB.ID, spec, stack.Version.Index)
+	require.True(errdefs.IsConflict(err))
+	stack, err = b.GetStack(respA.ID)
+	require.NoError(err)
+	_, err = b.UpdateStack(respA.ID, spec, stack.Version.Index)
+	require.NoError(err)
+
+	// The namespaces of stacks are unique across collections
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         types.StackSpec{Metadata: types.Metadata{Name: "team-a_app"}},
+	})
+	require.True(errdefs.IsConflict(err))
+	require.Contains(err.Error(), "would be named like those of stack app")
+
+	// Collection names can't be confused with stack names
+	spec.Collection = "team_a"
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+}
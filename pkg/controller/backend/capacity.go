@@ -0,0 +1,237 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/compose/convert"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// resourceTotals adds up CPUs, memory and generic resources, the latter by
+// kind. Named generic resources count as one unit each.
+type resourceTotals struct {
+	nanoCPUs    int64
+	memoryBytes int64
+	generic     map[string]int64
+}
+
+func newResourceTotals() resourceTotals {
+	return resourceTotals{generic: map[string]int64{}}
+}
+
+// add adds count times the provided resources to the totals.
+func (t *resourceTotals) add(resources *swarm.Resources, count int64) {
+	if resources == nil {
+		return
+	}
+	t.nanoCPUs += resources.NanoCPUs * count
+	t.memoryBytes += resources.MemoryBytes * count
+	for _, generic := range resources.GenericResources {
+		switch {
+		case generic.DiscreteResourceSpec != nil:
+			t.generic[generic.DiscreteResourceSpec.Kind] += generic.DiscreteResourceSpec.Value * count
+		case generic.NamedResourceSpec != nil:
+			t.generic[generic.NamedResourceSpec.Kind] += count
+		}
+	}
+}
+
+// minus returns the totals left once other is taken away from t.
+func (t resourceTotals) minus(other resourceTotals) resourceTotals {
+	result := newResourceTotals()
+	result.nanoCPUs = t.nanoCPUs - other.nanoCPUs
+	result.memoryBytes = t.memoryBytes - other.memoryBytes
+	for kind, value := range t.generic {
+		result.generic[kind] = value - other.generic[kind]
+	}
+	return result
+}
+
+// shortfalls describes the resources of t which exceed the available ones.
+func (t resourceTotals) shortfalls(available resourceTotals) []string {
+	problems := []string{}
+	if t.nanoCPUs > available.nanoCPUs {
+		problems = append(problems, fmt.Sprintf("%g CPUs reserved, %g available",
+			float64(t.nanoCPUs)/1e9, float64(maxInt64(available.nanoCPUs, 0))/1e9))
+	}
+	if t.memoryBytes > available.memoryBytes {
+		problems = append(problems, fmt.Sprintf("%s of memory reserved, %s available",
+			units.BytesSize(float64(t.memoryBytes)), units.BytesSize(float64(maxInt64(available.memoryBytes, 0)))))
+	}
+	kinds := make([]string, 0, len(t.generic))
+	for kind := range t.generic {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		if t.generic[kind] > available.generic[kind] {
+			problems = append(problems, fmt.Sprintf("%d %s reserved, %d available",
+				t.generic[kind], kind, maxInt64(available.generic[kind], 0)))
+		}
+	}
+	return problems
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func serviceReservations(service swarm.ServiceSpec) *swarm.Resources {
+	if service.TaskTemplate.Resources == nil {
+		return nil
+	}
+	return service.TaskTemplate.Resources.Reservations
+}
+
+// serviceTasks returns the number of tasks of a service, counting one task
+// per node satisfying the placement constraints of global services.
+func serviceTasks(service swarm.ServiceSpec, nodes []swarm.Node) int64 {
+	if service.Mode.Global == nil {
+		if service.Mode.Replicated != nil && service.Mode.Replicated.Replicas != nil {
+			return int64(*service.Mode.Replicated.Replicas)
+		}
+		return 1
+	}
+	return int64(len(matchingNodes(service, nodes)))
+}
+
+// serviceShortfalls describes why the tasks of a service do not fit on the
+// nodes satisfying its placement constraints: either a single task reserves
+// more than any of those nodes provides, or all of its tasks reserve more
+// than those nodes provide together. Services which no node can run are
+// reported as unschedulable instead.
+func serviceShortfalls(service swarm.ServiceSpec, nodes []swarm.Node) []string {
+	reservations := serviceReservations(service)
+	eligible := matchingNodes(service, nodes)
+	if reservations == nil || len(eligible) == 0 {
+		return nil
+	}
+
+	task := newResourceTotals()
+	task.add(reservations, 1)
+	// largest holds the largest amount of each resource of the nodes
+	largest := newResourceTotals()
+	capacity := newResourceTotals()
+	fits := false
+	for _, node := range eligible {
+		resources := newResourceTotals()
+		resources.add(&node.Description.Resources, 1)
+		fits = fits || len(task.shortfalls(resources)) == 0
+		capacity.add(&node.Description.Resources, 1)
+		largest.nanoCPUs = maxInt64(largest.nanoCPUs, resources.nanoCPUs)
+		largest.memoryBytes = maxInt64(largest.memoryBytes, resources.memoryBytes)
+		for kind, value := range resources.generic {
+			largest.generic[kind] = maxInt64(largest.generic[kind], value)
+		}
+	}
+	if problems := task.shortfalls(largest); len(problems) > 0 {
+		return []string{fmt.Sprintf("a task of service %s does not fit on any node it can be placed on: %s",
+			service.Annotations.Name, strings.Join(problems, ", "))}
+	}
+	if !fits {
+		return []string{fmt.Sprintf("a task of service %s does not fit on any node it can be placed on", service.Annotations.Name)}
+	}
+
+	required := newResourceTotals()
+	required.add(reservations, serviceTasks(service, nodes))
+	if problems := required.shortfalls(capacity); len(problems) > 0 {
+		return []string{fmt.Sprintf("the tasks of service %s do not fit on the nodes they can be placed on: %s",
+			service.Annotations.Name, strings.Join(problems, ", "))}
+	}
+	return nil
+}
+
+// checkCapacity compares the resource reservations of the services of a
+// stack with the resources of the cluster, and of each service with the
+// resources of the nodes satisfying its placement constraints. Stacks which
+// would not fit even in an empty cluster are rejected with an
+// errdefs.InvalidParameter error. Stacks which only do not fit next to the
+// reservations of the tasks already running are accepted with a warning,
+// as those reservations may be released later. Failures to inspect the
+// cluster are only logged.
+func (d *swarmDriver) checkCapacity(spec types.StackSpec, services []swarm.ServiceSpec, listNodes nodeLister) error {
+	name := spec.Metadata.Name
+	hasReservations := false
+	for _, service := range services {
+		hasReservations = hasReservations || serviceReservations(service) != nil
+	}
+	if !hasReservations {
+		return nil
+	}
+
+	nodes, err := listNodes()
+	if err != nil {
+		logrus.Warnf("unable to check the capacity required by stack %s: %s", name, err)
+		return nil
+	}
+	capacity := newResourceTotals()
+	for _, node := range nodes {
+		capacity.add(&node.Description.Resources, 1)
+	}
+	required := newResourceTotals()
+	problems := []string{}
+	for _, service := range services {
+		required.add(serviceReservations(service), serviceTasks(service, nodes))
+		problems = append(problems, serviceShortfalls(service, nodes)...)
+	}
+	problems = append(required.shortfalls(capacity), problems...)
+
+	if len(problems) > 0 {
+		return errdefs.InvalidParameter(fmt.Errorf("stack %s requires more resources than the cluster provides: %s", name, strings.Join(problems, "; ")))
+	}
+
+	reserved, err := d.reservedResources(stackNamespace(spec))
+	if err != nil {
+		logrus.Warnf("unable to check the capacity required by stack %s: %s", name, err)
+		return nil
+	}
+	if problems := required.shortfalls(capacity.minus(reserved)); len(problems) > 0 {
+		logrus.Warnf("stack %s requires more resources than are currently available: %s", name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// reservedResources adds up the reservations of the running tasks of the
+// cluster, leaving out the tasks of the stack of the namespace, which are
+// replaced when it is updated.
+func (d *swarmDriver) reservedResources(namespace convert.Namespace) (resourceTotals, error) {
+	stackServices, err := d.swarmBackend.GetServices(dockerTypes.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", convert.LabelNamespace+"="+namespace.Name())),
+	})
+	if err != nil {
+		return resourceTotals{}, errors.Wrap(err, "unable to list services")
+	}
+	ownServices := map[string]struct{}{}
+	for _, service := range stackServices {
+		ownServices[service.ID] = struct{}{}
+	}
+
+	tasks, err := d.swarmBackend.GetTasks(dockerTypes.TaskListOptions{
+		Filters: filters.NewArgs(filters.Arg("desired-state", string(swarm.TaskStateRunning))),
+	})
+	if err != nil {
+		return resourceTotals{}, errors.Wrap(err, "unable to list tasks")
+	}
+
+	reserved := newResourceTotals()
+	for _, task := range tasks {
+		if _, ok := ownServices[task.ServiceID]; ok || task.Spec.Resources == nil {
+			continue
+		}
+		reserved.add(task.Spec.Resources.Reservations, 1)
+	}
+	return reserved, nil
+}
@@ -0,0 +1,156 @@
+package backend
+
+import (
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestResourceTotals(t *testing.T) {
+	require := require.New(t)
+
+	required := newResourceTotals()
+	required.add(&swarm.Resources{
+		NanoCPUs:    500000000,
+		MemoryBytes: 512 * 1024 * 1024,
+		GenericResources: []swarm.GenericResource{
+			{DiscreteResourceSpec: &swarm.DiscreteGenericResource{Kind: "gpu", Value: 1}},
+		},
+	}, 3)
+	required.add(nil, 10)
+
+	capacity := newResourceTotals()
+	capacity.add(&swarm.Resources{
+		NanoCPUs:    2000000000,
+		MemoryBytes: 1024 * 1024 * 1024,
+		GenericResources: []swarm.GenericResource{
+			{NamedResourceSpec: &swarm.NamedGenericResource{Kind: "gpu", Value: "UUID1"}},
+			{NamedResourceSpec: &swarm.NamedGenericResource{Kind: "gpu", Value: "UUID2"}},
+		},
+	}, 1)
+
+	require.Equal([]string{
+		"1.5GiB of memory reserved, 1GiB available",
+		"3 gpu reserved, 2 available",
+	}, required.shortfalls(capacity))
+
+	reserved := newResourceTotals()
+	reserved.add(&swarm.Resources{NanoCPUs: 1000000000}, 1)
+	require.Equal([]string{
+		"1.5 CPUs reserved, 1 available",
+		"1.5GiB of memory reserved, 1GiB available",
+		"3 gpu reserved, 2 available",
+	}, required.shortfalls(capacity.minus(reserved)))
+}
+
+func TestStacksBackendCapacity(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	nodes := []swarm.Node{
+		testNode("worker1", swarm.NodeRoleWorker, nil),
+		testNode("worker2", swarm.NodeRoleWorker, nil),
+	}
+	for i := range nodes {
+		nodes[i].Description.Resources = swarm.Resources{NanoCPUs: 2000000000}
+	}
+
+	replicas := uint64(3)
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Services: []composeTypes.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx",
+				Deploy: composeTypes.DeployConfig{
+					Replicas: &replicas,
+					Resources: composeTypes.Resources{
+						Reservations: &composeTypes.Resource{NanoCPUs: "1"},
+					},
+				},
+			},
+			{
+				Name:  "agent",
+				Image: "agent",
+				Deploy: composeTypes.DeployConfig{
+					Mode: "global",
+					Resources: composeTypes.Resources{
+						Reservations: &composeTypes.Resource{NanoCPUs: "0.75"},
+					},
+				},
+			},
+		},
+	}
+
+	// 3 CPUs for web and 2*0.75 CPUs for agent do not fit in 4 CPUs
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil)
+	_, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "stack teststack requires more resources than the cluster provides: 4.5 CPUs reserved, 4 available")
+
+	// Stacks which only do not fit next to the tasks already running are
+	// accepted
+	replicas = 2
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil)
+	backendClient.EXPECT().GetServices(gomock.Any()).Return([]swarm.Service{{ID: "own"}}, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return([]swarm.Task{
+		{
+			ServiceID: "own",
+			Spec: swarm.TaskSpec{Resources: &swarm.ResourceRequirements{
+				Reservations: &swarm.Resources{NanoCPUs: 2000000000},
+			}},
+		},
+		{
+			ServiceID: "other",
+			Spec: swarm.TaskSpec{Resources: &swarm.ResourceRequirements{
+				Reservations: &swarm.Resources{NanoCPUs: 1000000000},
+			}},
+		},
+	}, nil)
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+
+	// A task must fit on a single node
+	spec.Metadata.Name = "bigstack"
+	spec.Services = spec.Services[:1]
+	replicas = 1
+	spec.Services[0].Deploy.Resources.Reservations.NanoCPUs = "3"
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil)
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "stack bigstack requires more resources than the cluster provides: a task of service web does not fit on any node it can be placed on: 3 CPUs reserved, 2 available")
+
+	// The tasks of a service must fit on the nodes satisfying its
+	// placement constraints
+	replicas = 3
+	spec.Services[0].Deploy.Resources.Reservations.NanoCPUs = "1"
+	spec.Services[0].Deploy.Placement.Constraints = []string{"node.hostname==worker1"}
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil)
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), "stack bigstack requires more resources than the cluster provides: the tasks of service web do not fit on the nodes they can be placed on: 3 CPUs reserved, 2 available")
+}
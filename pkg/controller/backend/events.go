@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// maxEventHistory is the number of past events kept in memory to serve
+	// subscriptions with a since parameter.
+	maxEventHistory = 1024
+
+	// eventSubscriberBufferDepth is the size of the channel buffer of each
+	// subscriber. Events are dropped for subscribers which fall further
+	// behind than this.
+	eventSubscriberBufferDepth = 64
+)
+
+// eventBroker keeps a bounded history of stack events, and fans out new
+// events to all subscribers.
+type eventBroker struct {
+	mu          sync.Mutex
+	history     []types.StackEvent
+	subscribers map[chan types.StackEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: make(map[chan types.StackEvent]struct{}),
+	}
+}
+
+// publish records an event and sends it to all subscribers. Publishing never
+// blocks: a subscriber whose buffer is full misses the event.
+func (e *eventBroker) publish(event types.StackEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.history = append(e.history, event)
+	if len(e.history) > maxEventHistory {
+		e.history = e.history[len(e.history)-maxEventHistory:]
+	}
+
+	for sub := range e.subscribers {
+		select {
+		case sub <- event:
+		default:
+			logrus.Warnf("dropping %s event of stack %s for slow subscriber", event.Action, event.StackID)
+		}
+	}
+}
+
+// subscribe returns the recorded events which occurred after since, and a
+// channel on which all subsequent events are sent. A zero since returns no
+// past events.
+func (e *eventBroker) subscribe(since time.Time) ([]types.StackEvent, chan types.StackEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	past := []types.StackEvent{}
+	if !since.IsZero() {
+		for _, event := range e.history {
+			if event.Time.After(since) {
+				past = append(past, event)
+			}
+		}
+	}
+
+	sub := make(chan types.StackEvent, eventSubscriberBufferDepth)
+	e.subscribers[sub] = struct{}{}
+	return past, sub
+}
+
+// unsubscribe removes a subscriber and closes its channel.
+func (e *eventBroker) unsubscribe(sub chan types.StackEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.subscribers[sub]; ok {
+		delete(e.subscribers, sub)
+		close(sub)
+	}
+}
@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendEvents(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	start := time.Now().Add(-time.Second)
+	past, eventC := b.SubscribeToStackEvents(time.Time{})
+	require.Empty(past)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{
+				Name: "teststack",
+			},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+
+	event := <-eventC
+	require.Equal(resp.ID, event.StackID)
+	require.Equal(types.StackEventCreated, event.Action)
+	require.False(event.Time.IsZero())
+
+	b.PublishStackEvent(types.StackEvent{
+		StackID: resp.ID,
+		Action:  types.StackEventConverged,
+	})
+	event = <-eventC
+	require.Equal(types.StackEventConverged, event.Action)
+
+	require.NoError(b.DeleteStack(resp.ID))
+	event = <-eventC
+	require.Equal(types.StackEventRemoved, event.Action)
+
+	b.UnsubscribeFromStackEvents(eventC)
+	_, ok := <-eventC
+	require.False(ok)
+
+	// A new subscription with a since timestamp gets the past events
+	past, eventC = b.SubscribeToStackEvents(start)
+	defer b.UnsubscribeFromStackEvents(eventC)
+	require.Len(past, 3)
+	require.Equal(types.StackEventCreated, past[0].Action)
+	require.Equal(types.StackEventRemoved, past[2].Action)
+}
+
+func TestEventBrokerHistoryIsBounded(t *testing.T) {
+	e := newEventBroker()
+	for i := 0; i < maxEventHistory+10; i++ {
+		e.publish(types.StackEvent{StackID: "1", Action: types.StackEventUpdated})
+	}
+	past, eventC := e.subscribe(time.Unix(1, 0))
+	defer e.unsubscribe(eventC)
+	require.Len(t, past, maxEventHistory)
+}
@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"github.com/docker/stacks/pkg/types"
+)
+
+// withoutFiles returns a copy of a stack without the contents of the files
+// of its secrets and configs. They are only kept in the store, to convert
+// the stack, and are never returned by the API.
+func withoutFiles(stack types.Stack) types.Stack {
+	stack.Spec.Files = nil
+	return stack
+}
+
+// preserveFiles returns a copy of the new spec of a stack where the files
+// its secrets and configs reference keep their current contents, unless the
+// new spec provides some. Clients never get the contents of files back, so
+// they can't resend them. Files which are no longer referenced are dropped.
+// The original Specs are not modified.
+func preserveFiles(current, spec types.StackSpec) types.StackSpec {
+	var paths []string
+	for _, secret := range spec.Secrets {
+		paths = append(paths, secret.File)
+	}
+	for _, config := range spec.Configs {
+		paths = append(paths, config.File)
+	}
+
+	var files map[string][]byte
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		data, ok := spec.Files[path]
+		if !ok {
+			data, ok = current.Files[path]
+		}
+		if !ok {
+			continue
+		}
+		if files == nil {
+			files = map[string][]byte{}
+		}
+		files[path] = data
+	}
+	spec.Files = files
+	return spec
+}
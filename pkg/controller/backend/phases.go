@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"sync"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// The phases of the stacks whose driver doesn't report one, as derived from
+// their lifecycle events.
+const (
+	phasePending     = "pending"
+	phaseReconciling = "reconciling"
+	phaseConverged   = "converged"
+	phaseFailed      = "failed"
+)
+
+// stackPhases keeps the phase of each stack, as reported by the last of its
+// lifecycle events which changed it. Stacks without a phase are pending: they
+// have not been reconciled since they were stored, or since the controller
+// started.
+type stackPhases struct {
+	mu     sync.Mutex
+	phases map[string]string
+}
+
+func newStackPhases() *stackPhases {
+	return &stackPhases{
+		phases: map[string]string{},
+	}
+}
+
+// record updates the phase of a stack after one of its lifecycle events.
+func (p *stackPhases) record(event types.StackEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch event.Action {
+	case types.StackEventReconciling:
+		p.phases[event.StackID] = phaseReconciling
+	case types.StackEventConverged:
+		p.phases[event.StackID] = phaseConverged
+	case types.StackEventFailed:
+		p.phases[event.StackID] = phaseFailed
+	case types.StackEventRemoved:
+		delete(p.phases, event.StackID)
+	}
+}
+
+// setPending marks a stack as pending, before the driver is asked to update
+// it, so that the events of the reconciliation the update triggers are never
+// overridden. The returned function restores the previous phase of the
+// stack, if the update fails.
+func (p *stackPhases) setPending(id string) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	previous, ok := p.phases[id]
+	delete(p.phases, id)
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if ok {
+			p.phases[id] = previous
+		}
+	}
+}
+
+// get returns the phase of a stack.
+func (p *stackPhases) get(id string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if phase, ok := p.phases[id]; ok {
+		return phase
+	}
+	return phasePending
+}
+
+// StacksByPhase counts the stacks of all drivers by phase. The phase of a
+// stack is the one reported by its driver, if any, and is otherwise derived
+// from its lifecycle events: pending until it is reconciled, then
+// reconciling, converged or failed. Stacks are counted as they are stored,
+// without computing their status.
+func (b *DefaultStacksBackend) StacksByPhase() (map[string]int, error) {
+	stacks, err := b.listStacks()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, stack := range stacks {
+		phase := stack.Status.Phase
+		if phase == "" {
+			phase = b.phases.get(stack.ID)
+		}
+		counts[phase]++
+	}
+	return counts, nil
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendStacksByPhase(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{Name: "teststack"},
+		},
+		Orchestrator: types.OrchestratorSwarm,
+	})
+	require.NoError(err)
+	id := resp.ID
+	phases := func() map[string]int {
+		counts, err := b.StacksByPhase()
+		require.NoError(err)
+		return counts
+	}
+
+	// Stacks are pending until the reconciler reports on them.
+	require.Equal(map[string]int{"pending": 1}, phases())
+	b.PublishStackEvent(types.StackEvent{StackID: id, Action: types.StackEventReconciling})
+	require.Equal(map[string]int{"reconciling": 1}, phases())
+	b.PublishStackEvent(types.StackEvent{StackID: id, Action: types.StackEventServiceCreated, Service: "web"})
+	require.Equal(map[string]int{"reconciling": 1}, phases())
+	b.PublishStackEvent(types.StackEvent{StackID: id, Action: types.StackEventConverged})
+	require.Equal(map[string]int{"converged": 1}, phases())
+
+	// Updates make them pending again, unless they fail.
+	stack, err := b.GetStack(id)
+	require.NoError(err)
+	_, err = b.UpdateStack(id, stack.Spec, stack.Version.Index+1)
+	require.Error(err)
+	require.Equal(map[string]int{"converged": 1}, phases())
+	_, err = b.UpdateStack(id, stack.Spec, stack.Version.Index)
+	require.NoError(err)
+	require.Equal(map[string]int{"pending": 1}, phases())
+	b.PublishStackEvent(types.StackEvent{StackID: id, Action: types.StackEventFailed, Message: "boom"})
+	require.Equal(map[string]int{"failed": 1}, phases())
+
+	require.NoError(b.DeleteStack(id))
+	require.Empty(phases())
+}
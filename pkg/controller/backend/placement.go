@@ -0,0 +1,316 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// constraint is a single placement constraint of a service, in the
+// "key==value" or "key!=value" form understood by swarm.
+type constraint struct {
+	expression string
+	key        string
+	value      string
+	equal      bool
+}
+
+// parseConstraint parses a placement constraint. Only the keys matching
+// node attributes known to swarm are accepted.
+func parseConstraint(expression string) (constraint, error) {
+	c := constraint{expression: expression}
+	parts := strings.SplitN(expression, "!=", 2)
+	if len(parts) != 2 {
+		parts = strings.SplitN(expression, "==", 2)
+		c.equal = true
+	}
+	if len(parts) != 2 {
+		return constraint{}, fmt.Errorf("constraint %q is not of the form key==value or key!=value", expression)
+	}
+
+	c.key = strings.TrimSpace(parts[0])
+	c.value = strings.TrimSpace(parts[1])
+	if c.value == "" {
+		return constraint{}, fmt.Errorf("constraint %q has no value", expression)
+	}
+
+	switch strings.ToLower(c.key) {
+	case "node.id", "node.hostname", "node.role", "node.platform.os", "node.platform.arch":
+		return c, nil
+	}
+	for _, prefix := range []string{"node.labels.", "engine.labels."} {
+		if len(c.key) > len(prefix) && strings.EqualFold(c.key[:len(prefix)], prefix) {
+			return c, nil
+		}
+	}
+	return constraint{}, fmt.Errorf("constraint %q has an unknown key %q", expression, c.key)
+}
+
+// matches returns true if the node satisfies the constraint. Like in swarm,
+// values are compared case-insensitively, and a missing label only
+// satisfies != constraints.
+func (c constraint) matches(node swarm.Node) bool {
+	var (
+		actual string
+		found  = true
+	)
+	switch key := strings.ToLower(c.key); {
+	case key == "node.id":
+		actual = node.ID
+	case key == "node.hostname":
+		actual = node.Description.Hostname
+	case key == "node.role":
+		actual = string(node.Spec.Role)
+	case key == "node.platform.os":
+		actual = node.Description.Platform.OS
+	case key == "node.platform.arch":
+		actual = node.Description.Platform.Architecture
+	case strings.HasPrefix(key, "node.labels."):
+		actual, found = node.Spec.Labels[c.key[len("node.labels."):]]
+	case strings.HasPrefix(key, "engine.labels."):
+		actual, found = node.Description.Engine.Labels[c.key[len("engine.labels."):]]
+	}
+	if !found {
+		return !c.equal
+	}
+	return strings.EqualFold(actual, c.value) == c.equal
+}
+
+// placementReason returns why no node satisfies all of the constraints, or
+// an empty string if some node does.
+func placementReason(constraints []constraint, nodes []swarm.Node) string {
+	for _, c := range constraints {
+		satisfied := false
+		for _, node := range nodes {
+			satisfied = satisfied || c.matches(node)
+		}
+		if !satisfied {
+			return fmt.Sprintf("no node satisfies constraint %q", c.expression)
+		}
+	}
+	for _, node := range nodes {
+		matchesAll := true
+		for _, c := range constraints {
+			matchesAll = matchesAll && c.matches(node)
+		}
+		if matchesAll {
+			return ""
+		}
+	}
+	return "no node satisfies all of the placement constraints"
+}
+
+// matchingNodes returns the nodes satisfying all of the placement
+// constraints of a service. Invalid constraints are ignored, as they are
+// rejected by validatePlacement.
+func matchingNodes(service swarm.ServiceSpec, nodes []swarm.Node) []swarm.Node {
+	constraints := []constraint{}
+	for _, expression := range serviceConstraints(service) {
+		if c, err := parseConstraint(expression); err == nil {
+			constraints = append(constraints, c)
+		}
+	}
+	matching := []swarm.Node{}
+	for _, node := range nodes {
+		matchesAll := true
+		for _, c := range constraints {
+			matchesAll = matchesAll && c.matches(node)
+		}
+		if matchesAll {
+			matching = append(matching, node)
+		}
+	}
+	return matching
+}
+
+// hasPlacementConstraints returns true if any service of a stack has
+// placement constraints.
+func hasPlacementConstraints(services composetypes.Services) bool {
+	for _, service := range services {
+		if len(service.Deploy.Placement.Constraints) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePlacement parses the placement constraints of services, and
+// returns an errdefs.InvalidParameter error reporting all of the invalid
+// ones.
+func validatePlacement(services []swarm.ServiceSpec) error {
+	problems := []string{}
+	for _, service := range services {
+		for _, expression := range serviceConstraints(service) {
+			if _, err := parseConstraint(expression); err != nil {
+				problems = append(problems, fmt.Sprintf("service %s: %s", service.Annotations.Name, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errdefs.InvalidParameter(fmt.Errorf("invalid placement constraints: %s", strings.Join(problems, "; ")))
+	}
+	return nil
+}
+
+func serviceConstraints(service swarm.ServiceSpec) []string {
+	if service.TaskTemplate.Placement == nil {
+		return nil
+	}
+	return service.TaskTemplate.Placement.Constraints
+}
+
+// unschedulableServices returns the services whose placement constraints
+// are satisfied by no schedulable node of the cluster, along with the
+// reason why. The nodes are only listed if some service has constraints.
+func unschedulableServices(services []swarm.ServiceSpec, listNodes nodeLister) (map[string]string, error) {
+	unschedulable := map[string]string{}
+
+	for _, service := range services {
+		expressions := serviceConstraints(service)
+		if len(expressions) == 0 {
+			continue
+		}
+		constraints := []constraint{}
+		for _, expression := range expressions {
+			c, err := parseConstraint(expression)
+			if err != nil {
+				unschedulable[service.Annotations.Name] = err.Error()
+				break
+			}
+			constraints = append(constraints, c)
+		}
+		if _, ok := unschedulable[service.Annotations.Name]; ok {
+			continue
+		}
+
+		nodes, err := listNodes()
+		if err != nil {
+			return nil, err
+		}
+		if reason := placementReason(constraints, nodes); reason != "" {
+			unschedulable[service.Annotations.Name] = reason
+		}
+	}
+	return unschedulable, nil
+}
+
+// nodeLister returns the active nodes of the cluster.
+type nodeLister func() ([]swarm.Node, error)
+
+// activeNodesOnce returns a nodeLister which lists the active nodes of the
+// cluster on its first call only, so that the placement of all the stacks
+// and services of a request is checked against a single listing.
+func (d *swarmDriver) activeNodesOnce() nodeLister {
+	var (
+		nodes  []swarm.Node
+		err    error
+		listed bool
+	)
+	return func() ([]swarm.Node, error) {
+		if !listed {
+			nodes, err = d.activeNodes()
+			listed = true
+		}
+		return nodes, err
+	}
+}
+
+// activeNodes lists the nodes of the cluster which receive new tasks,
+// leaving out drained and paused nodes.
+func (d *swarmDriver) activeNodes() ([]swarm.Node, error) {
+	allNodes, err := d.swarmBackend.GetNodes(dockerTypes.NodeListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list nodes")
+	}
+	nodes := []swarm.Node{}
+	for _, node := range allNodes {
+		if node.Spec.Availability == swarm.NodeAvailabilityActive {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// unschedulableWarnings reports the services of a stack which cannot be
+// scheduled. They are not rejected, as nodes satisfying their constraints
+// may join the cluster later.
+func (d *swarmDriver) unschedulableWarnings(name string, services []swarm.ServiceSpec, listNodes nodeLister) []types.StackWarning {
+	unschedulable, err := unschedulableServices(services, listNodes)
+	if err != nil {
+		logrus.Warnf("unable to check the placement of the services of stack %s: %s", name, err)
+		return nil
+	}
+	var warnings []types.StackWarning
+	for _, service := range sortedKeys(unschedulable) {
+		warnings = append(warnings, types.StackWarning{
+			Code:     types.StackWarningUnschedulable,
+			Service:  service,
+			Property: "deploy.placement.constraints",
+			Message:  fmt.Sprintf("the service cannot be scheduled: %s", unschedulable[service]),
+		})
+	}
+	return warnings
+}
+
+// setStacksStatus reports the services of swarm stacks which cannot be
+// scheduled in their status. The nodes of the cluster are listed once for
+// all of the stacks. Failures are only logged, as the status is purely
+// informational.
+func (d *swarmDriver) setStacksStatus(stacks []types.Stack) {
+	listNodes := d.activeNodesOnce()
+	for i := range stacks {
+		if stacks[i].Orchestrator == types.OrchestratorSwarm {
+			d.setPlacementStatus(&stacks[i], listNodes)
+		}
+	}
+}
+
+// setPlacementStatus reports the services of a stack which cannot be
+// scheduled in its status.
+func (d *swarmDriver) setPlacementStatus(stack *types.Stack, listNodes nodeLister) {
+	if !hasPlacementConstraints(stack.Spec.Services) {
+		return
+	}
+	swarmStack, err := d.stackStore.GetSwarmStack(stack.ID)
+	if err != nil {
+		logrus.Warnf("unable to retrieve swarm stack %s: %s", stack.ID, err)
+		return
+	}
+	unschedulable, err := unschedulableServices(swarmStack.Spec.Services, listNodes)
+	if err != nil {
+		logrus.Warnf("unable to check the placement of the services of stack %s: %s", stack.ID, err)
+		return
+	}
+	if len(unschedulable) == 0 {
+		return
+	}
+
+	if stack.Status.ServicesStatus == nil {
+		stack.Status.ServicesStatus = map[string]types.ServiceStatus{}
+	}
+	services := sortedKeys(unschedulable)
+	for _, service := range services {
+		status := stack.Status.ServicesStatus[service]
+		status.Message = "unschedulable: " + unschedulable[service]
+		stack.Status.ServicesStatus[service] = status
+	}
+	stack.Status.Message = fmt.Sprintf("services cannot be scheduled: %s", strings.Join(services, ", "))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func testNode(hostname string, role swarm.NodeRole, labels map[string]string) swarm.Node {
+	return swarm.Node{
+		ID: hostname + "-id",
+		Spec: swarm.NodeSpec{
+			Role:         role,
+			Availability: swarm.NodeAvailabilityActive,
+			Annotations:  swarm.Annotations{Labels: labels},
+		},
+		Description: swarm.NodeDescription{
+			Hostname: hostname,
+			Platform: swarm.Platform{OS: "linux", Architecture: "x86_64"},
+		},
+	}
+}
+
+func TestPlacementConstraints(t *testing.T) {
+	require := require.New(t)
+
+	for _, expression := range []string{"node.role", "node.role==", "node.color==red", "labels.zone==a"} {
+		_, err := parseConstraint(expression)
+		require.Error(err, expression)
+	}
+
+	nodes := []swarm.Node{
+		testNode("manager1", swarm.NodeRoleManager, map[string]string{"zone": "a"}),
+		testNode("worker1", swarm.NodeRoleWorker, map[string]string{"zone": "b", "ssd": "true"}),
+	}
+
+	for _, tc := range []struct {
+		constraints []string
+		reason      string
+	}{
+		{constraints: []string{"node.role==worker", "node.labels.ssd==true"}},
+		{constraints: []string{"node.hostname!=manager1", "node.platform.os==Linux"}},
+		{constraints: []string{"node.labels.ssd!=true", "node.labels.zone==a"}},
+		{
+			constraints: []string{"node.platform.arch==arm64"},
+			reason:      `no node satisfies constraint "node.platform.arch==arm64"`,
+		},
+		{
+			constraints: []string{"node.role==manager", "node.labels.zone==b"},
+			reason:      "no node satisfies all of the placement constraints",
+		},
+	} {
+		constraints := []constraint{}
+		for _, expression := range tc.constraints {
+			c, err := parseConstraint(expression)
+			require.NoError(err)
+			constraints = append(constraints, c)
+		}
+		require.Equal(tc.reason, placementReason(constraints, nodes), "%v", tc.constraints)
+	}
+}
+
+func TestStacksBackendPlacement(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	replicas := uint64(1)
+	spec := types.StackSpec{
+		Metadata: types.Metadata{Name: "teststack"},
+		Services: []composeTypes.ServiceConfig{
+			{
+				Name:  "web",
+				Image: "nginx",
+				Deploy: composeTypes.DeployConfig{
+					Replicas: &replicas,
+					Placement: composeTypes.Placement{
+						Constraints: []string{"node.labels.gpu==true", "node.color==red"},
+					},
+				},
+			},
+			{
+				Name:  "db",
+				Image: "postgres",
+				Deploy: composeTypes.DeployConfig{
+					Placement: composeTypes.Placement{
+						Constraints: []string{"node.role==manager"},
+					},
+				},
+			},
+		},
+	}
+
+	// Invalid constraints are rejected
+	_, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), `service web: constraint "node.color==red" has an unknown key "node.color"`)
+
+	// Unsatisfiable constraints are accepted, and reported in the
+	// warnings of the response and in the status of the stack
+	spec.Services[0].Deploy.Placement.Constraints = []string{"node.labels.gpu==true"}
+	nodes := []swarm.Node{testNode("manager1", swarm.NodeRoleManager, nil)}
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil).Times(2)
+	resp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+	require.Equal([]types.StackWarning{{
+		Code:     types.StackWarningUnschedulable,
+		Service:  "web",
+		Property: "deploy.placement.constraints",
+		Message:  `the service cannot be scheduled: no node satisfies constraint "node.labels.gpu==true"`,
+	}}, resp.Warnings)
+
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Equal("services cannot be scheduled: web", stack.Status.Message)
+	require.Equal(`unschedulable: no node satisfies constraint "node.labels.gpu==true"`, stack.Status.ServicesStatus["web"].Message)
+	require.NotContains(stack.Status.ServicesStatus, "db")
+
+	// Failing to list the nodes does not fail the retrieval of the stack
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nil, errors.New("unavailable"))
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	require.Empty(stack.Status.Message)
+
+	// The nodes are listed once for all of the stacks listed
+	spec.Metadata.Name = "otherstack"
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil)
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil)
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 2)
+	for _, stack := range stacks {
+		require.Equal("services cannot be scheduled: web", stack.Status.Message)
+	}
+
+	// The progress of the stack explains why its service is not running,
+	// without looking up the status of the stack
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	backendClient.EXPECT().GetNodes(dockerTypes.NodeListOptions{}).Return(nodes, nil)
+	for _, spec := range swarmStack.Spec.Services {
+		service := swarm.Service{ID: spec.Annotations.Name, Spec: spec}
+		backendClient.EXPECT().GetService(spec.Annotations.Name, false).Return(service, nil)
+		tasks := []swarm.Task{}
+		if spec.Annotations.Name == "db" {
+			tasks = append(tasks, swarm.Task{Status: swarm.TaskStatus{State: swarm.TaskStateRunning}})
+		}
+		backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil)
+	}
+	progress, err := b.GetStackProgress(resp.ID)
+	require.NoError(err)
+	require.False(progress.Converged)
+	require.Equal(`unschedulable: no node satisfies constraint "node.labels.gpu==true"`, progress.ServicesStatus["web"].Message)
+	require.Empty(progress.ServicesStatus["db"].Message)
+}
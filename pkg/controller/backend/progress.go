@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"fmt"
+	"reflect"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// GetStackProgress reports how far the services of a stack are from their
+// desired state, by comparing the swarm services and their tasks with the
+// stored SwarmStackSpec.
+func (d *swarmDriver) GetStackProgress(id string) (types.StackProgress, error) {
+	swarmStack, err := d.stackStore.GetSwarmStack(id)
+	if err != nil {
+		return types.StackProgress{}, errors.Wrapf(err, "unable to retrieve swarm stack %s", id)
+	}
+
+	unschedulable, err := unschedulableServices(swarmStack.Spec.Services, d.activeNodesOnce())
+	if err != nil {
+		return types.StackProgress{}, err
+	}
+
+	progress := types.StackProgress{
+		StackID:        id,
+		ServicesStatus: map[string]types.ServiceStatus{},
+		Converged:      true,
+	}
+	for _, spec := range swarmStack.Spec.Services {
+		status, converged, err := d.getServiceProgress(spec, unschedulable[spec.Annotations.Name])
+		if err != nil {
+			return types.StackProgress{}, err
+		}
+		progress.ServicesStatus[spec.Annotations.Name] = status
+		if !converged {
+			progress.Converged = false
+		}
+		if isFailedUpdateState(status.UpdateState) {
+			progress.Failed = true
+			progress.Message = fmt.Sprintf("service %s: %s", spec.Annotations.Name, status.Message)
+		}
+	}
+
+	return progress, nil
+}
+
+// getServiceProgress returns the status of the service matching the
+// provided spec, and whether that service has converged. unschedulable is
+// the reason why the tasks of the service cannot be scheduled, if so.
+func (d *swarmDriver) getServiceProgress(spec swarm.ServiceSpec, unschedulable string) (types.ServiceStatus, bool, error) {
+	service, err := d.swarmBackend.GetService(spec.Annotations.Name, false)
+	switch {
+	case errdefs.IsNotFound(err):
+		return types.ServiceStatus{Message: "waiting for the service to be created"}, false, nil
+	case err != nil:
+		return types.ServiceStatus{}, false, errors.Wrapf(err, "unable to retrieve service %s", spec.Annotations.Name)
+	}
+
+	tasks, err := d.swarmBackend.GetTasks(dockerTypes.TaskListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("service", service.ID),
+			filters.Arg("desired-state", string(swarm.TaskStateRunning)),
+		),
+	})
+	if err != nil {
+		return types.ServiceStatus{}, false, errors.Wrapf(err, "unable to list tasks of service %s", spec.Annotations.Name)
+	}
+
+	var status types.ServiceStatus
+	for _, task := range tasks {
+		if task.Status.State == swarm.TaskStateRunning {
+			status.RunningTasks++
+		}
+	}
+	// global services have one desired task per eligible node, which is
+	// exactly the set of tasks the orchestrator wants running.
+	status.DesiredTasks = uint64(len(tasks))
+	if service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil {
+		status.DesiredTasks = *service.Spec.Mode.Replicated.Replicas
+	}
+	if service.UpdateStatus != nil {
+		status.UpdateState = string(service.UpdateStatus.State)
+	}
+
+	switch {
+	case isFailedUpdateState(status.UpdateState):
+		status.Message = fmt.Sprintf("update %s: %s", status.UpdateState, service.UpdateStatus.Message)
+	case status.UpdateState == string(swarm.UpdateStateUpdating) || status.UpdateState == string(swarm.UpdateStateRollbackStarted):
+		status.Message = "update in progress"
+	case !reflect.DeepEqual(spec, service.Spec):
+		status.Message = "waiting for the service to be updated"
+	case status.RunningTasks < status.DesiredTasks && unschedulable != "":
+		status.Message = "unschedulable: " + unschedulable
+	case status.RunningTasks < status.DesiredTasks:
+		status.Message = fmt.Sprintf("%d/%d tasks running", status.RunningTasks, status.DesiredTasks)
+	default:
+		return status, true, nil
+	}
+	return status, false, nil
+}
+
+// isFailedUpdateState returns true for the update states which require an
+// intervention before the service can converge.
+func isFailedUpdateState(state string) bool {
+	switch swarm.UpdateState(state) {
+	case swarm.UpdateStatePaused, swarm.UpdateStateRollbackPaused, swarm.UpdateStateRollbackCompleted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestStacksBackendGetStackProgress(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+
+	replicas := uint64(2)
+	resp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec: types.StackSpec{
+			Metadata: types.Metadata{
+				Name: "teststack",
+			},
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:  "web",
+					Image: "nginx",
+					Deploy: composeTypes.DeployConfig{
+						Replicas: &replicas,
+					},
+				},
+			},
+		},
+	})
+	require.NoError(err)
+
+	swarmStack, err := b.GetSwarmStack(resp.ID)
+	require.NoError(err)
+	spec := swarmStack.Spec.Services[0]
+	name := spec.Annotations.Name
+
+	// The service has not been created by the reconciler yet
+	backendClient.EXPECT().GetService(name, false).Return(swarm.Service{}, errdefs.NotFound(errors.New("not found")))
+	progress, err := b.GetStackProgress(resp.ID)
+	require.NoError(err)
+	require.False(progress.Converged)
+	require.False(progress.Failed)
+	require.Equal("waiting for the service to be created", progress.ServicesStatus[name].Message)
+
+	// The service exists, with one of its two tasks running
+	service := swarm.Service{ID: "svc1", Spec: spec}
+	tasks := []swarm.Task{
+		{Status: swarm.TaskStatus{State: swarm.TaskStateRunning}},
+		{Status: swarm.TaskStatus{State: swarm.TaskStatePreparing}},
+	}
+	backendClient.EXPECT().GetService(name, false).Return(service, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).DoAndReturn(func(opts dockerTypes.TaskListOptions) ([]swarm.Task, error) {
+		require.Equal([]string{"svc1"}, opts.Filters.Get("service"))
+		return tasks, nil
+	})
+	progress, err = b.GetStackProgress(resp.ID)
+	require.NoError(err)
+	require.False(progress.Converged)
+	require.Equal(types.ServiceStatus{
+		DesiredTasks: 2,
+		RunningTasks: 1,
+		Message:      "1/2 tasks running",
+	}, progress.ServicesStatus[name])
+
+	// All tasks are running
+	tasks[1].Status.State = swarm.TaskStateRunning
+	backendClient.EXPECT().GetService(name, false).Return(service, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil)
+	progress, err = b.GetStackProgress(resp.ID)
+	require.NoError(err)
+	require.True(progress.Converged)
+
+	// A paused update fails the stack
+	service.UpdateStatus = &swarm.UpdateStatus{
+		State:   swarm.UpdateStatePaused,
+		Message: "update paused due to failure",
+	}
+	backendClient.EXPECT().GetService(name, false).Return(service, nil)
+	backendClient.EXPECT().GetTasks(gomock.Any()).Return(tasks, nil)
+	progress, err = b.GetStackProgress(resp.ID)
+	require.NoError(err)
+	require.False(progress.Converged)
+	require.True(progress.Failed)
+	require.Contains(progress.Message, "update paused due to failure")
+}
+
+func TestStacksBackendGetStackProgressNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), mocks.NewMockBackendClient(ctrl))
+	_, err := b.GetStackProgress("nosuchid")
+	require.True(t, errdefs.IsNotFound(err))
+}
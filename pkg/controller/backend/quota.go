@@ -0,0 +1,249 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/docker/stacks/pkg/admission"
+	"github.com/docker/stacks/pkg/opts"
+	"github.com/docker/stacks/pkg/substitution"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// quotaFileEntry is the quota of a collection in a quota file, where CPUs
+// and memory are written the way they are in compose files.
+type quotaFileEntry struct {
+	Stacks         uint64 `yaml:"stacks"`
+	Replicas       uint64 `yaml:"replicas"`
+	CPUs           string `yaml:"cpus"`
+	Memory         string `yaml:"memory"`
+	PublishedPorts uint64 `yaml:"published_ports"`
+}
+
+// LoadCollectionQuotas reads the quotas of collections from a YAML file
+// such as:
+//
+//	team-a:
+//	  stacks: 10
+//	  replicas: 50
+//	  cpus: "8"
+//	  memory: 16G
+//	  published_ports: 4
+func LoadCollectionQuotas(path string) (map[string]types.QuotaResources, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read collection quotas")
+	}
+	entries := map[string]quotaFileEntry{}
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return nil, errors.Wrapf(err, "invalid collection quotas %s", path)
+	}
+
+	quotas := map[string]types.QuotaResources{}
+	for collection, entry := range entries {
+		quota := types.QuotaResources{
+			Stacks:         entry.Stacks,
+			Replicas:       entry.Replicas,
+			PublishedPorts: entry.PublishedPorts,
+		}
+		if entry.CPUs != "" {
+			if quota.NanoCPUs, err = opts.ParseCPUs(entry.CPUs); err != nil {
+				return nil, errors.Wrapf(err, "invalid CPUs quota of collection %s", collection)
+			}
+		}
+		if entry.Memory != "" {
+			if quota.MemoryBytes, err = units.RAMInBytes(entry.Memory); err != nil {
+				return nil, errors.Wrapf(err, "invalid memory quota of collection %s", collection)
+			}
+		}
+		quotas[collection] = quota
+	}
+	return quotas, nil
+}
+
+// SetCollectionQuotas replaces the quotas of collections. The stacks of
+// collections without a quota are not limited.
+func (b *DefaultStacksBackend) SetCollectionQuotas(quotas map[string]types.QuotaResources) {
+	b.quotasMu.Lock()
+	defer b.quotasMu.Unlock()
+	b.quotas = quotas
+}
+
+func (b *DefaultStacksBackend) collectionQuota(collection string) (types.QuotaResources, bool) {
+	b.quotasMu.Lock()
+	defer b.quotasMu.Unlock()
+	quota, ok := b.quotas[collection]
+	return quota, ok
+}
+
+// GetCollectionQuota reports the quota of a collection and the resources
+// used by its stacks.
+func (b *DefaultStacksBackend) GetCollectionQuota(collection string) (types.CollectionQuota, error) {
+	quota, ok := b.collectionQuota(collection)
+	if !ok {
+		return types.CollectionQuota{}, errdefs.NotFound(fmt.Errorf("collection %s has no quota", collection))
+	}
+	usage, err := b.collectionUsage(collection, "")
+	if err != nil {
+		return types.CollectionQuota{}, err
+	}
+	return types.CollectionQuota{
+		Collection: collection,
+		Limits:     quota,
+		Usage:      usage,
+	}, nil
+}
+
+// ListCollectionQuotas reports the quotas of all collections, ordered by
+// collection.
+func (b *DefaultStacksBackend) ListCollectionQuotas() ([]types.CollectionQuota, error) {
+	b.quotasMu.Lock()
+	collections := make([]string, 0, len(b.quotas))
+	for collection := range b.quotas {
+		collections = append(collections, collection)
+	}
+	b.quotasMu.Unlock()
+	sort.Strings(collections)
+
+	quotas := []types.CollectionQuota{}
+	for _, collection := range collections {
+		quota, err := b.GetCollectionQuota(collection)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+// collectionUsage adds up the resources used by the stacks of a
+// collection, leaving out the stack excludeID.
+func (b *DefaultStacksBackend) collectionUsage(collection, excludeID string) (types.QuotaResources, error) {
+	stacks, err := b.listStacks()
+	if err != nil {
+		return types.QuotaResources{}, err
+	}
+	usage := types.QuotaResources{}
+	for _, stack := range stacks {
+		if stack.Spec.Collection != collection || stack.ID == excludeID {
+			continue
+		}
+		stackUsage, err := specUsage(stack.Spec)
+		if err != nil {
+			return types.QuotaResources{}, errors.Wrapf(err, "unable to compute the resources of stack %s", stack.ID)
+		}
+		usage = addQuotaResources(usage, stackUsage)
+	}
+	return usage, nil
+}
+
+// specUsage returns the resources used by a stack, computed from its spec
+// so that the stacks of all orchestrators are accounted for the same way.
+func specUsage(spec types.StackSpec) (types.QuotaResources, error) {
+	substituted, err := substitution.DoSubstitution(spec)
+	if err != nil {
+		return types.QuotaResources{}, err
+	}
+
+	usage := types.QuotaResources{Stacks: 1}
+	for _, service := range substituted.Services {
+		replicas := uint64(1)
+		if service.Deploy.Mode != "global" && service.Deploy.Replicas != nil {
+			replicas = *service.Deploy.Replicas
+		}
+		usage.Replicas += replicas
+
+		if reservations := service.Deploy.Resources.Reservations; reservations != nil {
+			if reservations.NanoCPUs != "" {
+				nanoCPUs, err := opts.ParseCPUs(reservations.NanoCPUs)
+				if err != nil {
+					return types.QuotaResources{}, errors.Wrapf(err, "service %s", service.Name)
+				}
+				usage.NanoCPUs += nanoCPUs * int64(replicas)
+			}
+			usage.MemoryBytes += int64(reservations.MemoryBytes) * int64(replicas)
+		}
+
+		for _, port := range service.Ports {
+			if port.Published != 0 {
+				usage.PublishedPorts++
+			}
+		}
+	}
+	return usage, nil
+}
+
+func addQuotaResources(a, b types.QuotaResources) types.QuotaResources {
+	return types.QuotaResources{
+		Stacks:         a.Stacks + b.Stacks,
+		Replicas:       a.Replicas + b.Replicas,
+		NanoCPUs:       a.NanoCPUs + b.NanoCPUs,
+		MemoryBytes:    a.MemoryBytes + b.MemoryBytes,
+		PublishedPorts: a.PublishedPorts + b.PublishedPorts,
+	}
+}
+
+// quotaController is the admission controller rejecting the stacks which
+// would take their collection over its quota. As stacks are scaled by
+// updating their spec, scaling is enforced as any other update.
+type quotaController struct {
+	backend *DefaultStacksBackend
+}
+
+func (c quotaController) Name() string {
+	return "collection-quota"
+}
+
+func (c quotaController) Validate(req admission.Request) []types.AdmissionViolation {
+	collection := req.Spec.Collection
+	quota, ok := c.backend.collectionQuota(collection)
+	if !ok {
+		return nil
+	}
+
+	violation := func(format string, args ...interface{}) []types.AdmissionViolation {
+		return []types.AdmissionViolation{{Message: fmt.Sprintf(format, args...)}}
+	}
+	requested, err := specUsage(*req.Spec)
+	if err != nil {
+		return violation("unable to compute the resources of the stack: %s", err)
+	}
+	used, err := c.backend.collectionUsage(collection, req.StackID)
+	if err != nil {
+		return violation("unable to compute the resources used by collection %s: %s", collection, err)
+	}
+	total := addQuotaResources(used, requested)
+
+	violations := []types.AdmissionViolation{}
+	exceeds := func(total, limit string) {
+		violations = append(violations, types.AdmissionViolation{
+			Message: fmt.Sprintf("quota of collection %q exceeded: %s would be used, the limit is %s", collection, total, limit),
+		})
+	}
+	if quota.Stacks > 0 && total.Stacks > quota.Stacks {
+		exceeds(fmt.Sprintf("%d stacks", total.Stacks), fmt.Sprint(quota.Stacks))
+	}
+	if quota.Replicas > 0 && total.Replicas > quota.Replicas {
+		exceeds(fmt.Sprintf("%d replicas", total.Replicas), fmt.Sprint(quota.Replicas))
+	}
+	if quota.NanoCPUs > 0 && total.NanoCPUs > quota.NanoCPUs {
+		exceeds(fmt.Sprintf("%s reserved CPUs", formatCPUs(total.NanoCPUs)), formatCPUs(quota.NanoCPUs))
+	}
+	if quota.MemoryBytes > 0 && total.MemoryBytes > quota.MemoryBytes {
+		exceeds(units.BytesSize(float64(total.MemoryBytes))+" of reserved memory", units.BytesSize(float64(quota.MemoryBytes)))
+	}
+	if quota.PublishedPorts > 0 && total.PublishedPorts > quota.PublishedPorts {
+		exceeds(fmt.Sprintf("%d published ports", total.PublishedPorts), fmt.Sprint(quota.PublishedPorts))
+	}
+	return violations
+}
+
+func formatCPUs(nanoCPUs int64) string {
+	return fmt.Sprintf("%g", float64(nanoCPUs)/1e9)
+}
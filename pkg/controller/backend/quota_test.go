@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	composeTypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestLoadCollectionQuotas(t *testing.T) {
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "quotas")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "quotas.yml")
+	require.NoError(ioutil.WriteFile(path, []byte(`
+team-a:
+  stacks: 2
+  cpus: "1.5"
+  memory: 1G
+team-b:
+  published_ports: 1
+`), 0600))
+	quotas, err := LoadCollectionQuotas(path)
+	require.NoError(err)
+	require.Equal(map[string]types.QuotaResources{
+		"team-a": {Stacks: 2, NanoCPUs: 1500000000, MemoryBytes: 1024 * 1024 * 1024},
+		"team-b": {PublishedPorts: 1},
+	}, quotas)
+
+	require.NoError(ioutil.WriteFile(path, []byte("team-a:\n  cpus: lots\n"), 0600))
+	_, err = LoadCollectionQuotas(path)
+	require.Error(err)
+}
+
+func TestStacksBackendCollectionQuotas(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(interfaces.NewFakeStackStore(), backendClient)
+	b.SetCollectionQuotas(map[string]types.QuotaResources{
+		"team-a": {Stacks: 2, Replicas: 4, PublishedPorts: 1},
+	})
+
+	stackSpec := func(name string, replicas uint64) types.StackSpec {
+		return types.StackSpec{
+			Metadata:   types.Metadata{Name: name},
+			Collection: "team-a",
+			Services: []composeTypes.ServiceConfig{
+				{
+					Name:   "web",
+					Image:  "nginx",
+					Deploy: composeTypes.DeployConfig{Replicas: &replicas},
+					Ports:  []composeTypes.ServicePortConfig{{Target: 80, Published: 8080}},
+				},
+			},
+		}
+	}
+
+	resp, err := b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         stackSpec("first", 3),
+	})
+	require.NoError(err)
+
+	quota, err := b.GetCollectionQuota("team-a")
+	require.NoError(err)
+	require.Equal(types.QuotaResources{Stacks: 1, Replicas: 3, PublishedPorts: 1}, quota.Usage)
+
+	// A second stack would exceed the replicas and published ports quotas
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         stackSpec("second", 3),
+	})
+	require.True(errdefs.IsInvalidParameter(err))
+	require.Contains(err.Error(), `quota of collection "team-a" exceeded: 6 replicas would be used, the limit is 4`)
+	require.Contains(err.Error(), `quota of collection "team-a" exceeded: 2 published ports would be used, the limit is 1`)
+
+	// Scaling the stack is limited by the quota, without counting the
+	// current replicas of the stack
+	stack, err := b.GetStack(resp.ID)
+	require.NoError(err)
+	_, err = b.UpdateStack(resp.ID, stackSpec("first", 4), stack.Version.Index)
+	require.NoError(err)
+	stack, err = b.GetStack(resp.ID)
+	require.NoError(err)
+	_, err = b.UpdateStack(resp.ID, stackSpec("first", 5), stack.Version.Index)
+	require.True(errdefs.IsInvalidParameter(err))
+
+	// Stacks of other collections are not limited
+	spec := stackSpec("other", 3)
+	spec.Collection = "team-b"
+	_, err = b.CreateStack(types.StackCreate{
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         spec,
+	})
+	require.NoError(err)
+	_, err = b.GetCollectionQuota("team-b")
+	require.True(errdefs.IsNotFound(err))
+
+	quotas, err := b.ListCollectionQuotas()
+	require.NoError(err)
+	require.Len(quotas, 1)
+	require.Equal(types.QuotaResources{Stacks: 1, Replicas: 4, PublishedPorts: 1}, quotas[0].Usage)
+}
+
+// slowStore is a StackStore taking some time to add stacks, so that
+// concurrent creations overlap.
+type slowStore struct {
+	interfaces.StackStore
+}
+
+func (s slowStore) AddStack(stack types.Stack, swarmStack interfaces.SwarmStack) (string, error) {
+	time.Sleep(10 * time.Millisecond)
+	return s.StackStore.AddStack(stack, swarmStack)
+}
+
+func TestStacksBackendConcurrentCreates(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backendClient := mocks.NewMockBackendClient(ctrl)
+	b := NewDefaultStacksBackend(slowStore{interfaces.NewFakeStackStore()}, backendClient)
+	b.SetCollectionQuotas(map[string]types.QuotaResources{
+		"team-a": {Stacks: 1},
+	})
+
+	// the quota of a collection and the names of stacks are checked and
+	// the stacks stored at once, so only one of each is created
+	specs := []types.StackSpec{}
+	for _, name := range []string{"first", "second", "third", "fourth"} {
+		specs = append(specs, types.StackSpec{
+			Metadata:   types.Metadata{Name: name},
+			Collection: "team-a",
+		})
+		specs = append(specs, types.StackSpec{
+			Metadata: types.Metadata{Name: "app"},
+		})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(specs))
+	for _, spec := range specs {
+		wg.Add(1)
+		go func(spec types.StackSpec) {
+			defer wg.Done()
+			_, err := b.CreateStack(types.StackCreate{
+				Orchestrator: types.OrchestratorSwarm,
+				Spec:         spec,
+			})
+			errs <- err
+		}(spec)
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+		}
+	}
+	require.Equal(2, created)
+	stacks, err := b.ListStacks()
+	require.NoError(err)
+	require.Len(stacks, 2)
+}
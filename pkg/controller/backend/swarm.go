@@ -0,0 +1,387 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+
+	"github.com/docker/stacks/pkg/admission"
+	"github.com/docker/stacks/pkg/compose/convert"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/substitution"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// swarmDriver is the interfaces.OrchestratorDriver of swarm stacks. Stacks
+// are converted to SwarmStacks, kept in the stack store, and reconciled by
+// the Swarm Stacks Reconciler.
+type swarmDriver struct {
+	// stackStore is the underlying CRUD store of stacks.
+	stackStore interfaces.StackStore
+
+	// swarmBackend provides access to swarmkit operations on secrets
+	// and configs, required for stack validation and conversion.
+	swarmBackend interfaces.SwarmResourceBackend
+
+	// admit runs the admission chain of the backend on converted stacks.
+	admit func(*admission.Request) error
+}
+
+func newSwarmDriver(stackStore interfaces.StackStore, swarmBackend interfaces.SwarmResourceBackend) *swarmDriver {
+	return &swarmDriver{
+		stackStore:   stackStore,
+		swarmBackend: swarmBackend,
+	}
+}
+
+func (d *swarmDriver) setAdmission(admit func(*admission.Request) error) {
+	d.admit = admit
+}
+
+// Orchestrator returns types.OrchestratorSwarm.
+func (d *swarmDriver) Orchestrator() types.OrchestratorChoice {
+	return types.OrchestratorSwarm
+}
+
+// CreateStack converts a stack to a SwarmStack and stores both.
+func (d *swarmDriver) CreateStack(create types.StackCreate) (string, error) {
+	id, _, err := d.createStackWithWarnings(create)
+	return id, err
+}
+
+// createStackWithWarnings creates a stack, and reports the options of its
+// services which are ignored by the conversion to a SwarmStack.
+func (d *swarmDriver) createStackWithWarnings(create types.StackCreate) (string, []types.StackWarning, error) {
+	// Create the Swarm Stack object
+	stack := types.Stack{
+		Spec:         create.Spec,
+		Orchestrator: types.OrchestratorSwarm,
+	}
+
+	if err := d.checkNameAvailable(create.Spec, ""); err != nil {
+		return "", nil, err
+	}
+	// the spec is substituted once, for both the validation and the
+	// conversion of the stack
+	substitutedSpec, err := substitution.DoSubstitution(create.Spec)
+	if err != nil {
+		return "", nil, errdefs.InvalidParameter(err)
+	}
+	if err := d.validateExternalReferences(create.Spec, substitutedSpec); err != nil {
+		return "", nil, err
+	}
+
+	// Convert to the Stack to a SwarmStack
+	swarmSpec, warnings, err := d.convertToSwarmStackSpec(create.Spec, substitutedSpec)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to translate swarm spec: %s", err)
+	}
+	if err := d.admit(&admission.Request{
+		Operation:    admission.OperationCreate,
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         &stack.Spec,
+		SwarmSpec:    &swarmSpec,
+	}); err != nil {
+		return "", nil, err
+	}
+	if err := validatePlacement(swarmSpec.Services); err != nil {
+		return "", nil, err
+	}
+	// the nodes are listed at most once for both checks
+	nodes := d.activeNodesOnce()
+	if err := d.checkCapacity(create.Spec, swarmSpec.Services, nodes); err != nil {
+		return "", nil, err
+	}
+	warnings = append(warnings, d.unschedulableWarnings(create.Spec.Metadata.Name, swarmSpec.Services, nodes)...)
+
+	swarmStack := interfaces.SwarmStack{
+		Spec: swarmSpec,
+	}
+
+	id, err := d.stackStore.AddStack(stack, swarmStack)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to store stack: %s", err)
+	}
+	return id, warnings, nil
+}
+
+// GetStack retrieves a stack by its ID.
+func (d *swarmDriver) GetStack(id string) (types.Stack, error) {
+	stack, err := d.stackStore.GetStack(id)
+	if err != nil {
+		return types.Stack{}, errors.Wrapf(err, "unable to retrieve stack %s", id)
+	}
+	return stack, nil
+}
+
+// ListStacks lists all swarm stacks.
+func (d *swarmDriver) ListStacks() ([]types.Stack, error) {
+	return d.stackStore.ListStacks()
+}
+
+// UpdateStack converts the new StackSpec to a SwarmStackSpec, and stores
+// both.
+func (d *swarmDriver) UpdateStack(id string, spec types.StackSpec, version uint64) error {
+	_, err := d.updateStackWithWarnings(id, spec, version)
+	return err
+}
+
+// updateStackWithWarnings updates a stack, and reports the options of its
+// services which are ignored by the conversion to a SwarmStackSpec.
+func (d *swarmDriver) updateStackWithWarnings(id string, spec types.StackSpec, version uint64) ([]types.StackWarning, error) {
+	if err := d.checkNameAvailable(spec, id); err != nil {
+		return nil, err
+	}
+	substitutedSpec, err := substitution.DoSubstitution(spec)
+	if err != nil {
+		return nil, errdefs.InvalidParameter(err)
+	}
+	if err := d.validateExternalReferences(spec, substitutedSpec); err != nil {
+		return nil, err
+	}
+
+	swarmSpec, warnings, err := d.convertToSwarmStackSpec(spec, substitutedSpec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to translate swarm spec: %s", err)
+	}
+	if err := d.admit(&admission.Request{
+		Operation:    admission.OperationUpdate,
+		StackID:      id,
+		Orchestrator: types.OrchestratorSwarm,
+		Spec:         &spec,
+		SwarmSpec:    &swarmSpec,
+	}); err != nil {
+		return nil, err
+	}
+	if err := validatePlacement(swarmSpec.Services); err != nil {
+		return nil, err
+	}
+	nodes := d.activeNodesOnce()
+	if err := d.checkCapacity(spec, swarmSpec.Services, nodes); err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, d.unschedulableWarnings(spec.Metadata.Name, swarmSpec.Services, nodes)...)
+
+	if err := d.stackStore.UpdateStack(id, spec, swarmSpec, version); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// DeleteStack removes a stack from the store. The reconciler then removes
+// the swarm objects of the stack.
+func (d *swarmDriver) DeleteStack(id string) error {
+	return d.stackStore.DeleteStack(id)
+}
+
+// checkNameAvailable returns an errdefs.Conflict error if another stack
+// has the namespace of the stack, which scopes the names of its objects by
+// its collection and name. The stack excludeID is not considered, so that
+// stacks can be updated. Unnamed stacks are not checked.
+func (d *swarmDriver) checkNameAvailable(spec types.StackSpec, excludeID string) error {
+	if spec.Metadata.Name == "" {
+		return nil
+	}
+	stacks, err := d.stackStore.ListStacks()
+	if err != nil {
+		return errors.Wrap(err, "unable to list stacks")
+	}
+	namespace := stackNamespace(spec).Name()
+	for _, stack := range stacks {
+		if stack.ID == excludeID || stack.Spec.Metadata.Name == "" || stackNamespace(stack.Spec).Name() != namespace {
+			continue
+		}
+		switch {
+		case stack.Spec.Collection != spec.Collection:
+			return errdefs.Conflict(fmt.Errorf("the objects of stack %s would be named like those of stack %s of collection %q", spec.Metadata.Name, stack.Spec.Metadata.Name, stack.Spec.Collection))
+		case spec.Collection == "":
+			return errdefs.Conflict(fmt.Errorf("a stack named %s already exists", spec.Metadata.Name))
+		}
+		return errdefs.Conflict(fmt.Errorf("a stack named %s already exists in collection %s", spec.Metadata.Name, spec.Collection))
+	}
+	return nil
+}
+
+// stackNamespace returns the namespace scoping the names of the objects of
+// a stack by its collection and name.
+func stackNamespace(spec types.StackSpec) convert.Namespace {
+	return convert.NewCollectionNamespace(spec.Collection, spec.Metadata.Name)
+}
+
+// convertToSwarmStackSpec converts a stack spec to a SwarmStackSpec.
+// substitutedSpec is the spec with its variables substituted with the
+// values of its properties, by substitution.DoSubstitution.
+func (d *swarmDriver) convertToSwarmStackSpec(spec, substitutedSpec types.StackSpec) (interfaces.SwarmStackSpec, []types.StackWarning, error) {
+	namespace := stackNamespace(spec)
+
+	services, warnings, err := convert.Services(namespace, substitutedSpec, d.swarmBackend)
+	if err != nil {
+		return interfaces.SwarmStackSpec{}, nil, fmt.Errorf("failed to convert services : %s", err)
+	}
+
+	configs, err := convert.Configs(namespace, substitutedSpec.Configs, substitutedSpec.Files)
+	if err != nil {
+		return interfaces.SwarmStackSpec{}, nil, fmt.Errorf("failed to convert configs: %s", err)
+	}
+
+	secrets, err := convert.Secrets(namespace, substitutedSpec.Secrets, substitutedSpec.Files)
+	if err != nil {
+		return interfaces.SwarmStackSpec{}, nil, fmt.Errorf("failed to convert secrets: %s", err)
+	}
+
+	serviceNetworks := convert.ServicesNetworks(substitutedSpec.Services)
+	networkCreates, _ := convert.Networks(namespace, substitutedSpec.Networks, serviceNetworks)
+
+	volumes, _ := convert.NamedVolumes(namespace, substitutedSpec.Volumes)
+
+	stackSpec := interfaces.SwarmStackSpec{
+		Annotations: swarm.Annotations{
+			Name:   spec.Metadata.Name,
+			Labels: spec.Metadata.Labels,
+		},
+		Collection: spec.Collection,
+		Services:   services,
+		Configs:    configs,
+		Secrets:    secrets,
+		Networks:   networkCreates,
+		Volumes:    volumes,
+
+		RemoveVolumes: spec.VolumeRemovalPolicy == types.VolumeRemovalPolicyDelete,
+	}
+
+	return stackSpec, warnings, nil
+}
+
+// validateExternalReferences verifies that the external networks, secrets,
+// configs and volumes referenced by a stack exist, and that external
+// networks are swarm-scoped. All invalid references are reported at once, in
+// an errdefs.InvalidParameter error.
+//
+// Volumes are local to each node, so external volumes are looked up on the
+// engine of the manager only. The references are read from substitutedSpec,
+// the spec substituted as for its conversion.
+func (d *swarmDriver) validateExternalReferences(spec, substitutedSpec types.StackSpec) error {
+	namespace := stackNamespace(spec)
+
+	problems := []string{}
+
+	_, externalNetworks := convert.Networks(namespace, substitutedSpec.Networks, convert.ServicesNetworks(substitutedSpec.Services))
+	sort.Strings(externalNetworks)
+	for _, name := range externalNetworks {
+		if name == "" || !container.NetworkMode(name).IsUserDefined() {
+			// Networks that are not user defined always exist on all nodes as
+			// local-scoped networks, so there's no need to inspect them.
+			continue
+		}
+		network, err := d.swarmBackend.GetNetwork(name)
+		switch {
+		case errdefs.IsNotFound(err):
+			problems = append(problems, fmt.Sprintf("network %q not found", name))
+		case err != nil:
+			return errors.Wrapf(err, "unable to look up external network %s", name)
+		case network.Scope != "swarm":
+			problems = append(problems, fmt.Sprintf("network %q is not swarm-scoped (scope %q)", name, network.Scope))
+		}
+	}
+
+	externalSecrets := externalObjectNames{}
+	for key, secret := range substitutedSpec.Secrets {
+		externalSecrets.add(key, composetypes.FileObjectConfig(secret))
+	}
+	if len(externalSecrets) > 0 {
+		secrets, err := d.swarmBackend.GetSecrets(dockerTypes.SecretListOptions{Filters: externalSecrets.filters()})
+		if err != nil {
+			return errors.Wrap(err, "unable to look up external secrets")
+		}
+		found := map[string]bool{}
+		for _, secret := range secrets {
+			found[secret.Spec.Annotations.Name] = true
+		}
+		for _, name := range externalSecrets.missing(found) {
+			problems = append(problems, fmt.Sprintf("secret %q not found", name))
+		}
+	}
+
+	externalConfigs := externalObjectNames{}
+	for key, config := range substitutedSpec.Configs {
+		externalConfigs.add(key, composetypes.FileObjectConfig(config))
+	}
+	if len(externalConfigs) > 0 {
+		configs, err := d.swarmBackend.GetConfigs(dockerTypes.ConfigListOptions{Filters: externalConfigs.filters()})
+		if err != nil {
+			return errors.Wrap(err, "unable to look up external configs")
+		}
+		found := map[string]bool{}
+		for _, config := range configs {
+			found[config.Spec.Annotations.Name] = true
+		}
+		for _, name := range externalConfigs.missing(found) {
+			problems = append(problems, fmt.Sprintf("config %q not found", name))
+		}
+	}
+
+	_, externalVolumes := convert.NamedVolumes(namespace, substitutedSpec.Volumes)
+	sort.Strings(externalVolumes)
+	for _, name := range externalVolumes {
+		_, err := d.swarmBackend.GetVolume(name)
+		switch {
+		case errdefs.IsNotFound(err):
+			problems = append(problems, fmt.Sprintf("volume %q not found", name))
+		case err != nil:
+			return errors.Wrapf(err, "unable to look up external volume %s", name)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errdefs.InvalidParameter(fmt.Errorf("invalid external references: %s", strings.Join(problems, "; ")))
+	}
+	return nil
+}
+
+// externalObjectNames is the set of names of the external secrets or
+// configs of a stack.
+type externalObjectNames map[string]struct{}
+
+// add adds an object to the set, if it is external. External objects are
+// named after their key unless they have an explicit name.
+func (n externalObjectNames) add(key string, obj composetypes.FileObjectConfig) {
+	if !obj.External.External {
+		return
+	}
+	name := key
+	if obj.Name != "" {
+		name = obj.Name
+	}
+	n[name] = struct{}{}
+}
+
+// filters returns the filters listing the objects of the set by name.
+func (n externalObjectNames) filters() filters.Args {
+	args := filters.NewArgs()
+	for name := range n {
+		args.Add("name", name)
+	}
+	return args
+}
+
+// missing returns the sorted names of the objects of the set which are not
+// found. The name filter matches on prefixes, so found must hold exact
+// names.
+func (n externalObjectNames) missing(found map[string]bool) []string {
+	missing := []string{}
+	for name := range n {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
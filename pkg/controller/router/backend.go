@@ -1,13 +1,23 @@
 package router
 
-import "github.com/docker/stacks/pkg/types"
+import (
+	"time"
+
+	"github.com/docker/stacks/pkg/types"
+)
 
 // Backend abstracts the Stacks API.
 type Backend interface {
 	CreateStack(types.StackCreate) (types.StackCreateResponse, error)
 	GetStack(id string) (types.Stack, error)
 	ListStacks() ([]types.Stack, error)
-	UpdateStack(id string, spec types.StackSpec, version uint64) error
+	UpdateStack(id string, spec types.StackSpec, version uint64) (types.StackUpdateResponse, error)
 	DeleteStack(id string) error
+	AdoptStack(types.StackAdopt) (types.StackCreateResponse, error)
 	ParseComposeInput(types.ComposeInput) (*types.StackCreate, error)
+	GetStackProgress(id string) (types.StackProgress, error)
+	SubscribeToStackEvents(since time.Time) ([]types.StackEvent, chan types.StackEvent)
+	UnsubscribeFromStackEvents(chan types.StackEvent)
+	GetCollectionQuota(collection string) (types.CollectionQuota, error)
+	ListCollectionQuotas() ([]types.CollectionQuota, error)
 }
@@ -1,21 +1,73 @@
 package router
 
-import "github.com/docker/docker/api/server/router"
+import (
+	"context"
+
+	"github.com/docker/docker/api/server/router"
+
+	"github.com/docker/stacks/pkg/auth"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Authorizer decides whether the client of a request, identified by its
+// context, may perform an operation on the stacks of a collection.
+type Authorizer interface {
+	Authorize(ctx context.Context, verb auth.Verb, collection string) error
+}
+
+// AuditLog records the mutations of stacks.
+type AuditLog interface {
+	Record(types.AuditEntry) error
+	Query(types.AuditFilter) ([]types.AuditEntry, error)
+}
 
 type stacksRouter struct {
-	backend Backend
-	routes  []router.Route
+	backend    Backend
+	authorizer Authorizer
+	auditLog   AuditLog
+	routes     []router.Route
+}
+
+// Option configures a Stacks Router.
+type Option func(*stacksRouter)
+
+// WithAuthorizer checks operations against the authorizer before the
+// backend is called.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(sr *stacksRouter) {
+		sr.authorizer = authorizer
+	}
+}
+
+// WithAuditLog records the mutations of stacks in the audit log, and
+// serves its entries.
+func WithAuditLog(auditLog AuditLog) Option {
+	return func(sr *stacksRouter) {
+		sr.auditLog = auditLog
+	}
 }
 
 // NewRouter creates a new Stacks Router.
-func NewRouter(b Backend) router.Router {
+func NewRouter(b Backend, opts ...Option) router.Router {
 	r := &stacksRouter{
 		backend: b,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
 	r.initRoutes()
 	return r
 }
 
+// authorize checks that the client of a request may perform verb on the
+// stacks of a collection.
+func (sr *stacksRouter) authorize(ctx context.Context, verb auth.Verb, collection string) error {
+	if sr.authorizer == nil {
+		return nil
+	}
+	return sr.authorizer.Authorize(ctx, verb, collection)
+}
+
 // Routes returns all routes of the stacks router.
 func (sr *stacksRouter) Routes() []router.Route {
 	return sr.routes
@@ -25,9 +77,20 @@ func (sr *stacksRouter) initRoutes() {
 	sr.routes = []router.Route{
 		router.NewGetRoute("/stacks", sr.getStacks),
 		router.NewPostRoute("/stacks", sr.createStack),
+		// the adopt route must be registered before /stacks/{id}, which
+		// would otherwise update a stack with the ID adopt.
+		router.NewPostRoute("/stacks/adopt", sr.adoptStack),
+		// the events routes must be registered before /stacks/{id}, which
+		// would otherwise match /stacks/events.
+		router.NewGetRoute("/stacks/events", sr.getStackEvents),
+		router.NewGetRoute("/stacks/{id}/events", sr.getStackEvents),
 		router.NewGetRoute("/stacks/{id}", sr.getStack),
+		router.NewGetRoute("/stacks/{id}/compose", sr.getStackCompose),
 		router.NewDeleteRoute("/stacks/{id}", sr.removeStack),
 		router.NewPostRoute("/stacks/{id}", sr.updateStack),
 		router.NewPostRoute("/parsecompose", sr.parseComposeInput),
+		router.NewGetRoute("/quotas", sr.getQuotas),
+		router.NewGetRoute("/quotas/{collection}", sr.getQuota),
+		router.NewGetRoute("/audit", sr.getAuditEntries),
 	}
 }
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+	timetypes "github.com/docker/docker/api/types/time"
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+
+	"github.com/docker/stacks/pkg/audit"
+	"github.com/docker/stacks/pkg/auth"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// stackBeforeMutation returns the stack an update or delete is about to
+// mutate, if it is needed to authorize or audit the mutation.
+func (sr *stacksRouter) stackBeforeMutation(id string) (*types.Stack, error) {
+	if sr.authorizer == nil && sr.auditLog == nil {
+		return nil, nil
+	}
+	stack, err := sr.backend.GetStack(id)
+	if err != nil {
+		return nil, err
+	}
+	return &stack, nil
+}
+
+// recordMutation records a mutation of a stack in the audit log, if any.
+// previous is the stack before the mutation, and is nil for creations.
+// stored is the stack as the mutation stored it, and is nil for deletions.
+// As the mutation has already happened, failures are only logged.
+func (sr *stacksRouter) recordMutation(ctx context.Context, r *http.Request, action types.AuditAction, id string, previous, stored *types.Stack) {
+	if sr.auditLog == nil {
+		return
+	}
+
+	entry := types.AuditEntry{
+		Time:          time.Now().UTC(),
+		Action:        action,
+		SourceAddress: r.RemoteAddr,
+		StackID:       id,
+	}
+	entry.Identity, _ = auth.IdentityFromContext(ctx)
+
+	oldSpec := types.StackSpec{}
+	if previous != nil {
+		entry.OldVersion = previous.Version.Index
+		entry.StackName = previous.Spec.Metadata.Name
+		entry.Collection = previous.Spec.Collection
+		oldSpec = previous.Spec
+	}
+	newSpec := types.StackSpec{}
+	if action != types.AuditActionDelete {
+		if stored == nil {
+			logrus.Errorf("unable to audit the %s of stack %s: the stored stack is unknown", action, id)
+			return
+		}
+		entry.NewVersion = stored.Version.Index
+		entry.StackName = stored.Spec.Metadata.Name
+		entry.Collection = stored.Spec.Collection
+		newSpec = stored.Spec
+	}
+	// created and deleted stacks are diffed against an empty stack of the
+	// same name and collection, so that only their objects are listed.
+	if previous == nil {
+		oldSpec.Metadata.Name, oldSpec.Collection = newSpec.Metadata.Name, newSpec.Collection
+	}
+	if action == types.AuditActionDelete {
+		newSpec.Metadata.Name, newSpec.Collection = oldSpec.Metadata.Name, oldSpec.Collection
+	}
+	entry.Changes = audit.Diff(oldSpec, newSpec)
+
+	if err := sr.auditLog.Record(entry); err != nil {
+		logrus.Errorf("unable to audit the %s of stack %s: %s", action, id, err)
+	}
+}
+
+// getAuditEntries returns the entries of the audit log, optionally filtered
+// by stack and by time range with the stack, since and until query
+// parameters. Entries about collections the client may not read are left
+// out.
+func (sr *stacksRouter) getAuditEntries(ctx context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+	if sr.auditLog == nil {
+		return errdefs.NotImplemented(fmt.Errorf("the audit log is not enabled"))
+	}
+
+	filter := types.AuditFilter{StackID: r.URL.Query().Get("stack")}
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := r.URL.Query().Get(param)
+		if raw == "" {
+			continue
+		}
+		secs, nsecs, err := timetypes.ParseTimestamps(raw, 0)
+		if err != nil {
+			return errdefs.InvalidParameter(fmt.Errorf("invalid %s value '%s': %v", param, raw, err))
+		}
+		*t = time.Unix(secs, nsecs)
+	}
+
+	entries, err := sr.auditLog.Query(filter)
+	if err != nil {
+		logrus.Errorf("Error querying the audit log: %s", err)
+		return err
+	}
+
+	readable := []types.AuditEntry{}
+	for _, entry := range entries {
+		if err := sr.authorize(ctx, auth.VerbRead, entry.Collection); err != nil {
+			if errdefs.IsForbidden(err) {
+				continue
+			}
+			return err
+		}
+		readable = append(readable, entry)
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, readable)
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	timetypes "github.com/docker/docker/api/types/time"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/auth"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// getStackEvents streams stack lifecycle events, either for all stacks or
+// for the stack identified by the id route variable. Events are written as
+// newline-delimited JSON, or as server-sent events if the client accepts
+// text/event-stream. The stream lasts until the client goes away.
+func (sr *stacksRouter) getStackEvents(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var since time.Time
+	if rawSince := r.URL.Query().Get("since"); rawSince != "" {
+		secs, nsecs, err := timetypes.ParseTimestamps(rawSince, 0)
+		if err != nil {
+			return errdefs.InvalidParameter(fmt.Errorf("invalid since value '%s': %v", rawSince, err))
+		}
+		since = time.Unix(secs, nsecs)
+	}
+
+	stackID := vars["id"]
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	visible := sr.eventVisibility(ctx)
+	if stackID != "" && sr.authorizer != nil {
+		stack, err := sr.backend.GetStack(stackID)
+		if err != nil {
+			return err
+		}
+		if err := sr.authorize(ctx, auth.VerbRead, stack.Spec.Collection); err != nil {
+			return err
+		}
+	}
+
+	past, eventC := sr.backend.SubscribeToStackEvents(since)
+	defer sr.backend.UnsubscribeFromStackEvents(eventC)
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+
+	write := func(event types.StackEvent) error {
+		if stackID != "" && event.StackID != stackID {
+			return nil
+		}
+		if stackID == "" && !visible(event.StackID) {
+			return nil
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if sse {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Action, data)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", data)
+		}
+		if err != nil {
+			return err
+		}
+		flush(w)
+		return nil
+	}
+
+	for _, event := range past {
+		if err := write(event); err != nil {
+			return nil
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-eventC:
+			if !ok {
+				return nil
+			}
+			// a write error means the client has gone away, and there is
+			// nobody left to report an error to.
+			if err := write(event); err != nil {
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// eventVisibility returns a function reporting whether the client of a
+// request may read the events of a stack. The collection of each stack is
+// looked up once. The events of stacks which no longer exist are only
+// visible to clients allowed to read the stacks of every collection.
+func (sr *stacksRouter) eventVisibility(ctx context.Context) func(stackID string) bool {
+	if sr.authorizer == nil {
+		return func(string) bool { return true }
+	}
+	readAll := sr.authorize(ctx, auth.VerbRead, auth.Any) == nil
+	visible := map[string]bool{}
+	return func(stackID string) bool {
+		if readAll {
+			return true
+		}
+		if v, ok := visible[stackID]; ok {
+			return v
+		}
+		stack, err := sr.backend.GetStack(stackID)
+		visible[stackID] = err == nil && sr.authorize(ctx, auth.VerbRead, stack.Spec.Collection) == nil
+		return visible[stackID]
+	}
+}
+
+// flush sends any buffered data to the client, if the ResponseWriter
+// supports it.
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -8,25 +8,64 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/errdefs"
 	"github.com/sirupsen/logrus"
 
+	"github.com/docker/stacks/pkg/audit"
+	"github.com/docker/stacks/pkg/auth"
+	"github.com/docker/stacks/pkg/compose/loader"
+	"github.com/docker/stacks/pkg/substitution"
 	"github.com/docker/stacks/pkg/types"
 )
 
-func (sr *stacksRouter) getStacks(_ context.Context, w http.ResponseWriter, _ *http.Request, _ map[string]string) error {
+// acceptedStackFilters are the filters supported by the stack list route.
+var acceptedStackFilters = map[string]bool{
+	"collection": true,
+	"name":       true,
+}
+
+func (sr *stacksRouter) getStacks(ctx context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+	stackFilters, err := filters.FromJSON(r.URL.Query().Get("filters"))
+	if err != nil {
+		return err
+	}
+	if err := stackFilters.Validate(acceptedStackFilters); err != nil {
+		return err
+	}
+
 	stacks, err := sr.backend.ListStacks()
 	if err != nil {
 		logrus.Errorf("error getting stacks: %s", err)
 		return err
 	}
 
-	return httputils.WriteJSON(w, http.StatusOK, stacks)
+	filtered := []types.Stack{}
+	for _, stack := range stacks {
+		if stackFilters.Contains("collection") && !stackFilters.ExactMatch("collection", stack.Spec.Collection) {
+			continue
+		}
+		if stackFilters.Contains("name") && !stackFilters.ExactMatch("name", stack.Spec.Metadata.Name) {
+			continue
+		}
+		// stacks the client may not read are left out of the list.
+		if err := sr.authorize(ctx, auth.VerbRead, stack.Spec.Collection); err != nil {
+			if errdefs.IsForbidden(err) {
+				continue
+			}
+			return err
+		}
+		stack.Spec = substitution.RedactSensitiveValues(stack.Spec)
+		filtered = append(filtered, stack)
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, filtered)
 }
 
-func (sr *stacksRouter) createStack(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+func (sr *stacksRouter) createStack(ctx context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
 	var stackCreate types.StackCreate
 	if err := json.NewDecoder(r.Body).Decode(&stackCreate); err != nil {
 		if err == io.EOF {
@@ -35,37 +74,142 @@ func (sr *stacksRouter) createStack(_ context.Context, w http.ResponseWriter, r
 		return errdefs.InvalidParameter(err)
 	}
 
+	if err := sr.authorize(ctx, auth.VerbCreate, stackCreate.Spec.Collection); err != nil {
+		return err
+	}
+
+	wait, err := parseWaitOptions(r)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
 	resp, err := sr.backend.CreateStack(stackCreate)
 	if err != nil {
 		logrus.Errorf("Error creating stack: %s", err)
 		return err
 	}
+	sr.recordMutation(ctx, r, types.AuditActionCreate, resp.ID, nil, resp.Stack)
+
+	if wait.wait {
+		return sr.streamDeployProgress(ctx, w, resp.ID, http.StatusCreated, start, wait.timeout)
+	}
 
 	return httputils.WriteJSON(w, http.StatusCreated, resp)
 }
 
-func (sr *stacksRouter) getStack(_ context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
-	stack, err := sr.backend.GetStack(vars["id"])
+// adoptStack creates a stack out of the objects deployed with docker stack
+// deploy in a namespace. Adopted stacks belong to no collection. As it
+// relabels objects of any namespace, adoption requires the adopt verb on
+// all collections.
+func (sr *stacksRouter) adoptStack(ctx context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
+	var adopt types.StackAdopt
+	if err := json.NewDecoder(r.Body).Decode(&adopt); err != nil {
+		if err == io.EOF {
+			return errdefs.InvalidParameter(errors.New("got EOF while reading request body"))
+		}
+		return errdefs.InvalidParameter(err)
+	}
+
+	if err := sr.authorize(ctx, auth.VerbAdopt, auth.Any); err != nil {
+		return err
+	}
+
+	resp, err := sr.backend.AdoptStack(adopt)
+	if err != nil {
+		logrus.Errorf("Error adopting stack %s: %s", adopt.Namespace, err)
+		return err
+	}
+	sr.recordMutation(ctx, r, types.AuditActionCreate, resp.ID, nil, resp.Stack)
+
+	return httputils.WriteJSON(w, http.StatusCreated, resp)
+}
+
+// readStack gets a stack the client of a request may read. The client is
+// authorized against the collection of the stack first, so that clients
+// which may not read it cannot tell in which collection it is. Stacks of
+// other collections are then reported as missing when a collection is
+// provided.
+func (sr *stacksRouter) readStack(ctx context.Context, r *http.Request, id string) (types.Stack, error) {
+	stack, err := sr.backend.GetStack(id)
+	if err != nil {
+		logrus.Errorf("Error getting stack %s: %s", id, err)
+		return types.Stack{}, err
+	}
+
+	if err := sr.authorize(ctx, auth.VerbRead, stack.Spec.Collection); err != nil {
+		return types.Stack{}, err
+	}
+
+	if _, ok := r.URL.Query()["collection"]; ok && r.URL.Query().Get("collection") != stack.Spec.Collection {
+		return types.Stack{}, errdefs.NotFound(fmt.Errorf("stack %s not found in collection %s", id, r.URL.Query().Get("collection")))
+	}
+	return stack, nil
+}
+
+func (sr *stacksRouter) getStack(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	stack, err := sr.readStack(ctx, r, vars["id"])
 	if err != nil {
-		logrus.Errorf("Error getting stack %s: %s", vars["id"], err)
 		return err
 	}
 
+	stack.Spec = substitution.RedactSensitiveValues(stack.Spec)
 	return httputils.WriteJSON(w, http.StatusOK, stack)
 }
 
-func (sr *stacksRouter) removeStack(_ context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
-	err := sr.backend.DeleteStack(vars["id"])
+// getStackCompose renders the spec of a stack as a compose file. Variables
+// are substituted with the property values of the stack if the substitute
+// query parameter is set, except for sensitive properties. Secrets and
+// configs read from files are rendered with their file references only,
+// as the contents of files are never returned. The collection query
+// parameter is honoured as for getStack.
+func (sr *stacksRouter) getStackCompose(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	stack, err := sr.readStack(ctx, r, vars["id"])
+	if err != nil {
+		return err
+	}
+
+	spec := stack.Spec
+	if httputils.BoolValue(r, "substitute") {
+		spec, err = substitution.DoRedactedSubstitution(spec)
+		if err != nil {
+			return fmt.Errorf("unable to substitute the properties of stack %s: %s", vars["id"], err)
+		}
+	}
+	data, err := loader.RenderComposeFile(spec)
+	if err != nil {
+		return fmt.Errorf("unable to render stack %s: %s", vars["id"], err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	return err
+}
+
+func (sr *stacksRouter) removeStack(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	previous, err := sr.stackBeforeMutation(vars["id"])
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		if err := sr.authorize(ctx, auth.VerbDelete, previous.Spec.Collection); err != nil {
+			return err
+		}
+	}
+
+	err = sr.backend.DeleteStack(vars["id"])
 	if err != nil {
 		logrus.Errorf("Error removing stack %s: %s", vars["id"], err)
 		return err
 	}
+	sr.recordMutation(ctx, r, types.AuditActionDelete, vars["id"], previous, nil)
 
 	w.WriteHeader(http.StatusNoContent)
 	return nil
 }
 
-func (sr *stacksRouter) updateStack(_ context.Context, _ http.ResponseWriter, r *http.Request, vars map[string]string) error {
+func (sr *stacksRouter) updateStack(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	var stackSpec types.StackSpec
 	if err := json.NewDecoder(r.Body).Decode(&stackSpec); err != nil {
 		if err == io.EOF {
@@ -81,13 +225,47 @@ func (sr *stacksRouter) updateStack(_ context.Context, _ http.ResponseWriter, r
 		return errdefs.InvalidParameter(err)
 	}
 
-	err = sr.backend.UpdateStack(vars["id"], stackSpec, version)
+	// moving a stack to another collection requires to be allowed to
+	// update the stacks of both collections.
+	previous, err := sr.stackBeforeMutation(vars["id"])
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		if err := sr.authorize(ctx, auth.VerbUpdate, previous.Spec.Collection); err != nil {
+			return err
+		}
+		if stackSpec.Collection != previous.Spec.Collection {
+			if err := sr.authorize(ctx, auth.VerbUpdate, stackSpec.Collection); err != nil {
+				return err
+			}
+		}
+	}
+
+	wait, err := parseWaitOptions(r)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	resp, err := sr.backend.UpdateStack(vars["id"], stackSpec, version)
 	if err != nil {
 		logrus.Errorf("Error updating stack %s: %s", vars["id"], err)
 		return err
 	}
+	// the stored spec is compared, as admission may have changed the spec
+	// of the request
+	action := types.AuditActionUpdate
+	if previous != nil && resp.Stack != nil && audit.IsScale(previous.Spec, resp.Stack.Spec) {
+		action = types.AuditActionScale
+	}
+	sr.recordMutation(ctx, r, action, vars["id"], previous, resp.Stack)
 
-	return nil
+	if wait.wait {
+		return sr.streamDeployProgress(ctx, w, vars["id"], http.StatusOK, start, wait.timeout)
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, resp)
 }
 
 func (sr *stacksRouter) parseComposeInput(_ context.Context, w http.ResponseWriter, r *http.Request, _ map[string]string) error {
@@ -107,3 +285,39 @@ func (sr *stacksRouter) parseComposeInput(_ context.Context, w http.ResponseWrit
 
 	return httputils.WriteJSON(w, http.StatusOK, resp)
 }
+
+func (sr *stacksRouter) getQuotas(ctx context.Context, w http.ResponseWriter, _ *http.Request, _ map[string]string) error {
+	quotas, err := sr.backend.ListCollectionQuotas()
+	if err != nil {
+		logrus.Errorf("Error listing collection quotas: %s", err)
+		return err
+	}
+
+	// the quotas of collections the client may not read are left out.
+	readable := []types.CollectionQuota{}
+	for _, quota := range quotas {
+		if err := sr.authorize(ctx, auth.VerbRead, quota.Collection); err != nil {
+			if errdefs.IsForbidden(err) {
+				continue
+			}
+			return err
+		}
+		readable = append(readable, quota)
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, readable)
+}
+
+func (sr *stacksRouter) getQuota(ctx context.Context, w http.ResponseWriter, _ *http.Request, vars map[string]string) error {
+	if err := sr.authorize(ctx, auth.VerbRead, vars["collection"]); err != nil {
+		return err
+	}
+
+	quota, err := sr.backend.GetCollectionQuota(vars["collection"])
+	if err != nil {
+		logrus.Errorf("Error getting quota of collection %s: %s", vars["collection"], err)
+		return err
+	}
+
+	return httputils.WriteJSON(w, http.StatusOK, quota)
+}
@@ -0,0 +1,192 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/errdefs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/stacks/pkg/auth"
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/mocks"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// testPolicy limits alice to the stacks of collection team-a, and lets
+// admin adopt stacks.
+var testPolicy = &auth.Policy{
+	Rules: []auth.Rule{
+		{
+			Identities:  []string{"alice"},
+			Collections: []string{"team-a"},
+			Verbs:       []auth.Verb{auth.VerbRead, auth.VerbCreate, auth.VerbUpdate, auth.VerbDelete, auth.VerbAdopt},
+		},
+		{
+			Identities:  []string{"admin"},
+			Collections: []string{auth.Any},
+			Verbs:       []auth.Verb{auth.VerbAdopt},
+		},
+	},
+}
+
+// routeHandler returns the handler of the route of the router matching
+// method and path.
+func routeHandler(t *testing.T, backend Backend, method, path string) httputils.APIFunc {
+	for _, route := range NewRouter(backend, WithAuthorizer(testPolicy)).Routes() {
+		if route.Method() == method && route.Path() == path {
+			return route.Handler()
+		}
+	}
+	t.Fatalf("no route %s %s", method, path)
+	return nil
+}
+
+func TestAdoptStackAuthorization(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mocks.NewMockBackendClient(ctrl)
+	adopt := routeHandler(t, backend, http.MethodPost, "/stacks/adopt")
+
+	// A client limited to one collection cannot adopt the objects of
+	// other namespaces, even with the adopt verb.
+	ctx := auth.WithIdentity(context.Background(), "alice")
+	r := httptest.NewRequest(http.MethodPost, "/stacks/adopt", strings.NewReader(`{"namespace":"web"}`))
+	err := adopt(ctx, httptest.NewRecorder(), r, nil)
+	require.True(errdefs.IsForbidden(err))
+	require.EqualError(err, "alice is not allowed to adopt the stacks of all collections")
+
+	// Clients granted the adopt verb on all collections can.
+	backend.EXPECT().AdoptStack(types.StackAdopt{Namespace: "web"}).Return(types.StackCreateResponse{ID: "web"}, nil)
+	ctx = auth.WithIdentity(context.Background(), "admin")
+	r = httptest.NewRequest(http.MethodPost, "/stacks/adopt", strings.NewReader(`{"namespace":"web"}`))
+	w := httptest.NewRecorder()
+	require.NoError(adopt(ctx, w, r, nil))
+	require.Equal(http.StatusCreated, w.Code)
+}
+
+func TestGetStackAuthorization(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mocks.NewMockBackendClient(ctrl)
+	backend.EXPECT().GetStack("stack").Return(types.Stack{
+		ID:   "stack",
+		Spec: types.StackSpec{Collection: "team-b"},
+	}, nil).AnyTimes()
+	getStack := routeHandler(t, backend, http.MethodGet, "/stacks/{id}")
+	vars := map[string]string{"id": "stack"}
+
+	// A client which may not read the stack is refused, whether or not
+	// the collection it asks for is the one of the stack.
+	ctx := auth.WithIdentity(context.Background(), "alice")
+	for _, target := range []string{"/stacks/stack", "/stacks/stack?collection=team-a", "/stacks/stack?collection=team-b"} {
+		err := getStack(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil), vars)
+		require.True(errdefs.IsForbidden(err), target)
+	}
+
+	// Clients which may read it get the stack unless they ask for another
+	// collection.
+	backend.EXPECT().GetStack("own").Return(types.Stack{
+		ID:   "own",
+		Spec: types.StackSpec{Collection: "team-a"},
+	}, nil).AnyTimes()
+	vars = map[string]string{"id": "own"}
+	w := httptest.NewRecorder()
+	require.NoError(getStack(ctx, w, httptest.NewRequest(http.MethodGet, "/stacks/own?collection=team-a", nil), vars))
+	require.Equal(http.StatusOK, w.Code)
+	err := getStack(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stacks/own?collection=team-b", nil), vars)
+	require.True(errdefs.IsNotFound(err))
+}
+
+func TestGetStackComposeCollection(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mocks.NewMockBackendClient(ctrl)
+	backend.EXPECT().GetStack("own").Return(types.Stack{
+		ID: "own",
+		Spec: types.StackSpec{
+			Collection: "team-a",
+			Services:   composetypes.Services{{Name: "web", Image: "nginx"}},
+		},
+	}, nil).AnyTimes()
+	backend.EXPECT().GetStack("other").Return(types.Stack{
+		ID:   "other",
+		Spec: types.StackSpec{Collection: "team-b"},
+	}, nil).AnyTimes()
+	getStackCompose := routeHandler(t, backend, http.MethodGet, "/stacks/{id}/compose")
+	ctx := auth.WithIdentity(context.Background(), "alice")
+
+	w := httptest.NewRecorder()
+	require.NoError(getStackCompose(ctx, w, httptest.NewRequest(http.MethodGet, "/stacks/own/compose?collection=team-a", nil), map[string]string{"id": "own"}))
+	require.Contains(w.Body.String(), "image: nginx")
+
+	err := getStackCompose(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stacks/own/compose?collection=team-b", nil), map[string]string{"id": "own"})
+	require.True(errdefs.IsNotFound(err))
+
+	err = getStackCompose(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stacks/other/compose?collection=team-a", nil), map[string]string{"id": "other"})
+	require.True(errdefs.IsForbidden(err))
+}
+
+// memoryAuditLog is an AuditLog keeping its entries in memory.
+type memoryAuditLog struct {
+	entries []types.AuditEntry
+}
+
+func (l *memoryAuditLog) Record(entry types.AuditEntry) error {
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *memoryAuditLog) Query(types.AuditFilter) ([]types.AuditEntry, error) {
+	return l.entries, nil
+}
+
+func TestUpdateStackAuditsStoredSpec(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backend := mocks.NewMockBackendClient(ctrl)
+	auditLog := &memoryAuditLog{}
+	var update httputils.APIFunc
+	for _, route := range NewRouter(backend, WithAuditLog(auditLog)).Routes() {
+		if route.Method() == http.MethodPost && route.Path() == "/stacks/{id}" {
+			update = route.Handler()
+		}
+	}
+
+	spec := func(replicas uint64, image string) types.StackSpec {
+		return types.StackSpec{
+			Metadata: types.Metadata{Name: "web"},
+			Services: composetypes.Services{
+				{Name: "web", Image: image, Deploy: composetypes.DeployConfig{Replicas: &replicas}},
+			},
+		}
+	}
+	previous := types.Stack{ID: "web", Spec: spec(1, "nginx:1.17")}
+	previous.Version.Index = 1
+	stored := types.Stack{ID: "web", Spec: spec(3, "nginx:1.17")}
+	stored.Version.Index = 2
+	backend.EXPECT().GetStack("web").Return(previous, nil)
+	// an admission mutator sets the image of the request back to the
+	// stored one, so that only the replicas change
+	backend.EXPECT().UpdateStack("web", spec(3, "nginx"), uint64(1)).Return(types.StackUpdateResponse{Stack: &stored}, nil)
+
+	body, err := json.Marshal(spec(3, "nginx"))
+	require.NoError(err)
+	r := httptest.NewRequest(http.MethodPost, "/stacks/web?version=1", bytes.NewReader(body))
+	require.NoError(update(context.Background(), httptest.NewRecorder(), r, map[string]string{"id": "web"}))
+
+	require.Len(auditLog.entries, 1)
+	require.Equal(types.AuditActionScale, auditLog.entries[0].Action)
+	require.Equal(uint64(2), auditLog.entries[0].NewVersion)
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+const (
+	// defaultWaitTimeout is the time a synchronous create or update waits
+	// for the stack to converge, unless the request sets a timeout.
+	defaultWaitTimeout = 10 * time.Minute
+
+	// waitPollInterval is the interval at which the progress of a stack is
+	// checked during a synchronous create or update.
+	waitPollInterval = time.Second
+)
+
+// waitOptions holds the query parameters of a synchronous create or update.
+type waitOptions struct {
+	wait    bool
+	timeout time.Duration
+}
+
+// parseWaitOptions parses the wait and timeout query parameters.
+func parseWaitOptions(r *http.Request) (waitOptions, error) {
+	opts := waitOptions{
+		wait:    httputils.BoolValue(r, "wait"),
+		timeout: defaultWaitTimeout,
+	}
+
+	if rawTimeout := r.URL.Query().Get("timeout"); rawTimeout != "" {
+		timeout, err := time.ParseDuration(rawTimeout)
+		if err != nil || timeout <= 0 {
+			return opts, errdefs.InvalidParameter(fmt.Errorf("invalid timeout '%s'", rawTimeout))
+		}
+		opts.timeout = timeout
+	}
+
+	return opts, nil
+}
+
+// streamDeployProgress writes the progress of a stack as newline-delimited
+// StackDeployMessages, until the stack converges, fails, or the timeout
+// expires. The last message always carries the result of the deploy. since
+// is the time at which the deploy started, so that failures reported by the
+// reconciler in the meantime are not missed.
+func (sr *stacksRouter) streamDeployProgress(ctx context.Context, w http.ResponseWriter, id string, statusCode int, since time.Time, timeout time.Duration) error {
+	// subscribing with a since timestamp returns the events that happened
+	// between the start of the deploy and now.
+	past, eventC := sr.backend.SubscribeToStackEvents(since)
+	defer sr.backend.UnsubscribeFromStackEvents(eventC)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	flush(w)
+
+	enc := json.NewEncoder(w)
+	writeResult := func(status types.StackDeployResultStatus, message string) error {
+		err := enc.Encode(types.StackDeployMessage{
+			Result: &types.StackDeployResult{
+				StackID: id,
+				Status:  status,
+				Message: message,
+			},
+		})
+		flush(w)
+		return err
+	}
+
+	for _, event := range past {
+		if event.StackID == id && event.Action == types.StackEventFailed {
+			return writeResult(types.StackDeployFailed, event.Message)
+		}
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	var last types.StackProgress
+	for {
+		progress, err := sr.backend.GetStackProgress(id)
+		if err != nil {
+			return writeResult(types.StackDeployFailed, err.Error())
+		}
+
+		if !reflect.DeepEqual(last, progress) {
+			if err := enc.Encode(types.StackDeployMessage{Progress: &progress}); err != nil {
+				// the client has gone away
+				return nil
+			}
+			flush(w)
+			last = progress
+		}
+
+		switch {
+		case progress.Converged:
+			return writeResult(types.StackDeployConverged, "")
+		case progress.Failed:
+			return writeResult(types.StackDeployFailed, progress.Message)
+		}
+
+		select {
+		case <-ticker.C:
+		case event, ok := <-eventC:
+			if ok && event.StackID == id && event.Action == types.StackEventFailed {
+				return writeResult(types.StackDeployFailed, event.Message)
+			}
+		case <-deadline.C:
+			return writeResult(types.StackDeployTimeout, fmt.Sprintf("stack did not converge within %s", timeout))
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -1,18 +1,32 @@
 package standalone
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/docker/docker/api/server/httputils"
 	"github.com/docker/docker/api/server/router"
 	"github.com/docker/docker/client"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
 
+	"github.com/docker/stacks/pkg/admission"
+	"github.com/docker/stacks/pkg/audit"
+	"github.com/docker/stacks/pkg/auth"
+	"github.com/docker/stacks/pkg/containers"
 	"github.com/docker/stacks/pkg/controller/backend"
 	stacksRouter "github.com/docker/stacks/pkg/controller/router"
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/kube"
+	"github.com/docker/stacks/pkg/metrics"
 	"github.com/docker/stacks/pkg/reconciler"
 )
 
@@ -22,6 +36,46 @@ type ServerOptions struct {
 	Debug            bool
 	DockerSocketPath string
 	ServerPort       int
+
+	// Kubernetes enables the Kubernetes orchestrator driver, using the
+	// in-cluster configuration of the Kubernetes cluster the server runs
+	// in. The cluster is expected to run compose-on-kubernetes.
+	Kubernetes bool
+
+	// AdmissionPolicyFile is the path of the policy file configuring the
+	// admission controllers run on stacks before they are stored.
+	AdmissionPolicyFile string
+
+	// QuotasFile is the path of the file defining the quotas of
+	// collections.
+	QuotasFile string
+
+	// TLSCertFile and TLSKeyFile are the certificate and key the API is
+	// served with over TLS. The API is served over plain HTTP without them.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TLSCACertFile is the path of the CA certificates client certificates
+	// are verified against. Clients are identified by the common name of
+	// their certificate.
+	TLSCACertFile string
+
+	// TokensFile is the path of the file defining the bearer tokens of
+	// clients. It requires TLSCertFile and TLSKeyFile.
+	TokensFile string
+
+	// AuthorizationPolicyFile is the path of the policy file defining the
+	// operations clients may perform on the stacks of each collection.
+	AuthorizationPolicyFile string
+
+	// AuditLogFile is the path of the file the mutations of stacks are
+	// recorded in.
+	AuditLogFile string
+
+	// ContainersStateFile is the path of the file the stacks of the "none"
+	// orchestrator are kept in, so that they survive restarts. They are
+	// kept in memory only without it.
+	ContainersStateFile string
 }
 
 // Server initializes and runs a standalone http Server that serves the Stacks
@@ -38,6 +92,9 @@ func Server(opts ServerOptions) error {
 	if err != nil {
 		return fmt.Errorf("unable to create docker client for unix socket at %s: %s", opts.DockerSocketPath, err)
 	}
+	// Service options are converted according to the API version of the
+	// engine, which is negotiated once.
+	dclient.NegotiateAPIVersion(context.Background())
 
 	// Create a shim for the SwarmResourceBackend interface using the docker client.
 	// This shim is used to access swarm resources by the Stacks API handlers
@@ -51,22 +108,113 @@ func Server(opts ServerOptions) error {
 	// Create a Stacks API Backend, which includes the API handling logic.
 	stacksBackend := backend.NewDefaultStacksBackend(stackStore, swarmResourceBackend)
 
+	// Enforce the admission policy, if any.
+	if opts.AdmissionPolicyFile != "" {
+		policy, err := admission.LoadPolicy(opts.AdmissionPolicyFile)
+		if err != nil {
+			return err
+		}
+		for _, controller := range policy.Controllers() {
+			stacksBackend.RegisterAdmissionController(controller)
+		}
+	}
+
+	// Enforce the quotas of collections, if any.
+	if opts.QuotasFile != "" {
+		quotas, err := backend.LoadCollectionQuotas(opts.QuotasFile)
+		if err != nil {
+			return err
+		}
+		stacksBackend.SetCollectionQuotas(quotas)
+	}
+
+	// Register the driver running stacks as plain containers on the
+	// engine, for the "none" orchestrator.
+	containersDriver := containers.NewDriver(dclient)
+	if opts.ContainersStateFile != "" {
+		if containersDriver, err = containers.LoadDriver(dclient, opts.ContainersStateFile); err != nil {
+			return err
+		}
+	}
+	stacksBackend.RegisterDriver(containersDriver)
+
+	// Register the Kubernetes orchestrator driver, if enabled.
+	if opts.Kubernetes {
+		kubeConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return fmt.Errorf("unable to load in-cluster Kubernetes configuration: %s", err)
+		}
+		kubeBackend, err := kube.NewStacksBackend(kubeConfig)
+		if err != nil {
+			return err
+		}
+		stacksBackend.RegisterDriver(kube.NewDriver(kubeBackend))
+	}
+
 	// Create a BackendClient shim for the reconciler
 	backendClient := interfaces.NewBackendAPIClientShim(dclient, stacksBackend)
 
 	// Create the reconciler manager
 	reconcilerManager := reconciler.New(backendClient)
 
+	// Report the number of stacks in each phase whenever metrics are
+	// collected.
+	metrics.StacksByPhase.SetFunc(func() map[string]float64 {
+		return stacksByPhase(stacksBackend)
+	})
+
+	// Authenticate clients and authorize their operations, if configured.
+	tlsConfig, err := serverTLSConfig(opts)
+	if err != nil {
+		return err
+	}
+	var authenticator *auth.Authenticator
+	if opts.TokensFile != "" || opts.TLSCACertFile != "" {
+		tokens := map[string]string{}
+		if opts.TokensFile != "" {
+			if tokens, err = auth.LoadTokens(opts.TokensFile); err != nil {
+				return err
+			}
+		}
+		authenticator = auth.NewAuthenticator(tokens)
+	}
+	routerOpts := []stacksRouter.Option{}
+	if opts.AuthorizationPolicyFile != "" {
+		if authenticator == nil {
+			return errors.New("an authorization policy requires clients to be authenticated by tokens or client certificates")
+		}
+		policy, err := auth.LoadPolicy(opts.AuthorizationPolicyFile)
+		if err != nil {
+			return err
+		}
+		routerOpts = append(routerOpts, stacksRouter.WithAuthorizer(policy))
+	}
+
+	// Record the mutations of stacks, if configured.
+	if opts.AuditLogFile != "" {
+		auditLog, err := audit.OpenFileLog(opts.AuditLogFile)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+		routerOpts = append(routerOpts, stacksRouter.WithAuditLog(auditLog))
+	}
+
 	// Create a Stacks API Router, which includes basic HTTP handlers
 	// for the Stacks APIs. This is wired up against the backendClient
 	// so that the API can trigger stack events.
-	r := stacksRouter.NewRouter(backendClient)
+	r := stacksRouter.NewRouter(backendClient, routerOpts...)
 
 	errChan := make(chan error)
 
+	handler := registerRoutes(r)
+	if authenticator != nil {
+		handler = authenticator.Handler(handler)
+	}
 	server := &http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:%d", opts.ServerPort),
-		Handler: registerRoutes(r),
+		Addr:      fmt.Sprintf("0.0.0.0:%d", opts.ServerPort),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
 	}
 
 	// Launch the reconciler in a goroutine
@@ -75,40 +223,163 @@ func Server(opts ServerOptions) error {
 		errChan <- reconcilerManager.Run()
 	}()
 
+	// Launch the reconciliation loop of plain container stacks. It is
+	// restarted when it fails, rather than stopping the server.
+	go func() {
+		logrus.Infof("Starting plain container stacks reconciler")
+		runContainersDriver(context.Background(), containersDriver)
+	}()
+
 	// Launch the HTTP server in a goroutine
 	go func() {
 		logrus.Infof("Running standalone Stacks API server")
+		if tlsConfig != nil {
+			errChan <- server.ListenAndServeTLS(opts.TLSCertFile, opts.TLSKeyFile)
+			return
+		}
 		errChan <- server.ListenAndServe()
 	}()
 
 	return <-errChan
 }
 
+const (
+	// containersRestartDelay is the delay after which the reconciliation
+	// loop of plain container stacks is restarted after its first failure.
+	// It doubles with each consecutive failure, up to
+	// containersMaxRestartDelay.
+	containersRestartDelay    = time.Second
+	containersMaxRestartDelay = time.Minute
+)
+
+// runContainersDriver runs the reconciliation loop of plain container
+// stacks until the context is cancelled, restarting it whenever it fails,
+// for instance when the docker event stream is interrupted.
+func runContainersDriver(ctx context.Context, driver *containers.Driver) {
+	delay := containersRestartDelay
+	for {
+		start := time.Now()
+		err := driver.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// a loop which ran for a while before failing starts over with
+		// the shortest delay
+		if time.Since(start) > containersMaxRestartDelay {
+			delay = containersRestartDelay
+		}
+		logrus.Errorf("plain container stacks reconciler failed, restarting in %s: %s", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > containersMaxRestartDelay {
+			delay = containersMaxRestartDelay
+		}
+	}
+}
+
+// serverTLSConfig returns the TLS configuration of the API server, or nil
+// if it is served over plain HTTP. Client certificates are required when
+// they are the only way for clients to authenticate.
+func serverTLSConfig(opts ServerOptions) (*tls.Config, error) {
+	if opts.TLSCertFile == "" && opts.TLSKeyFile == "" {
+		if opts.TLSCACertFile != "" {
+			return nil, errors.New("verifying client certificates requires a TLS certificate and key")
+		}
+		// bearer tokens would otherwise be sent in clear text
+		if opts.TokensFile != "" {
+			return nil, errors.New("authenticating clients by bearer tokens requires a TLS certificate and key")
+		}
+		return nil, nil
+	}
+	if opts.TLSCertFile == "" || opts.TLSKeyFile == "" {
+		return nil, errors.New("both a TLS certificate and key are required")
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.TLSCACertFile != "" {
+		pem, err := ioutil.ReadFile(opts.TLSCACertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read CA certificates")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificate found in %s", opts.TLSCACertFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if opts.TokensFile != "" {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return config, nil
+}
+
 // versionMatcher defines a variable matcher to be parsed by the router
 // when a request is about to be served.
 const versionMatcher = "/v{version:[0-9.]+}"
 
+// metricsPath is the path on which the Prometheus metrics are served.
+const metricsPath = "/metrics"
+
 // Implementation loosely based on
 // https://github.com/moby/moby/blob/master/api/server/server.go#L171-L198
 func registerRoutes(r router.Router) http.Handler {
 	m := mux.NewRouter()
 	for _, r := range r.Routes() {
-		f := makeHTTPHandler(r.Handler())
+		f := makeHTTPHandler(r.Method(), r.Path(), r.Handler())
 		m.Path(versionMatcher + r.Path()).Methods(r.Method()).Handler(f)
 		m.Path(r.Path()).Methods(r.Method()).Handler(f)
 	}
+	m.Path(metricsPath).Methods(http.MethodGet).Handler(metrics.Handler())
 
 	return m
 }
 
-func makeHTTPHandler(handler httputils.APIFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+// statusRecorder is an http.ResponseWriter that remembers the status code
+// written by a handler, so that it can be reported in the API metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher, so that streaming handlers keep working
+// through the recorder.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func makeHTTPHandler(method, route string, handler httputils.APIFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		w := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		defer func() {
+			metrics.APIRequests.WithLabelValues(method, route, strconv.Itoa(w.status)).Inc()
+			metrics.APIRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		}()
+
 		vars := mux.Vars(r)
 		if vars == nil {
 			vars = make(map[string]string)
 		}
 
 		if err := handler(r.Context(), w, r, vars); err != nil {
+			// Stacks rejected by admission controllers are reported with
+			// the list of violations.
+			if admissionErr, ok := errors.Cause(err).(*admission.Error); ok {
+				w.status = http.StatusBadRequest
+				httputils.WriteJSON(w, http.StatusBadRequest, admissionErr.Response())
+				return
+			}
 			statusCode := httputils.GetHTTPErrorStatusCode(err)
 			if statusCode >= 500 {
 				logrus.Errorf("Handler for %s %s returned error: %v", r.Method, r.URL.Path, err)
@@ -117,3 +388,18 @@ func makeHTTPHandler(handler httputils.APIFunc) http.HandlerFunc {
 		}
 	}
 }
+
+// stacksByPhase counts the stacks known to the backend by phase.
+func stacksByPhase(b *backend.DefaultStacksBackend) map[string]float64 {
+	counts, err := b.StacksByPhase()
+	if err != nil {
+		logrus.Errorf("unable to count stacks for metrics: %s", err)
+		return nil
+	}
+
+	phases := map[string]float64{}
+	for phase, count := range counts {
+		phases[phase] = float64(count)
+	}
+	return phases
+}
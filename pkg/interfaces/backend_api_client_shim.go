@@ -10,6 +10,7 @@ import (
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/docker/stacks/pkg/types"
@@ -92,7 +93,7 @@ func (c *BackendAPIClientShim) UnsubscribeFromEvents(eventChan chan interface{})
 func (c *BackendAPIClientShim) CreateStack(create types.StackCreate) (types.StackCreateResponse, error) {
 	resp, err := c.StacksBackend.CreateStack(create)
 	if err != nil {
-		return resp, fmt.Errorf("unable to create stack: %s", err)
+		return resp, errors.Wrap(err, "unable to create stack")
 	}
 
 	go func() {
@@ -110,9 +111,30 @@ func (c *BackendAPIClientShim) CreateStack(create types.StackCreate) (types.Stac
 	return resp, err
 }
 
+// AdoptStack creates a stack out of the objects deployed with docker stack
+// deploy in a namespace.
+func (c *BackendAPIClientShim) AdoptStack(adopt types.StackAdopt) (types.StackCreateResponse, error) {
+	resp, err := c.StacksBackend.AdoptStack(adopt)
+	if err != nil {
+		return resp, errors.Wrap(err, "unable to adopt stack")
+	}
+
+	go func() {
+		c.stackEvents <- events.Message{
+			Type:   "stack",
+			Action: "create",
+			Actor: events.Actor{
+				ID: resp.ID,
+			},
+		}
+	}()
+
+	return resp, err
+}
+
 // UpdateStack updates a stack.
-func (c *BackendAPIClientShim) UpdateStack(id string, spec types.StackSpec, version uint64) error {
-	err := c.StacksBackend.UpdateStack(id, spec, version)
+func (c *BackendAPIClientShim) UpdateStack(id string, spec types.StackSpec, version uint64) (types.StackUpdateResponse, error) {
+	resp, err := c.StacksBackend.UpdateStack(id, spec, version)
 	go func() {
 		logrus.Debugf("writing stack update event")
 		c.stackEvents <- events.Message{
@@ -125,7 +147,7 @@ func (c *BackendAPIClientShim) UpdateStack(id string, spec types.StackSpec, vers
 		logrus.Debugf("wrote stack update event")
 	}()
 
-	return err
+	return resp, err
 }
 
 // DeleteStack deletes a stack.
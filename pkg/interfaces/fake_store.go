@@ -86,6 +86,22 @@ func (s *FakeStackStore) UpdateStack(id string, spec types.StackSpec, swarmSpec
 	return nil
 }
 
+// UpdateStackResources replaces the StackResources of a stack, without
+// changing its version.
+func (s *FakeStackStore) UpdateStackResources(id string, resources types.StackResources) error {
+	s.Lock()
+	defer s.Unlock()
+
+	existingStack, err := s.getStack(id)
+	if err != nil {
+		return errNotFound
+	}
+
+	existingStack.Stack.StackResources = resources
+	s.stacks[id] = existingStack
+	return nil
+}
+
 // DeleteStack removes a stack from the store.
 func (s *FakeStackStore) DeleteStack(id string) error {
 	s.Lock()
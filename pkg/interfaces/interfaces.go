@@ -8,6 +8,7 @@ import (
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
 
 	"github.com/docker/stacks/pkg/types"
 )
@@ -18,15 +19,64 @@ type StacksBackend interface {
 	CreateStack(types.StackCreate) (types.StackCreateResponse, error)
 	GetStack(id string) (types.Stack, error)
 	ListStacks() ([]types.Stack, error)
-	UpdateStack(id string, spec types.StackSpec, version uint64) error
+	UpdateStack(id string, spec types.StackSpec, version uint64) (types.StackUpdateResponse, error)
 	DeleteStack(id string) error
 
+	// AdoptStack creates a stack out of the objects deployed with docker
+	// stack deploy in a namespace.
+	AdoptStack(types.StackAdopt) (types.StackCreateResponse, error)
+
 	// The following operations are only used by the Reconciler and not
 	// exposed via the Stacks API.
 	GetSwarmStack(id string) (SwarmStack, error)
 	ListSwarmStacks() ([]SwarmStack, error)
 
+	// AddStackResource and RemoveStackResource maintain the StackResources
+	// of a swarm stack, as its objects are created and removed.
+	AddStackResource(stackID, name string, resource types.StackResource) error
+	RemoveStackResource(stackID, kind, name string) error
+
 	ParseComposeInput(input types.ComposeInput) (*types.StackCreate, error)
+
+	// GetStackProgress reports how far the objects of a stack are from
+	// their desired state.
+	GetStackProgress(id string) (types.StackProgress, error)
+
+	// SubscribeToStackEvents and UnsubscribeFromStackEvents give access to
+	// the stack lifecycle event stream, and PublishStackEvent adds events
+	// to it.
+	SubscribeToStackEvents(since time.Time) ([]types.StackEvent, chan types.StackEvent)
+	UnsubscribeFromStackEvents(chan types.StackEvent)
+	PublishStackEvent(types.StackEvent)
+
+	// GetCollectionQuota and ListCollectionQuotas report the quotas of
+	// collections, along with the resources used by their stacks.
+	GetCollectionQuota(collection string) (types.CollectionQuota, error)
+	ListCollectionQuotas() ([]types.CollectionQuota, error)
+}
+
+// OrchestratorDriver deploys stacks on a single orchestrator. A driver is
+// responsible for converting stack specs to the objects of its orchestrator,
+// storing them, having them reconciled and reporting their status. The
+// StacksBackend routes each stack to the driver of its OrchestratorChoice.
+type OrchestratorDriver interface {
+	// Orchestrator returns the orchestrator served by the driver.
+	Orchestrator() types.OrchestratorChoice
+
+	// CreateStack converts and stores a new stack, and returns its ID.
+	CreateStack(types.StackCreate) (string, error)
+
+	// GetStack returns an errdefs.NotFound error if the stack does not
+	// belong to the driver, so that stacks can be looked up across all
+	// drivers.
+	GetStack(id string) (types.Stack, error)
+	ListStacks() ([]types.Stack, error)
+	UpdateStack(id string, spec types.StackSpec, version uint64) error
+	DeleteStack(id string) error
+
+	// GetStackProgress reports how far the objects of a stack are from
+	// their desired state.
+	GetStackProgress(id string) (types.StackProgress, error)
 }
 
 // SwarmResourceBackend is a subset of the swarm.Backend interface,
@@ -40,8 +90,14 @@ type SwarmResourceBackend interface {
 	// the Cluster object, which provides the rest of the implementation
 	Info() swarm.Info
 
+	// APIVersion returns the version of the engine API used to create
+	// services, which determines the service options they can have. It is
+	// empty if the latest version of the engine is used.
+	APIVersion() string
+
 	// The following methods are part of the swarm.Backend interface
 	GetNode(id string) (swarm.Node, error)
+	GetNodes(dockerTypes.NodeListOptions) ([]swarm.Node, error)
 	GetServices(dockerTypes.ServiceListOptions) ([]swarm.Service, error)
 	GetService(idOrName string, insertDefaults bool) (swarm.Service, error)
 	CreateService(swarm.ServiceSpec, string, bool) (*dockerTypes.ServiceCreateResponse, error)
@@ -59,6 +115,11 @@ type SwarmResourceBackend interface {
 	RemoveConfig(id string) error
 	GetConfig(id string) (swarm.Config, error)
 	UpdateConfig(idOrName string, version uint64, spec swarm.ConfigSpec) error
+
+	// The following methods are part of the volume API of the engine
+	GetVolume(name string) (dockerTypes.Volume, error)
+	CreateVolume(volume.VolumeCreateBody) (dockerTypes.Volume, error)
+	RemoveVolume(name string, force bool) error
 }
 
 // BackendClient is the full interface used by the Stacks Reconciler to
@@ -85,6 +146,11 @@ type StackStore interface {
 	UpdateStack(string, types.StackSpec, SwarmStackSpec, uint64) error
 	DeleteStack(string) error
 
+	// UpdateStackResources replaces the StackResources of a stack. It is
+	// used by the reconciler to record the objects it realized, and
+	// neither checks nor changes the version of the stack.
+	UpdateStackResources(string, types.StackResources) error
+
 	GetStack(id string) (types.Stack, error)
 	GetSwarmStack(id string) (SwarmStack, error)
 
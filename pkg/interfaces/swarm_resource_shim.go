@@ -6,6 +6,7 @@ import (
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/errdefs"
 )
@@ -34,6 +35,12 @@ func (c *SwarmResourceAPIClientShim) Info() swarm.Info {
 	return info.Swarm
 }
 
+// APIVersion returns the API version of the underlying client, which is
+// the version negotiated with the engine if the client negotiated it.
+func (c *SwarmResourceAPIClientShim) APIVersion() string {
+	return c.dclient.ClientVersion()
+}
+
 // GetNode returns a specific node by ID.
 func (c *SwarmResourceAPIClientShim) GetNode(id string) (swarm.Node, error) {
 	node, _, err := c.dclient.NodeInspectWithRaw(context.Background(), id)
@@ -43,6 +50,11 @@ func (c *SwarmResourceAPIClientShim) GetNode(id string) (swarm.Node, error) {
 	return node, err
 }
 
+// GetNodes lists nodes.
+func (c *SwarmResourceAPIClientShim) GetNodes(options dockerTypes.NodeListOptions) ([]swarm.Node, error) {
+	return c.dclient.NodeList(context.Background(), options)
+}
+
 // GetServices lists services.
 func (c *SwarmResourceAPIClientShim) GetServices(options dockerTypes.ServiceListOptions) ([]swarm.Service, error) {
 	return c.dclient.ServiceList(context.Background(), options)
@@ -198,3 +210,26 @@ func (c *SwarmResourceAPIClientShim) CreateNetwork(nc dockerTypes.NetworkCreateR
 func (c *SwarmResourceAPIClientShim) RemoveNetwork(name string) error {
 	return c.dclient.NetworkRemove(context.Background(), name)
 }
+
+// GetVolume inspects a volume.
+func (c *SwarmResourceAPIClientShim) GetVolume(name string) (dockerTypes.Volume, error) {
+	vol, err := c.dclient.VolumeInspect(context.Background(), name)
+	if client.IsErrNotFound(err) {
+		return vol, errdefs.NotFound(err)
+	}
+	return vol, err
+}
+
+// CreateVolume creates a new volume.
+func (c *SwarmResourceAPIClientShim) CreateVolume(options volume.VolumeCreateBody) (dockerTypes.Volume, error) {
+	return c.dclient.VolumeCreate(context.Background(), options)
+}
+
+// RemoveVolume removes a volume.
+func (c *SwarmResourceAPIClientShim) RemoveVolume(name string, force bool) error {
+	err := c.dclient.VolumeRemove(context.Background(), name, force)
+	if client.IsErrNotFound(err) {
+		return errdefs.NotFound(err)
+	}
+	return err
+}
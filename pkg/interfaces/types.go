@@ -3,6 +3,7 @@ package interfaces
 import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
 )
 
 const (
@@ -28,13 +29,23 @@ type SwarmStack struct {
 // Swarm stacks and is never exposed via the API.
 type SwarmStackSpec struct {
 	Annotations swarm.Annotations
-	Services    []swarm.ServiceSpec
+	// Collection is the collection of the stack. The names of the objects
+	// of the stack are scoped by collection, see
+	// convert.NewCollectionNamespace.
+	Collection string
+	Services   []swarm.ServiceSpec
 	// Networks is a map of name -> types.NetworkCreate. It's like this because
 	// Networks don't have a Spec, they're defined in terms of the
 	// NetworkCreate type only.
 	Networks map[string]types.NetworkCreate
 	Secrets  []swarm.SecretSpec
 	Configs  []swarm.ConfigSpec
-	// there is no "Volumes" in a SwarmStackSpec -- Swarm has no concept of
-	// volumes
+	// Volumes is a map of name -> volume.VolumeCreateBody. Swarm has no
+	// concept of volumes, so these are created through the volume API of the
+	// engine the reconciler talks to. Nodes create the volumes of their
+	// tasks on demand, from the options of the service mounts.
+	Volumes map[string]volume.VolumeCreateBody
+	// RemoveVolumes is set when the volumes of the stack must be removed
+	// along with the stack.
+	RemoveVolumes bool
 }
@@ -0,0 +1,75 @@
+package kube
+
+import (
+	"context"
+
+	"github.com/docker/compose-on-kubernetes/api/compose/v1beta2"
+
+	"github.com/docker/stacks/pkg/types"
+)
+
+// Driver is the interfaces.OrchestratorDriver of Kubernetes stacks. Stacks
+// are stored as compose-on-kubernetes Stack objects through a StacksBackend,
+// and reconciled by the compose-on-kubernetes controller.
+type Driver struct {
+	backend *StacksBackend
+}
+
+// NewDriver creates a new Driver using the provided StacksBackend.
+func NewDriver(backend *StacksBackend) *Driver {
+	return &Driver{
+		backend: backend,
+	}
+}
+
+// Orchestrator returns types.OrchestratorKubernetes.
+func (d *Driver) Orchestrator() types.OrchestratorChoice {
+	return types.OrchestratorKubernetes
+}
+
+// CreateStack creates a Kubernetes stack.
+func (d *Driver) CreateStack(create types.StackCreate) (string, error) {
+	resp, err := d.backend.StackCreate(context.TODO(), create, types.StackCreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// GetStack retrieves a Kubernetes stack by its ID.
+func (d *Driver) GetStack(id string) (types.Stack, error) {
+	return d.backend.StackInspect(context.TODO(), id)
+}
+
+// ListStacks lists the Kubernetes stacks of all namespaces.
+func (d *Driver) ListStacks() ([]types.Stack, error) {
+	return d.backend.StackList(context.TODO(), types.StackListOptions{})
+}
+
+// UpdateStack updates a Kubernetes stack.
+func (d *Driver) UpdateStack(id string, spec types.StackSpec, version uint64) error {
+	return d.backend.StackUpdate(context.TODO(), id, types.Version{Index: version}, spec, types.StackUpdateOptions{})
+}
+
+// DeleteStack deletes a Kubernetes stack.
+func (d *Driver) DeleteStack(id string) error {
+	return d.backend.StackDelete(context.TODO(), id)
+}
+
+// GetStackProgress reports the phase of a Kubernetes stack. The
+// compose-on-kubernetes controller does not expose the status of individual
+// services, so ServicesStatus is left empty.
+func (d *Driver) GetStackProgress(id string) (types.StackProgress, error) {
+	stack, err := d.GetStack(id)
+	if err != nil {
+		return types.StackProgress{}, err
+	}
+
+	return types.StackProgress{
+		StackID:        id,
+		ServicesStatus: map[string]types.ServiceStatus{},
+		Converged:      stack.Status.Phase == string(v1beta2.StackAvailable),
+		Failed:         stack.Status.Phase == string(v1beta2.StackFailure),
+		Message:        stack.Status.Message,
+	}, nil
+}
@@ -27,6 +27,12 @@ func ConvertFromKubeStack(kubeStack *v1beta2.Stack) (types.Stack, error) {
 		Orchestrator: types.OrchestratorKubernetes,
 		Spec:         stackSpec,
 	}
+	if kubeStack.Status != nil {
+		res.Status = types.StackStatus{
+			Phase:   string(kubeStack.Status.Phase),
+			Message: kubeStack.Status.Message,
+		}
+	}
 
 	// Parse the object version to a uint64 - should be possible for any
 	// conformant kubernetes distribution.
@@ -121,7 +121,6 @@ func (c *StacksBackend) StackInspect(_ context.Context, id string) (types.Stack,
 		return types.Stack{}, fmt.Errorf("unable to get stack %s: %s", id, err)
 	}
 
-	// TODO: populate status
 	return ConvertFromKubeStack(kubeStack)
 }
 
@@ -147,7 +146,6 @@ func (c *StacksBackend) StackList(_ context.Context, _ types.StackListOptions) (
 		allStacks = append(allStacks, resp.Items...)
 	}
 
-	// TODO: populate statuses
 	return ConvertFromKubeStacks(allStacks)
 }
 
@@ -294,3 +294,45 @@ func TestKubeStacksBackendStackUpdateNotFoundInvalid(t *testing.T) {
 	require.Error(t, err)
 	require.True(t, errdefs.IsNotFound(err))
 }
+
+func TestKubeDriverGetStackProgress(t *testing.T) {
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockComposeV1beta2Interface(ctrl)
+	d := NewDriver(&StacksBackend{
+		composeClient: s,
+	})
+
+	k := mocks.NewMockStackInterface(ctrl)
+	s.EXPECT().Stacks("namespace1").Return(k).Times(2)
+
+	kubeStack := &v1beta2.Stack{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "testname",
+			Namespace:       "namespace1",
+			ResourceVersion: "42",
+		},
+		Spec: &v1beta2.StackSpec{},
+		Status: &v1beta2.StackStatus{
+			Phase: v1beta2.StackProgressing,
+		},
+	}
+	k.EXPECT().Get("testname", gomock.Any()).Return(kubeStack, nil)
+
+	progress, err := d.GetStackProgress("kube_namespace1_testname")
+	require.NoError(err)
+	require.Equal("kube_namespace1_testname", progress.StackID)
+	require.False(progress.Converged)
+	require.False(progress.Failed)
+
+	kubeStack.Status = &v1beta2.StackStatus{
+		Phase:   v1beta2.StackFailure,
+		Message: "unable to create service",
+	}
+	k.EXPECT().Get("testname", gomock.Any()).Return(kubeStack, nil)
+
+	progress, err = d.GetStackProgress("kube_namespace1_testname")
+	require.NoError(err)
+	require.True(progress.Failed)
+	require.Equal("unable to create service", progress.Message)
+}
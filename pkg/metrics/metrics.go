@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler returns an http.Handler serving the metrics of the Default
+// registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Default, promhttp.HandlerOpts{})
+}
+
+// GaugeFunc is a prometheus.Collector of gauges partitioned by a single
+// label, whose values are all computed by a callback when the metrics are
+// collected. It suits values, like the number of stacks in each phase, that
+// are cheaper to compute on demand than to keep up to date.
+type GaugeFunc struct {
+	desc *prometheus.Desc
+
+	mu sync.Mutex
+	fn func() map[string]float64
+}
+
+// NewGaugeFunc creates a new GaugeFunc. It reports nothing until a callback
+// is set with SetFunc.
+func NewGaugeFunc(name, help, label string) *GaugeFunc {
+	return &GaugeFunc{
+		desc: prometheus.NewDesc(name, help, []string{label}, nil),
+	}
+}
+
+// SetFunc sets the callback computing the values of the gauge, keyed by the
+// value of its label.
+func (g *GaugeFunc) SetFunc(fn func() map[string]float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.fn = fn
+}
+
+// Describe implements prometheus.Collector.
+func (g *GaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect implements prometheus.Collector.
+func (g *GaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	g.mu.Lock()
+	fn := g.fn
+	g.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	for value, v := range fn() {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v, value)
+	}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	require := require.New(t)
+
+	APIRequests.WithLabelValues("GET", "/stacks", "200").Inc()
+	Leader.Set(1)
+	StacksByPhase.SetFunc(func() map[string]float64 {
+		return map[string]float64{"converged": 2, "failed": 1}
+	})
+	defer StacksByPhase.SetFunc(nil)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(200, rec.Code)
+	require.Contains(rec.Header().Get("Content-Type"), "text/plain")
+
+	out := rec.Body.String()
+	require.Contains(out, "# TYPE stacks_api_requests_total counter\n")
+	require.Contains(out, `stacks_api_requests_total{code="200",method="GET",route="/stacks"} 1`+"\n")
+	require.Contains(out, "stacks_reconciler_leader 1\n")
+	require.Contains(out, "# TYPE stacks_by_phase gauge\n")
+	require.Contains(out, `stacks_by_phase{phase="converged"} 2`+"\n")
+	require.Contains(out, `stacks_by_phase{phase="failed"} 1`+"\n")
+}
+
+func TestGaugeFuncUnset(t *testing.T) {
+	g := NewGaugeFunc("test_by_phase", "Phases.", "phase")
+	require.Equal(t, 0, collected(g))
+
+	g.SetFunc(func() map[string]float64 {
+		return map[string]float64{"pending": 1, "converged": 3}
+	})
+	require.Equal(t, 2, collected(g))
+}
+
+// collected returns the number of metrics collected from g.
+func collected(g *GaugeFunc) int {
+	ch := make(chan prometheus.Metric, 10)
+	g.Collect(ch)
+	close(ch)
+	return len(ch)
+}
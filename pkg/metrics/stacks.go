@@ -0,0 +1,81 @@
+// Package metrics contains the Prometheus metrics exposed by the stacks
+// controller.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Default is the registry holding all metrics of the stacks controller. It is
+// served by the standalone runtime on the /metrics endpoint.
+var Default = prometheus.NewRegistry()
+
+var (
+	// APIRequests counts the Stacks API requests served, by route.
+	APIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stacks_api_requests_total",
+		Help: "Number of Stacks API requests, partitioned by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	// APIRequestDuration tracks the latency of Stacks API requests, by route.
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "stacks_api_request_duration_seconds",
+		Help: "Latency of Stacks API requests, partitioned by method and route.",
+	}, []string{"method", "route"})
+
+	// DispatcherQueueDepth is the number of objects waiting in the
+	// dispatcher to be reconciled, by object kind.
+	DispatcherQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "stacks_dispatcher_queue_depth",
+		Help: "Number of objects pending reconciliation, partitioned by kind.",
+	}, []string{"kind"})
+
+	// ReconcileDuration tracks the time spent reconciling a single object,
+	// by object kind.
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "stacks_reconcile_duration_seconds",
+		Help: "Time spent reconciling a single object, partitioned by kind.",
+	}, []string{"kind"})
+
+	// ReconcileErrors counts the failed reconciliations, by object kind.
+	ReconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stacks_reconcile_errors_total",
+		Help: "Number of failed reconciliations, partitioned by kind.",
+	}, []string{"kind"})
+
+	// Resyncs counts the stacks reconciled again although they did not
+	// change, by reason: "retry" after a failed reconciliation, or "startup"
+	// when the orchestrator driver starts.
+	Resyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "stacks_resyncs_total",
+		Help: "Number of stacks reconciled again without having changed, partitioned by reason.",
+	}, []string{"reason"})
+
+	// Leader is 1 while the reconciler is running as the swarm leader, and 0
+	// otherwise.
+	Leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "stacks_reconciler_leader",
+		Help: "Whether the reconciler is currently running on the swarm leader (1) or not (0).",
+	})
+
+	// StacksByPhase is the number of stored stacks, by phase. Its values are
+	// computed at collection time by the callback set by the runtime.
+	StacksByPhase = NewGaugeFunc(
+		"stacks_by_phase",
+		"Number of stacks, partitioned by phase.",
+		"phase",
+	)
+)
+
+func init() {
+	Default.MustRegister(
+		APIRequests,
+		APIRequestDuration,
+		DispatcherQueueDepth,
+		ReconcileDuration,
+		ReconcileErrors,
+		Resyncs,
+		Leader,
+		StacksByPhase,
+	)
+}
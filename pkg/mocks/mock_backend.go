@@ -12,6 +12,7 @@ import (
 	events "github.com/docker/docker/api/types/events"
 	filters "github.com/docker/docker/api/types/filters"
 	swarm "github.com/docker/docker/api/types/swarm"
+	volume "github.com/docker/docker/api/types/volume"
 	interfaces "github.com/docker/stacks/pkg/interfaces"
 	types0 "github.com/docker/stacks/pkg/types"
 	gomock "github.com/golang/mock/gomock"
@@ -40,6 +41,45 @@ func (m *MockBackendClient) EXPECT() *MockBackendClientMockRecorder {
 	return m.recorder
 }
 
+// APIVersion mocks base method
+func (m *MockBackendClient) APIVersion() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "APIVersion")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// APIVersion indicates an expected call of APIVersion
+func (mr *MockBackendClientMockRecorder) APIVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "APIVersion", reflect.TypeOf((*MockBackendClient)(nil).APIVersion))
+}
+
+// AddStackResource mocks base method
+func (m *MockBackendClient) AddStackResource(arg0 string, arg1 string, arg2 types0.StackResource) error {
+	ret := m.ctrl.Call(m, "AddStackResource", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddStackResource indicates an expected call of AddStackResource
+func (mr *MockBackendClientMockRecorder) AddStackResource(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddStackResource", reflect.TypeOf((*MockBackendClient)(nil).AddStackResource), arg0, arg1, arg2)
+}
+
+// AdoptStack mocks base method
+func (m *MockBackendClient) AdoptStack(arg0 types0.StackAdopt) (types0.StackCreateResponse, error) {
+	ret := m.ctrl.Call(m, "AdoptStack", arg0)
+	ret0, _ := ret[0].(types0.StackCreateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdoptStack indicates an expected call of AdoptStack
+func (mr *MockBackendClientMockRecorder) AdoptStack(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdoptStack", reflect.TypeOf((*MockBackendClient)(nil).AdoptStack), arg0)
+}
+
 // CreateConfig mocks base method
 func (m *MockBackendClient) CreateConfig(arg0 swarm.ConfigSpec) (string, error) {
 	ret := m.ctrl.Call(m, "CreateConfig", arg0)
@@ -105,6 +145,19 @@ func (mr *MockBackendClientMockRecorder) CreateStack(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStack", reflect.TypeOf((*MockBackendClient)(nil).CreateStack), arg0)
 }
 
+// CreateVolume mocks base method
+func (m *MockBackendClient) CreateVolume(arg0 volume.VolumeCreateBody) (types.Volume, error) {
+	ret := m.ctrl.Call(m, "CreateVolume", arg0)
+	ret0, _ := ret[0].(types.Volume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateVolume indicates an expected call of CreateVolume
+func (mr *MockBackendClientMockRecorder) CreateVolume(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVolume", reflect.TypeOf((*MockBackendClient)(nil).CreateVolume), arg0)
+}
+
 // DeleteStack mocks base method
 func (m *MockBackendClient) DeleteStack(arg0 string) error {
 	ret := m.ctrl.Call(m, "DeleteStack", arg0)
@@ -117,6 +170,19 @@ func (mr *MockBackendClientMockRecorder) DeleteStack(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStack", reflect.TypeOf((*MockBackendClient)(nil).DeleteStack), arg0)
 }
 
+// GetCollectionQuota mocks base method
+func (m *MockBackendClient) GetCollectionQuota(arg0 string) (types0.CollectionQuota, error) {
+	ret := m.ctrl.Call(m, "GetCollectionQuota", arg0)
+	ret0, _ := ret[0].(types0.CollectionQuota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCollectionQuota indicates an expected call of GetCollectionQuota
+func (mr *MockBackendClientMockRecorder) GetCollectionQuota(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCollectionQuota", reflect.TypeOf((*MockBackendClient)(nil).GetCollectionQuota), arg0)
+}
+
 // GetConfig mocks base method
 func (m *MockBackendClient) GetConfig(arg0 string) (swarm.Config, error) {
 	ret := m.ctrl.Call(m, "GetConfig", arg0)
@@ -197,6 +263,19 @@ func (mr *MockBackendClientMockRecorder) GetNode(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNode", reflect.TypeOf((*MockBackendClient)(nil).GetNode), arg0)
 }
 
+// GetNodes mocks base method
+func (m *MockBackendClient) GetNodes(arg0 types.NodeListOptions) ([]swarm.Node, error) {
+	ret := m.ctrl.Call(m, "GetNodes", arg0)
+	ret0, _ := ret[0].([]swarm.Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNodes indicates an expected call of GetNodes
+func (mr *MockBackendClientMockRecorder) GetNodes(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNodes", reflect.TypeOf((*MockBackendClient)(nil).GetNodes), arg0)
+}
+
 // GetSecret mocks base method
 func (m *MockBackendClient) GetSecret(arg0 string) (swarm.Secret, error) {
 	ret := m.ctrl.Call(m, "GetSecret", arg0)
@@ -262,6 +341,19 @@ func (mr *MockBackendClientMockRecorder) GetStack(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStack", reflect.TypeOf((*MockBackendClient)(nil).GetStack), arg0)
 }
 
+// GetStackProgress mocks base method
+func (m *MockBackendClient) GetStackProgress(arg0 string) (types0.StackProgress, error) {
+	ret := m.ctrl.Call(m, "GetStackProgress", arg0)
+	ret0, _ := ret[0].(types0.StackProgress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStackProgress indicates an expected call of GetStackProgress
+func (mr *MockBackendClientMockRecorder) GetStackProgress(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStackProgress", reflect.TypeOf((*MockBackendClient)(nil).GetStackProgress), arg0)
+}
+
 // GetSwarmStack mocks base method
 func (m *MockBackendClient) GetSwarmStack(arg0 string) (interfaces.SwarmStack, error) {
 	ret := m.ctrl.Call(m, "GetSwarmStack", arg0)
@@ -301,6 +393,19 @@ func (mr *MockBackendClientMockRecorder) GetTasks(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTasks", reflect.TypeOf((*MockBackendClient)(nil).GetTasks), arg0)
 }
 
+// GetVolume mocks base method
+func (m *MockBackendClient) GetVolume(arg0 string) (types.Volume, error) {
+	ret := m.ctrl.Call(m, "GetVolume", arg0)
+	ret0, _ := ret[0].(types.Volume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVolume indicates an expected call of GetVolume
+func (mr *MockBackendClientMockRecorder) GetVolume(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolume", reflect.TypeOf((*MockBackendClient)(nil).GetVolume), arg0)
+}
+
 // Info mocks base method
 func (m *MockBackendClient) Info() swarm.Info {
 	m.ctrl.T.Helper()
@@ -315,6 +420,19 @@ func (mr *MockBackendClientMockRecorder) Info() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Info", reflect.TypeOf((*MockBackendClient)(nil).Info))
 }
 
+// ListCollectionQuotas mocks base method
+func (m *MockBackendClient) ListCollectionQuotas() ([]types0.CollectionQuota, error) {
+	ret := m.ctrl.Call(m, "ListCollectionQuotas")
+	ret0, _ := ret[0].([]types0.CollectionQuota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCollectionQuotas indicates an expected call of ListCollectionQuotas
+func (mr *MockBackendClientMockRecorder) ListCollectionQuotas() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCollectionQuotas", reflect.TypeOf((*MockBackendClient)(nil).ListCollectionQuotas))
+}
+
 // ListStacks mocks base method
 func (m *MockBackendClient) ListStacks() ([]types0.Stack, error) {
 	ret := m.ctrl.Call(m, "ListStacks")
@@ -354,6 +472,16 @@ func (mr *MockBackendClientMockRecorder) ParseComposeInput(arg0 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseComposeInput", reflect.TypeOf((*MockBackendClient)(nil).ParseComposeInput), arg0)
 }
 
+// PublishStackEvent mocks base method
+func (m *MockBackendClient) PublishStackEvent(arg0 types0.StackEvent) {
+	m.ctrl.Call(m, "PublishStackEvent", arg0)
+}
+
+// PublishStackEvent indicates an expected call of PublishStackEvent
+func (mr *MockBackendClientMockRecorder) PublishStackEvent(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishStackEvent", reflect.TypeOf((*MockBackendClient)(nil).PublishStackEvent), arg0)
+}
+
 // RemoveConfig mocks base method
 func (m *MockBackendClient) RemoveConfig(arg0 string) error {
 	ret := m.ctrl.Call(m, "RemoveConfig", arg0)
@@ -402,6 +530,30 @@ func (mr *MockBackendClientMockRecorder) RemoveService(arg0 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveService", reflect.TypeOf((*MockBackendClient)(nil).RemoveService), arg0)
 }
 
+// RemoveStackResource mocks base method
+func (m *MockBackendClient) RemoveStackResource(arg0 string, arg1 string, arg2 string) error {
+	ret := m.ctrl.Call(m, "RemoveStackResource", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveStackResource indicates an expected call of RemoveStackResource
+func (mr *MockBackendClientMockRecorder) RemoveStackResource(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveStackResource", reflect.TypeOf((*MockBackendClient)(nil).RemoveStackResource), arg0, arg1, arg2)
+}
+
+// RemoveVolume mocks base method
+func (m *MockBackendClient) RemoveVolume(arg0 string, arg1 bool) error {
+	ret := m.ctrl.Call(m, "RemoveVolume", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveVolume indicates an expected call of RemoveVolume
+func (mr *MockBackendClientMockRecorder) RemoveVolume(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveVolume", reflect.TypeOf((*MockBackendClient)(nil).RemoveVolume), arg0, arg1)
+}
+
 // SubscribeToEvents mocks base method
 func (m *MockBackendClient) SubscribeToEvents(arg0, arg1 time.Time, arg2 filters.Args) ([]events.Message, chan interface{}) {
 	ret := m.ctrl.Call(m, "SubscribeToEvents", arg0, arg1, arg2)
@@ -415,6 +567,19 @@ func (mr *MockBackendClientMockRecorder) SubscribeToEvents(arg0, arg1, arg2 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToEvents", reflect.TypeOf((*MockBackendClient)(nil).SubscribeToEvents), arg0, arg1, arg2)
 }
 
+// SubscribeToStackEvents mocks base method
+func (m *MockBackendClient) SubscribeToStackEvents(arg0 time.Time) ([]types0.StackEvent, chan types0.StackEvent) {
+	ret := m.ctrl.Call(m, "SubscribeToStackEvents", arg0)
+	ret0, _ := ret[0].([]types0.StackEvent)
+	ret1, _ := ret[1].(chan types0.StackEvent)
+	return ret0, ret1
+}
+
+// SubscribeToStackEvents indicates an expected call of SubscribeToStackEvents
+func (mr *MockBackendClientMockRecorder) SubscribeToStackEvents(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToStackEvents", reflect.TypeOf((*MockBackendClient)(nil).SubscribeToStackEvents), arg0)
+}
+
 // UnsubscribeFromEvents mocks base method
 func (m *MockBackendClient) UnsubscribeFromEvents(arg0 chan interface{}) {
 	m.ctrl.Call(m, "UnsubscribeFromEvents", arg0)
@@ -425,6 +590,16 @@ func (mr *MockBackendClientMockRecorder) UnsubscribeFromEvents(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsubscribeFromEvents", reflect.TypeOf((*MockBackendClient)(nil).UnsubscribeFromEvents), arg0)
 }
 
+// UnsubscribeFromStackEvents mocks base method
+func (m *MockBackendClient) UnsubscribeFromStackEvents(arg0 chan types0.StackEvent) {
+	m.ctrl.Call(m, "UnsubscribeFromStackEvents", arg0)
+}
+
+// UnsubscribeFromStackEvents indicates an expected call of UnsubscribeFromStackEvents
+func (mr *MockBackendClientMockRecorder) UnsubscribeFromStackEvents(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsubscribeFromStackEvents", reflect.TypeOf((*MockBackendClient)(nil).UnsubscribeFromStackEvents), arg0)
+}
+
 // UpdateConfig mocks base method
 func (m *MockBackendClient) UpdateConfig(arg0 string, arg1 uint64, arg2 swarm.ConfigSpec) error {
 	ret := m.ctrl.Call(m, "UpdateConfig", arg0, arg1, arg2)
@@ -463,10 +638,11 @@ func (mr *MockBackendClientMockRecorder) UpdateService(arg0, arg1, arg2, arg3, a
 }
 
 // UpdateStack mocks base method
-func (m *MockBackendClient) UpdateStack(arg0 string, arg1 types0.StackSpec, arg2 uint64) error {
+func (m *MockBackendClient) UpdateStack(arg0 string, arg1 types0.StackSpec, arg2 uint64) (types0.StackUpdateResponse, error) {
 	ret := m.ctrl.Call(m, "UpdateStack", arg0, arg1, arg2)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(types0.StackUpdateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // UpdateStack indicates an expected call of UpdateStack
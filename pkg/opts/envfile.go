@@ -1,6 +1,7 @@
 package opts
 
 import (
+	"bytes"
 	"os"
 )
 
@@ -20,3 +21,10 @@ import (
 func ParseEnvFile(filename string) ([]string, error) {
 	return parseKeyValueFile(filename, os.LookupEnv)
 }
+
+// ParseEnvFileContent parses the content of an env file the same way as
+// ParseEnvFile. As the file comes from elsewhere, variables without a value
+// are not looked up in the environment, and are left out.
+func ParseEnvFileContent(filename string, data []byte) ([]string, error) {
+	return parseKeyValues(bytes.NewReader(data), filename, nil)
+}
@@ -176,3 +176,19 @@ func TestParseEnvVariableWithNoNameFile(t *testing.T) {
 		t.Fatal("if a variable has no name parsing an environment file must fail")
 	}
 }
+
+// Test ParseEnvFileContent does not look up variables without a value
+func TestParseEnvFileContent(t *testing.T) {
+	os.Setenv("PARSE_ENV_FILE_CONTENT", "leaked")
+	defer os.Unsetenv("PARSE_ENV_FILE_CONTENT")
+
+	lines, err := ParseEnvFileContent("attached.env", []byte("foo=bar\nPARSE_ENV_FILE_CONTENT\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLines := []string{"foo=bar"}
+	if !reflect.DeepEqual(lines, expectedLines) {
+		t.Fatalf("lines %v not equal to expectedLines %v", lines, expectedLines)
+	}
+}
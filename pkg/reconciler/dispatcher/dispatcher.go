@@ -2,11 +2,13 @@ package dispatcher
 
 import (
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types/events"
 	"github.com/sirupsen/logrus"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/metrics"
 	"github.com/docker/stacks/pkg/reconciler/notifier"
 	"github.com/docker/stacks/pkg/reconciler/reconciler"
 )
@@ -86,6 +88,17 @@ func (d *dispatcher) Notify(kind, id string) {
 	case events.ServiceEventType:
 		d.pendingServices[id] = struct{}{}
 	}
+	d.updateQueueDepth()
+}
+
+// updateQueueDepth publishes the number of pending objects of each kind. It
+// must be called with the lock held.
+func (d *dispatcher) updateQueueDepth() {
+	metrics.DispatcherQueueDepth.WithLabelValues(interfaces.StackEventType).Set(float64(len(d.pendingStacks)))
+	metrics.DispatcherQueueDepth.WithLabelValues(events.NetworkEventType).Set(float64(len(d.pendingNetworks)))
+	metrics.DispatcherQueueDepth.WithLabelValues(events.SecretEventType).Set(float64(len(d.pendingSecrets)))
+	metrics.DispatcherQueueDepth.WithLabelValues(events.ConfigEventType).Set(float64(len(d.pendingConfigs)))
+	metrics.DispatcherQueueDepth.WithLabelValues(events.ServiceEventType).Set(float64(len(d.pendingServices)))
 }
 
 // HandleEvents takes a channel that issues events, and processes those events
@@ -154,10 +167,14 @@ func (d *dispatcher) HandleEvents(eventC chan interface{}) error {
 				}
 				// next state: reconcile the object. if it fails, add it back
 				// to the set of objects.
-				if err := d.r.Reconcile(kind, id); err != nil {
+				start := time.Now()
+				err := d.r.Reconcile(kind, id)
+				metrics.ReconcileDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+				if err != nil {
 					// TODO(dperny): if a given object always fails, we'll stay
 					// in this state forever, looping again and again.
 					logrus.Error(err)
+					metrics.ReconcileErrors.WithLabelValues(kind).Inc()
 					d.Notify(kind, id)
 				}
 			}
@@ -188,6 +205,7 @@ func (d *dispatcher) resolveMessage(ev interface{}) {
 func (d *dispatcher) pickObject() (string, string) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	defer d.updateQueueDepth()
 	for stack := range d.pendingStacks {
 		// it should be safe to delete from a map we're iterating over.
 		// especially considering we're not iterating any further.
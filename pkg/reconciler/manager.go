@@ -9,6 +9,7 @@ import (
 	"github.com/docker/docker/api/types/filters"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/metrics"
 	"github.com/docker/stacks/pkg/reconciler/dispatcher"
 	"github.com/docker/stacks/pkg/reconciler/notifier"
 	"github.com/docker/stacks/pkg/reconciler/reconciler"
@@ -201,6 +202,11 @@ func (m *Manager) checkNodeEventLeadership(ev interface{}) bool {
 
 // run is the private method that implements the actual logic of running.
 func (m *Manager) run() error {
+	// run is entered once waitReady has seen this node become the leader,
+	// and it exits as soon as leadership is lost.
+	metrics.Leader.Set(1)
+	defer metrics.Leader.Set(0)
+
 	// Using the client, get an events channel. SubscribeToEvents takes a
 	// couple of Time arguments, but we won't use them right now. Instead,
 	// we'll pass a raw time.Time, which is the zero-value. Additionally, to
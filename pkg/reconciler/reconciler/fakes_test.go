@@ -11,9 +11,11 @@ import (
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/errdefs"
 
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
 )
 
 // fakeReconcilerClient is a fake implementing the ReconcilerClient interface,
@@ -33,8 +35,21 @@ type fakeReconcilerClient struct {
 	// maps name -> id
 	stacksByName map[string]string
 
+	// maps stack id -> recorded resources
+	resources map[string]types.StackResources
+
 	services       map[string]*swarm.Service
 	servicesByName map[string]string
+
+	// networks, secrets and configs map id -> object
+	networks map[string]dockerTypes.NetworkResource
+	secrets  map[string]swarm.Secret
+	configs  map[string]swarm.Config
+	// volumes map name -> volume
+	volumes map[string]dockerTypes.Volume
+
+	// events records the stack events published by the reconciler
+	events []types.StackEvent
 }
 
 // error definitions to reuse
@@ -48,8 +63,13 @@ func newFakeReconcilerClient() *fakeReconcilerClient {
 	return &fakeReconcilerClient{
 		stacks:         map[string]*interfaces.SwarmStack{},
 		stacksByName:   map[string]string{},
+		resources:      map[string]types.StackResources{},
 		services:       map[string]*swarm.Service{},
 		servicesByName: map[string]string{},
+		networks:       map[string]dockerTypes.NetworkResource{},
+		secrets:        map[string]swarm.Secret{},
+		configs:        map[string]swarm.Config{},
+		volumes:        map[string]dockerTypes.Volume{},
 	}
 }
 
@@ -71,6 +91,62 @@ func (f *fakeReconcilerClient) GetSwarmStack(idOrName string) (interfaces.SwarmS
 	return *stack, nil
 }
 
+// ListSwarmStacks lists all SwarmStacks.
+func (f *fakeReconcilerClient) ListSwarmStacks() ([]interfaces.SwarmStack, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stacks := make([]interfaces.SwarmStack, 0, len(f.stacks))
+	for _, stack := range f.stacks {
+		stacks = append(stacks, *stack)
+	}
+	return stacks, nil
+}
+
+// GetStack gets a Stack. Only the ID and the StackResources of the stack are
+// filled in.
+func (f *fakeReconcilerClient) GetStack(idOrName string) (types.Stack, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := resolveID(f.stacksByName, idOrName)
+	if _, ok := f.stacks[id]; !ok {
+		return types.Stack{}, notFound
+	}
+	return types.Stack{
+		ID:             id,
+		StackResources: f.resources[id],
+	}, nil
+}
+
+// AddStackResource records a resource of a stack.
+func (f *fakeReconcilerClient) AddStackResource(stackID, name string, resource types.StackResource) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.stacks[stackID]; !ok {
+		return notFound
+	}
+	resources := f.resources[stackID]
+	resources.Set(name, resource)
+	f.resources[stackID] = resources
+	return nil
+}
+
+// RemoveStackResource removes a recorded resource of a stack.
+func (f *fakeReconcilerClient) RemoveStackResource(stackID, kind, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.stacks[stackID]; !ok {
+		return notFound
+	}
+	resources := f.resources[stackID]
+	resources.Remove(kind, name)
+	f.resources[stackID] = resources
+	return nil
+}
+
 // GetServices implements the GetServices method of the BackendClient,
 // returning a list of services. It only supports 1 kind of filter, which is
 // a filter for stack ID.
@@ -205,6 +281,167 @@ func (f *fakeReconcilerClient) RemoveService(idOrName string) error {
 	return nil
 }
 
+// GetNetworksByName lists the networks with the given name.
+func (f *fakeReconcilerClient) GetNetworksByName(name string) ([]dockerTypes.NetworkResource, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	networks := []dockerTypes.NetworkResource{}
+	for _, network := range f.networks {
+		if network.Name == name {
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+// CreateNetwork creates a network.
+func (f *fakeReconcilerClient) CreateNetwork(create dockerTypes.NetworkCreateRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := causeAnError("create", create.Labels); err != nil {
+		return "", err
+	}
+
+	network := dockerTypes.NetworkResource{
+		ID:     f.newID("network"),
+		Name:   create.Name,
+		Labels: create.Labels,
+	}
+	f.networks[network.ID] = network
+	return network.ID, nil
+}
+
+// RemoveNetwork removes a network.
+func (f *fakeReconcilerClient) RemoveNetwork(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	network, ok := f.networks[id]
+	if !ok {
+		return notFound
+	}
+	if err := causeAnError("remove", network.Labels); err != nil {
+		return err
+	}
+	delete(f.networks, id)
+	return nil
+}
+
+// GetSecrets lists secrets. The filters are ignored.
+func (f *fakeReconcilerClient) GetSecrets(_ dockerTypes.SecretListOptions) ([]swarm.Secret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets := []swarm.Secret{}
+	for _, secret := range f.secrets {
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// CreateSecret creates a secret.
+func (f *fakeReconcilerClient) CreateSecret(spec swarm.SecretSpec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secret := swarm.Secret{ID: f.newID("secret"), Spec: spec}
+	f.secrets[secret.ID] = secret
+	return secret.ID, nil
+}
+
+// RemoveSecret removes a secret.
+func (f *fakeReconcilerClient) RemoveSecret(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.secrets[id]; !ok {
+		return notFound
+	}
+	delete(f.secrets, id)
+	return nil
+}
+
+// GetConfigs lists configs. The filters are ignored.
+func (f *fakeReconcilerClient) GetConfigs(_ dockerTypes.ConfigListOptions) ([]swarm.Config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	configs := []swarm.Config{}
+	for _, config := range f.configs {
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// CreateConfig creates a config.
+func (f *fakeReconcilerClient) CreateConfig(spec swarm.ConfigSpec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	config := swarm.Config{ID: f.newID("config"), Spec: spec}
+	f.configs[config.ID] = config
+	return config.ID, nil
+}
+
+// RemoveConfig removes a config.
+func (f *fakeReconcilerClient) RemoveConfig(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.configs[id]; !ok {
+		return notFound
+	}
+	delete(f.configs, id)
+	return nil
+}
+
+// GetVolume gets a volume by name.
+func (f *fakeReconcilerClient) GetVolume(name string) (dockerTypes.Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	vol, ok := f.volumes[name]
+	if !ok {
+		return dockerTypes.Volume{}, notFound
+	}
+	return vol, nil
+}
+
+// CreateVolume creates a volume.
+func (f *fakeReconcilerClient) CreateVolume(create volume.VolumeCreateBody) (dockerTypes.Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	vol := dockerTypes.Volume{
+		Name:   create.Name,
+		Driver: create.Driver,
+		Labels: create.Labels,
+	}
+	f.volumes[vol.Name] = vol
+	return vol, nil
+}
+
+// RemoveVolume removes a volume.
+func (f *fakeReconcilerClient) RemoveVolume(name string, _ bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.volumes[name]; !ok {
+		return notFound
+	}
+	delete(f.volumes, name)
+	return nil
+}
+
+// PublishStackEvent records a stack event.
+func (f *fakeReconcilerClient) PublishStackEvent(event types.StackEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+}
+
 // resolveID takes a value that might be an ID or and figures out which it is,
 // returning the ID
 func resolveID(namesToIds map[string]string, key string) string {
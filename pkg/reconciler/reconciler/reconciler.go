@@ -8,18 +8,25 @@ import (
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/errdefs"
 	"github.com/sirupsen/logrus"
 
+	"github.com/docker/stacks/pkg/compose/convert"
 	"github.com/docker/stacks/pkg/interfaces"
 	"github.com/docker/stacks/pkg/reconciler/notifier"
+	"github.com/docker/stacks/pkg/types"
 )
 
 // Client is the subset of interfaces.BackendClient methods needed to
 // implement the Reconciler.
 type Client interface {
 	// stack methods
+	GetStack(string) (types.Stack, error)
 	GetSwarmStack(string) (interfaces.SwarmStack, error)
+	ListSwarmStacks() ([]interfaces.SwarmStack, error)
+	AddStackResource(stackID, name string, resource types.StackResource) error
+	RemoveStackResource(stackID, kind, name string) error
 
 	// service methods
 	GetServices(dockerTypes.ServiceListOptions) ([]swarm.Service, error)
@@ -28,6 +35,27 @@ type Client interface {
 	UpdateService(string, uint64, swarm.ServiceSpec, dockerTypes.ServiceUpdateOptions, bool) (*dockerTypes.ServiceUpdateResponse, error)
 	RemoveService(string) error
 
+	// network methods
+	GetNetworksByName(string) ([]dockerTypes.NetworkResource, error)
+	CreateNetwork(dockerTypes.NetworkCreateRequest) (string, error)
+	RemoveNetwork(string) error
+
+	// secret and config methods
+	GetSecrets(dockerTypes.SecretListOptions) ([]swarm.Secret, error)
+	CreateSecret(swarm.SecretSpec) (string, error)
+	RemoveSecret(string) error
+	GetConfigs(dockerTypes.ConfigListOptions) ([]swarm.Config, error)
+	CreateConfig(swarm.ConfigSpec) (string, error)
+	RemoveConfig(string) error
+
+	// volume methods
+	GetVolume(string) (dockerTypes.Volume, error)
+	CreateVolume(volume.VolumeCreateBody) (dockerTypes.Volume, error)
+	RemoveVolume(string, bool) error
+
+	// event methods
+	PublishStackEvent(types.StackEvent)
+
 	// TODO(dperny): there's a lot more where this came from, but these are the
 	// parts we need to make this part go
 }
@@ -64,6 +92,20 @@ type reconciler struct {
 	// belong to. it is used to determine if a deleted object belongs to a
 	// stack
 	stackResources map[string]string
+
+	// stackObjects maps stack IDs to the networks, secrets, configs and
+	// removable volumes last recorded for the stack, so that they can be
+	// removed once the stack is deleted and its StackResources are gone with
+	// it. It is loaded from the StackResources of all stacks before the
+	// first reconciliation, as stacks may be deleted before they are
+	// reconciled again.
+	stackObjects       map[string]types.StackResources
+	stackObjectsLoaded bool
+
+	// unconverged is the set of the IDs of the stacks whose services did not
+	// all match their specs when the stacks were last reconciled. Their
+	// convergence is reported once their services are reconciled.
+	unconverged map[string]bool
 }
 
 // New creates a new Reconciler object, which uses the provided
@@ -79,14 +121,30 @@ func newReconciler(notify notifier.ObjectChangeNotifier, cli Client) *reconciler
 		notify:         notify,
 		cli:            cli,
 		stackResources: map[string]string{},
+		stackObjects:   map[string]types.StackResources{},
+		unconverged:    map[string]bool{},
 	}
 	return r
 }
 
 func (r *reconciler) Reconcile(kind, id string) error {
+	if !r.stackObjectsLoaded {
+		if err := r.loadStackObjects(); err != nil {
+			return err
+		}
+	}
+
 	switch kind {
 	case interfaces.StackEventType:
-		return r.reconcileStack(id)
+		err := r.reconcileStack(id)
+		if err != nil {
+			r.cli.PublishStackEvent(types.StackEvent{
+				StackID: id,
+				Action:  types.StackEventFailed,
+				Message: err.Error(),
+			})
+		}
+		return err
 	case events.ServiceEventType:
 		return r.reconcileService(id)
 	default:
@@ -95,6 +153,51 @@ func (r *reconciler) Reconcile(kind, id string) error {
 	}
 }
 
+// loadStackObjects fills stackObjects with the networks, secrets, configs
+// and removable volumes recorded in the StackResources of the existing
+// stacks, so that the objects of the stacks deleted after the reconciler
+// started, but before it reconciled them, are removed too.
+func (r *reconciler) loadStackObjects() error {
+	stacks, err := r.cli.ListSwarmStacks()
+	if err != nil {
+		return fmt.Errorf("unable to list stacks: %s", err)
+	}
+	for _, swarmStack := range stacks {
+		if _, ok := r.stackObjects[swarmStack.ID]; ok {
+			continue
+		}
+		stack, err := r.cli.GetStack(swarmStack.ID)
+		switch {
+		case errdefs.IsNotFound(err):
+			continue
+		case err != nil:
+			return fmt.Errorf("unable to get the resources of stack %s: %s", swarmStack.ID, err)
+		}
+
+		objects := types.StackResources{}
+		for _, recorded := range []map[string]types.StackResource{
+			stack.StackResources.Networks,
+			stack.StackResources.Secrets,
+			stack.StackResources.Configs,
+		} {
+			for name, resource := range recorded {
+				objects.Set(name, resource)
+			}
+		}
+		if swarmStack.Spec.RemoveVolumes {
+			for name, resource := range stack.StackResources.Volumes {
+				vol, err := r.cli.GetVolume(name)
+				if err == nil && ownsVolume(swarmStack.Spec, vol) {
+					objects.Set(name, resource)
+				}
+			}
+		}
+		r.stackObjects[swarmStack.ID] = objects
+	}
+	r.stackObjectsLoaded = true
+	return nil
+}
+
 // reconcileStack implements the ReconcileStack method of the Reconciler
 // interface
 func (r *reconciler) reconcileStack(id string) error {
@@ -108,6 +211,38 @@ func (r *reconciler) reconcileStack(id string) error {
 		return err
 	}
 
+	r.cli.PublishStackEvent(types.StackEvent{
+		StackID: id,
+		Action:  types.StackEventReconciling,
+	})
+
+	current, err := r.cli.GetStack(id)
+	if err != nil {
+		return err
+	}
+	recorded := current.StackResources
+
+	// networks, secrets, configs and volumes are referenced by the
+	// services, so they have to exist before the services are created.
+	// objects collects them, for removal once the stack is deleted.
+	objects := types.StackResources{}
+	if err := r.reconcileNetworks(id, stack.Spec, recorded, &objects); err != nil {
+		return err
+	}
+	if err := r.reconcileSecrets(id, stack.Spec, recorded, &objects); err != nil {
+		return err
+	}
+	if err := r.reconcileConfigs(id, stack.Spec, recorded, &objects); err != nil {
+		return err
+	}
+	if err := r.reconcileVolumes(id, stack.Spec, recorded, &objects); err != nil {
+		return err
+	}
+
+	// converged tracks whether every service of the stack already exists
+	// with the desired spec, in which case there is nothing left to do.
+	converged := true
+
 	for _, spec := range stack.Spec.Services {
 		// try getting the service to see if it already exists
 		service, err := r.cli.GetService(spec.Annotations.Name, false)
@@ -121,15 +256,30 @@ func (r *reconciler) reconcileStack(id string) error {
 			if err != nil {
 				return err
 			}
+			converged = false
+			r.cli.PublishStackEvent(types.StackEvent{
+				StackID: id,
+				Action:  types.StackEventServiceCreated,
+				Service: spec.Annotations.Name,
+			})
 			// when we create the service, add it to the mapping of stack
 			// resources. this ensures that if the resource is deleted
 			// immediately after, then we still have record of it
 			r.stackResources[resp.ID] = id
+			if err := r.recordResource(id, spec.Annotations.Name, types.StackResourceKindService, resp.ID, recorded.Services, nil); err != nil {
+				return err
+			}
 		} else if err != nil {
 			return err
 		} else {
 			// add the service to the map of resources
 			r.stackResources[service.ID] = id
+			if err := r.recordResource(id, spec.Annotations.Name, types.StackResourceKindService, service.ID, recorded.Services, nil); err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(spec, service.Spec) {
+				converged = false
+			}
 			// if the service already exists, it should be reconciled after
 			// this, so notify
 			r.notify.Notify("service", service.ID)
@@ -151,10 +301,27 @@ func (r *reconciler) reconcileStack(id string) error {
 		// it would get caught when we reconciled that stack, so we don't need
 		// to handle that case here.
 		if _, ok := r.stackResources[service.ID]; !ok {
+			converged = false
 			r.notify.Notify("service", service.ID)
 		}
 	}
 
+	// networks, secrets, configs and volumes dropped from the stack are
+	// removed last, as the services using them may not have been updated
+	// yet.
+	r.removeStaleObjects(id, stack.Spec, recorded, &objects)
+	r.stackObjects[id] = objects
+
+	if converged {
+		delete(r.unconverged, id)
+		r.cli.PublishStackEvent(types.StackEvent{
+			StackID: id,
+			Action:  types.StackEventConverged,
+		})
+	} else {
+		r.unconverged[id] = true
+	}
+
 	return nil
 }
 
@@ -197,7 +364,7 @@ func (r *reconciler) reconcileService(id string) error {
 	// if the stack has been deleted, then the service must follow with it.
 	if errdefs.IsNotFound(err) {
 		delete(r.stackResources, id)
-		return r.cli.RemoveService(id)
+		return r.removeService(stackID, id, service.Spec.Annotations.Name)
 	}
 	// any other error means we can't reconcile this service right now
 	if err != nil {
@@ -222,7 +389,7 @@ func (r *reconciler) reconcileService(id string) error {
 	// if there is no matching service spec, then we need to delete the service
 	if !found {
 		delete(r.stackResources, id)
-		return r.cli.RemoveService(id)
+		return r.removeService(stackID, id, service.Spec.Annotations.Name)
 	}
 
 	// finally, check if the service is already the same
@@ -236,10 +403,56 @@ func (r *reconciler) reconcileService(id string) error {
 			dockerTypes.ServiceUpdateOptions{},
 			false,
 		)
-		return err
+		if err != nil {
+			return err
+		}
+		r.cli.PublishStackEvent(types.StackEvent{
+			StackID: stackID,
+			Action:  types.StackEventServiceUpdated,
+			Service: service.Spec.Annotations.Name,
+		})
+		return nil
 	}
 
-	// if it is. then there is nothing to do
+	// if it is. then there is nothing to do, but the stack may have
+	// converged with this service.
+	if r.unconverged[stackID] {
+		r.checkConverged(stackID, stack)
+	}
+	return nil
+}
+
+// checkConverged reports that a stack has converged, if all of its services
+// exist with the desired spec.
+func (r *reconciler) checkConverged(stackID string, stack interfaces.SwarmStack) {
+	for _, spec := range stack.Spec.Services {
+		service, err := r.cli.GetService(spec.Annotations.Name, false)
+		if err != nil || !reflect.DeepEqual(spec, service.Spec) {
+			return
+		}
+	}
+	delete(r.unconverged, stackID)
+	r.cli.PublishStackEvent(types.StackEvent{
+		StackID: stackID,
+		Action:  types.StackEventConverged,
+	})
+}
+
+// removeService removes a service which no longer belongs to its stack, and
+// reports the removal.
+func (r *reconciler) removeService(stackID, id, name string) error {
+	if err := r.cli.RemoveService(id); err != nil {
+		return err
+	}
+	// the stack may be gone already, in which case so are its resources
+	if err := r.cli.RemoveStackResource(stackID, types.StackResourceKindService, name); err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
+	r.cli.PublishStackEvent(types.StackEvent{
+		StackID: stackID,
+		Action:  types.StackEventServiceRemoved,
+		Service: name,
+	})
 	return nil
 }
 
@@ -256,6 +469,40 @@ func (r *reconciler) deleteStack(id string) error {
 	for _, service := range services {
 		r.notify.Notify("service", service.ID)
 	}
+
+	// the networks, secrets, configs and volumes of the stack can only be
+	// removed once its services are gone. Returning an error gets the
+	// deletion retried until they are.
+	objects, ok := r.stackObjects[id]
+	if !ok {
+		return nil
+	}
+	for name, resource := range objects.Networks {
+		if err := r.cli.RemoveNetwork(resource.ID); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("unable to remove network %s of deleted stack %s: %s", name, id, err)
+		}
+		delete(objects.Networks, name)
+	}
+	for name, resource := range objects.Secrets {
+		if err := r.cli.RemoveSecret(resource.ID); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("unable to remove secret %s of deleted stack %s: %s", name, id, err)
+		}
+		delete(objects.Secrets, name)
+	}
+	for name, resource := range objects.Configs {
+		if err := r.cli.RemoveConfig(resource.ID); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("unable to remove config %s of deleted stack %s: %s", name, id, err)
+		}
+		delete(objects.Configs, name)
+	}
+	for name := range objects.Volumes {
+		if err := r.cli.RemoveVolume(name, false); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("unable to remove volume %s of deleted stack %s: %s", name, id, err)
+		}
+		delete(objects.Volumes, name)
+	}
+	delete(r.stackObjects, id)
+	delete(r.unconverged, id)
 	return nil
 }
 
@@ -273,6 +520,234 @@ func (r *reconciler) handleDeletedService(id string) error {
 	return nil
 }
 
+// recordResource adds an object to the StackResources of a stack, unless it
+// is already recorded with the same ID. The object is also added to objects,
+// if it is not nil.
+func (r *reconciler) recordResource(stackID, name, kind, objectID string, recorded map[string]types.StackResource, objects *types.StackResources) error {
+	resource := types.StackResource{
+		Orchestrator: types.OrchestratorSwarm,
+		Kind:         kind,
+		ID:           objectID,
+	}
+	if objects != nil {
+		objects.Set(name, resource)
+	}
+	if existing, ok := recorded[name]; ok && existing.ID == objectID {
+		return nil
+	}
+	return r.cli.AddStackResource(stackID, name, resource)
+}
+
+// reconcileNetworks creates the networks of a stack which do not exist yet,
+// and records all of them. A network of the same name which does not
+// belong to the stack is a conflict: it is neither used nor recorded, so
+// that the deletion of the stack never removes it.
+func (r *reconciler) reconcileNetworks(stackID string, spec interfaces.SwarmStackSpec, recorded types.StackResources, objects *types.StackResources) error {
+	for name, create := range spec.Networks {
+		existing, err := r.cli.GetNetworksByName(name)
+		if err != nil {
+			return err
+		}
+		// the name filter matches on substrings, so look for an exact
+		// match
+		networkID := ""
+		for _, network := range existing {
+			if network.Name != name {
+				continue
+			}
+			if !ownsObject(spec, network.Labels) {
+				return conflictingObject("network", name, stackID)
+			}
+			networkID = network.ID
+		}
+		if networkID == "" {
+			networkID, err = r.cli.CreateNetwork(dockerTypes.NetworkCreateRequest{
+				Name:          name,
+				NetworkCreate: create,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := r.recordResource(stackID, name, types.StackResourceKindNetwork, networkID, recorded.Networks, objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileSecrets creates the secrets of a stack which do not exist yet,
+// and records all of them. Existing secrets are left untouched, as the data
+// of a secret cannot be updated. As with networks, a secret of the same
+// name which does not belong to the stack is a conflict.
+func (r *reconciler) reconcileSecrets(stackID string, stackSpec interfaces.SwarmStackSpec, recorded types.StackResources, objects *types.StackResources) error {
+	for _, spec := range stackSpec.Secrets {
+		name := spec.Annotations.Name
+		existing, err := r.cli.GetSecrets(dockerTypes.SecretListOptions{
+			Filters: filters.NewArgs(filters.Arg("name", name)),
+		})
+		if err != nil {
+			return err
+		}
+		secretID := ""
+		for _, secret := range existing {
+			if secret.Spec.Annotations.Name != name {
+				continue
+			}
+			if !ownsObject(stackSpec, secret.Spec.Annotations.Labels) {
+				return conflictingObject("secret", name, stackID)
+			}
+			secretID = secret.ID
+		}
+		if secretID == "" {
+			if secretID, err = r.cli.CreateSecret(spec); err != nil {
+				return err
+			}
+		}
+		if err := r.recordResource(stackID, name, types.StackResourceKindSecret, secretID, recorded.Secrets, objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileConfigs creates the configs of a stack which do not exist yet,
+// and records all of them. As with networks, a config of the same name
+// which does not belong to the stack is a conflict.
+func (r *reconciler) reconcileConfigs(stackID string, stackSpec interfaces.SwarmStackSpec, recorded types.StackResources, objects *types.StackResources) error {
+	for _, spec := range stackSpec.Configs {
+		name := spec.Annotations.Name
+		existing, err := r.cli.GetConfigs(dockerTypes.ConfigListOptions{
+			Filters: filters.NewArgs(filters.Arg("name", name)),
+		})
+		if err != nil {
+			return err
+		}
+		configID := ""
+		for _, config := range existing {
+			if config.Spec.Annotations.Name != name {
+				continue
+			}
+			if !ownsObject(stackSpec, config.Spec.Annotations.Labels) {
+				return conflictingObject("config", name, stackID)
+			}
+			configID = config.ID
+		}
+		if configID == "" {
+			if configID, err = r.cli.CreateConfig(spec); err != nil {
+				return err
+			}
+		}
+		if err := r.recordResource(stackID, name, types.StackResourceKindConfig, configID, recorded.Configs, objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileVolumes creates the volumes of a stack which do not exist yet,
+// and records all of them. Volumes are identified by their name. Only the
+// volumes created for the stack are removed with it, and only if the stack
+// asks for it: volumes which existed before the stack are never removed.
+func (r *reconciler) reconcileVolumes(stackID string, spec interfaces.SwarmStackSpec, recorded types.StackResources, objects *types.StackResources) error {
+	for name, create := range spec.Volumes {
+		existing, err := r.cli.GetVolume(name)
+		switch {
+		case errdefs.IsNotFound(err):
+			if existing, err = r.cli.CreateVolume(create); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+
+		removable := objects
+		if !spec.RemoveVolumes || !ownsVolume(spec, existing) {
+			removable = nil
+		}
+		if err := r.recordResource(stackID, name, types.StackResourceKindVolume, name, recorded.Volumes, removable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownsVolume returns true if the volume was created for the stack, in which
+// case it carries the namespace label of the stack.
+func ownsVolume(spec interfaces.SwarmStackSpec, vol dockerTypes.Volume) bool {
+	return ownsObject(spec, vol.Labels)
+}
+
+// ownsObject returns true if an object with the given labels was created
+// for the stack, in which case it carries the namespace label of the stack.
+func ownsObject(spec interfaces.SwarmStackSpec, labels map[string]string) bool {
+	return labels[convert.LabelNamespace] == convert.NewCollectionNamespace(spec.Collection, spec.Annotations.Name).Name()
+}
+
+// conflictingObject returns the error reported when an object of a stack
+// already exists, but does not belong to the stack.
+func conflictingObject(kind, name, stackID string) error {
+	return errdefs.Conflict(fmt.Errorf("%s %s already exists, and does not belong to stack %s", kind, name, stackID))
+}
+
+// removeStaleObjects removes the recorded networks, secrets and configs
+// which are no longer part of the stack spec. Volumes are only removed if
+// the stack removes its volumes, and otherwise simply stop being recorded.
+// Objects still in use cannot be removed yet, so failures are only logged:
+// the objects stay recorded, and in objects, and removal is attempted again
+// on the next reconciliation of the stack.
+func (r *reconciler) removeStaleObjects(stackID string, spec interfaces.SwarmStackSpec, recorded types.StackResources, objects *types.StackResources) {
+	secrets := map[string]struct{}{}
+	for _, secret := range spec.Secrets {
+		secrets[secret.Annotations.Name] = struct{}{}
+	}
+	configs := map[string]struct{}{}
+	for _, config := range spec.Configs {
+		configs[config.Annotations.Name] = struct{}{}
+	}
+
+	remove := func(kind, name string, resource types.StackResource, wanted bool, removeFunc func(string) error) {
+		if wanted {
+			return
+		}
+		if err := removeFunc(resource.ID); err != nil && !errdefs.IsNotFound(err) {
+			logrus.Warnf("unable to remove %s %s of stack %s: %s", kind, name, stackID, err)
+			objects.Set(name, resource)
+			return
+		}
+		if err := r.cli.RemoveStackResource(stackID, kind, name); err != nil {
+			logrus.Warnf("unable to remove %s %s from the resources of stack %s: %s", kind, name, stackID, err)
+		}
+	}
+
+	for name, resource := range recorded.Networks {
+		_, wanted := spec.Networks[name]
+		remove(types.StackResourceKindNetwork, name, resource, wanted, r.cli.RemoveNetwork)
+	}
+	for name, resource := range recorded.Secrets {
+		_, wanted := secrets[name]
+		remove(types.StackResourceKindSecret, name, resource, wanted, r.cli.RemoveSecret)
+	}
+	for name, resource := range recorded.Configs {
+		_, wanted := configs[name]
+		remove(types.StackResourceKindConfig, name, resource, wanted, r.cli.RemoveConfig)
+	}
+	for name, resource := range recorded.Volumes {
+		_, wanted := spec.Volumes[name]
+		removeVolume := func(name string) error {
+			if !spec.RemoveVolumes {
+				return nil
+			}
+			vol, err := r.cli.GetVolume(name)
+			if err != nil || !ownsVolume(spec, vol) {
+				return err
+			}
+			return r.cli.RemoveVolume(name, false)
+		}
+		remove(types.StackResourceKindVolume, name, resource, wanted, removeVolume)
+	}
+}
+
 // stackLabelFilter constructs a filter.Args which filters for stacks based on
 // the stack label being equal to the stack ID.
 func stackLabelFilter(stackID string) filters.Args {
@@ -14,8 +14,12 @@ import (
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
 
+	"github.com/docker/stacks/pkg/compose/convert"
 	"github.com/docker/stacks/pkg/interfaces"
+	"github.com/docker/stacks/pkg/types"
 )
 
 const (
@@ -60,6 +64,15 @@ func ConsistOfServices(specs []swarm.ServiceSpec) GomegaMatcher {
 	return WithTransform(serviceSpecs, ConsistOf(specs))
 }
 
+// eventActions returns the actions of a list of stack events, in order.
+func eventActions(events []types.StackEvent) []types.StackEventAction {
+	actions := make([]types.StackEventAction, 0, len(events))
+	for _, event := range events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
 // WTF AM I LOOKING AT AND HOW DOES THIS WORK: A PRIMER ON GINKGO TESTS
 //
 // Ginkgo is a BDD framework. BDD means a lot of things that don't really
@@ -192,6 +205,33 @@ var _ = Describe("Reconciler", func() {
 					Expect(r.stackResources[id]).To(Equal(stackID))
 				}
 			})
+			It("should record the services in the stack resources", func() {
+				Expect(f.resources[stackID].Services).To(HaveLen(2))
+				for name, resource := range f.resources[stackID].Services {
+					Expect(resource.Kind).To(Equal(types.StackResourceKindService))
+					Expect(f.servicesByName[name]).To(Equal(resource.ID))
+				}
+			})
+			It("should report the reconciliation and each created service", func() {
+				Expect(eventActions(f.events)).To(Equal([]types.StackEventAction{
+					types.StackEventReconciling,
+					types.StackEventServiceCreated,
+					types.StackEventServiceCreated,
+				}))
+			})
+			It("should report that the stack has converged once its services are reconciled", func() {
+				Expect(eventActions(f.events)).ToNot(ContainElement(types.StackEventConverged))
+				// both services were created with their specs, so the
+				// first one reconciled reports the convergence, only once
+				Expect(r.Reconcile(events.ServiceEventType, f.servicesByName["service1-name"])).To(Succeed())
+				Expect(r.Reconcile(events.ServiceEventType, f.servicesByName["service2-name"])).To(Succeed())
+				Expect(eventActions(f.events)).To(Equal([]types.StackEventAction{
+					types.StackEventReconciling,
+					types.StackEventServiceCreated,
+					types.StackEventServiceCreated,
+					types.StackEventConverged,
+				}))
+			})
 			When("resource creation fails", func() {
 				BeforeEach(func() {
 					// add the label "makemefail" to a service spec, which will
@@ -201,6 +241,206 @@ var _ = Describe("Reconciler", func() {
 				It("should return an error", func() {
 					Expect(err).To(HaveOccurred())
 				})
+				It("should report the failure", func() {
+					Expect(eventActions(f.events)).To(ContainElement(types.StackEventFailed))
+				})
+			})
+		})
+
+		When("the stack has networks, secrets and configs", func() {
+			BeforeEach(func() {
+				labels := map[string]string{convert.LabelNamespace: stackName}
+				stackFixture.Spec.Networks["stack_net"] = dockertypes.NetworkCreate{Driver: "overlay", Labels: labels}
+				stackFixture.Spec.Secrets = append(stackFixture.Spec.Secrets, swarm.SecretSpec{
+					Annotations: swarm.Annotations{Name: "stack_secret", Labels: labels},
+				})
+				stackFixture.Spec.Configs = append(stackFixture.Spec.Configs, swarm.ConfigSpec{
+					Annotations: swarm.Annotations{Name: "stack_config", Labels: labels},
+				})
+			})
+			It("should create and record them", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f.networks).To(HaveLen(1))
+				Expect(f.secrets).To(HaveLen(1))
+				Expect(f.configs).To(HaveLen(1))
+
+				resources := f.resources[stackID]
+				Expect(f.networks).To(HaveKey(resources.Networks["stack_net"].ID))
+				Expect(f.secrets).To(HaveKey(resources.Secrets["stack_secret"].ID))
+				Expect(f.configs).To(HaveKey(resources.Configs["stack_config"].ID))
+			})
+
+			When("they already exist", func() {
+				var networkID string
+				BeforeEach(func() {
+					networkID, _ = f.CreateNetwork(dockertypes.NetworkCreateRequest{
+						Name:          "stack_net",
+						NetworkCreate: stackFixture.Spec.Networks["stack_net"],
+					})
+				})
+				It("should record the existing objects", func() {
+					Expect(f.networks).To(HaveLen(1))
+					Expect(f.resources[stackID].Networks["stack_net"].ID).To(Equal(networkID))
+				})
+			})
+
+			When("objects of the same names which do not belong to the stack exist", func() {
+				var networkID, secretID, configID string
+				BeforeEach(func() {
+					networkID, _ = f.CreateNetwork(dockertypes.NetworkCreateRequest{Name: "stack_net"})
+					secretID, _ = f.CreateSecret(swarm.SecretSpec{Annotations: swarm.Annotations{Name: "stack_secret"}})
+					configID, _ = f.CreateConfig(swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "stack_config"}})
+				})
+				It("should report the conflict", func() {
+					Expect(errdefs.IsConflict(err)).To(BeTrue())
+					Expect(err.Error()).To(ContainSubstring("network stack_net already exists"))
+					Expect(f.resources[stackID].Networks).ToNot(HaveKey("stack_net"))
+				})
+				It("should report conflicting secrets and configs too", func() {
+					delete(f.networks, networkID)
+					err := r.Reconcile(interfaces.StackEventType, stackID)
+					Expect(errdefs.IsConflict(err)).To(BeTrue())
+					Expect(err.Error()).To(ContainSubstring("secret stack_secret already exists"))
+
+					delete(f.secrets, secretID)
+					err = r.Reconcile(interfaces.StackEventType, stackID)
+					Expect(errdefs.IsConflict(err)).To(BeTrue())
+					Expect(err.Error()).To(ContainSubstring("config stack_config already exists"))
+					Expect(f.resources[stackID].Configs).ToNot(HaveKey("stack_config"))
+				})
+				When("the stack is then deleted", func() {
+					JustBeforeEach(func() {
+						delete(f.stacksByName, stackFixture.Spec.Annotations.Name)
+						delete(f.stacks, stackFixture.ID)
+						err = r.Reconcile(interfaces.StackEventType, stackID)
+					})
+					It("should not remove them", func() {
+						Expect(err).ToNot(HaveOccurred())
+						Expect(f.networks).To(HaveKey(networkID))
+						Expect(f.secrets).To(HaveKey(secretID))
+						Expect(f.configs).To(HaveKey(configID))
+					})
+				})
+			})
+
+			When("a recorded network was dropped from the stack", func() {
+				var networkID string
+				BeforeEach(func() {
+					networkID, _ = f.CreateNetwork(dockertypes.NetworkCreateRequest{Name: "stack_old"})
+					f.AddStackResource(stackID, "stack_old", types.StackResource{
+						Orchestrator: types.OrchestratorSwarm,
+						Kind:         types.StackResourceKindNetwork,
+						ID:           networkID,
+					})
+				})
+				It("should remove the network and its record", func() {
+					Expect(f.networks).ToNot(HaveKey(networkID))
+					Expect(f.resources[stackID].Networks).ToNot(HaveKey("stack_old"))
+				})
+				When("the network cannot be removed yet", func() {
+					BeforeEach(func() {
+						network := f.networks[networkID]
+						network.Labels = map[string]string{"makemefail": "unavailable"}
+						f.networks[networkID] = network
+					})
+					It("should keep the network recorded", func() {
+						Expect(err).ToNot(HaveOccurred())
+						Expect(f.resources[stackID].Networks).To(HaveKey("stack_old"))
+					})
+				})
+			})
+
+			When("the stack is then deleted", func() {
+				JustBeforeEach(func() {
+					delete(f.stacksByName, stackFixture.Spec.Annotations.Name)
+					delete(f.stacks, stackFixture.ID)
+					err = r.Reconcile(interfaces.StackEventType, stackID)
+				})
+				It("should remove them", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(f.networks).To(BeEmpty())
+					Expect(f.secrets).To(BeEmpty())
+					Expect(f.configs).To(BeEmpty())
+				})
+			})
+		})
+
+		When("the stack has volumes", func() {
+			BeforeEach(func() {
+				stackFixture.Spec.Volumes = map[string]volume.VolumeCreateBody{
+					"stack_data": {
+						Name:   "stack_data",
+						Driver: "local",
+						Labels: map[string]string{convert.LabelNamespace: stackName},
+					},
+				}
+				// a volume which existed before the stack is not owned by it
+				f.volumes["shared"] = dockertypes.Volume{Name: "shared"}
+				stackFixture.Spec.Volumes["shared"] = volume.VolumeCreateBody{
+					Name:   "shared",
+					Labels: map[string]string{convert.LabelNamespace: stackName},
+				}
+			})
+			It("should create and record them", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f.volumes).To(HaveKey("stack_data"))
+				Expect(f.volumes["stack_data"].Driver).To(Equal("local"))
+				Expect(f.resources[stackID].Volumes).To(HaveLen(2))
+				Expect(f.resources[stackID].Volumes["stack_data"]).To(Equal(types.StackResource{
+					Orchestrator: types.OrchestratorSwarm,
+					Kind:         types.StackResourceKindVolume,
+					ID:           "stack_data",
+				}))
+			})
+
+			When("the stack is then deleted", func() {
+				JustBeforeEach(func() {
+					delete(f.stacksByName, stackFixture.Spec.Annotations.Name)
+					delete(f.stacks, stackFixture.ID)
+					err = r.Reconcile(interfaces.StackEventType, stackID)
+				})
+				It("should retain the volumes by default", func() {
+					Expect(err).ToNot(HaveOccurred())
+					Expect(f.volumes).To(HaveLen(2))
+				})
+				When("the stack removes its volumes", func() {
+					BeforeEach(func() {
+						stackFixture.Spec.RemoveVolumes = true
+					})
+					It("should only remove the volumes created for the stack", func() {
+						Expect(err).ToNot(HaveOccurred())
+						Expect(f.volumes).To(ConsistOf(dockertypes.Volume{Name: "shared"}))
+					})
+				})
+				When("the stack belongs to a collection", func() {
+					BeforeEach(func() {
+						stackFixture.Spec.RemoveVolumes = true
+						stackFixture.Spec.Collection = "team"
+						stackFixture.Spec.Volumes["team_stack_data"] = volume.VolumeCreateBody{
+							Name:   "team_stack_data",
+							Labels: map[string]string{convert.LabelNamespace: "team_" + stackName},
+						}
+					})
+					It("should only remove the volumes of its collection namespace", func() {
+						Expect(err).ToNot(HaveOccurred())
+						Expect(f.volumes).To(HaveLen(2))
+						Expect(f.volumes).ToNot(HaveKey("team_stack_data"))
+					})
+				})
+			})
+		})
+
+		When("all services of the stack already exist", func() {
+			BeforeEach(func() {
+				for _, spec := range stackFixture.Spec.Services {
+					f.CreateService(spec, "", false)
+				}
+			})
+			It("should report that the stack has converged", func() {
+				Expect(eventActions(f.events)).To(Equal([]types.StackEventAction{
+					types.StackEventReconciling,
+					types.StackEventConverged,
+				}))
 			})
 		})
 
@@ -339,6 +579,29 @@ var _ = Describe("Reconciler", func() {
 				obj("service", f.servicesByName["service3"]),
 			))
 		})
+
+		When("the stack was deleted before it was reconciled again", func() {
+			var networkID string
+			BeforeEach(func() {
+				// the stack and its network were recorded by a previous
+				// reconciler, and the stack is deleted once this one
+				// has loaded them
+				f.stacks[stackFixture.ID] = stackFixture
+				networkID, _ = f.CreateNetwork(dockertypes.NetworkCreateRequest{Name: "net"})
+				f.AddStackResource(stackID, "net", types.StackResource{
+					Orchestrator: types.OrchestratorSwarm,
+					Kind:         types.StackResourceKindNetwork,
+					ID:           networkID,
+				})
+				Expect(r.loadStackObjects()).To(Succeed())
+				delete(f.stacks, stackFixture.ID)
+			})
+			It("should remove its recorded objects", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f.networks).ToNot(HaveKey(networkID))
+				Expect(r.stackObjects).ToNot(HaveKey(stackID))
+			})
+		})
 	})
 
 	Describe("Reconciling services", func() {
@@ -412,12 +675,27 @@ var _ = Describe("Reconciler", func() {
 					It("should return no error if successful", func() {
 						Expect(err).ToNot(HaveOccurred())
 					})
+					It("should report the update", func() {
+						Expect(f.events).To(ConsistOf(types.StackEvent{
+							StackID: stackID,
+							Action:  types.StackEventServiceUpdated,
+							Service: spec.Annotations.Name,
+						}))
+					})
 				})
 
 				When("the service does not have a matching spec in the stack", func() {
 					BeforeEach(func() {
 						f.stacks[stackFixture.ID] = stackFixture
 						f.stacksByName[stackFixture.Spec.Annotations.Name] = stackFixture.ID
+						f.AddStackResource(stackID, spec.Annotations.Name, types.StackResource{
+							Orchestrator: types.OrchestratorSwarm,
+							Kind:         types.StackResourceKindService,
+							ID:           id,
+						})
+					})
+					It("should remove the service from the stack resources", func() {
+						Expect(f.resources[stackID].Services).To(BeEmpty())
 					})
 					It("should delete the service", func() {
 						Expect(f).To(ConsistOfServices([]swarm.ServiceSpec{}))
@@ -425,6 +703,13 @@ var _ = Describe("Reconciler", func() {
 					It("should not return an error", func() {
 						Expect(err).ToNot(HaveOccurred())
 					})
+					It("should report the removal", func() {
+						Expect(f.events).To(ConsistOf(types.StackEvent{
+							StackID: stackID,
+							Action:  types.StackEventServiceRemoved,
+							Service: spec.Annotations.Name,
+						}))
+					})
 				})
 
 				When("the service does match the stack's definition", func() {
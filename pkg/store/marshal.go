@@ -18,6 +18,12 @@ import (
 type CombinedStack struct {
 	Stack      *types.Stack
 	SwarmStack *interfaces.SwarmStack
+
+	// SpecVersion is the version of the resource when the specs of the
+	// stacks were last written, if the resource was updated since without
+	// changing them, as when recording StackResources. Otherwise, it is 0
+	// and the version of the resource is the version of the Stack.
+	SpecVersion uint64 `json:",omitempty"`
 }
 
 func init() {
@@ -41,10 +47,20 @@ func MarshalStacks(stack *types.Stack, swarmStack *interfaces.SwarmStack) (*gogo
 // Stack (Meta, Version, and ID) that are derrived from the values assigned by
 // swarmkit and contained in the Resource
 func UnmarshalStacks(resource *api.Resource) (*types.Stack, *interfaces.SwarmStack, error) {
-	iface, err := typeurl.UnmarshalAny(resource.Payload)
+	combinedStack, err := unmarshalCombinedStack(resource)
 	if err != nil {
 		return nil, nil, err
 	}
+	return combinedStack.Stack, combinedStack.SwarmStack, nil
+}
+
+// unmarshalCombinedStack does the work of UnmarshalStacks, and returns the
+// CombinedStack the stacks were unmarshaled from.
+func unmarshalCombinedStack(resource *api.Resource) (*CombinedStack, error) {
+	iface, err := typeurl.UnmarshalAny(resource.Payload)
+	if err != nil {
+		return nil, err
+	}
 	// this is a naked cast, which means if for some reason this _isn't_ a
 	// CombinedStack object, the program will panic. This is fine, because if
 	// such a thing were to occur, it would be panic-worthy.
@@ -52,15 +68,18 @@ func UnmarshalStacks(resource *api.Resource) (*types.Stack, *interfaces.SwarmSta
 
 	combinedStack.Stack.ID = resource.ID
 	combinedStack.Stack.Version = types.Version{Index: resource.Meta.Version.Index}
+	if combinedStack.SpecVersion != 0 {
+		combinedStack.Stack.Version.Index = combinedStack.SpecVersion
+	}
 
 	// extract the times from the swarmkit resource message.
 	createdAt, err := gogotypes.TimestampFromProto(resource.Meta.CreatedAt)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "error converting swarmkit timestamp")
+		return nil, errors.Wrap(err, "error converting swarmkit timestamp")
 	}
 	updatedAt, err := gogotypes.TimestampFromProto(resource.Meta.UpdatedAt)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "error converting swarmkit timestamp")
+		return nil, errors.Wrap(err, "error converting swarmkit timestamp")
 	}
 
 	combinedStack.SwarmStack.ID = resource.ID
@@ -71,5 +90,5 @@ func UnmarshalStacks(resource *api.Resource) (*types.Stack, *interfaces.SwarmSta
 		CreatedAt: createdAt,
 		UpdatedAt: updatedAt,
 	}
-	return combinedStack.Stack, combinedStack.SwarmStack, nil
+	return combinedStack, nil
 }
@@ -32,6 +32,12 @@ func (s *StackStore) UpdateStack(id string, st types.StackSpec, sst interfaces.S
 	return UpdateStack(context.TODO(), s.client, id, st, sst, version)
 }
 
+// UpdateStackResources replaces the StackResources of an existing Stack
+// object.
+func (s *StackStore) UpdateStackResources(id string, resources types.StackResources) error {
+	return UpdateStackResources(context.TODO(), s.client, id, resources)
+}
+
 // DeleteStack removes the stacks with the given ID.
 func (s *StackStore) DeleteStack(id string) error {
 	return DeleteStack(context.TODO(), s.client, id)
@@ -7,7 +7,10 @@ package store
 
 import (
 	"context"
+	"strings"
 
+	"github.com/containerd/typeurl"
+	"github.com/docker/docker/errdefs"
 	swarmapi "github.com/docker/swarmkit/api"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc/codes"
@@ -89,47 +92,129 @@ func AddStack(ctx context.Context, rc ResourcesClient, st types.Stack, sst inter
 	return resp.Resource.ID, nil
 }
 
-// UpdateStack updates a stack's specs.
+// UpdateStack updates a stack's specs. The update is rejected if version
+// is not the current version of the stack.
 func UpdateStack(ctx context.Context, rc ResourcesClient, id string, st types.StackSpec, sst interfaces.SwarmStackSpec, version uint64) error {
-	// get the swarmkit resource
-	resp, err := rc.GetResource(ctx, &swarmapi.GetResourceRequest{
-		ResourceID: id,
-	})
-	if err != nil {
-		return err
-	}
+	return retryOnSequenceConflict(func() error {
+		// get the swarmkit resource
+		resp, err := rc.GetResource(ctx, &swarmapi.GetResourceRequest{
+			ResourceID: id,
+		})
+		if err != nil {
+			return convertError(err)
+		}
 
-	resource := resp.Resource
-	// unmarshal the contents
-	stack, swarmStack, err := UnmarshalStacks(resource)
-	if err != nil {
+		resource := resp.Resource
+		// unmarshal the contents
+		combinedStack, err := unmarshalCombinedStack(resource)
+		if err != nil {
+			return err
+		}
+		// the version of the stack may differ from the version of the
+		// resource, which is checked by swarmkit in turn.
+		if combinedStack.Stack.Version.Index != version {
+			return errOutOfSequence
+		}
+
+		// update the specs
+		combinedStack.Stack.Spec = st
+		combinedStack.SwarmStack.Spec = sst
+		combinedStack.SpecVersion = 0
+
+		// marshal it all back
+		any, err := typeurl.MarshalAny(combinedStack)
+		if err != nil {
+			return err
+		}
+
+		// and then issue an update.
+		_, err = rc.UpdateResource(ctx,
+			&swarmapi.UpdateResourceRequest{
+				ResourceID:      id,
+				ResourceVersion: &resource.Meta.Version,
+				Annotations: &swarmapi.Annotations{
+					// Swarmkit will return an error if any changes to the
+					// name occur.
+					Name:   sst.Annotations.Name,
+					Labels: sst.Annotations.Labels,
+				},
+				Payload: any,
+			},
+		)
 		return err
-	}
+	})
+}
 
-	// update the specs
-	stack.Spec = st
-	swarmStack.Spec = sst
+// UpdateStackResources replaces the StackResources of a stack. The update
+// is made against the current version of the stack resource, so it never
+// fails because of a concurrent spec update, and it doesn't change the
+// version of the stack, so that clients can still update the stack with the
+// version they have.
+func UpdateStackResources(ctx context.Context, rc ResourcesClient, id string, resources types.StackResources) error {
+	return retryOnSequenceConflict(func() error {
+		resp, err := rc.GetResource(ctx, &swarmapi.GetResourceRequest{
+			ResourceID: id,
+		})
+		if err != nil {
+			return convertError(err)
+		}
 
-	// marshal it all back
-	any, err := MarshalStacks(stack, swarmStack)
-	if err != nil {
+		resource := resp.Resource
+		combinedStack, err := unmarshalCombinedStack(resource)
+		if err != nil {
+			return err
+		}
+
+		combinedStack.Stack.StackResources = resources
+		combinedStack.SpecVersion = combinedStack.Stack.Version.Index
+
+		any, err := typeurl.MarshalAny(combinedStack)
+		if err != nil {
+			return err
+		}
+
+		_, err = rc.UpdateResource(ctx,
+			&swarmapi.UpdateResourceRequest{
+				ResourceID:      id,
+				ResourceVersion: &resource.Meta.Version,
+				Annotations:     &resource.Annotations,
+				Payload:         any,
+			},
+		)
 		return err
+	})
+}
+
+// convertError converts the gRPC errors of swarmkit to the errdefs errors
+// the stacks backend checks for. Stacks which don't exist are reported as
+// errdefs.NotFound errors.
+func convertError(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return errdefs.NotFound(err)
 	}
+	return err
+}
 
-	// and then issue an update.
-	_, err = rc.UpdateResource(context.TODO(),
-		&swarmapi.UpdateResourceRequest{
-			ResourceID:      id,
-			ResourceVersion: &swarmapi.Version{Index: version},
-			Annotations: &swarmapi.Annotations{
-				// Swarmkit will return an error if any changes to the
-				// name occur.
-				Name:   sst.Annotations.Name,
-				Labels: sst.Annotations.Labels,
-			},
-			Payload: any,
-		},
-	)
+// errSequenceConflict is the message of the errors returned by swarmkit
+// when a resource is updated against a version which is not its current
+// one.
+const errSequenceConflict = "update out of sequence"
+
+// errOutOfSequence is returned when a stack is updated against a version
+// which is not its current one.
+var errOutOfSequence = errors.New(errSequenceConflict)
+
+// retryOnSequenceConflict runs update again, at most a few times, if it
+// fails because the resource it updates was updated concurrently, but not
+// if the stack itself was updated concurrently.
+func retryOnSequenceConflict(update func() error) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		err = update()
+		if err == nil || err == errOutOfSequence || !strings.Contains(err.Error(), errSequenceConflict) {
+			return err
+		}
+	}
 	return err
 }
 
@@ -139,7 +224,7 @@ func DeleteStack(ctx context.Context, rc ResourcesClient, id string) error {
 	_, err := rc.RemoveResource(
 		ctx, &swarmapi.RemoveResourceRequest{ResourceID: id},
 	)
-	return err
+	return convertError(err)
 }
 
 // GetStack returns a stack
@@ -148,7 +233,7 @@ func GetStack(ctx context.Context, rc ResourcesClient, id string) (types.Stack,
 		ctx, &swarmapi.GetResourceRequest{ResourceID: id},
 	)
 	if err != nil {
-		return types.Stack{}, err
+		return types.Stack{}, convertError(err)
 	}
 	resource := resp.Resource
 
@@ -171,7 +256,7 @@ func GetSwarmStack(ctx context.Context, rc ResourcesClient, id string) (interfac
 		ctx, &swarmapi.GetResourceRequest{ResourceID: id},
 	)
 	if err != nil {
-		return interfaces.SwarmStack{}, err
+		return interfaces.SwarmStack{}, convertError(err)
 	}
 	resource := resp.Resource
 	_, swarmStack, err := UnmarshalStacks(resource)
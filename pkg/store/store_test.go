@@ -8,10 +8,14 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/containerd/typeurl"
 	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
 	swarmapi "github.com/docker/swarmkit/api"
 	gogotypes "github.com/gogo/protobuf/types"
 	"github.com/golang/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	composetypes "github.com/docker/stacks/pkg/compose/types"
 	"github.com/docker/stacks/pkg/interfaces"
@@ -239,6 +243,101 @@ var _ = Describe("StackStore", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		Specify("UpdateStackResources", func() {
+			mockClient.EXPECT().GetResource(
+				context.TODO(),
+				&swarmapi.GetResourceRequest{
+					ResourceID: stackResource.ID,
+				},
+			).Return(
+				&swarmapi.GetResourceResponse{
+					Resource: stackResource,
+				},
+				nil,
+			)
+
+			resources := types.StackResources{
+				Services: map[string]types.StackResource{
+					"someName_bar": {
+						Orchestrator: types.OrchestratorSwarm,
+						Kind:         types.StackResourceKindService,
+						ID:           "serviceID",
+					},
+				},
+			}
+
+			// the update is made against the current version of the
+			// resource, and only changes the recorded resources
+			mockClient.EXPECT().UpdateResource(context.TODO(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, req *swarmapi.UpdateResourceRequest) (*swarmapi.UpdateResourceResponse, error) {
+					Expect(req.ResourceID).To(Equal(stackResource.ID))
+					Expect(req.ResourceVersion).To(Equal(&stackResource.Meta.Version))
+					Expect(req.Annotations).To(Equal(&stackResource.Annotations))
+
+					// the version of the stack stays the same once the
+					// version of the resource is incremented.
+					meta := stackResource.Meta
+					meta.Version.Index++
+					updated, updatedSwarm, err := UnmarshalStacks(&swarmapi.Resource{
+						ID:      stackResource.ID,
+						Meta:    meta,
+						Payload: req.Payload,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(updated.StackResources).To(Equal(resources))
+					Expect(updated.Spec).To(Equal(stack.Spec))
+					Expect(updated.Version.Index).To(Equal(stackResource.Meta.Version.Index))
+					Expect(updatedSwarm.Spec).To(Equal(swarmStack.Spec))
+					return &swarmapi.UpdateResourceResponse{}, nil
+				},
+			)
+
+			err := s.UpdateStackResources(stackResource.ID, resources)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Specify("UpdateStack after UpdateStackResources", func() {
+			// the resource was updated once its StackResources were
+			// recorded, but the stack is still at version 1
+			combinedStack, err := unmarshalCombinedStack(stackResource)
+			Expect(err).ToNot(HaveOccurred())
+			combinedStack.SpecVersion = 1
+			recordedAny, err := typeurl.MarshalAny(combinedStack)
+			Expect(err).ToNot(HaveOccurred())
+			recorded := *stackResource
+			recorded.Meta.Version.Index = 2
+			recorded.Payload = recordedAny
+
+			mockClient.EXPECT().GetResource(
+				context.TODO(), &swarmapi.GetResourceRequest{ResourceID: stackResource.ID},
+			).Return(&swarmapi.GetResourceResponse{Resource: &recorded}, nil).Times(2)
+
+			// updates against the version of the resource are rejected
+			err = s.UpdateStack(stackResource.ID, stack.Spec, swarmStack.Spec, 2)
+			Expect(err).To(MatchError("update out of sequence"))
+
+			// updates against the version of the stack are made against
+			// the version of the resource, and reset the version of the
+			// stack to the version of the resource
+			mockClient.EXPECT().UpdateResource(context.TODO(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, req *swarmapi.UpdateResourceRequest) (*swarmapi.UpdateResourceResponse, error) {
+					Expect(req.ResourceVersion).To(Equal(&recorded.Meta.Version))
+					meta := recorded.Meta
+					meta.Version.Index++
+					updated, _, err := UnmarshalStacks(&swarmapi.Resource{
+						ID:      stackResource.ID,
+						Meta:    meta,
+						Payload: req.Payload,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(updated.Version.Index).To(Equal(uint64(3)))
+					return &swarmapi.UpdateResourceResponse{}, nil
+				},
+			)
+			err = s.UpdateStack(stackResource.ID, stack.Spec, swarmStack.Spec, 1)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
 		Specify("DeleteStack", func() {
 			mockClient.EXPECT().RemoveResource(
 				context.TODO(),
@@ -305,6 +404,18 @@ var _ = Describe("StackStore", func() {
 			Expect(resSwarmStack).To(Equal(expectedSwarmStackWithFields))
 		})
 
+		Specify("GetStack and GetSwarmStack of a missing stack", func() {
+			mockClient.EXPECT().GetResource(
+				context.TODO(),
+				&swarmapi.GetResourceRequest{ResourceID: "missing"},
+			).Return(nil, status.Errorf(codes.NotFound, "resource missing not found")).Times(2)
+
+			_, err := s.GetStack("missing")
+			Expect(errdefs.IsNotFound(err)).To(BeTrue())
+			_, err = s.GetSwarmStack("missing")
+			Expect(errdefs.IsNotFound(err)).To(BeTrue())
+		})
+
 		Describe("Listing", func() {
 			var (
 				numListedResources = 10
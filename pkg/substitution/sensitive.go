@@ -0,0 +1,168 @@
+package substitution
+
+import (
+	"strings"
+
+	"github.com/docker/stacks/pkg/compose/template"
+	"github.com/docker/stacks/pkg/types"
+)
+
+// RedactSensitiveValues returns a copy of a StackSpec where the values of
+// its sensitive properties are removed from its PropertyValues, leaving
+// only their names. The original Spec is not modified.
+func RedactSensitiveValues(spec types.StackSpec) types.StackSpec {
+	sensitive := sensitiveProperties(spec)
+	if len(sensitive) == 0 {
+		return spec
+	}
+
+	values := make([]string, len(spec.PropertyValues))
+	for i, keyval := range spec.PropertyValues {
+		name := strings.SplitN(keyval, "=", 2)[0]
+		if sensitive[name] {
+			keyval = name
+		}
+		values[i] = keyval
+	}
+	spec.PropertyValues = values
+	return spec
+}
+
+// PreserveSensitiveValues returns a copy of the new spec of a stack where
+// the sensitive properties of its current spec stay sensitive, and keep
+// their current values unless the new spec provides some. Properties are
+// sensitive once marked so, as their values are write-only and clients
+// can't resend them. The original Specs are not modified.
+func PreserveSensitiveValues(current, spec types.StackSpec) types.StackSpec {
+	if len(sensitiveProperties(current)) == 0 {
+		return spec
+	}
+
+	currentValues := propertyValues(current)
+	newValues := propertyValues(spec)
+
+	properties := append([]types.StackProperty{}, spec.Properties...)
+	values := append([]string{}, spec.PropertyValues...)
+	for _, property := range current.Properties {
+		if !property.Sensitive {
+			continue
+		}
+
+		found := false
+		for i := range properties {
+			if properties[i].Name == property.Name {
+				properties[i].Sensitive = true
+				found = true
+			}
+		}
+		if !found {
+			properties = append(properties, property)
+		}
+
+		value, ok := currentValues[property.Name]
+		if _, resent := newValues[property.Name]; resent || !ok {
+			continue
+		}
+		// the name of the property may be listed without its value, as
+		// returned by RedactSensitiveValues.
+		replaced := false
+		for i, keyval := range values {
+			if keyval == property.Name {
+				values[i] = property.Name + "=" + value
+				replaced = true
+			}
+		}
+		if !replaced {
+			values = append(values, property.Name+"="+value)
+		}
+	}
+
+	spec.Properties = properties
+	spec.PropertyValues = values
+	return spec
+}
+
+// DoRedactedSubstitution substitutes the variables of a StackSpec like
+// DoSubstitution, except for the ones of sensitive properties, which are
+// kept as is. Typed options using sensitive properties are left in the
+// TemplatedValues of the result. The original Spec is not modified.
+func DoRedactedSubstitution(spec types.StackSpec) (types.StackSpec, error) {
+	sensitive := sensitiveProperties(spec)
+	if len(sensitive) == 0 {
+		return DoSubstitution(spec)
+	}
+
+	// sensitive properties are substituted by themselves
+	values := []string{}
+	for _, keyval := range spec.PropertyValues {
+		if !sensitive[strings.SplitN(keyval, "=", 2)[0]] {
+			values = append(values, keyval)
+		}
+	}
+	for name := range sensitive {
+		values = append(values, name+"=${"+name+"}")
+	}
+	spec.PropertyValues = values
+
+	var kept map[string]string
+	templated := map[string]string{}
+	for path, value := range spec.TemplatedValues {
+		if !usesProperties(value, sensitive) {
+			templated[path] = value
+			continue
+		}
+		if kept == nil {
+			kept = map[string]string{}
+		}
+		kept[path] = value
+	}
+	spec.TemplatedValues = templated
+
+	substituted, err := DoSubstitution(spec)
+	if err != nil {
+		return substituted, err
+	}
+	substituted.TemplatedValues = kept
+	return substituted, nil
+}
+
+// usesProperties returns true if a value uses any of the given properties.
+func usesProperties(value string, names map[string]bool) bool {
+	for _, match := range template.DefaultPattern.FindAllStringSubmatch(value, -1) {
+		// the named and braced groups hold the variable, along with its
+		// default or error message, if any.
+		variable := match[2] + match[3]
+		if i := strings.IndexAny(variable, ":-?"); i >= 0 {
+			variable = variable[:i]
+		}
+		if names[variable] {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveProperties returns the names of the sensitive properties of a
+// StackSpec.
+func sensitiveProperties(spec types.StackSpec) map[string]bool {
+	sensitive := map[string]bool{}
+	for _, property := range spec.Properties {
+		if property.Sensitive {
+			sensitive[property.Name] = true
+		}
+	}
+	return sensitive
+}
+
+// propertyValues returns the values of the properties of a StackSpec, by
+// name. Properties listed without values are left out.
+func propertyValues(spec types.StackSpec) map[string]string {
+	values := map[string]string{}
+	for _, keyval := range spec.PropertyValues {
+		split := strings.SplitN(keyval, "=", 2)
+		if len(split) == 2 {
+			values[split[0]] = split[1]
+		}
+	}
+	return values
+}
@@ -0,0 +1,98 @@
+package substitution
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+
+	composetypes "github.com/docker/stacks/pkg/compose/types"
+	"github.com/docker/stacks/pkg/types"
+)
+
+func TestRedactSensitiveValues(t *testing.T) {
+	spec := types.StackSpec{
+		Properties: []types.StackProperty{
+			{Name: "PASSWORD", Type: types.PropertyTypeString, Sensitive: true},
+			{Name: "TOKEN", Type: types.PropertyTypeString, Sensitive: true},
+			{Name: "USER", Type: types.PropertyTypeString},
+		},
+		PropertyValues: []string{"USER=admin", "PASSWORD=secret", "TOKEN"},
+	}
+	redacted := RedactSensitiveValues(spec)
+	assert.Check(t, is.DeepEqual([]string{"USER=admin", "PASSWORD", "TOKEN"}, redacted.PropertyValues))
+	assert.Check(t, is.DeepEqual([]string{"USER=admin", "PASSWORD=secret", "TOKEN"}, spec.PropertyValues))
+}
+
+func TestPreserveSensitiveValues(t *testing.T) {
+	current := types.StackSpec{
+		Properties: []types.StackProperty{
+			{Name: "PASSWORD", Type: types.PropertyTypeString, Sensitive: true},
+			{Name: "TOKEN", Type: types.PropertyTypeString, Sensitive: true},
+			{Name: "KEY", Type: types.PropertyTypeString, Sensitive: true},
+			{Name: "USER", Type: types.PropertyTypeString},
+		},
+		PropertyValues: []string{"USER=admin", "PASSWORD=secret", "TOKEN=token", "KEY=key"},
+	}
+
+	// the password is redacted, the token is not resent, the key is
+	// changed and the user is removed.
+	spec := types.StackSpec{
+		Properties: []types.StackProperty{
+			{Name: "PASSWORD", Type: types.PropertyTypeString},
+			{Name: "KEY", Type: types.PropertyTypeString},
+		},
+		PropertyValues: []string{"PASSWORD", "KEY=other"},
+	}
+	preserved := PreserveSensitiveValues(current, spec)
+	assert.Check(t, is.DeepEqual([]types.StackProperty{
+		{Name: "PASSWORD", Type: types.PropertyTypeString, Sensitive: true},
+		{Name: "KEY", Type: types.PropertyTypeString, Sensitive: true},
+		{Name: "TOKEN", Type: types.PropertyTypeString, Sensitive: true},
+	}, preserved.Properties))
+	assert.Check(t, is.DeepEqual([]string{"PASSWORD=secret", "KEY=other", "TOKEN=token"}, preserved.PropertyValues))
+
+	// the original spec is not modified
+	assert.Check(t, !spec.Properties[0].Sensitive)
+	assert.Check(t, is.DeepEqual([]string{"PASSWORD", "KEY=other"}, spec.PropertyValues))
+}
+
+func TestDoRedactedSubstitution(t *testing.T) {
+	spec := types.StackSpec{
+		Services: composetypes.Services{
+			{
+				Name:  "web",
+				Image: "nginx:${TAG}",
+				Environment: composetypes.MappingWithEquals{
+					"PASSWORD": strPtr("${PASSWORD}"),
+				},
+			},
+		},
+		Properties: []types.StackProperty{
+			{Name: "PASSWORD", Type: types.PropertyTypeString, Sensitive: true},
+			{Name: "PASSWORDS", Type: types.PropertyTypeInt, Sensitive: true},
+			{Name: "TAG", Type: types.PropertyTypeString},
+			{Name: "REPLICAS", Type: types.PropertyTypeInt},
+		},
+		PropertyValues: []string{"PASSWORD=secret", "PASSWORDS=2", "TAG=latest", "REPLICAS=3"},
+		TemplatedValues: map[string]string{
+			"services.web.deploy.replicas":              "${REPLICAS}",
+			"services.web.healthcheck.retries":          "${PASSWORDS}",
+			"services.web.deploy.restart_policy.window": "${WINDOW:-10s}",
+		},
+	}
+
+	substituted, err := DoRedactedSubstitution(spec)
+	assert.NilError(t, err)
+	web := substituted.Services[0]
+	assert.Check(t, is.Equal("nginx:latest", web.Image))
+	assert.Check(t, is.Equal("${PASSWORD}", *web.Environment["PASSWORD"]))
+	assert.Check(t, is.Equal(uint64(3), *web.Deploy.Replicas))
+	assert.Check(t, is.DeepEqual(map[string]string{
+		"services.web.healthcheck.retries": "${PASSWORDS}",
+	}, substituted.TemplatedValues))
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -6,6 +6,7 @@ package substitution
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/docker/stacks/pkg/compose/loader"
@@ -47,10 +48,76 @@ func DoSubstitution(spec types.StackSpec) (types.StackSpec, error) {
 		return finalSpec, err
 	}
 
+	if len(spec.TemplatedValues) > 0 {
+		specPostJSON, err = applyTemplatedValues(specPostJSON)
+		if err != nil {
+			return finalSpec, err
+		}
+	}
+
 	err = json.Unmarshal([]byte(specPostJSON), &finalSpec)
 	return finalSpec, err
 }
 
+// applyTemplatedValues casts the substituted TemplatedValues of a spec, in
+// JSON, and sets them at their paths in the spec. The TemplatedValues are
+// removed from the spec once applied.
+func applyTemplatedValues(specJSON string) (string, error) {
+	var spec map[string]interface{}
+	if err := json.Unmarshal([]byte(specJSON), &spec); err != nil {
+		return "", err
+	}
+	templated, _ := spec["templated_values"].(map[string]interface{})
+	delete(spec, "templated_values")
+
+	paths := make([]string, 0, len(templated))
+	for path := range templated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		value, err := loader.CastTemplatedValue(path, fmt.Sprint(templated[path]))
+		if err != nil {
+			return "", err
+		}
+		if err := loader.SetTemplatedValue(spec, path, value); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := json.Marshal(spec)
+	return string(data), err
+}
+
+// ValidateProperties checks the PropertyValues of a StackSpec against
+// its Properties, with the semantics of compose: required properties must
+// be set, and not empty unless they are only required to be set, and the
+// values of properties, or their defaults, must be valid for their types.
+// Values of undeclared properties are not checked.
+func ValidateProperties(spec types.StackSpec) error {
+	values := propertyValues(spec)
+
+	for _, property := range spec.Properties {
+		value, ok := values[property.Name]
+		if property.Required && (!ok || value == "" && property.RequiredOperator != types.RequiredIfUnsetOperator) {
+			if property.ErrorMessage != "" {
+				return fmt.Errorf("property %s is required: %s", property.Name, property.ErrorMessage)
+			}
+			return fmt.Errorf("property %s is required", property.Name)
+		}
+		if property.Default != nil && (!ok || value == "" && property.DefaultOperator != types.DefaultIfUnsetOperator) {
+			value, ok = *property.Default, true
+		}
+		if !ok {
+			continue
+		}
+		if err := loader.ValidatePropertyValue(property.Type, value); err != nil {
+			return fmt.Errorf("invalid value for property %s: %s", property.Name, err)
+		}
+	}
+	return nil
+}
+
 // Wrap the template.Substitute function to automatically lookup
 // from spec.Properties
 func doSubstitute(data string, spec *types.StackSpec) (string, error) {
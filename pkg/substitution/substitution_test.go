@@ -2,10 +2,12 @@ package substitution
 
 import (
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
 
+	"github.com/docker/stacks/pkg/compose/loader"
 	composetypes "github.com/docker/stacks/pkg/compose/types"
 	"github.com/docker/stacks/pkg/types"
 )
@@ -74,3 +76,121 @@ func TestDoVolumeSubstitutions(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Check(t, is.DeepEqual(outspec.Services[0].Volumes, expectedVolumes))
 }
+
+func TestDoTypedSubstitutions(t *testing.T) {
+	create, err := loader.ParseComposeInput(types.ComposeInput{
+		ComposeFiles: []string{`version: "3.8"
+services:
+  web:
+    image: nginx
+    read_only: ${READ_ONLY}
+    stop_grace_period: ${GRACE:-10s}
+    ulimits:
+      nofile: ${NOFILE}
+    secrets:
+      - source: password
+        mode: ${MODE}
+    healthcheck:
+      test: ["CMD", "true"]
+      retries: ${RETRIES}
+    deploy:
+      replicas: ${REPLICAS}
+secrets:
+  password:
+    external: true
+`},
+	})
+	assert.NilError(t, err)
+	spec := create.Spec
+	spec.PropertyValues = []string{"READ_ONLY=true", "NOFILE=1024", "MODE=256", "RETRIES=3", "REPLICAS=2"}
+
+	outspec, err := DoSubstitution(spec)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(outspec.TemplatedValues, 0))
+	web := outspec.Services[0]
+	assert.Check(t, web.ReadOnly)
+	assert.Check(t, is.Equal(composetypes.Duration(10*time.Second), *web.StopGracePeriod))
+	assert.Check(t, is.DeepEqual(&composetypes.UlimitsConfig{Single: 1024}, web.Ulimits["nofile"]))
+	assert.Check(t, is.Equal(uint32(0400), *web.Secrets[0].Mode))
+	assert.Check(t, is.Equal(uint64(3), *web.HealthCheck.Retries))
+	assert.Check(t, is.DeepEqual([]string{"CMD", "true"}, []string(web.HealthCheck.Test)))
+	assert.Check(t, is.Equal(uint64(2), *web.Deploy.Replicas))
+
+	spec.PropertyValues = []string{"READ_ONLY=true", "NOFILE=1024", "MODE=256", "RETRIES=3", "REPLICAS=many"}
+	_, err = DoSubstitution(spec)
+	assert.Check(t, is.ErrorContains(err, "invalid value for services.web.deploy.replicas"))
+}
+
+func TestValidateProperties(t *testing.T) {
+	defaultReplicas, defaultRetries := "2", "3"
+	properties := []types.StackProperty{
+		{Name: "LABEL", Type: types.PropertyTypeString, Required: true, RequiredOperator: "?"},
+		{Name: "PORT", Type: types.PropertyTypePort, Required: true, RequiredOperator: ":?", ErrorMessage: "the web port is required"},
+		{Name: "REPLICAS", Type: types.PropertyTypeInt, Default: &defaultReplicas, DefaultOperator: ":-"},
+		{Name: "RETRIES", Type: types.PropertyTypeInt, Default: &defaultRetries, DefaultOperator: "-"},
+		{Name: "TAG", Type: types.PropertyTypeString, Required: true, RequiredOperator: ":?"},
+		{Name: "TIMEOUT", Type: types.PropertyTypeDuration},
+	}
+	for _, tc := range []struct {
+		name   string
+		values []string
+		err    string
+	}{
+		{
+			name:   "valid",
+			values: []string{"LABEL=web", "PORT=80", "TAG=latest", "TIMEOUT", "OTHER=other"},
+		},
+		{
+			name:   "port range",
+			values: []string{"LABEL=web", "PORT=8000-8010", "TAG=latest"},
+		},
+		{
+			name:   "empty value set with ?",
+			values: []string{"LABEL=", "PORT=80", "TAG=latest"},
+		},
+		{
+			name:   "unset with ?",
+			values: []string{"PORT=80", "TAG=latest"},
+			err:    "property LABEL is required",
+		},
+		{
+			name:   "empty value with :- default",
+			values: []string{"LABEL=web", "PORT=80", "TAG=latest", "REPLICAS="},
+		},
+		{
+			name:   "empty value with - default",
+			values: []string{"LABEL=web", "PORT=80", "TAG=latest", "RETRIES="},
+			err:    `invalid value for property RETRIES: strconv.Atoi: parsing "": invalid syntax`,
+		},
+		{
+			name:   "missing required with message",
+			values: []string{"LABEL=web", "PORT", "TAG=latest"},
+			err:    "property PORT is required: the web port is required",
+		},
+		{
+			name:   "empty required",
+			values: []string{"LABEL=web", "PORT=80", "TAG="},
+			err:    "property TAG is required",
+		},
+		{
+			name:   "invalid type",
+			values: []string{"LABEL=web", "PORT=80", "TAG=latest", "REPLICAS=many"},
+			err:    `invalid value for property REPLICAS: strconv.Atoi: parsing "many": invalid syntax`,
+		},
+		{
+			name:   "invalid port",
+			values: []string{"LABEL=web", "PORT=http", "TAG=latest"},
+			err:    "invalid value for property PORT: invalid port: http",
+		},
+	} {
+		err := ValidateProperties(types.StackSpec{
+			Properties:     properties,
+			PropertyValues: tc.values,
+		})
+		if tc.err == "" {
+			assert.Check(t, is.Nil(err), tc.name)
+		} else {
+			assert.Check(t, is.Error(err, tc.err), tc.name)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package types
+
+// AdmissionViolation is a breach of an admission policy by a stack.
+type AdmissionViolation struct {
+	// Controller is the name of the admission controller which rejected
+	// the stack.
+	Controller string `json:"controller"`
+
+	// Service is the name of the offending service, if the violation is
+	// specific to a service.
+	Service string `json:"service,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// AdmissionErrorResponse is the body of the 400 responses to the creation
+// or update of a stack rejected by admission controllers. Its message is a
+// summary of the violations, so that it can be read as a plain
+// ErrorResponse.
+type AdmissionErrorResponse struct {
+	Message    string               `json:"message"`
+	Violations []AdmissionViolation `json:"violations"`
+}
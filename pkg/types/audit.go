@@ -0,0 +1,55 @@
+package types
+
+import "time"
+
+// AuditAction is the kind of mutation of a stack recorded in an AuditEntry.
+// There is no rollback action, as the stacks API has no rollback
+// operation: a stack is rolled back by updating it with an earlier spec,
+// which is recorded as an update, or a scale.
+type AuditAction string
+
+const (
+	// AuditActionCreate records the creation of a stack.
+	AuditActionCreate AuditAction = "create"
+
+	// AuditActionUpdate records an update of the spec of a stack.
+	AuditActionUpdate AuditAction = "update"
+
+	// AuditActionScale records an update which only changes the number of
+	// replicas of services.
+	AuditActionScale AuditAction = "scale"
+
+	// AuditActionDelete records the deletion of a stack.
+	AuditActionDelete AuditAction = "delete"
+)
+
+// AuditEntry records who mutated a stack, and how.
+type AuditEntry struct {
+	Time   time.Time   `json:"time"`
+	Action AuditAction `json:"action"`
+
+	// Identity is the authenticated identity of the client, if any, and
+	// SourceAddress the address the request came from.
+	Identity      string `json:"identity,omitempty"`
+	SourceAddress string `json:"source_address"`
+
+	StackID    string `json:"stack_id"`
+	StackName  string `json:"stack_name"`
+	Collection string `json:"collection,omitempty"`
+
+	// OldVersion and NewVersion are the versions of the stack before and
+	// after the mutation. They are zero for created and deleted stacks
+	// respectively.
+	OldVersion uint64 `json:"old_version"`
+	NewVersion uint64 `json:"new_version"`
+
+	// Changes summarizes the differences between the old and new specs.
+	Changes []string `json:"changes,omitempty"`
+}
+
+// AuditFilter selects audit entries. Zero values match every entry.
+type AuditFilter struct {
+	StackID string
+	Since   time.Time
+	Until   time.Time
+}
@@ -0,0 +1,60 @@
+package types
+
+import "time"
+
+// StackEventAction describes what happened to a stack in a StackEvent.
+type StackEventAction string
+
+const (
+	// StackEventCreated is emitted when a stack is stored for the first time.
+	StackEventCreated StackEventAction = "created"
+
+	// StackEventUpdated is emitted when the spec of a stack is updated.
+	StackEventUpdated StackEventAction = "updated"
+
+	// StackEventRemoved is emitted when a stack is deleted.
+	StackEventRemoved StackEventAction = "removed"
+
+	// StackEventReconciling is emitted when the reconciler starts a pass
+	// over a stack.
+	StackEventReconciling StackEventAction = "reconciling"
+
+	// StackEventServiceCreated is emitted when a service of the stack is
+	// created.
+	StackEventServiceCreated StackEventAction = "service_created"
+
+	// StackEventServiceUpdated is emitted when a service of the stack is
+	// updated to match the stack spec.
+	StackEventServiceUpdated StackEventAction = "service_updated"
+
+	// StackEventServiceRemoved is emitted when a service which no longer
+	// belongs to the stack is removed.
+	StackEventServiceRemoved StackEventAction = "service_removed"
+
+	// StackEventConverged is emitted when all of the services of the stack
+	// match the stack spec.
+	StackEventConverged StackEventAction = "converged"
+
+	// StackEventFailed is emitted when the stack could not be reconciled.
+	StackEventFailed StackEventAction = "failed"
+)
+
+// StackEvent is a single entry of the stack lifecycle event stream.
+type StackEvent struct {
+	StackID string           `json:"stack_id"`
+	Action  StackEventAction `json:"action"`
+	// Service is the name of the service concerned by the event, if any.
+	Service string    `json:"service,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// StackEventsOptions is input to the Events operation for Stacks
+type StackEventsOptions struct {
+	// StackID restricts the stream to the events of a single stack. The
+	// events of all stacks are streamed if it is empty.
+	StackID string
+	// Since streams past events that occurred after the given timestamp,
+	// in any format accepted by the docker events API.
+	Since string
+}
@@ -0,0 +1,57 @@
+package types
+
+import "time"
+
+// StackProgress is the observed progress of a stack towards its desired
+// state.
+type StackProgress struct {
+	StackID string `json:"stack_id"`
+	// ServicesStatus contains the status of each service of the stack,
+	// keyed by service name.
+	ServicesStatus map[string]ServiceStatus `json:"services_status"`
+	// Converged is true once every service exists with the desired spec,
+	// and runs all of its desired tasks.
+	Converged bool `json:"converged"`
+	// Failed is true if the stack can not converge without intervention,
+	// for example because a service update was paused or rolled back.
+	Failed  bool   `json:"failed"`
+	Message string `json:"message,omitempty"`
+}
+
+// StackDeployResultStatus is the outcome of a synchronous deploy.
+type StackDeployResultStatus string
+
+const (
+	// StackDeployConverged means that the stack reached its desired state.
+	StackDeployConverged StackDeployResultStatus = "converged"
+
+	// StackDeployFailed means that the stack can not reach its desired
+	// state.
+	StackDeployFailed StackDeployResultStatus = "failed"
+
+	// StackDeployTimeout means that the stack did not reach its desired
+	// state in time.
+	StackDeployTimeout StackDeployResultStatus = "timeout"
+)
+
+// StackDeployResult is the final outcome of a synchronous deploy.
+type StackDeployResult struct {
+	StackID string                  `json:"stack_id"`
+	Status  StackDeployResultStatus `json:"status"`
+	Message string                  `json:"message,omitempty"`
+}
+
+// StackDeployMessage is a single message of the stream returned by a
+// synchronous create or update. Every message but the last one carries
+// Progress, and the last one carries Result.
+type StackDeployMessage struct {
+	Progress *StackProgress     `json:"progress,omitempty"`
+	Result   *StackDeployResult `json:"result,omitempty"`
+}
+
+// StackWaitOptions configures a synchronous create or update.
+type StackWaitOptions struct {
+	// Timeout is the maximum time to wait for the stack to converge. The
+	// server default is used if it is zero.
+	Timeout time.Duration
+}
@@ -0,0 +1,69 @@
+package types
+
+// PropertyType is the type of the values of a stack property.
+type PropertyType string
+
+const (
+	// PropertyTypeString is the type of properties which are only used in
+	// strings, and of the properties with conflicting types.
+	PropertyTypeString PropertyType = "string"
+
+	// PropertyTypeInt is the type of properties used as integer options,
+	// such as deploy.replicas.
+	PropertyTypeInt PropertyType = "int"
+
+	// PropertyTypeFloat is the type of properties used as floating point
+	// options, such as deploy.update_config.max_failure_ratio.
+	PropertyTypeFloat PropertyType = "float"
+
+	// PropertyTypeBool is the type of properties used as boolean options,
+	// such as read_only. Values are YAML booleans, such as "true" or "no".
+	PropertyTypeBool PropertyType = "bool"
+
+	// PropertyTypePort is the type of properties used as port numbers, or
+	// ranges of ports such as "8000-8010".
+	PropertyTypePort PropertyType = "port"
+
+	// PropertyTypeDuration is the type of properties used as durations,
+	// such as healthcheck.interval.
+	PropertyTypeDuration PropertyType = "duration"
+)
+
+const (
+	// DefaultOperator uses the default of a property when its value is
+	// unset or empty, and DefaultIfUnsetOperator only when it is unset.
+	DefaultOperator        = ":-"
+	DefaultIfUnsetOperator = "-"
+
+	// RequiredOperator requires a property to have a non-empty value, and
+	// RequiredIfUnsetOperator only requires it to be set.
+	RequiredOperator        = ":?"
+	RequiredIfUnsetOperator = "?"
+)
+
+// StackProperty describes a variable of the compose files of a stack, which
+// is substituted by the value provided in the PropertyValues of the stack.
+type StackProperty struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Type        PropertyType `json:"type"`
+
+	// Default is the value of the property when the stack provides none.
+	// With the DefaultOperator ":-", as in ${NAME:-default}, it is also
+	// used for empty values, and with "-" only for unset ones.
+	Default         *string `json:"default,omitempty"`
+	DefaultOperator string  `json:"default_operator,omitempty"`
+
+	// Required properties must have a value. With the RequiredOperator
+	// ":?", as in ${NAME:?message}, the value must not be empty, and with
+	// "?" it may be. ErrorMessage is the error reported when they have
+	// none.
+	Required         bool   `json:"required,omitempty"`
+	RequiredOperator string `json:"required_operator,omitempty"`
+	ErrorMessage     string `json:"error_message,omitempty"`
+
+	// Sensitive properties have write-only values, which are left out of
+	// the stacks returned by the API, and are kept by updates which don't
+	// provide new ones.
+	Sensitive bool `json:"sensitive,omitempty"`
+}
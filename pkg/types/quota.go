@@ -0,0 +1,28 @@
+package types
+
+// QuotaResources is an amount of the resources limited by collection
+// quotas. In quota limits, zero means unlimited.
+type QuotaResources struct {
+	// Stacks is the number of stacks.
+	Stacks uint64 `json:"stacks"`
+
+	// Replicas is the total number of replicas of the services of the
+	// stacks. Global services count as a single replica.
+	Replicas uint64 `json:"replicas"`
+
+	// NanoCPUs and MemoryBytes are the total resources reserved by the
+	// replicas.
+	NanoCPUs    int64 `json:"nano_cpus"`
+	MemoryBytes int64 `json:"memory_bytes"`
+
+	// PublishedPorts is the number of ports published by the services.
+	PublishedPorts uint64 `json:"published_ports"`
+}
+
+// CollectionQuota reports the quota of a collection along with the
+// resources its stacks currently use.
+type CollectionQuota struct {
+	Collection string         `json:"collection"`
+	Limits     QuotaResources `json:"limits"`
+	Usage      QuotaResources `json:"usage"`
+}
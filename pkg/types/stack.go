@@ -32,6 +32,14 @@ type StackListOptions struct {
 	Filters filters.Args
 }
 
+// StackComposeOptions is input to the operation rendering a Stack as a
+// compose file
+type StackComposeOptions struct {
+	// Substitute substitutes the variables of the compose file with the
+	// property values of the stack, except for sensitive properties.
+	Substitute bool
+}
+
 // Version represents the internal object version.
 type Version struct {
 	Index uint64 `json:",omitempty"`
@@ -41,6 +49,18 @@ type Version struct {
 type StackCreate struct {
 	Spec         StackSpec          `json:"spec"`
 	Orchestrator OrchestratorChoice `json:"orchestrator"`
+
+	// Warnings reports the options of the compose files a StackCreate was
+	// parsed from which are ignored. It is ignored by the Create operation.
+	Warnings []StackWarning `json:"warnings,omitempty"`
+}
+
+// StackAdopt is input to the Adopt operation, which creates a Stack out of
+// the objects deployed with docker stack deploy
+type StackAdopt struct {
+	// Namespace is the name the objects were deployed with, which they
+	// are labeled with.
+	Namespace string `json:"namespace"`
 }
 
 // Metadata contains metadata for a Stack.
@@ -65,8 +85,42 @@ type StackSpec struct {
 	StackImage     string                           `json:"stack_image,omitempty"`
 	PropertyValues []string                         `json:"property_values,omitempty"`
 	Collection     string                           `json:"collection,omitempty"`
+
+	// Properties describes the variables of the compose files of the
+	// stack. The PropertyValues of the stack are validated against them.
+	// Properties may be marked sensitive when creating the stack, in which
+	// case their values are never returned by the API.
+	Properties []StackProperty `json:"properties,omitempty"`
+
+	// TemplatedValues holds the raw values of the options which are not
+	// strings, such as deploy.replicas, and use variables, by path such as
+	// services.web.deploy.replicas. They are substituted and cast along
+	// with the rest of the spec.
+	TemplatedValues map[string]string `json:"templated_values,omitempty"`
+
+	// VolumeRemovalPolicy tells whether the volumes created for the stack
+	// are removed along with it. Volumes are retained by default.
+	VolumeRemovalPolicy VolumeRemovalPolicy `json:"volume_removal_policy,omitempty"`
+
+	// Files holds the contents of the files of the secrets and configs of
+	// the stack, indexed by the paths they are referenced with. They are
+	// write-only: stacks are returned by the API without them, and updates
+	// keep the current contents of the files they don't provide.
+	Files map[string][]byte `json:"files,omitempty"`
 }
 
+// VolumeRemovalPolicy is the policy applied to the volumes of a stack when
+// the stack is deleted.
+type VolumeRemovalPolicy string
+
+const (
+	// VolumeRemovalPolicyRetain keeps the volumes of a deleted stack, so
+	// that no data is lost. It is the default policy.
+	VolumeRemovalPolicyRetain VolumeRemovalPolicy = "retain"
+	// VolumeRemovalPolicyDelete removes the volumes of a deleted stack.
+	VolumeRemovalPolicyDelete VolumeRemovalPolicy = "delete"
+)
+
 // StackResources links to the running instances of the StackSpec
 type StackResources struct {
 	Services map[string]StackResource `json:"services,omitempty"`
@@ -87,6 +141,55 @@ type StackResource struct {
 	ID           string             `json:"id"`
 }
 
+// The kinds of StackResource.
+const (
+	StackResourceKindService   = "service"
+	StackResourceKindContainer = "container"
+	StackResourceKindNetwork   = "network"
+	StackResourceKindSecret    = "secret"
+	StackResourceKindConfig    = "config"
+	StackResourceKindVolume    = "volume"
+)
+
+// resourceMap returns a pointer to the map holding the resources of the
+// given kind. Containers are the instances of services when running on
+// basic containers, so they are kept with the services.
+func (r *StackResources) resourceMap(kind string) *map[string]StackResource {
+	switch kind {
+	case StackResourceKindService, StackResourceKindContainer:
+		return &r.Services
+	case StackResourceKindNetwork:
+		return &r.Networks
+	case StackResourceKindSecret:
+		return &r.Secrets
+	case StackResourceKindConfig:
+		return &r.Configs
+	case StackResourceKindVolume:
+		return &r.Volumes
+	}
+	return nil
+}
+
+// Set records a resource under the given name, in the map matching the
+// kind of the resource. Resources of an unknown kind are ignored.
+func (r *StackResources) Set(name string, resource StackResource) {
+	m := r.resourceMap(resource.Kind)
+	if m == nil {
+		return
+	}
+	if *m == nil {
+		*m = map[string]StackResource{}
+	}
+	(*m)[name] = resource
+}
+
+// Remove removes the resource of the given kind recorded under name.
+func (r *StackResources) Remove(kind, name string) {
+	if m := r.resourceMap(kind); m != nil {
+		delete(*m, name)
+	}
+}
+
 // StackStatus defines the observed state of Stack
 type StackStatus struct {
 	Message       string `json:"message"`
@@ -105,6 +208,10 @@ type ServiceStatus struct {
 	// in the cluster that satisfy those constraints.
 	DesiredTasks uint64 `json:"desired_tasks"`
 	RunningTasks uint64 `json:"running_tasks"`
+	// UpdateState is the state of the last update of the service, if any.
+	UpdateState string `json:"update_state,omitempty"`
+	// Message explains why the service has not converged yet, if so.
+	Message string `json:"message,omitempty"`
 }
 
 // StackTaskList contains a summary of the underlying tasks that make up this Stack
@@ -141,10 +248,35 @@ const (
 // ComposeInput carries one or more compose files for parsing by the server
 type ComposeInput struct {
 	ComposeFiles []string `json:"compose_files"`
+
+	// Files holds the contents of the files referenced by the compose
+	// files, such as env files and the files of secrets and configs,
+	// indexed by their path as written in the compose files. The server
+	// only resolves references against these files.
+	Files map[string][]byte `json:"files,omitempty"`
 }
 
 // StackCreateResponse is the response type of the Create Stack
 // operation.
 type StackCreateResponse struct {
 	ID string
+
+	// Warnings reports the options of the stack which are ignored.
+	Warnings []StackWarning `json:",omitempty"`
+
+	// Stack is the stack as it was stored, if it could be retrieved. It
+	// is only used by the server, to audit the creation, and is not sent
+	// to clients.
+	Stack *Stack `json:"-"`
+}
+
+// StackUpdateResponse is the response type of the Update Stack operation.
+type StackUpdateResponse struct {
+	// Warnings reports the options of the stack which are ignored.
+	Warnings []StackWarning `json:",omitempty"`
+
+	// Stack is the stack as it was stored, if it could be retrieved. It
+	// is only used by the server, to audit the update, and is not sent to
+	// clients.
+	Stack *Stack `json:"-"`
 }
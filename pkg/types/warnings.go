@@ -0,0 +1,37 @@
+package types
+
+// StackWarningCode identifies the kind of a StackWarning.
+type StackWarningCode string
+
+const (
+	// StackWarningUnsupportedProperty reports a service option which stacks
+	// do not support.
+	StackWarningUnsupportedProperty StackWarningCode = "unsupported_property"
+
+	// StackWarningDeprecatedProperty reports a service option which was
+	// removed from the version 3 compose file format.
+	StackWarningDeprecatedProperty StackWarningCode = "deprecated_property"
+
+	// StackWarningUnsupportedByEngine reports a service option which the
+	// engine running the stack does not support.
+	StackWarningUnsupportedByEngine StackWarningCode = "unsupported_by_engine"
+
+	// StackWarningUnschedulable reports a service whose placement
+	// constraints no node of the cluster satisfies. Its tasks remain
+	// pending until such a node joins.
+	StackWarningUnschedulable StackWarningCode = "unschedulable"
+)
+
+// StackWarning reports an option of a stack which is ignored when the
+// stack is deployed, or which prevents its services from running.
+type StackWarning struct {
+	Code StackWarningCode `json:"code"`
+
+	// Service is the name of the service the option belongs to, and
+	// Property the path of the option in the service, such as
+	// "deploy.placement".
+	Service  string `json:"service,omitempty"`
+	Property string `json:"property"`
+
+	Message string `json:"message"`
+}